@@ -46,6 +46,10 @@ fi
 
 next="0"
 migrationDir="./mysql/migration"
+# 0_sample.sqlは各自の作業用でリポジトリでは管理しないため、無い場合は1から適用する
+if ! ls $migrationDir/0_*.sql >/dev/null 2>&1; then
+    next="1"
+fi
 
 
 echo "MySQLのマイグレーションを開始します。"
@@ -59,6 +63,9 @@ do
 
     echo "${fileName}を適用します..."
     docker exec tuning-mysql bash -c "mysql -u root -pmysql 42Tokyo2508-db < /etc/mysql/migration/${fileName}"
+    # バックエンドの -migrate up が同じファイルを再び適用しないよう、適用済みとして記録する
+    name=${fileName#${next}_}
+    docker exec tuning-mysql mysql -u root -pmysql 42Tokyo2508-db -e "CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)); INSERT IGNORE INTO schema_migrations (version, name) VALUES (${next}, '${name%.sql}');"
     next=$(($next + 1))
 done
 
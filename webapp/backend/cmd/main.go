@@ -2,29 +2,68 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"backend/internal/logging"
 	"backend/internal/server"
 	"backend/internal/telemetry"
 )
 
 func main() {
+	logging.Setup()
+
+	// -migrateを指定した場合はスキーマを移行して終了する（サーバーは起動しない）
+	migrateCommand := flag.String("migrate", "", "apply schema migrations and exit: up, down, status or baseline")
+	migrateSteps := flag.Int("migrate-steps", 1, "number of migrations to revert with -migrate down")
+	migrateVersion := flag.Int("migrate-version", -1, "record migrations up to this version as applied with -migrate baseline")
+	flag.Parse()
+
+	// SIGINT・SIGTERMを受けたら処理中のリクエストを待ってから終了する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *migrateCommand != "" {
+		if err := runMigrate(ctx, *migrateCommand, *migrateSteps, *migrateVersion); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
 
 	// jaeger の初期化
-	shutdown, err := telemetry.Init(context.Background())
+	shutdownTelemetry, err := telemetry.Init(context.Background())
 	if err != nil {
 		log.Printf("telemetry init failed: %v, continuing without telemetry", err)
-	} else {
-		defer func() { _ = shutdown(context.Background()) }()
+		shutdownTelemetry = nil
 	}
 
 	srv, dbConn, err := server.NewServer()
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
+
+	runErr := srv.Run(ctx)
+
+	// HTTPサーバーとバックグラウンド処理（Run内で停止済み）が使い終わってから、
+	// テレメトリのエクスポーター、DB接続の順に閉じる
+	if shutdownTelemetry != nil {
+		telemetryCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdownTelemetry(telemetryCtx); err != nil {
+			log.Printf("Failed to flush telemetry: %v", err)
+		}
+		cancel()
+	}
 	if dbConn != nil {
-		defer dbConn.Close()
+		if err := dbConn.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
 	}
 
-	srv.Run()
+	if runErr != nil {
+		log.Fatalf("Server exited: %v", runErr)
+	}
 }
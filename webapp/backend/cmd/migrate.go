@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/db"
+	"backend/internal/migrate"
+)
+
+// -migrateに指定したコマンドでスキーマを移行する
+func runMigrate(ctx context.Context, command string, steps, version int) error {
+	dbConn, err := db.InitDBConnection()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+	if err := db.Ping(ctx, dbConn, 10*time.Second); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	runner, err := migrate.New(dbConn)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := runner.Up(ctx)
+		log.Printf("[migrate] applied %d migrations", len(applied))
+		return err
+	case "down":
+		if steps <= 0 {
+			return fmt.Errorf("-migrate-steps must be positive")
+		}
+		reverted, err := runner.Down(ctx, steps)
+		log.Printf("[migrate] reverted %d migrations", len(reverted))
+		return err
+	case "baseline":
+		if version < 0 {
+			return fmt.Errorf("-migrate-version is required for baseline")
+		}
+		recorded, err := runner.Baseline(ctx, version)
+		log.Printf("[migrate] recorded %d migrations up to version %d as applied", recorded, version)
+		return err
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.DateTime)
+			}
+			down := ""
+			if s.Down == "" {
+				down = " (no down)"
+			}
+			fmt.Printf("%4d  %-32s %s%s\n", s.Version, s.Name, state, down)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown -migrate command %q (up, down, status or baseline)", command)
+}
@@ -0,0 +1,32 @@
+// リクエストの残り時間（contextのデッドライン）が、これから始める処理に足りるかを判定する
+// デッドラインはミドルウェアで設定し、サービス・リポジトリの各段階で残り時間を確かめる
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 残り時間が処理の見込み時間に足りない
+var ErrExhausted = errors.New("request deadline budget exhausted")
+
+// 残り時間（デッドラインがない場合はfalse）
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// 残り時間がneed未満であればErrExhaustedを返す（デッドラインがない場合は常にnil）
+// 途中でキャンセルされる見込みの重い処理を始める前に呼ぶ
+func Require(ctx context.Context, need time.Duration) error {
+	remaining, ok := Remaining(ctx)
+	if !ok || remaining > need {
+		return nil
+	}
+	return fmt.Errorf("%w: %s remaining, %s needed", ErrExhausted, max(remaining, 0).Round(time.Millisecond), need)
+}
@@ -0,0 +1,299 @@
+// プロセス内で保持するキャッシュ
+package cache
+
+import (
+	"backend/internal/metrics"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 画像キャッシュのデフォルトの容量
+const DefaultImageBudget = 100 << 20
+
+var imageCacheStats = metrics.Cache("image")
+
+type ImageCacheEntry struct {
+	// 圧縮して保持している場合はnil（Bytesで展開する）
+	Data []byte
+	// gzipで圧縮した内容（圧縮していない場合はnil）
+	Gzipped     []byte
+	ContentType string
+	// 読み込んだ時点のファイルの更新時刻（ファイルの差し替えの検知に使う）
+	ModTime time.Time
+	// 内容のハッシュから作ったETag（引用符を含む）
+	ETag string
+
+	// レスポンスヘッダーの値（リクエストごとにスライスを作らないよう保存時に作っておく）
+	contentTypeHeader   []string
+	contentLengthHeader []string
+	etagHeader          []string
+	lastModifiedHeader  []string
+	gzipLengthHeader    []string
+	gzipETagHeader      []string
+}
+
+func newImageCacheEntry(data []byte, contentType string, modTime time.Time) *ImageCacheEntry {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	entry := &ImageCacheEntry{
+		Data:                data,
+		ContentType:         contentType,
+		ModTime:             modTime,
+		ETag:                etag,
+		contentTypeHeader:   []string{contentType},
+		contentLengthHeader: []string{strconv.Itoa(len(data))},
+		etagHeader:          []string{etag},
+	}
+	if !modTime.IsZero() {
+		entry.lastModifiedHeader = []string{modTime.UTC().Format(http.TimeFormat)}
+	}
+	return entry
+}
+
+// dataをgzipで圧縮し、圧縮後のサイズが元のmaxPercent%以下であれば圧縮した内容だけを保持する
+func (e *ImageCacheEntry) compress(maxPercent int) {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := zw.Write(e.Data); err != nil || zw.Close() != nil {
+		return
+	}
+	if buf.Len()*100 > len(e.Data)*maxPercent {
+		return
+	}
+	e.Gzipped = bytes.Clone(buf.Bytes())
+	e.Data = nil
+	e.gzipLengthHeader = []string{strconv.Itoa(len(e.Gzipped))}
+	// 圧縮した表現には別のETagを付ける（RFC 9110 8.8.3）
+	e.gzipETagHeader = []string{strings.TrimSuffix(e.ETag, `"`) + `-gzip"`}
+}
+
+// 圧縮して保持しているか
+func (e *ImageCacheEntry) Compressed() bool {
+	return e.Gzipped != nil
+}
+
+// 圧縮前の内容（圧縮して保持している場合は展開する）
+func (e *ImageCacheEntry) Bytes() ([]byte, error) {
+	if e.Gzipped == nil {
+		return e.Data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(e.Gzipped))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// 圧縮したまま返すときのContent-Type・Content-Encoding・Content-Length・ETag・Last-Modifiedをhに設定する
+// 圧縮して保持していない場合はSetHeadersと同じ
+func (e *ImageCacheEntry) SetGzipHeaders(h http.Header) {
+	if e.Gzipped == nil {
+		e.SetHeaders(h)
+		return
+	}
+	h["Content-Type"] = e.contentTypeHeader
+	h["Content-Encoding"] = gzipEncodingHeader
+	h["Content-Length"] = e.gzipLengthHeader
+	e.SetGzipValidators(h)
+}
+
+// 圧縮した表現のETagとLast-Modifiedをhに設定する（304のレスポンスにはこちらだけを使う）
+func (e *ImageCacheEntry) SetGzipValidators(h http.Header) {
+	if e.Gzipped == nil {
+		e.SetValidators(h)
+		return
+	}
+	h["Etag"] = e.gzipETagHeader
+	if e.lastModifiedHeader != nil {
+		h["Last-Modified"] = e.lastModifiedHeader
+	}
+}
+
+var gzipEncodingHeader = []string{"gzip"}
+
+// Content-Type・Content-Length・ETag・Last-Modifiedをhに設定する
+// 値のスライスはエントリ間・リクエスト間で共有するため、変更しないこと
+func (e *ImageCacheEntry) SetHeaders(h http.Header) {
+	h["Content-Type"] = e.contentTypeHeader
+	h["Content-Length"] = e.contentLengthHeader
+	e.SetValidators(h)
+}
+
+// ETagとLast-Modifiedをhに設定する（304のレスポンスにはこちらだけを使う）
+func (e *ImageCacheEntry) SetValidators(h http.Header) {
+	h["Etag"] = e.etagHeader
+	if e.lastModifiedHeader != nil {
+		h["Last-Modified"] = e.lastModifiedHeader
+	}
+}
+
+// 条件付きリクエストのヘッダーから、クライアントの持つ画像が最新かを判定する
+// If-None-Matchがある場合はIf-Modified-Sinceを無視する（RFC 9110 13.1.3）
+func (e *ImageCacheEntry) NotModified(h http.Header) bool {
+	if inm := h.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			tag = strings.TrimPrefix(tag, "W/")
+			if tag == "*" || tag == e.ETag || e.gzipETagHeader != nil && tag == e.gzipETagHeader[0] {
+				return true
+			}
+		}
+		return false
+	}
+	ims := h.Get("If-Modified-Since")
+	if ims == "" || e.ModTime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// Last-Modifiedは秒単位のため、秒未満を切り捨てて比べる
+	return !e.ModTime.Truncate(time.Second).After(t)
+}
+
+// サムネイルのキーで元の画像のパスと幅を区切る文字列
+const thumbnailKeySep = "?w="
+
+// 画像pathを幅widthに縮小したサムネイルのキー
+func ThumbnailKey(path string, width int) string {
+	return path + thumbnailKeySep + strconv.Itoa(width)
+}
+
+// キーの元の画像のパス（サムネイルでなければkeyそのもの）
+func sourcePath(key string) string {
+	path, _, _ := strings.Cut(key, thumbnailKeySep)
+	return path
+}
+
+// 画像ファイルの内容をパスごとに保持する
+// サムネイルはThumbnailKeyのキーで元の画像と同じく保持し、元のファイルが変わると合わせて破棄する
+// 合計サイズが容量を超える場合は方式（IMAGE_CACHE_POLICY）に従って破棄する
+// EnableDiskでディスクの2段目を設定した場合、メモリにない画像はディスクから読んでメモリに戻す
+type ImageCache struct {
+	entries *Sharded[*ImageCacheEntry]
+	// 0より大きい場合、PNG・GIFをgzipで圧縮して保持する（圧縮後のサイズが元のこの割合（%）以下の場合のみ）
+	gzipMaxPercent int
+	// 保存した画像をディスクにも保持する2段目（nilの場合はメモリのみ）
+	disk *ImageDiskCache
+}
+
+func NewImageCache(budget int64, ttl time.Duration, policy Policy) *ImageCache {
+	return &ImageCache{entries: NewShardedWith[*ImageCacheEntry](budget, ttl, Options{Policy: policy, Stats: imageCacheStats})}
+}
+
+// PNG・GIFを圧縮して保持し、同じ容量により多くの画像を載せる
+// 圧縮後のサイズが元のmaxPercent%を超える画像は圧縮しない。サーバーの起動前に呼ぶこと
+func (c *ImageCache) EnableCompression(maxPercent int) {
+	c.gzipMaxPercent = maxPercent
+}
+
+// 保存した画像をdiskにも保持し、メモリから破棄された画像をdiskから読めるようにする。サーバーの起動前に呼ぶこと
+func (c *ImageCache) EnableDisk(disk *ImageDiskCache) {
+	c.disk = disk
+}
+
+// メモリにない場合は、ディスクにあればメモリに戻して返す
+func (c *ImageCache) Get(path string) (*ImageCacheEntry, bool) {
+	entry, ok := c.entries.Get(path)
+	if ok {
+		imageCacheStats.Hit()
+		return entry, true
+	}
+	imageCacheStats.Miss()
+	if c.disk == nil {
+		return nil, false
+	}
+	data, contentType, modTime, ok := c.disk.Load(path)
+	if !ok {
+		return nil, false
+	}
+	// ディスクには保存済みのため、メモリにだけ保存する
+	return c.store(path, data, contentType, modTime), true
+}
+
+// 容量を超える画像はキャッシュしない
+// キャッシュしなかった場合も、レスポンスに使えるエントリを返す
+func (c *ImageCache) Set(path string, data []byte, contentType string, modTime time.Time) *ImageCacheEntry {
+	entry := c.store(path, data, contentType, modTime)
+	if c.disk != nil {
+		c.disk.Store(path, data, contentType, modTime)
+	}
+	return entry
+}
+
+func (c *ImageCache) store(path string, data []byte, contentType string, modTime time.Time) *ImageCacheEntry {
+	entry := newImageCacheEntry(data, contentType, modTime)
+	size := len(data)
+	// JPEG・WebPは圧縮済みのためほとんど縮まない
+	if c.gzipMaxPercent > 0 && (contentType == "image/png" || contentType == "image/gif") {
+		entry.compress(c.gzipMaxPercent)
+		if entry.Compressed() {
+			size = len(entry.Gzipped)
+		}
+	}
+	c.entries.Set(path, entry, int64(size))
+	return entry
+}
+
+// 指定したパスの画像を（ディスクからも）破棄し、破棄した件数を返す
+func (c *ImageCache) Delete(paths ...string) int {
+	if c.disk == nil {
+		return c.entries.Delete(paths...)
+	}
+	deleted := 0
+	for _, path := range paths {
+		// どちらか一方にだけある場合も破棄した件数に数える
+		if n := c.entries.Delete(path) + c.disk.Delete(path); n > 0 {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// メモリ・ディスクの全画像について、キーと読み込んだ時点のファイルの更新時刻でfnを呼ぶ（両方にあるキーは1回だけ）
+func (c *ImageCache) rangeKeys(fn func(key string, modTime time.Time)) {
+	seen := make(map[string]bool)
+	c.entries.Range(func(key string, entry *ImageCacheEntry) {
+		seen[key] = true
+		fn(key, entry.ModTime)
+	})
+	if c.disk != nil {
+		c.disk.Range(func(key string, modTime time.Time) {
+			if !seen[key] {
+				fn(key, modTime)
+			}
+		})
+	}
+}
+
+// 期限内のキャッシュがあるか（参照としては記録しない）
+func (c *ImageCache) Contains(path string) bool {
+	_, ok := c.entries.Peek(path)
+	return ok
+}
+
+// 容量を変更し、超えている分を方式に従って破棄する
+func (c *ImageCache) SetBudget(budget int64) {
+	c.entries.SetBudget(budget)
+}
+
+// 現在の合計サイズ・件数・容量
+func (c *ImageCache) Usage() (bytes int64, entries int, budget int64) {
+	return c.entries.Usage()
+}
+
+// 期限切れの画像を破棄する（スケジューラーから定期的に呼ばれる）
+func (c *ImageCache) RemoveStale() {
+	c.entries.RemoveStale(nil)
+	if c.disk != nil {
+		c.disk.RemoveStale()
+	}
+}
@@ -0,0 +1,357 @@
+package cache
+
+import (
+	"backend/internal/metrics"
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ディスクに保存した画像のファイルの拡張子
+const imageDiskExt = ".img"
+
+var imageDiskCacheStats = metrics.Cache("image_disk")
+
+// 画像キャッシュの2段目として、読み込んだ画像をローカルのディレクトリに保持する
+// メモリから破棄された画像・再起動後にまだ読み込んでいない画像を、元の画像ディレクトリを読まずに返せる
+// 1つの画像を1つのファイル（キーのハッシュの名前）に保存し、先頭の1行にキー・Content-Type・元のファイルの更新時刻を書く
+// 合計サイズが容量を超える場合は参照が古いものから破棄する
+type ImageDiskCache struct {
+	dir    string
+	budget int64
+	ttl    time.Duration
+
+	mutex sync.Mutex
+	items map[string]*list.Element
+	// 参照順（先頭が最も古い）
+	order *list.List
+	size  int64
+
+	// 保存はリクエストを待たせないよう、Runで順に書き込む
+	writes chan imageDiskWrite
+	// キーごとの世代。Deleteのたびに進め、それより前に受け付けたそのキーの保存は書き込まない（差し替え前の画像を書き戻さない）
+	// 世代が0のキー（一度もDeleteしていないもの）は持たない
+	generations sync.Map
+}
+
+type imageDiskItem struct {
+	key      string
+	size     int64
+	modTime  time.Time
+	storedAt time.Time
+}
+
+type imageDiskWrite struct {
+	key         string
+	data        []byte
+	contentType string
+	modTime     time.Time
+	generation  uint64
+}
+
+// ファイルの先頭の1行
+type imageDiskHeader struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"mod_time"`
+}
+
+// dirがなければ作り、前回までに保存した画像を読み込む
+// 保存を待つ画像がqueueSize件を超えた場合、超えた分は保存しない
+func NewImageDiskCache(dir string, budget int64, ttl time.Duration, queueSize int) (*ImageDiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &ImageDiskCache{
+		dir:    dir,
+		budget: budget,
+		ttl:    ttl,
+		items:  make(map[string]*list.Element),
+		order:  list.New(),
+		writes: make(chan imageDiskWrite, queueSize),
+	}
+	imageDiskCacheStats.SetPolicy(string(PolicyLRU))
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 前回までに保存したファイルを、更新時刻の古い順に参照順として読み込む
+// 書き込み途中で止まった一時ファイル・読めないファイルは消す
+func (c *ImageDiskCache) load() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var items []imageDiskItem
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, imageDiskExt) {
+			if strings.HasSuffix(name, ".tmp") {
+				os.Remove(filepath.Join(c.dir, name))
+			}
+			continue
+		}
+		item, err := c.readItem(name)
+		if err != nil {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].storedAt.Before(items[j].storedAt) })
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, item := range items {
+		c.items[item.key] = c.order.PushBack(item)
+		c.size += item.size
+	}
+	c.evict(c.budget)
+	return nil
+}
+
+func (c *ImageDiskCache) readItem(name string) (imageDiskItem, error) {
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		return imageDiskItem{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return imageDiskItem{}, err
+	}
+	header, err := readImageDiskHeader(bufio.NewReader(f))
+	if err != nil {
+		return imageDiskItem{}, err
+	}
+	if imageDiskFile(header.Key) != name {
+		return imageDiskItem{}, fmt.Errorf("file name does not match key %q", header.Key)
+	}
+	return imageDiskItem{key: header.Key, size: info.Size(), modTime: header.ModTime, storedAt: info.ModTime()}, nil
+}
+
+func readImageDiskHeader(r *bufio.Reader) (imageDiskHeader, error) {
+	var header imageDiskHeader
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return header, err
+	}
+	err = json.Unmarshal(line, &header)
+	return header, err
+}
+
+// キーを保存するファイルの名前（パスの区切り文字・サムネイルの?w=を含むため、ハッシュにする）
+func imageDiskFile(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + imageDiskExt
+}
+
+// 期限内の画像を読み込む（参照として記録する）
+// 読み込めなかったファイルは破棄する
+func (c *ImageDiskCache) Load(key string) (data []byte, contentType string, modTime time.Time, ok bool) {
+	c.mutex.Lock()
+	e, found := c.items[key]
+	if found && time.Since(e.Value.(imageDiskItem).storedAt) > c.ttl {
+		c.remove(e)
+		found = false
+	}
+	if found {
+		c.order.MoveToBack(e)
+	}
+	c.mutex.Unlock()
+	if !found {
+		imageDiskCacheStats.Miss()
+		return nil, "", time.Time{}, false
+	}
+
+	raw, err := os.ReadFile(filepath.Join(c.dir, imageDiskFile(key)))
+	if err == nil {
+		r := bufio.NewReader(bytes.NewReader(raw))
+		var header imageDiskHeader
+		if header, err = readImageDiskHeader(r); err == nil && header.Key == key {
+			data, err = io.ReadAll(r)
+			contentType, modTime = header.ContentType, header.ModTime
+		} else if err == nil {
+			err = fmt.Errorf("file does not match key %q", key)
+		}
+	}
+	if err != nil {
+		log.Printf("[ImageDiskCache] failed to read %s: %v", key, err)
+		c.Delete(key)
+		imageDiskCacheStats.Miss()
+		return nil, "", time.Time{}, false
+	}
+	imageDiskCacheStats.Hit()
+	return data, contentType, modTime, true
+}
+
+// 画像の保存を受け付ける（書き込みはRunで行う）
+// 保存を待つ画像が多すぎる場合は保存しない
+func (c *ImageDiskCache) Store(key string, data []byte, contentType string, modTime time.Time) {
+	if int64(len(data)) > c.budget {
+		return
+	}
+	select {
+	case c.writes <- imageDiskWrite{key: key, data: data, contentType: contentType, modTime: modTime, generation: c.generation(key)}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまで、受け付けた画像をディスクに書き込む（呼び出し元をブロックする）
+func (c *ImageDiskCache) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case w := <-c.writes:
+			if err := c.write(w); err != nil {
+				log.Printf("[ImageDiskCache] failed to write %s: %v", w.key, err)
+			}
+		}
+	}
+}
+
+// 書き込み途中で停止しても壊れたファイルが残らないよう、一時ファイルに書いてから置き換える
+func (c *ImageDiskCache) write(w imageDiskWrite) error {
+	if w.generation != c.generation(w.key) {
+		return nil
+	}
+	header, err := json.Marshal(imageDiskHeader{Key: w.key, ContentType: w.contentType, ModTime: w.modTime})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "image.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	bw := bufio.NewWriter(tmp)
+	bw.Write(header)
+	bw.WriteByte('\n')
+	bw.Write(w.data)
+	if err := bw.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// 書き込み中に破棄された場合も保存しない
+	if w.generation != c.generation(w.key) {
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, imageDiskFile(w.key))); err != nil {
+		return err
+	}
+	size := int64(len(header) + 1 + len(w.data))
+	if e, ok := c.items[w.key]; ok {
+		c.size -= e.Value.(imageDiskItem).size
+		c.order.Remove(e)
+	}
+	c.items[w.key] = c.order.PushBack(imageDiskItem{key: w.key, size: size, modTime: w.modTime, storedAt: time.Now()})
+	c.size += size
+	imageDiskCacheStats.Evicted(c.evict(c.budget))
+	return nil
+}
+
+// 合計サイズがlimit以下になるまで参照が古いものから破棄し、破棄した件数を返す
+func (c *ImageDiskCache) evict(limit int64) int {
+	evicted := 0
+	for c.size > limit {
+		e := c.order.Front()
+		if e == nil {
+			break
+		}
+		c.remove(e)
+		evicted++
+	}
+	return evicted
+}
+
+func (c *ImageDiskCache) remove(e *list.Element) {
+	item := c.order.Remove(e).(imageDiskItem)
+	delete(c.items, item.key)
+	c.size -= item.size
+	if err := os.Remove(filepath.Join(c.dir, imageDiskFile(item.key))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[ImageDiskCache] failed to remove %s: %v", item.key, err)
+	}
+}
+
+// 指定したキーの画像を破棄し、破棄した件数を返す
+// 保存を待っている画像も書き込まない
+func (c *ImageDiskCache) Delete(keys ...string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	deleted := 0
+	for _, key := range keys {
+		counter, _ := c.generations.LoadOrStore(key, new(atomic.Uint64))
+		counter.(*atomic.Uint64).Add(1)
+		if e, ok := c.items[key]; ok {
+			c.remove(e)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// 保存済みの全画像について、キーと元のファイルの更新時刻でfnを呼ぶ（fnはロックの外で呼ばれる）
+func (c *ImageDiskCache) Range(fn func(key string, modTime time.Time)) {
+	c.mutex.Lock()
+	items := make([]imageDiskItem, 0, len(c.items))
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value.(imageDiskItem))
+	}
+	c.mutex.Unlock()
+	for _, item := range items {
+		fn(item.key, item.modTime)
+	}
+}
+
+// キーの現在の世代
+func (c *ImageDiskCache) generation(key string) uint64 {
+	if counter, ok := c.generations.Load(key); ok {
+		return counter.(*atomic.Uint64).Load()
+	}
+	return 0
+}
+
+// 期限切れの画像を破棄する
+func (c *ImageDiskCache) RemoveStale() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		if now.Sub(e.Value.(imageDiskItem).storedAt) > c.ttl {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+// 現在の合計サイズ・件数・容量
+func (c *ImageDiskCache) Usage() (bytes int64, entries int, budget int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size, len(c.items), c.budget
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestImageDiskCache(t *testing.T, dir string, budget int64, ttl time.Duration) *ImageDiskCache {
+	t.Helper()
+	c, err := NewImageDiskCache(dir, budget, ttl, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Runを通さずに書き込む
+func storeNow(t *testing.T, c *ImageDiskCache, key string, data []byte) {
+	t.Helper()
+	err := c.write(imageDiskWrite{key: key, data: data, contentType: "image/png", modTime: time.Unix(1700000000, 0), generation: c.generation(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertLoaded(t *testing.T, c *ImageDiskCache, key string, want []byte) {
+	t.Helper()
+	data, contentType, modTime, ok := c.Load(key)
+	if !ok {
+		t.Fatalf("Load(%q) missed", key)
+	}
+	if !bytes.Equal(data, want) || contentType != "image/png" || !modTime.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("Load(%q) = %q, %q, %s", key, data, contentType, modTime)
+	}
+}
+
+func assertMissing(t *testing.T, c *ImageDiskCache, key string) {
+	t.Helper()
+	if _, _, _, ok := c.Load(key); ok {
+		t.Fatalf("Load(%q) hit, want miss", key)
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, imageDiskFile(key))); !os.IsNotExist(err) {
+		t.Fatalf("file for %q still exists: %v", key, err)
+	}
+}
+
+func TestImageDiskCacheLoadsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestImageDiskCache(t, dir, 1<<20, time.Hour)
+	storeNow(t, c, "a.png", []byte("first"))
+	storeNow(t, c, "b.png?w=100", []byte("second"))
+	// 書き込み途中で止まった一時ファイルは読み込み時に消す
+	if err := os.WriteFile(filepath.Join(dir, "image.1.tmp"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestImageDiskCache(t, dir, 1<<20, time.Hour)
+	assertLoaded(t, restarted, "a.png", []byte("first"))
+	assertLoaded(t, restarted, "b.png?w=100", []byte("second"))
+	if bytes, entries, _ := restarted.Usage(); entries != 2 || bytes <= 0 {
+		t.Fatalf("Usage() = %d bytes, %d entries, want 2 entries", bytes, entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "image.1.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("temporary file was not removed: %v", err)
+	}
+}
+
+func TestImageDiskCacheEvictsOverBudget(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+	probe := newTestImageDiskCache(t, t.TempDir(), 1<<20, time.Hour)
+	storeNow(t, probe, "a.png", data)
+	itemSize, _, _ := probe.Usage()
+
+	// 2件分の容量に3件書き込む
+	c := newTestImageDiskCache(t, t.TempDir(), 2*itemSize, time.Hour)
+	storeNow(t, c, "a.png", data)
+	storeNow(t, c, "b.png", data)
+	// aを参照し、bを最も古くする
+	assertLoaded(t, c, "a.png", data)
+	storeNow(t, c, "c.png", data)
+
+	assertMissing(t, c, "b.png")
+	assertLoaded(t, c, "a.png", data)
+	assertLoaded(t, c, "c.png", data)
+	if size, entries, budget := c.Usage(); size > budget || entries != 2 {
+		t.Fatalf("Usage() = %d bytes, %d entries, budget %d", size, entries, budget)
+	}
+}
+
+func TestImageDiskCacheExpires(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	c := newTestImageDiskCache(t, t.TempDir(), 1<<20, ttl)
+	storeNow(t, c, "a.png", []byte("a"))
+	storeNow(t, c, "b.png", []byte("b"))
+	time.Sleep(2 * ttl)
+
+	assertMissing(t, c, "a.png")
+	c.RemoveStale()
+	if _, entries, _ := c.Usage(); entries != 0 {
+		t.Fatalf("%d entries left after RemoveStale", entries)
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, imageDiskFile("b.png"))); !os.IsNotExist(err) {
+		t.Fatalf("expired file was not removed: %v", err)
+	}
+}
+
+func TestImageDiskCacheDeleteDropsQueuedWrite(t *testing.T) {
+	c := newTestImageDiskCache(t, t.TempDir(), 1<<20, time.Hour)
+	storeNow(t, c, "a.png", []byte("old"))
+
+	// Runの前に受け付けた保存のうち、Deleteしたキーのものだけを捨てる
+	c.Store("a.png", []byte("stale"), "image/png", time.Unix(1700000000, 0))
+	c.Store("b.png", []byte("other"), "image/png", time.Unix(1700000000, 0))
+	if deleted := c.Delete("a.png"); deleted != 1 {
+		t.Fatalf("Delete() = %d, want 1", deleted)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, entries, _ := c.Usage(); entries == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued write for another key was not written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	assertMissing(t, c, "a.png")
+	assertLoaded(t, c, "b.png", []byte("other"))
+
+	// Delete後に受け付けた保存は書き込む
+	storeNow(t, c, "a.png", []byte("new"))
+	assertLoaded(t, c, "a.png", []byte("new"))
+}
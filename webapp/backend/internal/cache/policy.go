@@ -0,0 +1,350 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 容量を超えたときに破棄するエントリを選ぶ方式
+type Policy string
+
+const (
+	// 保存が古いものから破棄する（デフォルト）
+	PolicyFIFO Policy = "fifo"
+	// 参照が古いものから破棄する
+	PolicyLRU Policy = "lru"
+	// 参照回数が少ないものから破棄する（同じ回数の場合は参照が古いもの）
+	PolicyLFU Policy = "lfu"
+	// 1回だけ参照されたものと繰り返し参照されたものに容量を配分し、破棄した直後に再保存された側の配分を増やす
+	// 一覧を1周するような一度きりの参照で、繰り返し参照されるものが押し出されにくい
+	PolicyARC Policy = "arc"
+)
+
+var ErrUnknownPolicy = errors.New("unknown cache eviction policy")
+
+// 設定値から方式を読み取る（空の場合はPolicyFIFO）
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyFIFO, nil
+	case PolicyFIFO, PolicyLRU, PolicyLFU, PolicyARC:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownPolicy, s)
+}
+
+// 参照を記録する方式か（FIFOは参照で順序が変わらないため、Getを読み取りロックだけで済ませる）
+func (p Policy) tracksAccess() bool {
+	return p != PolicyFIFO
+}
+
+// 分割ごとに破棄の順序を管理する（分割のロック内で呼ばれる）
+type evictionPolicy interface {
+	// 保存を記録する（記録済みのキーはサイズを更新し、参照として扱う）
+	add(key string, size int64)
+	// 参照を記録する
+	access(key string)
+	// 記録を消す（期限切れ・明示的な破棄で呼ばれ、ARCでも破棄の履歴には残さない）
+	remove(key string)
+	// skip以外から破棄するキーを選び、記録から消す（skipは上書き中のキー）
+	evict(skip string) (string, bool)
+	// 分割1つ分の容量
+	setBudget(budget int64)
+}
+
+func newEvictionPolicy(p Policy, budget int64) evictionPolicy {
+	switch p {
+	case PolicyLRU:
+		return newListPolicy(true)
+	case PolicyLFU:
+		return &lfuPolicy{items: make(map[string]*lfuItem)}
+	case PolicyARC:
+		return newARCPolicy(budget)
+	default:
+		return newListPolicy(false)
+	}
+}
+
+// 保存順（FIFO）または参照順（LRU）のリスト
+type listPolicy struct {
+	order         *list.List
+	elems         map[string]*list.Element
+	touchOnAccess bool
+}
+
+func newListPolicy(touchOnAccess bool) *listPolicy {
+	return &listPolicy{order: list.New(), elems: make(map[string]*list.Element), touchOnAccess: touchOnAccess}
+}
+
+func (p *listPolicy) add(key string, _ int64) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToBack(e)
+		return
+	}
+	p.elems[key] = p.order.PushBack(key)
+}
+
+func (p *listPolicy) access(key string) {
+	if !p.touchOnAccess {
+		return
+	}
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToBack(e)
+	}
+}
+
+func (p *listPolicy) remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *listPolicy) evict(skip string) (string, bool) {
+	e := p.order.Front()
+	if e != nil && e.Value.(string) == skip {
+		e = e.Next()
+	}
+	if e == nil {
+		return "", false
+	}
+	key := p.order.Remove(e).(string)
+	delete(p.elems, key)
+	return key, true
+}
+
+func (p *listPolicy) setBudget(int64) {}
+
+type lfuItem struct {
+	key   string
+	count int
+	// 最後に参照した順序（同じ回数の中で古いものから破棄する）
+	tick  uint64
+	index int
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].tick < h[j].tick
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x any) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// 参照回数の最小ヒープ
+type lfuPolicy struct {
+	items map[string]*lfuItem
+	heap  lfuHeap
+	tick  uint64
+}
+
+func (p *lfuPolicy) add(key string, _ int64) {
+	if _, ok := p.items[key]; ok {
+		p.access(key)
+		return
+	}
+	p.tick++
+	item := &lfuItem{key: key, count: 1, tick: p.tick}
+	p.items[key] = item
+	heap.Push(&p.heap, item)
+}
+
+func (p *lfuPolicy) access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.tick++
+	item.count++
+	item.tick = p.tick
+	heap.Fix(&p.heap, item.index)
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if item, ok := p.items[key]; ok {
+		heap.Remove(&p.heap, item.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfuPolicy) evict(skip string) (string, bool) {
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	i := 0
+	// 最小がskipの場合は、その子のうち小さい方が次に小さい
+	if p.heap[0].key == skip {
+		i = 1
+		if len(p.heap) > 2 && p.heap.Less(2, 1) {
+			i = 2
+		}
+		if i >= len(p.heap) {
+			return "", false
+		}
+	}
+	item := heap.Remove(&p.heap, i).(*lfuItem)
+	delete(p.items, item.key)
+	return item.key, true
+}
+
+func (p *lfuPolicy) setBudget(int64) {}
+
+type arcItem struct {
+	key  string
+	size int64
+}
+
+// 参照順のリストと合計サイズ
+type arcList struct {
+	order *list.List
+	elems map[string]*list.Element
+	bytes int64
+}
+
+func newARCList() arcList {
+	return arcList{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (l *arcList) pushBack(key string, size int64) {
+	l.elems[key] = l.order.PushBack(arcItem{key: key, size: size})
+	l.bytes += size
+}
+
+func (l *arcList) remove(key string) (int64, bool) {
+	e, ok := l.elems[key]
+	if !ok {
+		return 0, false
+	}
+	item := l.order.Remove(e).(arcItem)
+	delete(l.elems, key)
+	l.bytes -= item.size
+	return item.size, true
+}
+
+func (l *arcList) popFront() (arcItem, bool) {
+	return l.popFrontExcept("")
+}
+
+// skip以外で最も古いものを取り出す
+func (l *arcList) popFrontExcept(skip string) (arcItem, bool) {
+	e := l.order.Front()
+	if e != nil && e.Value.(arcItem).key == skip {
+		e = e.Next()
+	}
+	if e == nil {
+		return arcItem{}, false
+	}
+	item := e.Value.(arcItem)
+	l.remove(item.key)
+	return item, true
+}
+
+// サイズで重み付けしたARC（Adaptive Replacement Cache）
+// t1は1回だけ参照されたもの、t2は繰り返し参照されたもの、b1・b2はそれぞれから破棄したキーの履歴
+type arcPolicy struct {
+	budget int64
+	// t1に配分する容量（b1の履歴が再保存されると増やし、b2の履歴が再保存されると減らす）
+	target         int64
+	t1, t2, b1, b2 arcList
+}
+
+func newARCPolicy(budget int64) *arcPolicy {
+	return &arcPolicy{budget: budget, t1: newARCList(), t2: newARCList(), b1: newARCList(), b2: newARCList()}
+}
+
+func (p *arcPolicy) add(key string, size int64) {
+	if _, ok := p.t1.remove(key); ok {
+		p.t2.pushBack(key, size)
+		return
+	}
+	if _, ok := p.t2.remove(key); ok {
+		p.t2.pushBack(key, size)
+		return
+	}
+	if _, ok := p.b1.elems[key]; ok {
+		p.target = min(p.target+size*max(p.b2.bytes/max(p.b1.bytes, 1), 1), p.budget)
+		p.b1.remove(key)
+		p.t2.pushBack(key, size)
+		return
+	}
+	if _, ok := p.b2.elems[key]; ok {
+		p.target = max(p.target-size*max(p.b1.bytes/max(p.b2.bytes, 1), 1), 0)
+		p.b2.remove(key)
+		p.t2.pushBack(key, size)
+		return
+	}
+	p.t1.pushBack(key, size)
+	p.trimHistory()
+}
+
+func (p *arcPolicy) access(key string) {
+	if size, ok := p.t1.remove(key); ok {
+		p.t2.pushBack(key, size)
+		return
+	}
+	if e, ok := p.t2.elems[key]; ok {
+		p.t2.order.MoveToBack(e)
+	}
+}
+
+func (p *arcPolicy) remove(key string) {
+	if _, ok := p.t1.remove(key); !ok {
+		p.t2.remove(key)
+	}
+}
+
+func (p *arcPolicy) evict(skip string) (string, bool) {
+	from, ghost, other, otherGhost := &p.t2, &p.b2, &p.t1, &p.b1
+	if p.t1.order.Len() > 0 && (p.t1.bytes > p.target || p.t2.order.Len() == 0) {
+		from, ghost, other, otherGhost = &p.t1, &p.b1, &p.t2, &p.b2
+	}
+	item, ok := from.popFrontExcept(skip)
+	if !ok {
+		// 選んだ側にskipしかない場合はもう一方から破棄する
+		if item, ok = other.popFrontExcept(skip); !ok {
+			return "", false
+		}
+		ghost = otherGhost
+	}
+	ghost.pushBack(item.key, item.size)
+	p.trimHistory()
+	return item.key, true
+}
+
+func (p *arcPolicy) setBudget(budget int64) {
+	p.budget = budget
+	p.target = min(p.target, budget)
+	p.trimHistory()
+}
+
+// 履歴を、t1とb1の合計が容量以内、全体が容量の2倍以内になるまで古いものから消す
+func (p *arcPolicy) trimHistory() {
+	for p.t1.bytes+p.b1.bytes > p.budget && p.b1.order.Len() > 0 {
+		p.b1.popFront()
+	}
+	for p.t1.bytes+p.t2.bytes+p.b1.bytes+p.b2.bytes > 2*p.budget && p.b2.order.Len() > 0 {
+		p.b2.popFront()
+	}
+}
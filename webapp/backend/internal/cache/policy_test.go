@@ -0,0 +1,410 @@
+package cache
+
+import (
+	"backend/internal/metrics"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// skipを除いて破棄できるものをすべて破棄し、順に返す
+func evictAll(p evictionPolicy, skip string) []string {
+	var keys []string
+	for {
+		key, ok := p.evict(skip)
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListPolicyEvictOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		lru  bool
+		skip string
+		want []string
+	}{
+		{name: "fifo", want: []string{"a", "b", "c"}},
+		{name: "lru", lru: true, want: []string{"b", "c", "a"}},
+		{name: "fifo_skip_oldest", skip: "a", want: []string{"b", "c"}},
+		{name: "lru_skip_oldest", lru: true, skip: "b", want: []string{"c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newListPolicy(tt.lru)
+			for _, key := range []string{"a", "b", "c"} {
+				p.add(key, 1)
+			}
+			p.access("a")
+			if got := evictAll(p, tt.skip); !sameKeys(got, tt.want) {
+				t.Fatalf("evict order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLFUPolicyEvictOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		access []string
+		remove string
+		skip   string
+		want   []string
+	}{
+		{name: "insertion_order_on_tie", want: []string{"a", "b", "c"}},
+		{name: "fewest_accesses_first", access: []string{"a", "a", "b"}, want: []string{"c", "b", "a"}},
+		{name: "oldest_access_on_tie", access: []string{"a", "b"}, want: []string{"c", "a", "b"}},
+		{name: "add_counts_as_access", access: []string{"+a"}, want: []string{"b", "c", "a"}},
+		{name: "removed", access: []string{"a"}, remove: "b", want: []string{"c", "a"}},
+		{name: "skip_min", access: []string{"b", "b", "c"}, skip: "a", want: []string{"c", "b"}},
+		{name: "skip_min_left_child", access: []string{"c"}, skip: "a", want: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newEvictionPolicy(PolicyLFU, 0)
+			for _, key := range []string{"a", "b", "c"} {
+				p.add(key, 1)
+			}
+			for _, key := range tt.access {
+				if key[0] == '+' {
+					p.add(key[1:], 1)
+					continue
+				}
+				p.access(key)
+			}
+			if tt.remove != "" {
+				p.remove(tt.remove)
+			}
+			if got := evictAll(p, tt.skip); !sameKeys(got, tt.want) {
+				t.Fatalf("evict order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLFUPolicySkipOnly(t *testing.T) {
+	p := newEvictionPolicy(PolicyLFU, 0)
+	p.add("a", 1)
+	if key, ok := p.evict("a"); ok {
+		t.Fatalf("evict(a) = %q, want none", key)
+	}
+	if key, ok := p.evict(""); !ok || key != "a" {
+		t.Fatalf("evict() = %q, %v, want a", key, ok)
+	}
+}
+
+// 各リストのキーを古い順に返す
+func arcKeys(l *arcList) []string {
+	var keys []string
+	for e := l.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(arcItem).key)
+	}
+	return keys
+}
+
+func checkARC(t *testing.T, p *arcPolicy, t1, t2, b1, b2 []string) {
+	t.Helper()
+	for _, l := range []struct {
+		name string
+		list *arcList
+		want []string
+	}{{"t1", &p.t1, t1}, {"t2", &p.t2, t2}, {"b1", &p.b1, b1}, {"b2", &p.b2, b2}} {
+		if got := arcKeys(l.list); !sameKeys(got, l.want) {
+			t.Fatalf("%s = %v, want %v", l.name, got, l.want)
+		}
+	}
+}
+
+func TestARCPolicyPromotion(t *testing.T) {
+	p := newARCPolicy(10)
+	p.add("a", 1)
+	p.add("b", 1)
+	p.add("c", 1)
+	checkARC(t, p, []string{"a", "b", "c"}, nil, nil, nil)
+
+	p.access("a")
+	p.add("b", 2)
+	checkARC(t, p, []string{"c"}, []string{"a", "b"}, nil, nil)
+	if p.t1.bytes != 1 || p.t2.bytes != 3 {
+		t.Fatalf("bytes = t1 %d t2 %d, want 1, 3", p.t1.bytes, p.t2.bytes)
+	}
+
+	p.access("a")
+	checkARC(t, p, []string{"c"}, []string{"b", "a"}, nil, nil)
+
+	p.remove("c")
+	p.remove("b")
+	checkARC(t, p, nil, []string{"a"}, nil, nil)
+}
+
+func TestARCPolicyEvictChoice(t *testing.T) {
+	tests := []struct {
+		name   string
+		target int64
+		skip   string
+		want   string
+		t1, t2 []string
+		b1, b2 []string
+	}{
+		// t1がtargetを超えているときはt1から破棄する
+		{name: "t1_over_target", target: 1, want: "a", t1: []string{"b"}, t2: []string{"c", "d"}, b1: []string{"a"}},
+		// t1がtarget以内のときはt2から破棄する
+		{name: "t1_within_target", target: 2, want: "c", t1: []string{"a", "b"}, t2: []string{"d"}, b2: []string{"c"}},
+		{name: "skip_in_t1", target: 1, skip: "a", want: "b", t1: []string{"a"}, t2: []string{"c", "d"}, b1: []string{"b"}},
+		{name: "skip_in_t2", target: 2, skip: "c", want: "d", t1: []string{"a", "b"}, t2: []string{"c"}, b2: []string{"d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newARCPolicy(10)
+			p.add("a", 1)
+			p.add("b", 1)
+			p.add("c", 1)
+			p.add("d", 1)
+			p.access("c")
+			p.access("d")
+			p.target = tt.target
+			if key, ok := p.evict(tt.skip); !ok || key != tt.want {
+				t.Fatalf("evict(%q) = %q, %v, want %q", tt.skip, key, ok, tt.want)
+			}
+			checkARC(t, p, tt.t1, tt.t2, tt.b1, tt.b2)
+		})
+	}
+}
+
+func TestARCPolicyEvictFallsBackToOtherList(t *testing.T) {
+	p := newARCPolicy(10)
+	p.add("a", 1)
+	p.add("b", 1)
+	p.access("b")
+	// t1にはaしかないため、skipするとt2から破棄する
+	if key, ok := p.evict("a"); !ok || key != "b" {
+		t.Fatalf("evict(a) = %q, %v, want b", key, ok)
+	}
+	checkARC(t, p, []string{"a"}, nil, nil, []string{"b"})
+	if key, ok := p.evict("a"); ok {
+		t.Fatalf("evict(a) = %q, want none", key)
+	}
+}
+
+func TestARCPolicyTargetAdaptation(t *testing.T) {
+	p := newARCPolicy(10)
+	p.add("a", 2)
+	p.add("b", 2)
+	p.access("b")
+	p.evict("")
+	p.evict("")
+	checkARC(t, p, nil, nil, []string{"a"}, []string{"b"})
+
+	// b1の履歴の再保存でt1の配分を増やし、t2に入れる
+	p.add("a", 2)
+	if p.target != 2 {
+		t.Fatalf("target after b1 hit = %d, want 2", p.target)
+	}
+	checkARC(t, p, nil, []string{"a"}, nil, []string{"b"})
+
+	// b2の履歴の再保存でt1の配分を減らす
+	p.add("b", 3)
+	if p.target != 0 {
+		t.Fatalf("target after b2 hit = %d, want 0", p.target)
+	}
+	checkARC(t, p, nil, []string{"a", "b"}, nil, nil)
+
+	// 配分は容量を超えない
+	p.add("c", 8)
+	p.evict("")
+	p.target = 9
+	p.add("c", 8)
+	if p.target != 10 {
+		t.Fatalf("target = %d, want budget 10", p.target)
+	}
+
+	p.setBudget(4)
+	if p.target != 4 {
+		t.Fatalf("target after setBudget(4) = %d, want 4", p.target)
+	}
+}
+
+func TestARCPolicyTrimHistory(t *testing.T) {
+	const budget = 10
+	p := newARCPolicy(budget)
+	var usedB1, usedB2 bool
+	for i := range 50 {
+		key := fmt.Sprint(i)
+		p.add(key, 1)
+		if i%3 == 0 {
+			p.access(key)
+		}
+		// 後半はt1に容量を配分し、t2から破棄させる
+		if i == 25 {
+			p.target = budget
+		}
+		for p.t1.bytes+p.t2.bytes > budget {
+			p.evict("")
+		}
+		if got := p.t1.bytes + p.b1.bytes; got > budget {
+			t.Fatalf("step %d: t1+b1 = %d, want <= %d", i, got, budget)
+		}
+		if got := p.t1.bytes + p.t2.bytes + p.b1.bytes + p.b2.bytes; got > 2*budget {
+			t.Fatalf("step %d: total = %d, want <= %d", i, got, 2*budget)
+		}
+		usedB1 = usedB1 || p.b1.order.Len() > 0
+		usedB2 = usedB2 || p.b2.order.Len() > 0
+	}
+	if !usedB1 || !usedB2 {
+		t.Fatalf("history used = b1 %v b2 %v, want both", usedB1, usedB2)
+	}
+
+	p.setBudget(4)
+	if got := p.t1.bytes + p.b1.bytes; got > 4 && p.b1.order.Len() > 0 {
+		t.Fatalf("t1+b1 after setBudget(4) = %d, want <= 4", got)
+	}
+}
+
+// 繰り返し参照されるキーを、一度きりの参照が続いた後も保持しているか
+func TestShardedSweepKeepsHotSet(t *testing.T) {
+	const (
+		hot   = 32
+		sweep = 4000
+	)
+	tests := []struct {
+		policy  Policy
+		keepHot bool
+	}{
+		{policy: PolicyLRU, keepHot: false},
+		{policy: PolicyLFU, keepHot: true},
+		{policy: PolicyARC, keepHot: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			// 分割1つあたり20件
+			c := NewShardedWith[int](shardCount*20, time.Hour, Options{Policy: tt.policy})
+			for i := range hot {
+				c.Set(fmt.Sprintf("hot%d", i), i, 1)
+			}
+			for range 3 {
+				for i := range hot {
+					if _, ok := c.Get(fmt.Sprintf("hot%d", i)); !ok {
+						t.Fatalf("Get(hot%d) before sweep = miss", i)
+					}
+				}
+			}
+			for i := range sweep {
+				// 読み込み時のキャッシュと同じく、見つからなければ保存する
+				key := fmt.Sprintf("sweep%d", i)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i, 1)
+				}
+			}
+			kept := 0
+			for i := range hot {
+				if _, ok := c.Peek(fmt.Sprintf("hot%d", i)); ok {
+					kept++
+				}
+			}
+			if tt.keepHot && kept != hot {
+				t.Fatalf("kept %d/%d hot keys after sweep, want all", kept, hot)
+			}
+			if !tt.keepHot && kept > hot/2 {
+				t.Fatalf("kept %d/%d hot keys after sweep, want most evicted", kept, hot)
+			}
+		})
+	}
+}
+
+// 同じ分割に入るキーをn個返す
+func keysInSameShard(c *Sharded[int], n int) []string {
+	byShard := make(map[*shard[int]][]string)
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("k%d", i)
+		s := c.shardFor(key)
+		byShard[s] = append(byShard[s], key)
+		if len(byShard[s]) == n {
+			return byShard[s]
+		}
+	}
+}
+
+func TestShardedSetOverwrite(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		// 上書きで破棄されるキー（keysの添字）
+		evicted int
+	}{
+		{policy: PolicyFIFO, evicted: 1},
+		{policy: PolicyLRU, evicted: 1},
+		{policy: PolicyLFU, evicted: 1},
+		{policy: PolicyARC, evicted: 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			stats := &metrics.HitCounter{}
+			c := NewShardedWith[int](shardCount*10, time.Hour, Options{Policy: tt.policy, Stats: stats})
+			keys := keysInSameShard(c, 3)
+			c.Set(keys[0], 0, 4)
+			c.Set(keys[1], 1, 3)
+			c.Set(keys[2], 2, 3)
+			// keys[0]以外は繰り返し参照されている（LFU・ARCでもkeys[0]が最初の破棄の候補）
+			for range 2 {
+				c.Get(keys[1])
+				c.Get(keys[2])
+			}
+			c.Set(keys[0], 10, 5)
+
+			if v, ok := c.Peek(keys[0]); !ok || v != 10 {
+				t.Fatalf("Peek(%s) = %d, %v, want 10", keys[0], v, ok)
+			}
+			if _, ok := c.Peek(keys[tt.evicted]); ok {
+				t.Fatalf("Peek(%s) = hit, want evicted", keys[tt.evicted])
+			}
+			if size, count, _ := c.Usage(); size != 8 || count != 2 {
+				t.Fatalf("Usage() = %d, %d, want 8, 2", size, count)
+			}
+			if got := stats.Stat().Evictions; got != 1 {
+				t.Fatalf("evictions = %d, want 1", got)
+			}
+
+			s := c.shardFor(keys[0])
+			switch p := s.policy.(type) {
+			case *lfuPolicy:
+				// 上書きは参照として数える
+				if got := p.items[keys[0]].count; got != 2 {
+					t.Fatalf("count(%s) = %d, want 2", keys[0], got)
+				}
+			case *arcPolicy:
+				// 上書きはt2への昇格として扱い、履歴の再保存として配分を変えない
+				if p.target != 0 {
+					t.Fatalf("target = %d, want 0", p.target)
+				}
+				if _, ok := p.t2.elems[keys[0]]; !ok {
+					t.Fatalf("t2 = %v, want %s", arcKeys(&p.t2), keys[0])
+				}
+			}
+		})
+	}
+}
+
+func TestShardedSetOverwriteOnly(t *testing.T) {
+	c := NewShardedWith[int](shardCount*10, time.Hour, Options{Policy: PolicyARC})
+	c.Set("a", 1, 6)
+	c.Set("a", 2, 10)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Fatalf("Get(a) = %d, %v, want 2", v, ok)
+	}
+	if size, count, _ := c.Usage(); size != 10 || count != 1 {
+		t.Fatalf("Usage() = %d, %d, want 10, 1", size, count)
+	}
+}
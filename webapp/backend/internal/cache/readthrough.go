@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"backend/internal/metrics"
+	"backend/internal/task"
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// 同時のミスで共有する読み込みのデフォルトのタイムアウト
+const defaultLoadTimeout = 10 * time.Second
+
+type ReadThroughOptions[V any] struct {
+	// 保存する値の推定サイズ（nilの場合は1件を1とする）
+	Size func(V) int64
+	// ヒット・ミスと容量の超過で破棄した件数を記録する（nil可）
+	Stats *metrics.HitCounter
+	// 共有する読み込みのタイムアウト（0の場合はdefaultLoadTimeout）
+	LoadTimeout time.Duration
+	// 容量を超えたときに破棄するエントリを選ぶ方式（空の場合はPolicyFIFO）
+	Policy Policy
+}
+
+// 引数Aから値Vを読み込む処理をキャッシュアサイドでキャッシュする
+// キャッシュにない場合は同じキーの読み込みをsingleflightで1つにまとめ、結果を保存する
+// リポジトリの読み込みメソッドは、キーと読み込み処理を渡してGetを呼ぶだけでキャッシュできる
+type ReadThrough[A, V any] struct {
+	key     func(A) string
+	load    func(ctx context.Context, arg A) (V, error)
+	size    func(V) int64
+	stats   *metrics.HitCounter
+	timeout time.Duration
+	cache   *Sharded[V]
+	sf      singleflight.Group
+	// 無効化のたびに進める世代（無効化より前に始まった読み込みの結果は保存しない）
+	generation atomic.Uint64
+}
+
+func NewReadThrough[A, V any](budget int64, ttl time.Duration, key func(A) string, load func(ctx context.Context, arg A) (V, error), opts ReadThroughOptions[V]) *ReadThrough[A, V] {
+	if opts.Size == nil {
+		opts.Size = func(V) int64 { return 1 }
+	}
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = defaultLoadTimeout
+	}
+	return &ReadThrough[A, V]{
+		key:     key,
+		load:    load,
+		size:    opts.Size,
+		stats:   opts.Stats,
+		timeout: opts.LoadTimeout,
+		cache:   NewShardedWith[V](budget, ttl, Options{Policy: opts.Policy, Stats: opts.Stats}),
+	}
+}
+
+// argの値をキャッシュから返し、なければ読み込んで保存する
+// 待機は呼び出し元のctxに従い、共有する読み込みは最初の呼び出し元のキャンセルに巻き込まない
+func (c *ReadThrough[A, V]) Get(ctx context.Context, arg A) (V, error) {
+	key := c.key(arg)
+	if value, ok := c.cache.Get(key); ok {
+		c.hit()
+		return value, nil
+	}
+	c.miss()
+
+	generation := c.generation.Load()
+	sfKey := key + "@" + strconv.FormatUint(generation, 10)
+	result, err := task.Shared(ctx, &c.sf, sfKey, c.timeout, func(ctx context.Context) (interface{}, error) {
+		value, err := c.load(ctx, arg)
+		if err != nil {
+			return nil, err
+		}
+		if c.generation.Load() == generation {
+			c.cache.Set(key, value, c.size(value))
+		}
+		return value, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return result.(V), nil
+}
+
+// 指定した引数のキャッシュを破棄し、破棄した件数を返す
+func (c *ReadThrough[A, V]) Invalidate(args ...A) int {
+	c.generation.Add(1)
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = c.key(arg)
+	}
+	return c.cache.Delete(keys...)
+}
+
+// 全てのキャッシュを破棄する
+func (c *ReadThrough[A, V]) Purge() {
+	c.generation.Add(1)
+	c.cache.RemoveStale(func(string, V) bool { return true })
+}
+
+// キャッシュの推定サイズ・件数・容量
+func (c *ReadThrough[A, V]) Usage() (size int64, count int, budget int64) {
+	return c.cache.Usage()
+}
+
+func (c *ReadThrough[A, V]) hit() {
+	if c.stats != nil {
+		c.stats.Hit()
+	}
+}
+
+func (c *ReadThrough[A, V]) miss() {
+	if c.stats != nil {
+		c.stats.Miss()
+	}
+}
@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"backend/internal/metrics"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// キャッシュを分割する数
+// 書き込み・破棄のロックを分け、並行アクセス時に1つのロックで詰まらないようにする
+const shardCount = 16
+
+type entry[V any] struct {
+	value    V
+	size     int64
+	storedAt time.Time
+}
+
+type shard[V any] struct {
+	mutex   sync.RWMutex
+	entries map[string]*entry[V]
+	policy  evictionPolicy
+	size    int64
+	budget  int64
+}
+
+// キーのハッシュで分割したキャッシュ
+// 容量は分割数で等分し、分割ごとに超えた分を方式（デフォルトはFIFO）に従って破棄する
+type Sharded[V any] struct {
+	seed   maphash.Seed
+	ttl    time.Duration
+	policy Policy
+	stats  *metrics.HitCounter
+	shards [shardCount]shard[V]
+}
+
+type Options struct {
+	// 容量を超えたときに破棄するエントリを選ぶ方式（空の場合はPolicyFIFO）
+	Policy Policy
+	// 容量の超過で破棄した件数を記録する（nil可）
+	Stats *metrics.HitCounter
+}
+
+func NewSharded[V any](budget int64, ttl time.Duration) *Sharded[V] {
+	return NewShardedWith[V](budget, ttl, Options{})
+}
+
+func NewShardedWith[V any](budget int64, ttl time.Duration, opts Options) *Sharded[V] {
+	if opts.Policy == "" {
+		opts.Policy = PolicyFIFO
+	}
+	c := &Sharded[V]{seed: maphash.MakeSeed(), ttl: ttl, policy: opts.Policy, stats: opts.Stats}
+	// マップは最初の保存時に作る（トランザクションごとに作られるリポジトリでは使われないことが多い）
+	for i := range c.shards {
+		c.shards[i].budget = budget / shardCount
+	}
+	if c.stats != nil {
+		c.stats.SetPolicy(string(c.policy))
+	}
+	return c
+}
+
+func (c *Sharded[V]) shardFor(key string) *shard[V] {
+	return &c.shards[maphash.String(c.seed, key)%shardCount]
+}
+
+// 期限内の値を返す（破棄の方式に参照として記録する）
+func (c *Sharded[V]) Get(key string) (V, bool) {
+	if !c.policy.tracksAccess() {
+		return c.Peek(key)
+	}
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[key]
+	if !ok || time.Since(e.storedAt) > c.ttl {
+		var zero V
+		return zero, false
+	}
+	s.policy.access(key)
+	return e.value, true
+}
+
+// 参照として記録せずに期限内の値を返す（統計・管理用）
+func (c *Sharded[V]) Peek(key string) (V, bool) {
+	s := c.shardFor(key)
+	s.mutex.RLock()
+	e, ok := s.entries[key]
+	s.mutex.RUnlock()
+	if !ok || time.Since(e.storedAt) > c.ttl {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// sizeが分割1つ分の容量を超える値は保存しない
+func (c *Sharded[V]) Set(key string, value V, size int64) {
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if size > s.budget {
+		return
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]*entry[V])
+		s.policy = newEvictionPolicy(c.policy, s.budget)
+	}
+	// 保存済みのキーは方式の記録を残し、上書きを参照として扱う（空きを作るときの破棄の対象から外す）
+	s.drop(key)
+	c.stats.Evicted(s.evict(s.budget-size, key))
+	s.entries[key] = &entry[V]{value: value, size: size, storedAt: time.Now()}
+	s.size += size
+	s.policy.add(key, size)
+}
+
+// 指定したキーを破棄し、破棄した件数を返す
+func (c *Sharded[V]) Delete(keys ...string) int {
+	deleted := 0
+	for _, key := range keys {
+		s := c.shardFor(key)
+		s.mutex.Lock()
+		if s.remove(key) {
+			deleted++
+		}
+		s.mutex.Unlock()
+	}
+	return deleted
+}
+
+// 期限切れのもの・staleがtrueを返すものを破棄する（staleはnil可）
+func (c *Sharded[V]) RemoveStale(stale func(key string, value V) bool) {
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.Lock()
+		for key, e := range s.entries {
+			if now.Sub(e.storedAt) > c.ttl || (stale != nil && stale(key, e.value)) {
+				s.remove(key)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// 期限内の全エントリについてfnを呼ぶ（fnはロックの外で呼ばれる）
+func (c *Sharded[V]) Range(fn func(key string, value V)) {
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.RLock()
+		keys := make([]string, 0, len(s.entries))
+		values := make([]V, 0, len(s.entries))
+		for key, e := range s.entries {
+			if now.Sub(e.storedAt) <= c.ttl {
+				keys = append(keys, key)
+				values = append(values, e.value)
+			}
+		}
+		s.mutex.RUnlock()
+		for j, key := range keys {
+			fn(key, values[j])
+		}
+	}
+}
+
+// 容量を変更し、超えている分を方式に従って破棄する
+func (c *Sharded[V]) SetBudget(budget int64) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.Lock()
+		s.budget = budget / shardCount
+		if s.policy != nil {
+			s.policy.setBudget(s.budget)
+		}
+		c.stats.Evicted(s.evict(s.budget, ""))
+		s.mutex.Unlock()
+	}
+}
+
+// 全分割の合計サイズ・件数・容量
+func (c *Sharded[V]) Usage() (size int64, count int, budget int64) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.RLock()
+		size += s.size
+		count += len(s.entries)
+		budget += s.budget
+		s.mutex.RUnlock()
+	}
+	return size, count, budget
+}
+
+func (s *shard[V]) remove(key string) bool {
+	if s.policy != nil {
+		s.policy.remove(key)
+	}
+	return s.drop(key)
+}
+
+// 方式の記録を変えずにエントリを消す
+func (s *shard[V]) drop(key string) bool {
+	e, ok := s.entries[key]
+	if !ok {
+		return false
+	}
+	s.size -= e.size
+	delete(s.entries, key)
+	return true
+}
+
+// 合計サイズがlimit以下になるまでskip以外を方式に従って破棄し、破棄した件数を返す
+func (s *shard[V]) evict(limit int64, skip string) int {
+	evicted := 0
+	for s.size > limit && s.policy != nil {
+		key, ok := s.policy.evict(skip)
+		if !ok {
+			break
+		}
+		if s.drop(key) {
+			evicted++
+		}
+	}
+	return evicted
+}
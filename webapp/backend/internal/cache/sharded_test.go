@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"backend/internal/metrics"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 分割ごとの容量と、その合計が全体の容量を超えていないか
+func checkBudget(t *testing.T, c *Sharded[int], budget int64) {
+	t.Helper()
+	var total int64
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.RLock()
+		size, shardBudget := s.size, s.budget
+		s.mutex.RUnlock()
+		if size > shardBudget {
+			t.Fatalf("shard %d size = %d, want <= %d", i, size, shardBudget)
+		}
+		total += size
+	}
+	size, _, gotBudget := c.Usage()
+	if size != total {
+		t.Fatalf("Usage() size = %d, want sum of shards %d", size, total)
+	}
+	if gotBudget > budget {
+		t.Fatalf("Usage() budget = %d, want <= %d", gotBudget, budget)
+	}
+	if size > budget {
+		t.Fatalf("Usage() size = %d, want <= %d", size, budget)
+	}
+}
+
+func TestShardedBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int64
+		policy Policy
+	}{
+		{name: "fifo", budget: 1600, policy: PolicyFIFO},
+		{name: "lru", budget: 1600, policy: PolicyLRU},
+		{name: "lfu", budget: 1600, policy: PolicyLFU},
+		{name: "arc", budget: 1600, policy: PolicyARC},
+		// 分割数で割り切れない容量は切り捨てる
+		{name: "not_divisible", budget: 1615, policy: PolicyFIFO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &metrics.HitCounter{}
+			c := NewShardedWith[int](tt.budget, time.Hour, Options{Policy: tt.policy, Stats: stats})
+			if _, _, budget := c.Usage(); budget != tt.budget/shardCount*shardCount {
+				t.Fatalf("Usage() budget = %d, want %d", budget, tt.budget/shardCount*shardCount)
+			}
+			rng := rand.New(rand.NewPCG(1, 2))
+			stored := 0
+			for i := range 2000 {
+				key := fmt.Sprint(i)
+				c.Set(key, i, rng.Int64N(30)+1)
+				if _, ok := c.Peek(key); ok {
+					stored++
+				}
+				if i%100 == 0 {
+					checkBudget(t, c, tt.budget)
+				}
+			}
+			checkBudget(t, c, tt.budget)
+			_, count, _ := c.Usage()
+			if got := int(stats.Stat().Evictions); got != stored-count {
+				t.Fatalf("evictions = %d, want %d", got, stored-count)
+			}
+		})
+	}
+}
+
+func TestShardedSkipsValueOverShardBudget(t *testing.T) {
+	c := NewSharded[int](shardCount*10, time.Hour)
+	// 全体の容量以内でも、分割1つ分を超える値は保存しない
+	c.Set("big", 1, 11)
+	if _, ok := c.Peek("big"); ok {
+		t.Fatalf("Peek(big) = hit, want value over shard budget skipped")
+	}
+	c.Set("fit", 1, 10)
+	if _, ok := c.Peek("fit"); !ok {
+		t.Fatalf("Peek(fit) = miss, want stored")
+	}
+	if size, count, _ := c.Usage(); size != 10 || count != 1 {
+		t.Fatalf("Usage() = %d, %d, want 10, 1", size, count)
+	}
+}
+
+func TestShardedSetBudget(t *testing.T) {
+	stats := &metrics.HitCounter{}
+	c := NewShardedWith[int](shardCount*100, time.Hour, Options{Policy: PolicyLRU, Stats: stats})
+	for i := range 1000 {
+		c.Set(fmt.Sprint(i), i, 5)
+	}
+	checkBudget(t, c, shardCount*100)
+	_, before, _ := c.Usage()
+	evictedBefore := stats.Stat().Evictions
+
+	c.SetBudget(shardCount * 20)
+	checkBudget(t, c, shardCount*20)
+	_, after, _ := c.Usage()
+	if after > shardCount*4 {
+		t.Fatalf("count after SetBudget = %d, want <= %d", after, shardCount*4)
+	}
+	if got := stats.Stat().Evictions - evictedBefore; got != int64(before-after) {
+		t.Fatalf("evictions by SetBudget = %d, want %d", got, before-after)
+	}
+
+	c.SetBudget(0)
+	if size, count, budget := c.Usage(); size != 0 || count != 0 || budget != 0 {
+		t.Fatalf("Usage() after SetBudget(0) = %d, %d, %d, want all 0", size, count, budget)
+	}
+}
+
+func TestShardedConcurrentBudget(t *testing.T) {
+	const budget = shardCount * 50
+	c := NewShardedWith[int](budget, time.Hour, Options{Policy: PolicyARC})
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(g), 0))
+			for i := range 2000 {
+				key := fmt.Sprint(rng.IntN(500))
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i, rng.Int64N(10)+1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	checkBudget(t, c, budget)
+}
+
+// 分割前と同じく、1つのロックで全体を管理するキャッシュ（ベンチマークの比較用）
+type singleLockCache struct {
+	mutex sync.Mutex
+	shard shard[int]
+	ttl   time.Duration
+}
+
+func newSingleLockCache(budget int64, ttl time.Duration, policy Policy) *singleLockCache {
+	c := &singleLockCache{ttl: ttl}
+	c.shard.entries = make(map[string]*entry[int])
+	c.shard.policy = newEvictionPolicy(policy, budget)
+	c.shard.budget = budget
+	return c
+}
+
+func (c *singleLockCache) Get(key string) (int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.shard.entries[key]
+	if !ok || time.Since(e.storedAt) > c.ttl {
+		return 0, false
+	}
+	c.shard.policy.access(key)
+	return e.value, true
+}
+
+func (c *singleLockCache) Set(key string, value int, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := &c.shard
+	if size > s.budget {
+		return
+	}
+	s.drop(key)
+	s.evict(s.budget-size, key)
+	s.entries[key] = &entry[int]{value: value, size: size, storedAt: time.Now()}
+	s.size += size
+	s.policy.add(key, size)
+}
+
+type benchCache interface {
+	Get(key string) (int, bool)
+	Set(key string, value int, size int64)
+}
+
+// 読み込み9割・保存1割の並行アクセス
+func BenchmarkCacheParallel(b *testing.B) {
+	const (
+		keyCount = 4096
+		budget   = keyCount / 2 * 64
+	)
+	keys := make([]string, keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("products/%d", i)
+	}
+	for _, policy := range []Policy{PolicyFIFO, PolicyLRU} {
+		caches := []struct {
+			name  string
+			cache func() benchCache
+		}{
+			{name: "single_lock", cache: func() benchCache { return newSingleLockCache(budget, time.Hour, policy) }},
+			{name: "sharded", cache: func() benchCache {
+				return NewShardedWith[int](budget, time.Hour, Options{Policy: policy})
+			}},
+		}
+		for _, cc := range caches {
+			b.Run(fmt.Sprintf("%s/%s", policy, cc.name), func(b *testing.B) {
+				c := cc.cache()
+				for i, key := range keys {
+					c.Set(key, i, 64)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewPCG(rand.Uint64(), 0))
+					for pb.Next() {
+						i := rng.IntN(keyCount)
+						if rng.IntN(10) == 0 {
+							c.Set(keys[i], i, 64)
+							continue
+						}
+						c.Get(keys[i])
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"backend/internal/task"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 画像ディレクトリの変更を監視し、変更・削除されたファイルのキャッシュを破棄する
+// fsnotifyが使えない環境（inotifyの上限・一部のネットワークファイルシステム）では
+// キャッシュ済みのファイルの更新時刻を定期的に確認する
+type ImageWatcher struct {
+	dir          string
+	cache        *ImageCache
+	pollInterval time.Duration
+}
+
+// キャッシュのキーはdirからの相対パス
+func NewImageWatcher(dir string, cache *ImageCache, pollInterval time.Duration) *ImageWatcher {
+	return &ImageWatcher{dir: dir, cache: cache, pollInterval: pollInterval}
+}
+
+// ctxがキャンセルされるまで監視する（呼び出し元をブロックする）
+func (w *ImageWatcher) Run(ctx context.Context) {
+	// ディスクに保存した画像は前回の起動時に読み込んだものを含むため、停止中に差し替えられたものを先に破棄する
+	if w.cache.disk != nil {
+		w.removeModified()
+	}
+	watcher, err := w.newWatcher()
+	if err != nil {
+		log.Printf("[ImageWatcher] fsnotify unavailable, falling back to polling every %s: %v", w.pollInterval, err)
+		w.poll(ctx)
+		return
+	}
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// イベントの取りこぼし（キューあふれ）が起きた場合は、どのファイルが変わったか分からないため
+			// 更新時刻の確認で整合性を取り直す
+			log.Printf("[ImageWatcher] %v", err)
+			w.removeModified()
+		}
+	}
+}
+
+// dir以下の全ディレクトリを監視対象に加える（fsnotifyはサブディレクトリを再帰的に監視しない）
+func (w *ImageWatcher) newWatcher() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := addTree(watcher, w.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+func addTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
+
+func (w *ImageWatcher) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		// 新しく作られたディレクトリも監視する
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := addTree(watcher, event.Name); err != nil {
+				log.Printf("[ImageWatcher] failed to watch %s: %v", event.Name, err)
+			}
+		}
+	}
+	rel, err := filepath.Rel(w.dir, event.Name)
+	if err != nil {
+		return
+	}
+	// サムネイル、およびディレクトリごと消えた場合に備えて配下のキャッシュもまとめて破棄する
+	w.removeUnder(rel)
+}
+
+// relそのもの、またはrel以下のパスのキャッシュ（サムネイルを含む）を破棄する
+func (w *ImageWatcher) removeUnder(rel string) {
+	prefix := rel + string(filepath.Separator)
+	var keys []string
+	w.cache.rangeKeys(func(key string, _ time.Time) {
+		if path := sourcePath(key); path == rel || strings.HasPrefix(path, prefix) {
+			keys = append(keys, key)
+		}
+	})
+	w.cache.Delete(keys...)
+}
+
+func (w *ImageWatcher) poll(ctx context.Context) {
+	task.Loop(ctx, "ImageWatcher", w.pollInterval, func(context.Context) error {
+		w.removeModified()
+		return nil
+	})
+}
+
+// キャッシュ済みのファイルのうち、読み込み後に更新・削除されたもののキャッシュを破棄する
+// サムネイルは元のファイルの更新時刻を保持しているため、元のファイルと比べる
+func (w *ImageWatcher) removeModified() {
+	var keys []string
+	w.cache.rangeKeys(func(key string, modTime time.Time) {
+		info, err := os.Stat(filepath.Join(w.dir, sourcePath(key)))
+		if errors.Is(err, fs.ErrNotExist) || err == nil && !info.ModTime().Equal(modTime) {
+			keys = append(keys, key)
+		}
+	})
+	if n := w.cache.Delete(keys...); n > 0 {
+		log.Printf("[ImageWatcher] removed %d modified images from cache", n)
+	}
+}
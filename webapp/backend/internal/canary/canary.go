@@ -0,0 +1,61 @@
+// 新しい実装への段階的な切り替え（カナリア）
+// ユーザーIDのハッシュで一部のユーザーを新しい実装に振り分け、コホートごとのレイテンシ・失敗数を集計する
+// 同じユーザーは常に同じコホートになるため、リクエストごとに結果が入れ替わることはない
+package canary
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"backend/internal/metrics"
+)
+
+const (
+	// 新しい実装を使うコホート
+	CohortCanary = "canary"
+	// 従来の実装を使うコホート
+	CohortControl = "control"
+)
+
+// 1つの切り替え
+type Rollout struct {
+	name    string
+	percent int
+}
+
+// percent%のユーザーを新しい実装に振り分ける（100以上は全員）
+func New(name string, percent int) *Rollout {
+	return &Rollout{name: name, percent: min(percent, 100)}
+}
+
+// ユーザーを0〜99の区画に割り当てる
+// 切り替えの名前もハッシュに含め、切り替えごとに別のユーザーが新しい実装の対象になるようにする
+func Bucket(name string, userID int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write(strconv.AppendInt(nil, int64(userID), 10))
+	return int(h.Sum32() % 100)
+}
+
+// userIDのユーザーが新しい実装を使うか（nilのRolloutは常にfalse）
+func (r *Rollout) Enabled(userID int) bool {
+	return r != nil && Bucket(r.name, userID) < r.percent
+}
+
+// userIDのユーザーのコホート
+func (r *Rollout) Cohort(userID int) string {
+	if r.Enabled(userID) {
+		return CohortCanary
+	}
+	return CohortControl
+}
+
+// cohortで処理したリクエストの処理時間と成否を記録する（nilのRolloutは記録しない）
+func (r *Rollout) Observe(cohort string, d time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	metrics.Canary(r.name, cohort).Observe(d, err != nil)
+}
@@ -6,19 +6,40 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
+// フェイルオーバー時にプールを作り直した後も同じ値に戻す
+const maxIdleConns = 25
+
 func InitDBConnection() (*sqlx.DB, error) {
 	dbUrl := os.Getenv("DATABASE_URL")
 	if dbUrl == "" {
 		dbUrl = "user:password@tcp(db:4306)/42Tokyo2508-db"
 	}
+	return open(dbUrl, 100)
+}
+
+// DATABASE_READ_URLが設定されている場合、重い読み取りを送るレプリカへの接続プールを作る（未設定の場合はnil）
+// 最大接続数はDATABASE_READ_MAX_OPEN_CONNS（既定はプライマリと同じ100）
+func InitReadDBConnection() (*sqlx.DB, error) {
+	dbUrl := os.Getenv("DATABASE_READ_URL")
+	if dbUrl == "" {
+		return nil, nil
+	}
+	maxOpen := 100
+	if v, err := strconv.Atoi(os.Getenv("DATABASE_READ_MAX_OPEN_CONNS")); err == nil && v > 0 {
+		maxOpen = v
+	}
+	return open(dbUrl, maxOpen)
+}
+
+func open(dbUrl string, maxOpen int) (*sqlx.DB, error) {
 	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=Local", dbUrl)
-	log.Printf(dsn)
 
 	driverName := telemetry.WrapSQLDriver("mysql")
 	dbConn, err := sqlx.Open(driverName, dsn)
@@ -26,21 +47,19 @@ func InitDBConnection() (*sqlx.DB, error) {
 		log.Printf("Failed to open database connection: %v", err)
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err = dbConn.PingContext(ctx)
-	if err != nil {
-		dbConn.Close()
-		log.Printf("Failed to connect to database: %v", err)
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-	log.Println("Successfully connected to MySQL!")
+	// 接続確認はここでは行わず、起動シーケンスでWaitReadyを再試行する
 
 	// 高負荷対応のための接続プール設定
-	dbConn.SetMaxOpenConns(100)  // 最大接続数を増加
-	dbConn.SetMaxIdleConns(25)   // アイドル接続数を増加
+	dbConn.SetMaxOpenConns(maxOpen)            // 最大接続数を増加
+	dbConn.SetMaxIdleConns(maxIdleConns)       // アイドル接続数を増加
 	dbConn.SetConnMaxLifetime(5 * time.Minute) // 接続の最大生存時間を設定
 
 	return dbConn, nil
 }
+
+// DBに接続できるか確認する（timeoutで打ち切る）
+func Ping(ctx context.Context, dbConn *sqlx.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return dbConn.PingContext(ctx)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// フェイルオーバー中に返されるMySQLのエラー番号
+var failoverErrorNumbers = map[uint16]bool{
+	1053: true, // ER_SERVER_SHUTDOWN
+	1290: true, // ER_OPTION_PREVENTS_STATEMENT（昇格前・降格後の--read-only）
+	1792: true, // ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+	1836: true, // ER_READ_ONLY_MODE
+	1927: true, // ER_CONNECTION_KILLED
+	2006: true, // CR_SERVER_GONE_ERROR
+	2013: true, // CR_SERVER_LOST
+}
+
+// フェイルオーバー（接続断・読み取り専用への切り替わり）によるエラーか判定する
+func IsFailoverError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return failoverErrorNumbers[mysqlErr.Number]
+	}
+	return false
+}
+
+// フェイルオーバーによるエラーを検知したら、接続プールのアイドル接続を破棄して
+// 以降のクエリが新しいプライマリに接続し直すようにする
+type FailoverMonitor struct {
+	db          *sqlx.DB
+	maxIdle     int
+	minInterval time.Duration
+
+	mutex       sync.Mutex
+	lastRefresh time.Time
+	refreshes   atomic.Int64
+}
+
+// 障害中は大量のエラーが続くため、プールの作り直しはminIntervalに1回までにする
+func NewFailoverMonitor(db *sqlx.DB, minInterval time.Duration) *FailoverMonitor {
+	return &FailoverMonitor{db: db, maxIdle: maxIdleConns, minInterval: minInterval}
+}
+
+// errがフェイルオーバーによるものか判定し、そうであればプールを作り直す
+func (m *FailoverMonitor) Observe(err error) bool {
+	if !IsFailoverError(err) {
+		return false
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if time.Since(m.lastRefresh) < m.minInterval {
+		return true
+	}
+	m.lastRefresh = time.Now()
+	m.refreshes.Add(1)
+	log.Printf("[db] failover detected, refreshing connection pool: %v", err)
+	// アイドル接続を0にすると既存のアイドル接続が閉じられる
+	// 使用中の接続は返却時に上限を超えた分が閉じられる
+	m.db.SetMaxIdleConns(0)
+	m.db.SetMaxIdleConns(m.maxIdle)
+	return true
+}
+
+// これまでにプールを作り直した回数
+func (m *FailoverMonitor) Refreshes() int64 {
+	return m.refreshes.Load()
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/task"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// MySQL 8.0.22より前はSHOW REPLICA STATUSが構文エラーになる
+const errParse = 1064
+
+// 読み取り用のレプリカに接続でき、遅延が許容範囲内か定期的に確認する
+// 使えない間（確認前・接続できない・遅延が大きい・レプリケーションが止まっている）は、読み取りをプライマリに送る
+type ReplicaMonitor struct {
+	db     *sqlx.DB
+	maxLag time.Duration
+
+	available atomic.Bool
+	lag       atomic.Int64
+	checks    atomic.Int64
+	failures  atomic.Int64
+
+	// 状態が変わったときだけログを出す
+	mutex  sync.Mutex
+	reason string
+}
+
+// 遅延がmaxLagを超えたレプリカは使わない（0以下の場合は遅延を確認しない）
+func NewReplicaMonitor(db *sqlx.DB, maxLag time.Duration) *ReplicaMonitor {
+	return &ReplicaMonitor{db: db, maxLag: maxLag}
+}
+
+// レプリカから読んでよいか
+func (m *ReplicaMonitor) Available() bool {
+	return m.available.Load()
+}
+
+// 最後に確認したレプリケーションの遅延
+func (m *ReplicaMonitor) Lag() time.Duration {
+	return time.Duration(m.lag.Load())
+}
+
+// これまでの確認の回数と、そのうち使えないと判定した回数
+func (m *ReplicaMonitor) Checks() (checks, failures int64) {
+	return m.checks.Load(), m.failures.Load()
+}
+
+// レプリカへのクエリのエラーが接続断などによるものであれば、次の確認まで使わないようにする
+// 呼び出し元はtrueの場合にプライマリで実行し直せる
+func (m *ReplicaMonitor) Observe(err error) bool {
+	if !IsFailoverError(err) {
+		return false
+	}
+	m.setUnavailable(fmt.Sprintf("query failed: %v", err))
+	return true
+}
+
+// レプリカに接続でき、遅延がmaxLag以内か確認する（timeoutで打ち切る）
+// 使えない場合はその理由を返す
+func (m *ReplicaMonitor) Check(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	m.checks.Add(1)
+
+	lag, err := m.replicationLag(ctx)
+	if err == nil && m.maxLag > 0 && lag > m.maxLag {
+		err = fmt.Errorf("replication lag %s exceeds %s", lag, m.maxLag)
+	}
+	m.lag.Store(int64(lag))
+	if err != nil {
+		m.setUnavailable(err.Error())
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.available.Swap(true) {
+		log.Printf("[db] read replica available (lag %s)", lag)
+	}
+	m.reason = ""
+	return nil
+}
+
+// ctxがキャンセルされるまで、interval毎にレプリカの状態を確認する（起動直後にも確認する）
+// 確認の失敗は状態が変わったときにのみログに出すため、ここでは出さない
+// 確認にinterval以上かかるレプリカは使えないものとみなす
+func (m *ReplicaMonitor) Run(ctx context.Context, interval time.Duration) {
+	m.Check(ctx, interval)
+	task.Loop(ctx, "ReadReplica", interval, func(ctx context.Context) error {
+		m.Check(ctx, interval)
+		return nil
+	})
+}
+
+func (m *ReplicaMonitor) setUnavailable(reason string) {
+	m.failures.Add(1)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// 使えない状態が続く間、同じ理由は繰り返し出さない
+	if m.available.Swap(false) || m.reason != reason {
+		log.Printf("[db] read replica unavailable, reading from primary: %s", reason)
+	}
+	m.reason = reason
+}
+
+// レプリケーションの遅延を返す
+// レプリケーションの設定がないサーバー（プロキシ経由でプライマリに接続している場合など）は遅延0とみなす
+func (m *ReplicaMonitor) replicationLag(ctx context.Context) (time.Duration, error) {
+	if err := m.db.PingContext(ctx); err != nil {
+		return 0, err
+	}
+	if m.maxLag <= 0 {
+		return 0, nil
+	}
+	status, err := m.status(ctx, "SHOW REPLICA STATUS")
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParse {
+		status, err = m.status(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil || status == nil {
+		return 0, err
+	}
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := status[column]
+		if !ok {
+			continue
+		}
+		// NULLはレプリケーションのスレッドが止まっている
+		if value == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.ParseInt(columnString(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", column, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replication lag is not reported")
+}
+
+// クエリの結果の最初の行を列名で返す（行がない場合はnil）
+func (m *ReplicaMonitor) status(ctx context.Context, query string) (map[string]interface{}, error) {
+	rows, err := m.db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	status := make(map[string]interface{})
+	if err := rows.MapScan(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// MapScanの値（ドライバーは文字列の列を[]byteで返す）を文字列にする
+func columnString(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
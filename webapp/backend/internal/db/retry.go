@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend/internal/task"
+)
+
+// 再試行キューが満杯で受け付けられない
+var ErrRetryQueueFull = errors.New("retry queue is full")
+
+type retryJob struct {
+	name       string
+	run        func(ctx context.Context) error
+	enqueuedAt time.Time
+	attempts   int
+}
+
+// フェイルオーバー中に失敗した書き込みを保持し、DBが復旧したら再実行する
+// 何度実行しても結果が変わらない（冪等な）書き込みだけを入れること
+type RetryQueue struct {
+	mutex   sync.Mutex
+	jobs    []retryJob
+	maxSize int
+	maxAge  time.Duration
+	timeout time.Duration
+}
+
+// maxAgeを過ぎても成功しなかった書き込みは破棄する
+func NewRetryQueue(maxSize int, maxAge time.Duration) *RetryQueue {
+	return &RetryQueue{maxSize: maxSize, maxAge: maxAge, timeout: 5 * time.Second}
+}
+
+func (q *RetryQueue) Enqueue(name string, run func(ctx context.Context) error) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.jobs) >= q.maxSize {
+		return ErrRetryQueueFull
+	}
+	q.jobs = append(q.jobs, retryJob{name: name, run: run, enqueuedAt: time.Now()})
+	return nil
+}
+
+func (q *RetryQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.jobs)
+}
+
+// ctxがキャンセルされるまで、interval毎にキューの書き込みを再実行する（呼び出し元をブロックする）
+func (q *RetryQueue) Run(ctx context.Context, interval time.Duration) {
+	task.Loop(ctx, "RetryQueue", interval, q.Flush)
+}
+
+// キューの書き込みを先頭から再実行する
+// フェイルオーバーが続いている場合は残りを次回に回す
+func (q *RetryQueue) Flush(ctx context.Context) error {
+	q.mutex.Lock()
+	jobs := q.jobs
+	q.jobs = nil
+	q.mutex.Unlock()
+
+	var pending []retryJob
+	var errs []error
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			pending = append(pending, jobs[i:]...)
+			break
+		}
+		job.attempts++
+		err := q.runJob(ctx, job)
+		if err == nil {
+			continue
+		}
+		if !IsFailoverError(err) {
+			errs = append(errs, fmt.Errorf("%s: dropped after %d attempts: %w", job.name, job.attempts, err))
+			continue
+		}
+		if time.Since(job.enqueuedAt) > q.maxAge {
+			errs = append(errs, fmt.Errorf("%s: expired after %d attempts: %w", job.name, job.attempts, err))
+			continue
+		}
+		// まだ復旧していないため、残りも実行せずに次回に回す
+		pending = append(pending, job)
+		pending = append(pending, jobs[i+1:]...)
+		break
+	}
+
+	if len(pending) > 0 {
+		q.mutex.Lock()
+		q.jobs = append(pending, q.jobs...)
+		q.mutex.Unlock()
+	}
+	if len(errs) > 0 {
+		log.Printf("[RetryQueue] %d writes were not applied", len(errs))
+	}
+	return errors.Join(errs...)
+}
+
+func (q *RetryQueue) runJob(ctx context.Context, job retryJob) error {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+	return job.run(ctx)
+}
@@ -0,0 +1,113 @@
+// エンティティの変更をキャッシュ層に通知するためのプロセス内イベントバス
+package events
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+type Topic string
+
+const (
+	// 商品が作成・更新・削除された（IDsは商品ID）
+	ProductChanged Topic = "product_changed"
+	// 注文が作成された（IDsは注文ID、UserIDは注文したユーザー）
+	OrderCreated Topic = "order_created"
+	// 注文のステータスが変わった（IDsは注文ID、Statusは変更後のステータス）
+	OrderStatusChanged Topic = "order_status_changed"
+	// 全注文が削除され、テストデータに入れ替わった
+	OrdersReset Topic = "orders_reset"
+)
+
+type Event struct {
+	Topic  Topic
+	IDs    []int64
+	Status string
+	UserID int
+}
+
+type Publisher interface {
+	Publish(ev Event)
+}
+
+// 購読者にイベントを同期的に配送する
+// 購読者はブロックしない処理（キャッシュの削除など）のみを行うこと
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[Topic][]func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[Topic][]func(Event))}
+}
+
+func (b *Bus) Subscribe(topic Topic, fn func(Event)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers[topic] = append(b.subscribers[topic], fn)
+}
+
+func (b *Bus) Publish(ev Event) {
+	b.mutex.RLock()
+	subs := b.subscribers[ev.Topic]
+	b.mutex.RUnlock()
+	for _, fn := range subs {
+		deliver(fn, ev)
+	}
+}
+
+// 購読者のpanicで発行元の処理を失敗させない
+func deliver(fn func(Event), ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[events] subscriber panic on %s: %v\n%s", ev.Topic, r, debug.Stack())
+		}
+	}()
+	fn(ev)
+}
+
+// トランザクション中のイベントを溜めておき、コミット後にまとめて発行する
+// （コミット前に無効化すると、その間の読み込みで古い値が再びキャッシュされるため）
+type Buffer struct {
+	next   Publisher
+	mutex  sync.Mutex
+	events []Event
+}
+
+func NewBuffer(next Publisher) *Buffer {
+	return &Buffer{next: next}
+}
+
+func (b *Buffer) Publish(ev Event) {
+	b.mutex.Lock()
+	b.events = append(b.events, ev)
+	b.mutex.Unlock()
+}
+
+// topicのイベントが溜まっているか
+func (b *Buffer) Contains(topic Topic) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, ev := range b.events {
+		if ev.Topic == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Buffer) Flush() {
+	b.mutex.Lock()
+	evs := b.events
+	b.events = nil
+	b.mutex.Unlock()
+	for _, ev := range evs {
+		b.next.Publish(ev)
+	}
+}
+
+// 何もしないPublisher
+type Discard struct{}
+
+func (Discard) Publish(Event) {}
@@ -0,0 +1,123 @@
+package geocode
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"backend/internal/telemetry"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrAddressNotFound = errors.New("address not found")
+
+// 住所を座標に変換するためのインターフェース
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (model.Coordinates, error)
+}
+
+// 常に座標が見つからない扱いにする実装
+// ジオコーディングAPIが設定されていない環境で使用する
+type NoopGeocoder struct{}
+
+func (NoopGeocoder) Geocode(ctx context.Context, address string) (model.Coordinates, error) {
+	return model.Coordinates{}, ErrAddressNotFound
+}
+
+// Nominatim互換のHTTP APIを利用する実装
+// GET {baseURL}?q={address}&format=json のレスポンス [{"lat": "...", "lon": "..."}] を解釈する
+type HTTPGeocoder struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPGeocoder(baseURL string) *HTTPGeocoder {
+	return &HTTPGeocoder{
+		baseURL: baseURL,
+		client:  telemetry.NewHTTPClient(3 * time.Second),
+	}
+}
+
+func (g *HTTPGeocoder) Geocode(ctx context.Context, address string) (model.Coordinates, error) {
+	q := url.Values{}
+	q.Set("q", address)
+	q.Set("format", "json")
+	q.Set("limit", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	req.Header.Set("User-Agent", "backend-geocoder/1.0")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return model.Coordinates{}, fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return model.Coordinates{}, err
+	}
+	if len(results) == 0 {
+		return model.Coordinates{}, ErrAddressNotFound
+	}
+
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	lng, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	return model.Coordinates{Latitude: lat, Longitude: lng}, nil
+}
+
+// 別のGeocoderをラップし、結果をメモリ上にキャッシュする
+// 同じ住所への注文が繰り返されても外部APIを叩かないようにする
+// 件数がmaxEntriesを超える場合は参照が古いものから破棄する
+type CachingGeocoder struct {
+	next  Geocoder
+	cache *cache.Sharded[model.Coordinates]
+}
+
+func NewCachingGeocoder(next Geocoder, ttl time.Duration, maxEntries int) *CachingGeocoder {
+	// 1件を大きさ1として数え、容量を件数の上限にする
+	return &CachingGeocoder{
+		next:  next,
+		cache: cache.NewShardedWith[model.Coordinates](int64(maxEntries), ttl, cache.Options{Policy: cache.PolicyLRU}),
+	}
+}
+
+func (g *CachingGeocoder) Geocode(ctx context.Context, address string) (model.Coordinates, error) {
+	key := normalizeAddress(address)
+	if coords, ok := g.cache.Get(key); ok {
+		return coords, nil
+	}
+
+	coords, err := g.next.Geocode(ctx, address)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	g.cache.Set(key, coords, 1)
+	return coords, nil
+}
+
+func normalizeAddress(address string) string {
+	return strings.Join(strings.Fields(address), " ")
+}
@@ -0,0 +1,172 @@
+// 配送ロボット向けAPIのgRPC実装（HTTPのハンドラーと同じRobotServiceを使う）
+package grpcapi
+
+import (
+	"backend/internal/budget"
+	"backend/internal/model"
+	"backend/internal/robotpb"
+	"backend/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 1回のBulkUpdateOrderStatusで受け付ける更新の上限
+const maxBulkStatusUpdates = 1000
+
+type RobotServer struct {
+	robotpb.UnimplementedRobotServiceServer
+	robotSvc *service.RobotService
+}
+
+func NewRobotServer(robotSvc *service.RobotService) *RobotServer {
+	return &RobotServer{robotSvc: robotSvc}
+}
+
+// 配送計画を取得
+func (s *RobotServer) GetDeliveryPlan(ctx context.Context, req *robotpb.GetDeliveryPlanRequest) (*robotpb.DeliveryPlan, error) {
+	robotID := req.GetRobotId()
+	if robotID == "" {
+		robotID = "robot-001"
+	}
+	if len(req.GetExclude()) > service.MaxPlanExclusions {
+		return nil, status.Errorf(codes.InvalidArgument, "exclude must have at most %d order IDs", service.MaxPlanExclusions)
+	}
+	for _, id := range req.GetExclude() {
+		if id <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "exclude must contain positive order IDs")
+		}
+	}
+
+	plan, err := s.robotSvc.GenerateDeliveryPlan(ctx, robotID, int(req.GetCapacity()), req.GetExclude(), req.GetDebug())
+	if err != nil {
+		return nil, errorStatus(ctx, "GetDeliveryPlan", err)
+	}
+	return deliveryPlanToProto(plan), nil
+}
+
+// 配送計画の受領を確認
+func (s *RobotServer) AcknowledgePlan(ctx context.Context, req *robotpb.AcknowledgePlanRequest) (*robotpb.AcknowledgePlanResponse, error) {
+	if req.GetRobotId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "robot_id is required")
+	}
+	n, err := s.robotSvc.AcknowledgePlan(ctx, req.GetRobotId())
+	if err != nil {
+		return nil, errorStatus(ctx, "AcknowledgePlan", err)
+	}
+	return &robotpb.AcknowledgePlanResponse{RobotId: req.GetRobotId(), Acknowledged: int32(n)}, nil
+}
+
+// 注文ステータスを更新
+func (s *RobotServer) UpdateOrderStatus(ctx context.Context, req *robotpb.UpdateOrderStatusRequest) (*robotpb.UpdateOrderStatusResponse, error) {
+	queued, err := s.updateOrderStatus(ctx, req)
+	if err != nil {
+		return nil, errorStatus(ctx, "UpdateOrderStatus", err)
+	}
+	return &robotpb.UpdateOrderStatusResponse{Queued: queued}, nil
+}
+
+// 複数の注文ステータスを順に更新し、注文ごとの結果を返す
+func (s *RobotServer) BulkUpdateOrderStatus(ctx context.Context, req *robotpb.BulkUpdateOrderStatusRequest) (*robotpb.BulkUpdateOrderStatusResponse, error) {
+	if len(req.GetUpdates()) > maxBulkStatusUpdates {
+		return nil, status.Errorf(codes.InvalidArgument, "updates must have at most %d entries", maxBulkStatusUpdates)
+	}
+	results := make([]*robotpb.OrderStatusResult, len(req.GetUpdates()))
+	for i, update := range req.GetUpdates() {
+		// 呼び出し元が諦めた後の更新は行わない（残りは失敗として返さずRPC自体をエラーにする）
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		result := &robotpb.OrderStatusResult{OrderId: update.GetOrderId()}
+		queued, err := s.updateOrderStatus(ctx, update)
+		if err != nil {
+			st := errorStatus(ctx, "BulkUpdateOrderStatus", err)
+			result.Code = int32(status.Code(st))
+			result.Message = status.Convert(st).Message()
+		}
+		result.Queued = queued
+		results[i] = result
+	}
+	return &robotpb.BulkUpdateOrderStatusResponse{Results: results}, nil
+}
+
+// DBの復旧後に反映するため受け付けただけの場合、queuedはtrue
+func (s *RobotServer) updateOrderStatus(ctx context.Context, req *robotpb.UpdateOrderStatusRequest) (queued bool, err error) {
+	if req.GetOrderId() <= 0 || req.GetNewStatus() == "" {
+		return false, errInvalidStatusUpdate
+	}
+	err = s.robotSvc.UpdateOrderStatus(ctx, req.GetOrderId(), req.GetNewStatus(), req.GetClaimToken())
+	if errors.Is(err, service.ErrWriteDeferred) {
+		return true, nil
+	}
+	return false, err
+}
+
+var errInvalidStatusUpdate = errors.New("order_id and new_status are required")
+
+// サービスのエラーをgRPCのステータスに変換する（想定外のエラーはログに出してInternalにする）
+func errorStatus(ctx context.Context, method string, err error) error {
+	switch {
+	case errors.Is(err, errInvalidStatusUpdate):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidCapacity), errors.Is(err, service.ErrInvalidOrderStatus):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrOrderCancelled):
+		return status.Error(codes.FailedPrecondition, "order has been cancelled")
+	case errors.Is(err, service.ErrInvalidClaim):
+		return status.Error(codes.PermissionDenied, "order is not claimed by this robot")
+	case errors.Is(err, service.ErrOrderNotFound):
+		return status.Error(codes.NotFound, "order not found")
+	case errors.Is(err, budget.ErrExhausted), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.Unavailable, "request deadline too short to complete")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	slog.ErrorContext(ctx, "gRPC request failed", "method", method, "err", err)
+	return status.Error(codes.Internal, fmt.Sprintf("%s failed", method))
+}
+
+func deliveryPlanToProto(plan *model.DeliveryPlan) *robotpb.DeliveryPlan {
+	pb := &robotpb.DeliveryPlan{
+		RobotId:                plan.RobotID,
+		TotalWeight:            int32(plan.TotalWeight),
+		TotalValue:             int32(plan.TotalValue),
+		Orders:                 make([]*robotpb.PlannedOrder, len(plan.Orders)),
+		EstimatedTravelSeconds: int32(plan.EstimatedTravelSeconds),
+		Warnings:               plan.Warnings,
+		ClaimToken:             plan.ClaimToken,
+	}
+	for i, o := range plan.Orders {
+		order := &robotpb.PlannedOrder{
+			OrderId:     o.OrderID,
+			ProductId:   int32(o.ProductID),
+			ProductName: o.ProductName,
+			Weight:      int32(o.Weight),
+			Value:       int32(o.Value),
+		}
+		if o.Address != nil {
+			order.Address = *o.Address
+		}
+		if o.Latitude != nil && o.Longitude != nil {
+			order.Location = &robotpb.Coordinates{Latitude: *o.Latitude, Longitude: *o.Longitude}
+		}
+		pb.Orders[i] = order
+	}
+	if d := plan.Diagnostics; d != nil {
+		pb.Diagnostics = &robotpb.PlanDiagnostics{
+			Candidates:          int32(d.Candidates),
+			Excluded:            int32(d.Excluded),
+			Algorithm:           d.Algorithm,
+			RuntimeMs:           d.RuntimeMs,
+			MemoryEstimateBytes: d.MemoryEstimateBytes,
+			Optimal:             d.Optimal,
+			UpperBound:          int32(d.UpperBound),
+			ReusedRows:          int32(d.ReusedRows),
+		}
+	}
+	return pb
+}
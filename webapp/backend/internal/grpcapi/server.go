@@ -0,0 +1,70 @@
+package grpcapi
+
+import (
+	"backend/internal/logging"
+	"backend/internal/robotpb"
+	"backend/internal/service"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ロボット向けのgRPCサーバー
+// HTTPのX-API-KEYヘッダーと同じキーをメタデータx-api-keyで受け取って認証する
+// readOnlyがtrueを返す間は、HTTPと同じくすべてのRPC（いずれも書き込みを伴う）をUnavailableで拒否する
+func NewServer(robotSvc *service.RobotService, robotAPIKey string, readOnly func() bool) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(requestIDInterceptor, recoverInterceptor, apiKeyInterceptor(robotAPIKey), readOnlyInterceptor(readOnly)))
+	robotpb.RegisterRobotServiceServer(server, NewRobotServer(robotSvc))
+	return server
+}
+
+func apiKeyInterceptor(validAPIKey string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get("x-api-key")
+		if len(keys) != 1 || subtle.ConstantTimeCompare([]byte(keys[0]), []byte(validAPIKey)) != 1 {
+			return nil, status.Error(codes.PermissionDenied, "invalid robot API key")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func readOnlyInterceptor(readOnly func() bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if readOnly() {
+			return nil, status.Error(codes.Unavailable, "service is in read-only mode")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// HTTPのX-Request-IDと同じく、メタデータx-request-idのIDを引き継ぐか新しく作ってctxに入れ、ヘッダーで返す
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if ids := md.Get("x-request-id"); len(ids) == 1 && logging.ValidRequestID(ids[0]) {
+		id = ids[0]
+	} else {
+		id = logging.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	return handler(logging.WithRequestID(ctx, id), req)
+}
+
+// ハンドラーのpanicでプロセスを落とさず、Internalとして返す
+func recoverInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "gRPC handler panicked", "method", info.FullMethod, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"backend/internal/i18n"
+	"backend/internal/metrics"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminHandler struct {
+	AdminSvc     *service.AdminService
+	DashboardSvc *service.DashboardService
+	RobotSvc     *service.RobotService
+	ReadOnlyMode *middleware.ReadOnlyMode
+}
+
+func NewAdminHandler(adminSvc *service.AdminService, dashboardSvc *service.DashboardService, robotSvc *service.RobotService, readOnly *middleware.ReadOnlyMode) *AdminHandler {
+	return &AdminHandler{AdminSvc: adminSvc, DashboardSvc: dashboardSvc, RobotSvc: robotSvc, ReadOnlyMode: readOnly}
+}
+
+// 管理者向け統計情報を取得
+func (h *AdminHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.AdminSvc.GetStats(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch admin stats", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchStatsFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// 稼働状況のダッシュボードを取得
+func (h *AdminHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	dashboard, err := h.DashboardSvc.GetDashboard(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch admin dashboard", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchDashboardFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+// 商品の価値・重量を変更
+func (h *AdminHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidProductID)
+		return
+	}
+
+	var req model.ProductUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	product, err := h.AdminSvc.UpdateProduct(r.Context(), productID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProductNotFound):
+			i18n.Error(w, r, http.StatusNotFound, i18n.ProductNotFound)
+		case errors.Is(err, service.ErrInvalidProduct):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidProductValues)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to update product", "product_id", productID, "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.UpdateProductFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+// 商品の価値・重量の変更履歴を取得
+func (h *AdminHandler) ProductHistory(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidProductID)
+		return
+	}
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidLimit)
+			return
+		}
+	}
+
+	history, err := h.AdminSvc.ProductHistory(r.Context(), productID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch product history", "product_id", productID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchProductHistoryFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// 在庫数が発注点を下回った商品を取得
+func (h *AdminHandler) LowStock(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := 1, 20
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"page", &page}, {"page_size", &pageSize}} {
+		s := r.URL.Query().Get(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidPagination)
+			return
+		}
+		*p.value = v
+	}
+
+	products, err := h.AdminSvc.LowStockProducts(r.Context(), page, pageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch low-stock products", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchLowStockFailed)
+		return
+	}
+	metrics.RecordItems(r.Context(), "rows", len(products.Data))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+// 価値・重量が不正な商品を取得
+func (h *AdminHandler) InvalidProducts(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidLimit)
+			return
+		}
+	}
+
+	products, err := h.AdminSvc.InvalidProducts(r.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch invalid products", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchProductsFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+// 商品一覧キャッシュを無効化
+func (h *AdminHandler) InvalidateProductCache(w http.ResponseWriter, r *http.Request) {
+	var req model.ProductCacheInvalidateRequest
+	// 本文なしの場合は全件を無効化する
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.AdminSvc.InvalidateProductCache(req))
+}
+
+// よくアクセスされる画像を取得
+func (h *AdminHandler) HotImages(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidLimit)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.AdminSvc.HotImages(limit))
+}
+
+// 商品の一括補正の最大サイズ
+const maxRecalibrationBytes = 16 << 20
+
+// 商品の価値・重量を一括で補正する
+// Content-Typeがtext/csvの場合はCSV、それ以外はJSONで指定する
+// ?dry_run=trueの場合は更新せずに配送待ち注文への影響を返す（&capacity=で積載量を指定すると計画した価値も比べる）
+func (h *AdminHandler) RecalibrateProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidDryRun)
+			return
+		}
+	}
+	capacity := 0
+	if v := query.Get("capacity"); v != "" {
+		var err error
+		if capacity, err = strconv.Atoi(v); err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.CapacityNotInteger)
+			return
+		}
+		if capacity <= 0 {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidCapacity, "must be positive")
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxRecalibrationBytes)
+	var report *model.ProductRecalibrationReport
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		report, err = h.AdminSvc.RecalibrateProductsCSV(r.Context(), body, dryRun, capacity)
+	} else {
+		var req model.ProductRecalibrationRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				i18n.Error(w, r, http.StatusRequestEntityTooLarge, i18n.RecalibrationTooLarge, tooLarge.Limit)
+				return
+			}
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+			return
+		}
+		report, err = h.AdminSvc.RecalibrateProducts(r.Context(), req, dryRun, capacity)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			i18n.Error(w, r, http.StatusRequestEntityTooLarge, i18n.RecalibrationTooLarge, tooLarge.Limit)
+		case errors.Is(err, service.ErrInvalidRecalibration):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRecalibration, err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "Failed to recalibrate products", "dry_run", dryRun, "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.RecalibrateFailed)
+		}
+		return
+	}
+	if !dryRun {
+		slog.InfoContext(r.Context(), "Recalibrated products", "updated", report.Updated, "unchanged", report.Unchanged, "invalid", report.Invalid)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 注文のCSVの最大サイズ
+const maxOrderImportBytes = 64 << 20
+
+// 旧システムの注文をCSVから取り込む
+func (h *AdminHandler) ImportOrders(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxOrderImportBytes)
+	report, err := h.AdminSvc.ImportOrders(r.Context(), body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			i18n.Error(w, r, http.StatusRequestEntityTooLarge, i18n.ImportTooLarge, tooLarge.Limit)
+		case errors.Is(err, service.ErrInvalidImportCSV):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidImportCSV, err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "Failed to import orders", "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.ImportOrdersFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 不整合な状態の注文を修復する
+func (h *AdminHandler) RepairOrderStatuses(w http.ResponseWriter, r *http.Request) {
+	report, err := h.AdminSvc.RepairOrderStatuses(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to repair order statuses", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.RepairStatusFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 配送中のまま期限を過ぎた注文を配送待ちに戻す
+// ?older_than=30m のように期限を指定できる（省略時はROBOT_DELIVERY_TIMEOUT）
+func (h *AdminHandler) RequeueStaleDeliveries(w http.ResponseWriter, r *http.Request) {
+	olderThan := h.RobotSvc.DeliveryTimeout()
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		var err error
+		olderThan, err = time.ParseDuration(v)
+		if err != nil {
+			olderThan = 0
+		}
+	}
+	if olderThan <= 0 {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOlderThan)
+		return
+	}
+
+	requeued, err := h.RobotSvc.RequeueStaleDeliveries(r.Context(), olderThan)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to requeue stale deliveries", "requeued", requeued, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.RequeueStaleFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.StaleDeliveryRequeueResponse{Requeued: requeued, OlderThanSeconds: int(olderThan / time.Second)})
+}
+
+// 読み取り専用モードの状態を取得
+func (h *AdminHandler) ReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.ReadOnlyMode.State())
+}
+
+// 読み取り専用モードを切り替える（このインスタンスのみ）
+func (h *AdminHandler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req model.ReadOnlyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+	state := h.ReadOnlyMode.Set(req.Enabled, req.Reason)
+	slog.WarnContext(r.Context(), "Read-only mode changed", "enabled", state.Enabled, "reason", state.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// 頻出座標間の移動時間を一括で事前計算する
+func (h *AdminHandler) PrecomputeDistances(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidLimit)
+			return
+		}
+	}
+
+	resp, err := h.AdminSvc.PrecomputeDistances(r.Context(), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to precompute distances", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.PrecomputeFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 注文・セッションを削除し、テストデータの注文に入れ替える（負荷試験用）
+func (h *AdminHandler) ResetTestdata(w http.ResponseWriter, r *http.Request) {
+	var req model.TestdataResetRequest
+	// 本文なしの場合はシード0・既定の注文数で作る
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	resp, err := h.AdminSvc.ResetTestdata(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTestdataRequest):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidTestdataRequest, service.MaxFixtureOrders)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to reset testdata", "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.ResetTestdataFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"net/http"
 
+	"backend/internal/i18n"
+	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
 )
@@ -22,16 +24,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	var req model.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
 		return
 	}
 
-	sessionID, expiresAt, err := h.AuthSvc.Login(r.Context(), req.UserName, req.Password)
+	sessionID, expiresAt, err := h.AuthSvc.Login(r.Context(), req.UserName, req.Password, middleware.ClientFingerprint(r))
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrInvalidPassword) {
-			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
+			i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidCredentials)
 		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.InternalError)
 		}
 		return
 	}
@@ -1,37 +1,109 @@
 package handler
 
 import (
+	"backend/internal/i18n"
+	"backend/internal/metrics"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
+// ストリームの接続を保つためにコメント行を送る間隔
+const streamHeartbeatInterval = 15 * time.Second
+
 type OrderHandler struct {
-	OrderSvc *service.OrderService
+	OrderSvc      *service.OrderService
+	PreferenceSvc *service.PreferenceService
+	StatusStream  *service.OrderStatusStream
 }
 
-func NewOrderHandler(svc *service.OrderService) *OrderHandler {
-	return &OrderHandler{OrderSvc: svc}
+func NewOrderHandler(svc *service.OrderService, preferenceSvc *service.PreferenceService, statusStream *service.OrderStatusStream) *OrderHandler {
+	return &OrderHandler{OrderSvc: svc, PreferenceSvc: preferenceSvc, StatusStream: statusStream}
+}
+
+// 自分の注文のステータス変更をServer-Sent Eventsで送り続ける
+// 接続前の変更は送らないため、接続後に注文一覧を取得し直すこと
+func (h *OrderHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	// ?watched=1 の場合はウォッチ中の注文の変更のみを送る
+	subscribe := h.StatusStream.Subscribe
+	if r.URL.Query().Get("watched") == "1" {
+		subscribe = h.StatusStream.SubscribeWatched
+	}
+	changes, unsubscribe := subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// リバースプロキシにバッファリングさせない
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if _, err := w.Write([]byte(": connected\n\n")); err != nil || rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = w.Write([]byte(": heartbeat\n\n"))
+		case change, ok := <-changes:
+			// サーバーの停止中
+			if !ok {
+				return
+			}
+			var data []byte
+			if data, err = json.Marshal(change); err == nil {
+				_, err = fmt.Fprintf(w, "event: order_status\nid: %d\ndata: %s\n\n", change.OrderID, data)
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
 }
 
 // 注文履歴一覧を取得
 func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found", http.StatusInternalServerError)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
 		return
 	}
 
 	var req model.ListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
 		return
 	}
 
 	// デフォルト値の設定
+	// 省略された値はユーザーの設定で補い、それもなければ既定値を使う
+	h.PreferenceSvc.ApplyDefaults(r.Context(), userID, &req)
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -48,22 +120,358 @@ func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 		req.Type = "partial"
 	}
 	req.Offset = (req.Page - 1) * req.PageSize
+	// cursorを指定した場合は、そのcursorを作ったときの並び順で続きを返す
+	if req.Cursor != "" {
+		after, err := service.DecodeOrderCursor(req.Cursor)
+		if err != nil {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidCursor)
+			return
+		}
+		req.After = after
+		req.SortField = after.SortField
+		req.SortOrder = after.SortOrder
+		req.Pagination = model.PaginationCursor
+	}
+	for _, f := range req.Fields {
+		if !slices.Contains(model.OrderListFields, f) {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.UnknownField, f)
+			return
+		}
+	}
 
 	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
+	if writeBudgetExhausted(w, r, err) {
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to fetch orders for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch orders", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to fetch orders", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchOrdersFailed)
 		return
 	}
+	metrics.RecordItems(r.Context(), "rows", len(orders))
 
+	var data interface{} = orders
+	if len(req.Fields) > 0 {
+		data = selectOrderFields(orders, req.Fields)
+	}
 	resp := struct {
-		Data  []model.Order `json:"data"`
-		Total int           `json:"total"`
+		Data  interface{} `json:"data"`
+		Total int         `json:"total"`
+		// キーセットページングの場合のみ（最後のページでは省略）
+		NextCursor string `json:"next_cursor,omitempty"`
 	}{
-		Data:  orders,
+		Data:  data,
 		Total: total,
 	}
+	if req.Pagination == model.PaginationCursor {
+		resp.NextCursor = service.NextOrderCursor(req, orders)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// 注文履歴をすべてCSVで取得（?search=&type=&sort_field=&sort_order=、注文一覧と同じ検索・並び替え）
+// 1行ずつ書き出すため、書き出し中に失敗した場合は接続を切って途中までのCSVであることを知らせる
+func (h *OrderHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	req := model.ListRequest{
+		Search:    q.Get("search"),
+		Type:      q.Get("type"),
+		SortField: q.Get("sort_field"),
+		SortOrder: q.Get("sort_order"),
+	}
+	h.PreferenceSvc.ApplyDefaults(r.Context(), userID, &req)
+	if req.SortField == "" {
+		req.SortField = "order_id"
+	}
+	if req.SortOrder == "" {
+		req.SortOrder = "desc"
+	}
+	if req.Type != "" && req.Type != "partial" && req.Type != "prefix" {
+		req.Type = "partial"
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.csv"`)
+	w.Header().Set("Cache-Control", "no-store")
+	rows, err := h.OrderSvc.ExportOrders(r.Context(), userID, req, w)
+	if errors.Is(err, service.ErrExportInterrupted) {
+		if !errors.Is(err, context.Canceled) && r.Context().Err() == nil {
+			slog.ErrorContext(r.Context(), "Failed to export orders", "user_id", userID, "rows", rows, "err", err)
+		}
+		// ステータスは送信済みのため、接続を切ってクライアントに不完全なCSVであることを知らせる
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to export orders", "user_id", userID, "err", err)
+		w.Header().Del("Content-Disposition")
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.ExportOrdersFailed)
+		return
+	}
+	metrics.RecordItems(r.Context(), "rows", rows)
+}
+
+// 自分が受取人のギフト一覧を取得（?page=&page_size=、省略時は1ページ目・20件）
+func (h *OrderHandler) ReceivedGifts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	page, pageSize := 1, 20
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"page", &page}, {"page_size", &pageSize}} {
+		s := r.URL.Query().Get(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidPagination)
+			return
+		}
+		*p.value = v
+	}
+
+	gifts, err := h.OrderSvc.ReceivedGifts(r.Context(), userID, page, pageSize)
+	if writeBudgetExhausted(w, r, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch received gifts", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchGiftsFailed)
+		return
+	}
+	metrics.RecordItems(r.Context(), "rows", len(gifts.Data))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gifts)
+}
+
+// 指定されたフィールドのみを含むレスポンスに変換する
+func selectOrderFields(orders []model.Order, fields []string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(orders))
+	for i, o := range orders {
+		row := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			switch f {
+			case "order_id":
+				row[f] = o.OrderID
+			case "product_id":
+				row[f] = o.ProductID
+			case "product_name":
+				row[f] = o.ProductName
+			case "shipped_status":
+				row[f] = o.ShippedStatus
+			case "created_at":
+				row[f] = o.CreatedAt
+			case "arrived_at":
+				row[f] = o.ArrivedAt
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// 注文詳細を取得
+func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
+		return
+	}
+
+	order, err := h.OrderSvc.GetOrder(r.Context(), userID, orderID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to fetch order", "order_id", orderID, "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchOrderFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// 配送待ちの注文をキャンセル
+func (h *OrderHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
+		return
+	}
+
+	resp, err := h.OrderSvc.CancelOrder(r.Context(), userID, orderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+		case errors.Is(err, service.ErrOrderNotCancellable):
+			i18n.Error(w, r, http.StatusConflict, i18n.OrderNotCancellable)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to cancel order", "order_id", orderID, "user_id", userID, "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.CancelOrderFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 注文をウォッチし、ステータスの変更を /api/v1/orders/stream?watched=1 で受け取れるようにする
+// 配送完了・キャンセルになると自動で解除される
+func (h *OrderHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
+		return
+	}
+
+	order, err := h.OrderSvc.GetOrder(r.Context(), userID, orderID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to fetch order", "order_id", orderID, "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchOrderFailed)
+		return
+	}
+
+	watches, err := h.StatusStream.Watch(userID, order)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotWatchable):
+			i18n.Error(w, r, http.StatusConflict, i18n.OrderNotWatchable)
+		case errors.Is(err, service.ErrTooManyWatches):
+			i18n.Error(w, r, http.StatusConflict, i18n.TooManyWatches, h.StatusStream.WatchLimit())
+		default:
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.InternalError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.OrderWatchResponse{OrderID: orderID, Watching: true, Watches: watches})
+}
+
+// 注文のウォッチを解除
+func (h *OrderHandler) Unwatch(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
+		return
+	}
+
+	watches := h.StatusStream.Unwatch(userID, orderID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.OrderWatchResponse{OrderID: orderID, Watching: false, Watches: watches})
+}
+
+// 注文の請求内容を取得
+func (h *OrderHandler) Invoice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
+		return
+	}
+
+	invoice, err := h.OrderSvc.Invoice(r.Context(), userID, orderID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to build invoice", "order_id", orderID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.BuildInvoiceFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoice)
+}
+
+// 注文の集計（件数・金額・送料・税額）を取得
+// 商品ごと・ステータスごとの注文数を取得
+func (h *OrderHandler) ByProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	counts, err := h.OrderSvc.CountByProduct(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to count orders by product", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.SummarizeOrdersFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Data []model.ProductOrderCounts `json:"data"`
+	}{Data: counts})
+}
+
+func (h *OrderHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	summary, err := h.OrderSvc.Summary(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to summarize orders", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.SummarizeOrdersFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"backend/internal/i18n"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+type PreferenceHandler struct {
+	PreferenceSvc *service.PreferenceService
+}
+
+func NewPreferenceHandler(svc *service.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{PreferenceSvc: svc}
+}
+
+// ログインユーザーの設定を取得
+func (h *PreferenceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	prefs, err := h.PreferenceSvc.Get(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch preferences", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchPreferencesFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// ログインユーザーの設定を更新（省略した項目は未設定に戻る）
+func (h *PreferenceHandler) Put(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotFound)
+		return
+	}
+
+	var req model.UserPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	prefs, err := h.PreferenceSvc.Update(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPreferences) {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidPreferences)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to update preferences", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UpdatePreferencesFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -1,39 +1,75 @@
 package handler
 
 import (
+	"backend/internal/budget"
+	"backend/internal/cache"
+	"backend/internal/i18n"
+	"backend/internal/imaging"
+	"backend/internal/metrics"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// おすすめ商品の件数（nの省略時と上限）
+const (
+	defaultFeaturedProducts = 10
+	maxFeaturedProducts     = 50
 )
 
+// 商品画像を配置するディレクトリ（画像APIのpathはここからの相対パス）
+const ImageDir = "/app/images"
+
+// 画像パスごとのアクセス回数（キャッシュサイズやプリロード対象の判断に使う）
+var imageAccess = metrics.Access("image")
+
 type ProductHandler struct {
-	ProductSvc *service.ProductService
+	ProductSvc    *service.ProductService
+	PreferenceSvc *service.PreferenceService
+	Images        *cache.ImageCache
+	// 画像取得のwに指定できるサムネイルの幅（空の場合はサムネイルを作らない）
+	ThumbnailWidths []int
+
+	// 同じサムネイルを同時に作らないよう、生成をまとめる
+	thumbnails singleflight.Group
 }
 
-func NewProductHandler(svc *service.ProductService) *ProductHandler {
-	return &ProductHandler{ProductSvc: svc}
+func NewProductHandler(svc *service.ProductService, preferenceSvc *service.PreferenceService, images *cache.ImageCache, thumbnailWidths []int) *ProductHandler {
+	return &ProductHandler{ProductSvc: svc, PreferenceSvc: preferenceSvc, Images: images, ThumbnailWidths: thumbnailWidths}
 }
 
 // 商品一覧を取得
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotInContext)
 		return
 	}
 
 	var req model.ListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
 		return
 	}
 
+	// 省略された値はユーザーの設定で補い、それもなければ既定値を使う
+	h.PreferenceSvc.ApplyDefaults(r.Context(), userID, &req)
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -47,76 +83,333 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 		req.SortOrder = "asc"
 	}
 	req.Offset = (req.Page - 1) * req.PageSize
+	// ?include=stats のようにクエリでも指定できる
+	if include := r.URL.Query().Get("include"); include != "" {
+		req.Include = append(req.Include, strings.Split(include, ",")...)
+	}
 
-	products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+	resp, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+	if writeBudgetExhausted(w, r, err) {
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to fetch products for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to fetch products", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchProductsFailed)
 		return
 	}
+	metrics.RecordItems(r.Context(), "rows", len(resp.Data))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// おすすめ商品を取得（?n=、よく注文される商品ほど選ばれやすい無作為抽出で、リクエストごとに変わる）
+func (h *ProductHandler) Featured(w http.ResponseWriter, r *http.Request) {
+	n := defaultFeaturedProducts
+	if s := r.URL.Query().Get("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > maxFeaturedProducts {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidFeaturedCount, maxFeaturedProducts)
+			return
+		}
+		n = v
+	}
 
-	resp := struct {
-		Data  []model.Product `json:"data"`
-		Total int             `json:"total"`
-	}{
-		Data:  products,
-		Total: total,
+	products, err := h.ProductSvc.FeaturedProducts(r.Context(), n)
+	if errors.Is(err, service.ErrFeaturedNotReady) {
+		w.Header().Set("Retry-After", "5")
+		i18n.Error(w, r, http.StatusServiceUnavailable, i18n.FeaturedNotReady)
+		return
+	}
+	if writeBudgetExhausted(w, r, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to fetch featured products", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchProductsFailed)
+		return
 	}
+	metrics.RecordItems(r.Context(), "products", len(products))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		Data []model.Product `json:"data"`
+	}{Data: products})
 }
 
 // 注文を作成
 func (h *ProductHandler) CreateOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotInContext)
 		return
 	}
 
 	var req model.CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	var insertedOrderIDs []int64
+	var err error
+	if req.RecipientUserID != nil {
+		insertedOrderIDs, err = h.ProductSvc.CreateGiftOrders(r.Context(), userID, *req.RecipientUserID, req.Items, req.Address)
+	} else {
+		insertedOrderIDs, err = h.ProductSvc.CreateOrders(r.Context(), userID, req.Items, req.Address)
+	}
+	if err != nil {
+		if writeOrderLimitError(w, r, err) || writeProductUnavailableError(w, r, err) || writeInsufficientStockError(w, r, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidGiftRecipient) {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidGiftRecipient)
+			return
+		}
+		if errors.Is(err, service.ErrProductNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.ProductNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to create orders", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.CreateOrderFailed)
+		return
+	}
+	metrics.RecordItems(r.Context(), "items", len(req.Items))
+	metrics.RecordItems(r.Context(), "orders", len(insertedOrderIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createOrdersResponse(insertedOrderIDs))
+}
+
+func createOrdersResponse(orderIDs []int64) model.CreateOrdersResponse {
+	resp := model.CreateOrdersResponse{Message: "Orders created successfully"}
+	if orderIDs != nil {
+		resp.OrderIDs = make([]string, len(orderIDs))
+		for i, id := range orderIDs {
+			resp.OrderIDs[i] = strconv.FormatInt(id, 10)
+		}
+	}
+	return resp
+}
+
+// 数量の上限超過であれば詳細をJSONで返し、trueを返す
+func writeOrderLimitError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var limitErr *service.OrderLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	status := http.StatusUnprocessableEntity
+	if limitErr.Limit == service.OrderLimitPerUserHour {
+		status = http.StatusTooManyRequests
+	}
+	lang := i18n.Language(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Error-Code", string(i18n.OrderLimitExceeded))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.OrderLimitResponse{
+		Error:     i18n.Message(lang, i18n.OrderLimitExceeded),
+		Limit:     limitErr.Limit,
+		Max:       limitErr.Max,
+		Requested: limitErr.Requested,
+	})
+	return true
+}
+
+// リクエストの残り時間が足りずに中止した場合は503を返し、trueを返す
+func writeBudgetExhausted(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, budget.ErrExhausted) && !errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	w.Header().Set("Retry-After", "1")
+	i18n.Error(w, r, http.StatusServiceUnavailable, i18n.RequestBudgetExhausted)
+	return true
+}
+
+// 注文できる期間外の商品であればエラーを返し、trueを返す
+func writeProductUnavailableError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var unavailableErr *service.ProductUnavailableError
+	if !errors.As(err, &unavailableErr) {
+		return false
+	}
+	i18n.Error(w, r, http.StatusConflict, i18n.ProductUnavailable, unavailableErr.ProductID)
+	return true
+}
+
+// 在庫の足りない商品であればエラーを返し、trueを返す
+func writeInsufficientStockError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var stockErr *service.InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		return false
+	}
+	i18n.Error(w, r, http.StatusConflict, i18n.InsufficientStock, stockErr.ProductID)
+	return true
+}
+
+// 過去の注文と同じ内容で再注文
+func (h *ProductHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotInContext)
 		return
 	}
 
-	insertedOrderIDs, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		log.Printf("Failed to create orders: %v", err)
-		http.Error(w, "Failed to process order request", http.StatusInternalServerError)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderID)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message":   "Orders created successfully",
-		"order_ids": insertedOrderIDs,
+	insertedOrderIDs, err := h.ProductSvc.Reorder(r.Context(), userID, orderID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+			return
+		}
+		if writeOrderLimitError(w, r, err) || writeProductUnavailableError(w, r, err) || writeInsufficientStockError(w, r, err) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to reorder", "order_id", orderID, "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.ReorderFailed)
+		return
 	}
+	metrics.RecordItems(r.Context(), "orders", len(insertedOrderIDs))
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(createOrdersResponse(insertedOrderIDs))
+}
+
+// 注文を作成せずに内容を確認
+func (h *ProductHandler) ValidateOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UserNotInContext)
+		return
+	}
+
+	var req model.CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	resp, err := h.ProductSvc.ValidateOrder(r.Context(), userID, req.Items)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to validate orders", "user_id", userID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.ValidateOrderFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
-	imagePath := r.URL.Query().Get("path")
+	// r.URL.Query()はリクエストごとにマップを作るため、pathだけを取り出す
+	imagePath := queryValue(r.URL.RawQuery, "path")
 	if imagePath == "" {
-		http.Error(w, "画像パスが指定されていません", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.ImagePathRequired)
 		return
 	}
 
 	imagePath = filepath.Clean(imagePath)
 	if filepath.IsAbs(imagePath) || strings.Contains(imagePath, "..") {
-		http.Error(w, "無効なパスです", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidImagePath)
 		return
 	}
 
-	baseImageDir := "/app/images"
-	fullPath := filepath.Join(baseImageDir, imagePath)
+	// ?w=200 のように幅を指定した場合は縮小したサムネイルを返す
+	width := 0
+	if v := queryValue(r.URL.RawQuery, "w"); v != "" {
+		var err error
+		width, err = strconv.Atoi(v)
+		if err != nil || !slices.Contains(h.ThumbnailWidths, width) {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidThumbnailWidth, joinInts(h.ThumbnailWidths))
+			return
+		}
+	}
 
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		http.Error(w, "画像が見つかりません", http.StatusNotFound)
+	var entry *cache.ImageCacheEntry
+	var err error
+	if width > 0 {
+		entry, err = h.thumbnail(imagePath, width)
+	} else {
+		entry, err = h.image(imagePath)
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		i18n.Error(w, r, http.StatusNotFound, i18n.ImageNotFound)
+		return
+	case err != nil:
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.ImageReadFailed)
 		return
 	}
+	imageAccess.Hit(imagePath)
+	writeImage(w, r, entry)
+}
+
+// 元の画像（キャッシュになければファイルから読み込んでキャッシュする）
+func (h *ProductHandler) image(imagePath string) (*cache.ImageCacheEntry, error) {
+	if entry, ok := h.Images.Get(imagePath); ok {
+		return entry, nil
+	}
+	data, contentType, modTime, err := readImage(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	return h.Images.Set(imagePath, data, contentType, modTime), nil
+}
+
+// 幅widthのサムネイル（キャッシュになければ元の画像から作ってキャッシュする）
+// 縮小できない形式の画像は元の画像を返す
+// 元の画像はキャッシュにあれば使い、なければファイルから読むだけでキャッシュしない
+func (h *ProductHandler) thumbnail(imagePath string, width int) (*cache.ImageCacheEntry, error) {
+	key := cache.ThumbnailKey(imagePath, width)
+	if entry, ok := h.Images.Get(key); ok {
+		return entry, nil
+	}
+	v, err, _ := h.thumbnails.Do(key, func() (interface{}, error) {
+		var data []byte
+		var contentType string
+		var modTime time.Time
+		if source, ok := h.Images.Get(imagePath); ok {
+			var err error
+			if data, err = source.Bytes(); err != nil {
+				return nil, err
+			}
+			contentType, modTime = source.ContentType, source.ModTime
+		} else {
+			var err error
+			if data, contentType, modTime, err = readImage(imagePath); err != nil {
+				return nil, err
+			}
+		}
+		thumb, thumbType, err := imaging.Thumbnail(data, contentType, width)
+		if err != nil {
+			if !errors.Is(err, imaging.ErrUnsupportedFormat) {
+				slog.Warn("Failed to make thumbnail", "path", imagePath, "width", width, "err", err)
+			}
+			thumb, thumbType = data, contentType
+		}
+		// 元のファイルの更新時刻を保持し、ファイルの差し替えで合わせて破棄されるようにする
+		return h.Images.Set(key, thumb, thumbType, modTime), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*cache.ImageCacheEntry), nil
+}
+
+// 画像ディレクトリから画像を読み込む（存在しない場合はfs.ErrNotExist）
+func readImage(imagePath string) ([]byte, string, time.Time, error) {
+	fullPath := filepath.Join(ImageDir, imagePath)
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return nil, "", time.Time{}, fs.ErrNotExist
+	}
 
 	ext := filepath.Ext(fullPath)
 	var contentType string
@@ -132,13 +425,95 @@ func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 	default:
 		contentType = "application/octet-stream"
 	}
-	w.Header().Set("Content-Type", contentType)
-
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
-		http.Error(w, "画像の読み込みに失敗しました", http.StatusInternalServerError)
-		return
+		return nil, "", time.Time{}, err
+	}
+	var modTime time.Time
+	if info != nil {
+		modTime = info.ModTime()
 	}
+	return data, contentType, modTime, nil
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+var varyAcceptEncoding = []string{"Accept-Encoding"}
 
+// 画像を書き込む（クライアントの持つ画像が最新であれば本文を返さず304を返す）
+// 圧縮して保持している画像は、gzipを受け付けるクライアントには圧縮したまま返し、それ以外には展開して返す
+func writeImage(w http.ResponseWriter, r *http.Request, entry *cache.ImageCacheEntry) {
+	gzipped := false
+	if entry.Compressed() {
+		w.Header()["Vary"] = varyAcceptEncoding
+		gzipped = acceptsGzip(r.Header.Get("Accept-Encoding"))
+	}
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && entry.NotModified(r.Header) {
+		if gzipped {
+			entry.SetGzipValidators(w.Header())
+		} else {
+			entry.SetValidators(w.Header())
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if gzipped {
+		entry.SetGzipHeaders(w.Header())
+		w.Write(entry.Gzipped)
+		return
+	}
+	data, err := entry.Bytes()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to decompress cached image", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.ImageReadFailed)
+		return
+	}
+	entry.SetHeaders(w.Header())
 	w.Write(data)
 }
+
+// Accept-Encodingがgzipを受け付けるか（q=0で拒否されていないか）
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// クエリ文字列からnameの最初の値を取り出す
+// エスケープを含まない値は元の文字列を切り出すだけで、新たに確保しない
+func queryValue(rawQuery, name string) string {
+	for rawQuery != "" {
+		var pair string
+		pair, rawQuery, _ = strings.Cut(rawQuery, "&")
+		key, value, _ := strings.Cut(pair, "=")
+		if key != name {
+			continue
+		}
+		if !strings.ContainsAny(value, "%+") {
+			return value
+		}
+		unescaped, err := url.QueryUnescape(value)
+		if err != nil {
+			return ""
+		}
+		return unescaped
+	}
+	return ""
+}
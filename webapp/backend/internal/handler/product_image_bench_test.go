@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"backend/internal/cache"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 画像取得の1リクエストあたりの時間・アロケーション
+// 元の画像はImageDirを読まないよう、あらかじめキャッシュに載せておく
+func BenchmarkGetImage(b *testing.B) {
+	const imagePath = "bench/product.png"
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		b.Fatal(err)
+	}
+
+	images := cache.NewImageCache(64<<20, time.Hour, cache.PolicyLRU)
+	entry := images.Set(imagePath, buf.Bytes(), "image/png", time.Unix(1700000000, 0))
+	h := NewProductHandler(nil, nil, images, []int{200})
+	etag := make(http.Header)
+	entry.SetValidators(etag)
+
+	cases := []struct {
+		name   string
+		query  string
+		header http.Header
+		status int
+	}{
+		{name: "hit", query: "path=" + imagePath, status: http.StatusOK},
+		{name: "not_modified", query: "path=" + imagePath, header: http.Header{"If-None-Match": {etag.Get("Etag")}}, status: http.StatusNotModified},
+		{name: "thumbnail", query: "path=" + imagePath + "&w=200", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/image?"+c.query, nil)
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			// サムネイルは最初のリクエストで作られ、以降はキャッシュから返る
+			rec := httptest.NewRecorder()
+			h.GetImage(rec, req)
+			if rec.Code != c.status {
+				b.Fatalf("status = %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				h.GetImage(rec, req)
+				if rec.Code != c.status {
+					b.Fatalf("status = %d, want %d", rec.Code, c.status)
+				}
+			}
+		})
+	}
+}
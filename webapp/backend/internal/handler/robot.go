@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"backend/internal/i18n"
+	"backend/internal/metrics"
 	"backend/internal/model"
 	"backend/internal/service"
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type RobotHandler struct {
@@ -18,47 +25,224 @@ func NewRobotHandler(robotSvc *service.RobotService) *RobotHandler {
 
 // 配送計画を取得
 func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
-	robotID := "robot-001"
+	// 追跡APIで配送担当ロボットを特定できるよう、ロボットIDを受け取る
+	robotID := r.URL.Query().Get("robot_id")
+	if robotID == "" {
+		robotID = "robot-001"
+	}
 
 	capacityStr := r.URL.Query().Get("capacity")
 	if capacityStr == "" {
-		http.Error(w, "Query parameter 'capacity' is required", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.CapacityRequired)
 		return
 	}
 	capacity, err := strconv.Atoi(capacityStr)
 	if err != nil {
-		http.Error(w, "Query parameter 'capacity' must be an integer", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.CapacityNotInteger)
 		return
 	}
 
-	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, capacity)
+	// exclude=1,2,3 の注文（前回の配送で見つけられなかった注文など）は一定時間計画から除外する
+	excluded, ok := parseExcludedOrders(r.URL.Query().Get("exclude"))
+	if !ok {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidExcludedOrders, service.MaxPlanExclusions)
+		return
+	}
+
+	// debug=1 の場合は計画のアルゴリズムと実行コストを含める
+	debug, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+
+	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, capacity, excluded, debug)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCapacity) {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidCapacity, err.Error())
+			return
+		}
+		if writeBudgetExhausted(w, r, err) {
+			return
+		}
 		// ログ出力を削減（パフォーマンス向上）
-		// log.Printf("Failed to generate delivery plan: %v", err)
-		http.Error(w, "Failed to create delivery plan", http.StatusInternalServerError)
+		// slog.ErrorContext(r.Context(), "Failed to generate delivery plan", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.CreatePlanFailed)
 		return
 	}
+	metrics.RecordItems(r.Context(), "orders", len(plan.Orders))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(plan)
 }
 
+// 配送計画を作れるだけの配送待ち注文が溜まるまで待つ（ロングポーリング）
+// ?timeout=20s のように待つ時間を指定できる（省略時・上限超過時はROBOT_PLAN_WAIT_MAX）
+// 溜まらないまま時間切れになった場合もready:falseとして200を返す
+func (h *RobotHandler) WaitForPlan(w http.ResponseWriter, r *http.Request) {
+	capacityStr := r.URL.Query().Get("capacity")
+	if capacityStr == "" {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.CapacityRequired)
+		return
+	}
+	capacity, err := strconv.Atoi(capacityStr)
+	if err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.CapacityNotInteger)
+		return
+	}
+	var timeout time.Duration
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil || timeout <= 0 {
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidWaitTimeout)
+			return
+		}
+	}
+
+	availability, err := h.RobotSvc.WaitForPlan(r.Context(), capacity, timeout)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCapacity):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidCapacity, err.Error())
+		case errors.Is(err, context.Canceled):
+			// ロボットが待つのをやめた
+		default:
+			slog.ErrorContext(r.Context(), "Failed to wait for plannable orders", "capacity", capacity, "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.PlanWaitFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availability)
+}
+
+// カンマ区切りの注文IDを読み取る（空の場合はnil）
+func parseExcludedOrders(s string) ([]int64, bool) {
+	if s == "" {
+		return nil, true
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > service.MaxPlanExclusions {
+		return nil, false
+	}
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
 // 配送完了時に注文ステータスを更新
 func (h *RobotHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	var req model.UpdateOrderStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
 		return
 	}
 
-	err := h.RobotSvc.UpdateOrderStatus(r.Context(), req.OrderID, req.NewStatus)
+	err := h.RobotSvc.UpdateOrderStatus(r.Context(), req.OrderID, req.NewStatus, req.ClaimToken)
+	if errors.Is(err, service.ErrInvalidClaim) {
+		i18n.Error(w, r, http.StatusForbidden, i18n.OrderNotClaimed)
+		return
+	}
+	if errors.Is(err, service.ErrOrderNotFound) {
+		i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidOrderStatus) {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidOrderStatus)
+		return
+	}
+	if errors.Is(err, service.ErrOrderCancelled) {
+		i18n.Error(w, r, http.StatusConflict, i18n.OrderCancelled)
+		return
+	}
+	if errors.Is(err, service.ErrWriteDeferred) {
+		// DBの復旧後に反映されるため、受け付けたことだけを返す
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Order status update queued"))
+		return
+	}
 	if err != nil {
 		// ログ出力を削減（パフォーマンス向上）
-		// log.Printf("Failed to update order status for order %d: %v", req.OrderID, err)
-		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		// slog.ErrorContext(r.Context(), "Failed to update order status", "order_id", req.OrderID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.UpdateStatusFailed)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Order status updated"))
 }
+
+// 配送計画の受領を確認
+func (h *RobotHandler) AcknowledgePlan(w http.ResponseWriter, r *http.Request) {
+	var req model.PlanAckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+	if req.RobotID == "" {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.RobotIDRequired)
+		return
+	}
+
+	n, err := h.RobotSvc.AcknowledgePlan(r.Context(), req.RobotID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to acknowledge delivery plan", "robot_id", req.RobotID, "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.AcknowledgePlanFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.PlanAckResponse{RobotID: req.RobotID, Acknowledged: n})
+}
+
+// ロボットの現在位置を報告
+func (h *RobotHandler) ReportPosition(w http.ResponseWriter, r *http.Request) {
+	var req model.RobotPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+	if req.RobotID == "" {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.RobotIDRequired)
+		return
+	}
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidCoordinates)
+		return
+	}
+
+	h.RobotSvc.ReportPosition(req.RobotID, model.Coordinates{Latitude: req.Latitude, Longitude: req.Longitude})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 配送失敗を報告し、注文を再配送待ちにする
+func (h *RobotHandler) ReportDeliveryFailure(w http.ResponseWriter, r *http.Request) {
+	var req model.DeliveryFailedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+		return
+	}
+
+	resp, err := h.RobotSvc.ReportDeliveryFailure(r.Context(), req.OrderID, req.Reason, req.ClaimToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidFailureReason):
+			i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidFailureReason)
+		case errors.Is(err, service.ErrInvalidClaim):
+			i18n.Error(w, r, http.StatusForbidden, i18n.OrderNotClaimed)
+		case errors.Is(err, service.ErrOrderNotFound):
+			i18n.Error(w, r, http.StatusNotFound, i18n.OrderNotFound)
+		case errors.Is(err, service.ErrOrderNotDelivering):
+			i18n.Error(w, r, http.StatusConflict, i18n.OrderNotDelivering)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to report delivery failure", "order_id", req.OrderID, "err", err)
+			i18n.Error(w, r, http.StatusInternalServerError, i18n.ReportFailureFailed)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"backend/internal/i18n"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TrackingHandler struct {
+	TrackingSvc *service.TrackingService
+}
+
+func NewTrackingHandler(trackingSvc *service.TrackingService) *TrackingHandler {
+	return &TrackingHandler{TrackingSvc: trackingSvc}
+}
+
+// 追跡トークンから配送状況を取得（認証不要）
+func (h *TrackingHandler) Track(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	info, err := h.TrackingSvc.Track(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrTrackingNotFound) {
+			i18n.Error(w, r, http.StatusNotFound, i18n.TrackingNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to fetch tracking information", "err", err)
+		i18n.Error(w, r, http.StatusInternalServerError, i18n.FetchTrackingFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,264 @@
+// APIのエラーメッセージをエラーコードごとに日本語・英語で保持する
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	Japanese = "ja"
+	English  = "en"
+	// Accept-Languageがない・対応する言語がない場合
+	DefaultLanguage = English
+)
+
+// APIのエラーを識別するコード（レスポンスのX-Error-Codeヘッダーに入る）
+type Code string
+
+const (
+	InvalidRequestBody        Code = "invalid_request_body"
+	RequestValidationFailed   Code = "request_validation_failed"
+	UserNotInContext          Code = "user_not_in_context"
+	UserNotFound              Code = "user_not_found"
+	InternalError             Code = "internal_error"
+	TooManyRequests           Code = "too_many_requests"
+	TooManyConcurrent         Code = "too_many_concurrent_requests"
+	RequestBudgetExhausted    Code = "request_budget_exhausted"
+	ReadOnlyMode              Code = "read_only_mode"
+	FeaturedNotReady          Code = "featured_not_ready"
+	NoSessionCookie           Code = "no_session_cookie"
+	InvalidSession            Code = "invalid_session"
+	InvalidCredentials        Code = "invalid_credentials"
+	InvalidRobotKey           Code = "invalid_robot_key"
+	InvalidRobotSignature     Code = "invalid_robot_signature"
+	StaleRobotRequest         Code = "stale_robot_request"
+	ReplayedRobotRequest      Code = "replayed_robot_request"
+	InvalidAdminKey           Code = "invalid_admin_key"
+	InvalidLimit              Code = "invalid_limit"
+	InvalidOlderThan          Code = "invalid_older_than"
+	InvalidOrderID            Code = "invalid_order_id"
+	InvalidProductID          Code = "invalid_product_id"
+	UnknownField              Code = "unknown_field"
+	InvalidCursor             Code = "invalid_cursor"
+	InvalidPagination         Code = "invalid_pagination"
+	InvalidFeaturedCount      Code = "invalid_featured_count"
+	OrderNotFound             Code = "order_not_found"
+	ProductNotFound           Code = "product_not_found"
+	ProductUnavailable        Code = "product_unavailable"
+	InsufficientStock         Code = "insufficient_stock"
+	TrackingNotFound          Code = "tracking_not_found"
+	OrderLimitExceeded        Code = "order_limit_exceeded"
+	InvalidGiftRecipient      Code = "invalid_gift_recipient"
+	InvalidProductValues      Code = "invalid_product_values"
+	InvalidPreferences        Code = "invalid_preferences"
+	CapacityRequired          Code = "capacity_required"
+	CapacityNotInteger        Code = "capacity_not_integer"
+	InvalidCapacity           Code = "invalid_capacity"
+	InvalidExcludedOrders     Code = "invalid_excluded_orders"
+	InvalidWaitTimeout        Code = "invalid_wait_timeout"
+	RobotIDRequired           Code = "robot_id_required"
+	InvalidCoordinates        Code = "invalid_coordinates"
+	InvalidFailureReason      Code = "invalid_failure_reason"
+	OrderNotClaimed           Code = "order_not_claimed"
+	OrderNotDelivering        Code = "order_not_delivering"
+	OrderCancelled            Code = "order_cancelled"
+	InvalidOrderStatus        Code = "invalid_order_status"
+	OrderNotCancellable       Code = "order_not_cancellable"
+	OrderNotWatchable         Code = "order_not_watchable"
+	TooManyWatches            Code = "too_many_watches"
+	ImagePathRequired         Code = "image_path_required"
+	InvalidImagePath          Code = "invalid_image_path"
+	InvalidThumbnailWidth     Code = "invalid_thumbnail_width"
+	ImageNotFound             Code = "image_not_found"
+	ImageReadFailed           Code = "image_read_failed"
+	FetchProductsFailed       Code = "fetch_products_failed"
+	FetchProductHistoryFailed Code = "fetch_product_history_failed"
+	UpdateProductFailed       Code = "update_product_failed"
+	CreateOrderFailed         Code = "create_order_failed"
+	ReorderFailed             Code = "reorder_failed"
+	ValidateOrderFailed       Code = "validate_order_failed"
+	FetchOrdersFailed         Code = "fetch_orders_failed"
+	FetchOrderFailed          Code = "fetch_order_failed"
+	ExportOrdersFailed        Code = "export_orders_failed"
+	FetchGiftsFailed          Code = "fetch_gifts_failed"
+	CancelOrderFailed         Code = "cancel_order_failed"
+	BuildInvoiceFailed        Code = "build_invoice_failed"
+	SummarizeOrdersFailed     Code = "summarize_orders_failed"
+	FetchTrackingFailed       Code = "fetch_tracking_failed"
+	FetchPreferencesFailed    Code = "fetch_preferences_failed"
+	UpdatePreferencesFailed   Code = "update_preferences_failed"
+	CreatePlanFailed          Code = "create_plan_failed"
+	PlanWaitFailed            Code = "plan_wait_failed"
+	UpdateStatusFailed        Code = "update_status_failed"
+	AcknowledgePlanFailed     Code = "acknowledge_plan_failed"
+	ReportFailureFailed       Code = "report_failure_failed"
+	FetchStatsFailed          Code = "fetch_stats_failed"
+	FetchDashboardFailed      Code = "fetch_dashboard_failed"
+	PrecomputeFailed          Code = "precompute_distances_failed"
+	RepairStatusFailed        Code = "repair_order_status_failed"
+	RequeueStaleFailed        Code = "requeue_stale_deliveries_failed"
+	InvalidImportCSV          Code = "invalid_import_csv"
+	ImportTooLarge            Code = "import_too_large"
+	ImportOrdersFailed        Code = "import_orders_failed"
+	InvalidRecalibration      Code = "invalid_recalibration"
+	InvalidDryRun             Code = "invalid_dry_run"
+	RecalibrationTooLarge     Code = "recalibration_too_large"
+	RecalibrateFailed         Code = "recalibrate_products_failed"
+	InvalidTestdataRequest    Code = "invalid_testdata_request"
+	ResetTestdataFailed       Code = "reset_testdata_failed"
+	FetchLowStockFailed       Code = "fetch_low_stock_failed"
+)
+
+type message struct {
+	ja string
+	en string
+}
+
+// メッセージはfmt.Sprintfの書式（UnknownField・TooManyConcurrent・InvalidCapacity・InvalidExcludedOrders・ProductUnavailable・InsufficientStock・InvalidFeaturedCount・InvalidTestdataRequest・InvalidRecalibration・RecalibrationTooLarge・TooManyWatches・InvalidThumbnailWidthは引数を取る）
+var catalog = map[Code]message{
+	InvalidRequestBody:        {"リクエストの形式が正しくありません", "Invalid request body"},
+	RequestValidationFailed:   {"リクエストの内容がAPIの定義に合っていません", "Request does not match the API specification"},
+	UserNotInContext:          {"ユーザー情報を取得できませんでした", "User not found in context"},
+	UserNotFound:              {"ユーザーが見つかりません", "User not found"},
+	InternalError:             {"サーバー内部でエラーが発生しました", "Internal server error"},
+	TooManyRequests:           {"リクエストが多すぎます。しばらくしてから再度お試しください", "Too Many Requests"},
+	TooManyConcurrent:         {"同時に実行できるリクエストは1ユーザーあたり%d件までです", "Too many concurrent requests: at most %d requests to this endpoint may run at once per user"},
+	RequestBudgetExhausted:    {"処理時間の上限までに完了できないため中止しました。しばらくしてから再度お試しください", "Request aborted: not enough time left in the request deadline"},
+	ReadOnlyMode:              {"メンテナンス中のため、現在は参照のみ受け付けています", "The service is in read-only mode for maintenance; only reads are accepted"},
+	FeaturedNotReady:          {"おすすめ商品を準備中です。しばらくしてから再度お試しください", "Featured products are not ready yet; please retry shortly"},
+	NoSessionCookie:           {"ログインしていません（セッションがありません）", "Unauthorized: No session cookie"},
+	InvalidSession:            {"セッションが無効です。再度ログインしてください", "Unauthorized: Invalid session"},
+	InvalidCredentials:        {"ユーザー名またはパスワードが正しくありません", "Unauthorized: Invalid credentials"},
+	InvalidRobotKey:           {"APIキーが無効です", "Forbidden: Invalid or missing API key"},
+	InvalidRobotSignature:     {"リクエストの署名が無効です", "Unauthorized: Invalid or missing request signature"},
+	StaleRobotRequest:         {"リクエストの時刻が許容範囲外です", "Unauthorized: Request timestamp is outside the allowed window"},
+	ReplayedRobotRequest:      {"同じリクエストが既に処理されています", "Unauthorized: Request nonce has already been used"},
+	InvalidAdminKey:           {"管理者キーが無効です", "Forbidden: Invalid or missing admin key"},
+	InvalidLimit:              {"limitには整数を指定してください", "Query parameter 'limit' must be an integer"},
+	InvalidOlderThan:          {"older_thanに正の期間（例: 30m）を指定してください", "Query parameter 'older_than' must be a positive duration such as 30m"},
+	InvalidOrderID:            {"注文IDが正しくありません", "Invalid order id"},
+	InvalidProductID:          {"商品IDが正しくありません", "Invalid product id"},
+	UnknownField:              {"不明なフィールドです: %s", "Unknown field: %s"},
+	InvalidCursor:             {"cursorが正しくありません", "Invalid cursor"},
+	InvalidPagination:         {"pageとpage_sizeには正の整数を指定してください", "Query parameters 'page' and 'page_size' must be positive integers"},
+	InvalidFeaturedCount:      {"nには1以上%d以下の整数を指定してください", "Query parameter 'n' must be an integer between 1 and %d"},
+	OrderNotFound:             {"注文が見つかりません", "Order not found"},
+	ProductNotFound:           {"商品が見つかりません", "Product not found"},
+	ProductUnavailable:        {"商品（ID: %d）は現在注文できません", "Product %d is not available for purchase at this time"},
+	InsufficientStock:         {"商品（ID: %d）の在庫が足りません", "Product %d does not have enough stock"},
+	TrackingNotFound:          {"追跡情報が見つかりません", "Tracking information not found"},
+	OrderLimitExceeded:        {"注文数量の上限を超えています", "order quantity limit exceeded"},
+	InvalidGiftRecipient:      {"ギフトの受取人には自分以外の存在するユーザーを指定してください", "Gift recipient must be an existing user other than yourself"},
+	InvalidProductValues:      {"価格と重量には1以上の値を指定してください", "Value and weight must be positive"},
+	InvalidPreferences:        {"設定値が正しくありません", "Invalid preferences"},
+	CapacityRequired:          {"capacityを指定してください", "Query parameter 'capacity' is required"},
+	CapacityNotInteger:        {"capacityには整数を指定してください", "Query parameter 'capacity' must be an integer"},
+	InvalidCapacity:           {"積載量が正しくありません（%s）", "%s"},
+	InvalidExcludedOrders:     {"excludeには注文IDをカンマ区切りで%d件まで指定してください", "Query parameter 'exclude' must be up to %d comma-separated order IDs"},
+	InvalidWaitTimeout:        {"timeoutには正の時間（例: 20s）を指定してください", "Query parameter 'timeout' must be a positive duration such as 20s"},
+	RobotIDRequired:           {"robot_idを指定してください", "robot_id is required"},
+	InvalidCoordinates:        {"座標が正しくありません", "Invalid coordinates"},
+	InvalidFailureReason:      {"配送失敗の理由が正しくありません", "Invalid failure reason"},
+	OrderNotClaimed:           {"この配送計画の注文ではありません", "Order is not claimed by this delivery plan"},
+	OrderNotDelivering:        {"注文は配送中ではありません", "Order is not in delivering status"},
+	OrderCancelled:            {"注文はキャンセルされています", "Order has been cancelled"},
+	InvalidOrderStatus:        {"注文ステータスが正しくありません", "Invalid order status"},
+	OrderNotCancellable:       {"配送待ちの注文のみキャンセルできます", "Only orders waiting for shipment can be cancelled"},
+	OrderNotWatchable:         {"配送完了・キャンセル済みの注文はウォッチできません", "Completed or cancelled orders cannot be watched"},
+	TooManyWatches:            {"ウォッチできる注文は%d件までです", "Too many watched orders: at most %d orders may be watched at once"},
+	ImagePathRequired:         {"画像パスが指定されていません", "Image path is required"},
+	InvalidImagePath:          {"無効なパスです", "Invalid image path"},
+	InvalidThumbnailWidth:     {"wには次のいずれかを指定してください: %s", "Query parameter 'w' must be one of: %s"},
+	ImageNotFound:             {"画像が見つかりません", "Image not found"},
+	ImageReadFailed:           {"画像の読み込みに失敗しました", "Failed to read image"},
+	FetchProductsFailed:       {"商品一覧の取得に失敗しました", "Failed to fetch products"},
+	FetchProductHistoryFailed: {"商品の変更履歴の取得に失敗しました", "Failed to fetch product history"},
+	UpdateProductFailed:       {"商品の更新に失敗しました", "Failed to update product"},
+	CreateOrderFailed:         {"注文の処理に失敗しました", "Failed to process order request"},
+	ReorderFailed:             {"再注文の処理に失敗しました", "Failed to process reorder request"},
+	ValidateOrderFailed:       {"注文内容の確認に失敗しました", "Failed to validate order request"},
+	FetchOrdersFailed:         {"注文一覧の取得に失敗しました", "Failed to fetch orders"},
+	FetchOrderFailed:          {"注文の取得に失敗しました", "Failed to fetch order"},
+	ExportOrdersFailed:        {"注文履歴の書き出しに失敗しました", "Failed to export orders"},
+	FetchGiftsFailed:          {"受け取ったギフトの取得に失敗しました", "Failed to fetch received gifts"},
+	CancelOrderFailed:         {"注文のキャンセルに失敗しました", "Failed to cancel order"},
+	BuildInvoiceFailed:        {"請求内容の作成に失敗しました", "Failed to build invoice"},
+	SummarizeOrdersFailed:     {"注文の集計に失敗しました", "Failed to summarize orders"},
+	FetchTrackingFailed:       {"追跡情報の取得に失敗しました", "Failed to fetch tracking information"},
+	FetchPreferencesFailed:    {"設定の取得に失敗しました", "Failed to fetch preferences"},
+	UpdatePreferencesFailed:   {"設定の更新に失敗しました", "Failed to update preferences"},
+	CreatePlanFailed:          {"配送計画の作成に失敗しました", "Failed to create delivery plan"},
+	PlanWaitFailed:            {"配送待ちの注文の確認に失敗しました", "Failed to check for plannable orders"},
+	UpdateStatusFailed:        {"注文ステータスの更新に失敗しました", "Failed to update order status"},
+	AcknowledgePlanFailed:     {"配送計画の受領確認に失敗しました", "Failed to acknowledge delivery plan"},
+	ReportFailureFailed:       {"配送失敗の報告に失敗しました", "Failed to report delivery failure"},
+	FetchStatsFailed:          {"統計の取得に失敗しました", "Failed to fetch stats"},
+	FetchDashboardFailed:      {"ダッシュボードの取得に失敗しました", "Failed to fetch dashboard"},
+	PrecomputeFailed:          {"距離の事前計算に失敗しました", "Failed to precompute distances"},
+	RepairStatusFailed:        {"注文ステータスの修復に失敗しました", "Failed to repair order statuses"},
+	RequeueStaleFailed:        {"配送中の注文を配送待ちに戻せませんでした", "Failed to requeue stale deliveries"},
+	InvalidImportCSV:          {"CSVが不正です（%s）", "%s"},
+	ImportTooLarge:            {"CSVが大きすぎます（上限%dバイト）", "CSV is too large (limit %d bytes)"},
+	ImportOrdersFailed:        {"注文の取り込みに失敗しました", "Failed to import orders"},
+	InvalidRecalibration:      {"補正の指定が不正です（%s）", "%s"},
+	InvalidDryRun:             {"dry_runにはtrueまたはfalseを指定してください", "Query parameter 'dry_run' must be true or false"},
+	RecalibrationTooLarge:     {"補正の指定が大きすぎます（上限%dバイト）", "Request body is too large (limit %d bytes)"},
+	RecalibrateFailed:         {"商品の一括補正に失敗しました", "Failed to recalibrate products"},
+	InvalidTestdataRequest:    {"ordersには1から%dまでの値を指定してください", "Field 'orders' must be between 1 and %d"},
+	ResetTestdataFailed:       {"テストデータのリセットに失敗しました", "Failed to reset testdata"},
+	FetchLowStockFailed:       {"在庫が少ない商品の取得に失敗しました", "Failed to fetch low-stock products"},
+}
+
+// 言語langでのメッセージ（未登録のコードはコードそのものを返す）
+func Message(lang string, code Code, args ...interface{}) string {
+	m, ok := catalog[code]
+	if !ok {
+		return string(code)
+	}
+	format := m.en
+	if lang == Japanese {
+		format = m.ja
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Accept-Languageから対応する言語のうち最も優先度の高いものを選ぶ
+func Negotiate(acceptLanguage string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// ja-JP・en-USなどは主言語で判定する
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (primary == Japanese || primary == English) && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
+
+// リクエストのAccept-Languageで選んだ言語
+func Language(r *http.Request) string {
+	return Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// エラーメッセージをリクエストの言語で返す（http.Errorと同じくtext/plain）
+func Error(w http.ResponseWriter, r *http.Request, status int, code Code, args ...interface{}) {
+	lang := Language(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Error-Code", string(code))
+	http.Error(w, Message(lang, code, args...), status)
+}
@@ -0,0 +1,106 @@
+// 商品画像の縮小（一覧に表示するサムネイルの生成）
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// 縮小できない形式の画像（WebPなど標準ライブラリで読めない形式）
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// JPEGで出力する場合の画質
+const jpegQuality = 85
+
+// dataを幅widthに縮小した画像とそのContent-Typeを返す（縦横比は保つ）
+// 元の幅がwidth以下の場合は拡大せず、dataとcontentTypeをそのまま返す
+// JPEGはJPEGで、PNG・GIFはPNGで出力する（GIFのアニメーションは最初のフレームのみ）
+func Thumbnail(data []byte, contentType string, width int) ([]byte, string, error) {
+	var decode func([]byte) (image.Image, error)
+	switch contentType {
+	case "image/jpeg":
+		decode = func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }
+	case "image/png":
+		decode = func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }
+	case "image/gif":
+		decode = func(b []byte) (image.Image, error) { return gif.Decode(bytes.NewReader(b)) }
+	default:
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+	if width <= 0 {
+		return nil, "", fmt.Errorf("invalid thumbnail width %d", width)
+	}
+
+	// 縮小が不要な画像は、ピクセルを読み込まずに大きさだけ確かめる
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width <= width {
+		return data, contentType, nil
+	}
+	src, err := decode(data)
+	if err != nil {
+		return nil, "", err
+	}
+	bounds := src.Bounds()
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+	dst := resize(toRGBA(src), width, height)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		contentType = "image/png"
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// 原点が(0, 0)のRGBAに変換する（JPEGのYCbCrなどはdraw.Drawの変換が速い）
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// 面積平均で縮小する（縮小後の1ピクセルに対応する元の範囲のピクセルを平均する）
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * sh / height
+		y1 := max((dy+1)*sh/height, y0+1)
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * sw / width
+			x1 := max((dx+1)*sw/width, x0+1)
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+				}
+				n += uint64(x1 - x0)
+			}
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i] = uint8((r + n/2) / n)
+			dst.Pix[i+1] = uint8((g + n/2) / n)
+			dst.Pix[i+2] = uint8((b + n/2) / n)
+			dst.Pix[i+3] = uint8((a + n/2) / n)
+		}
+	}
+	return dst
+}
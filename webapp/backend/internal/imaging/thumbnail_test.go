@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeTestImage(t *testing.T, contentType string, w, h int) []byte {
+	t.Helper()
+	rect := image.Rect(0, 0, w, h)
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/png":
+		img := image.NewRGBA(rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+		err = png.Encode(&buf, img)
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, image.NewRGBA(rect), nil)
+	case "image/gif":
+		err = gif.Encode(&buf, image.NewPaletted(rect, palette.Plan9), nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		srcW, srcH  int
+		width       int
+		wantType    string
+		wantW       int
+		wantH       int
+		// 縮小せずに元のデータをそのまま返す
+		unchanged bool
+	}{
+		// 縦横比どおりでは高さが0になるため1に揃える
+		{name: "wide", contentType: "image/png", srcW: 400, srcH: 1, width: 100, wantType: "image/png", wantW: 100, wantH: 1},
+		{name: "tall", contentType: "image/png", srcW: 100, srcH: 400, width: 50, wantType: "image/png", wantW: 50, wantH: 200},
+		{name: "no_upscale", contentType: "image/png", srcW: 100, srcH: 80, width: 200, wantType: "image/png", wantW: 100, wantH: 80, unchanged: true},
+		{name: "same_width", contentType: "image/jpeg", srcW: 100, srcH: 80, width: 100, wantType: "image/jpeg", wantW: 100, wantH: 80, unchanged: true},
+		{name: "jpeg", contentType: "image/jpeg", srcW: 200, srcH: 100, width: 100, wantType: "image/jpeg", wantW: 100, wantH: 50},
+		{name: "gif_to_png", contentType: "image/gif", srcW: 200, srcH: 100, width: 100, wantType: "image/png", wantW: 100, wantH: 50},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := encodeTestImage(t, c.contentType, c.srcW, c.srcH)
+			out, contentType, err := Thumbnail(data, c.contentType, c.width)
+			if err != nil {
+				t.Fatalf("Thumbnail() error = %v", err)
+			}
+			if contentType != c.wantType {
+				t.Fatalf("Thumbnail() content type = %q, want %q", contentType, c.wantType)
+			}
+			if c.unchanged && !bytes.Equal(out, data) {
+				t.Fatal("Thumbnail() re-encoded an image that needs no resizing")
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if "image/"+format != c.wantType || cfg.Width != c.wantW || cfg.Height != c.wantH {
+				t.Fatalf("Thumbnail() = %s %dx%d, want %s %dx%d", format, cfg.Width, cfg.Height, c.wantType, c.wantW, c.wantH)
+			}
+		})
+	}
+}
+
+func TestThumbnailUnsupportedFormat(t *testing.T) {
+	_, _, err := Thumbnail([]byte("RIFF....WEBP"), "image/webp", 100)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Thumbnail() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestResizeAveragesArea(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	// 左半分は黒、右半分は白
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			v := uint8(0)
+			if x >= 2 {
+				v = 255
+			}
+			src.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	dst := resize(src, 2, 1)
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{A: 255}) {
+		t.Fatalf("resize() left = %v, want black", got)
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Fatalf("resize() right = %v, want white", got)
+	}
+	// 1ピクセルにまとめると平均の灰色になる
+	if got := resize(src, 1, 1).RGBAAt(0, 0); got.R != 128 || got.A != 255 {
+		t.Fatalf("resize() = %v, want gray", got)
+	}
+}
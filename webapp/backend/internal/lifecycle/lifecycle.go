@@ -0,0 +1,136 @@
+// バックグラウンドで動くコンポーネントの起動・停止をまとめて管理する
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"backend/internal/task"
+)
+
+// 起動・停止が必要なコンポーネント
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+type entry struct {
+	name      string
+	component Component
+}
+
+// コンポーネントを登録順に起動し、逆順に停止する
+type Registry struct {
+	mutex   sync.Mutex
+	entries []entry
+	started int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(name string, c Component) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = append(r.entries, entry{name: name, component: c})
+}
+
+// 登録順に起動する。途中で失敗した場合は起動済みのものを停止してエラーを返す
+func (r *Registry) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for r.started < len(r.entries) {
+		e := r.entries[r.started]
+		if err := e.component.Start(ctx); err != nil {
+			startErr := fmt.Errorf("start %s: %w", e.name, err)
+			return errors.Join(startErr, r.stopLocked(ctx))
+		}
+		log.Printf("[lifecycle] started %s", e.name)
+		r.started++
+	}
+	return nil
+}
+
+// 起動済みのコンポーネントを逆順に停止する
+// 1つが失敗しても残りは停止を試み、エラーはまとめて返す
+func (r *Registry) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stopLocked(ctx)
+}
+
+func (r *Registry) stopLocked(ctx context.Context) error {
+	var errs []error
+	for r.started > 0 {
+		r.started--
+		e := r.entries[r.started]
+		if err := e.component.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", e.name, err))
+			continue
+		}
+		log.Printf("[lifecycle] stopped %s", e.name)
+	}
+	return errors.Join(errs...)
+}
+
+// 起動・停止を関数で指定するコンポーネント（nilの場合は何もしない）
+type Hook struct {
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+func (h Hook) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+func (h Hook) Stop(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+// ctxがキャンセルされるまでブロックする処理をコンポーネントとして扱う
+// Stopはキャンセル後、処理が終わるまで（またはStopのctxが切れるまで）待つ
+type Background struct {
+	name   string
+	run    func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewBackground(name string, run func(ctx context.Context)) *Background {
+	return &Background{name: name, run: run}
+}
+
+func (b *Background) Start(ctx context.Context) error {
+	// 起動時のctxはリクエスト等で切れる可能性があるため、キャンセルは切り離す
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b.cancel = cancel
+	b.done = make(chan struct{})
+	task.Go(runCtx, b.name, func(ctx context.Context) {
+		defer close(b.done)
+		b.run(ctx)
+	})
+	return nil
+}
+
+func (b *Background) Stop(ctx context.Context) error {
+	if b.cancel == nil {
+		return nil
+	}
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,108 @@
+// プロセスのメモリ使用量に応じてキャッシュの容量を調整する
+package memory
+
+import (
+	"backend/internal/task"
+	"context"
+	"log"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	// 上限に対する使用率がこれを超えたらキャッシュを縮める
+	highWatermark = 0.85
+	// 上限に対する使用率がこれを下回ったらキャッシュを元の容量に戻していく
+	lowWatermark = 0.6
+	// 縮める際の最小倍率（基準容量に対する割合）
+	minScale = 0.05
+)
+
+// 容量を変更でき、超えた分を破棄するキャッシュ
+type Budgeted interface {
+	SetBudget(bytes int64)
+}
+
+type budgetedCache struct {
+	name  string
+	base  int64
+	cache Budgeted
+}
+
+// 使用量が上限に近づいたら登録されたキャッシュの容量を半分ずつ縮め、
+// 余裕ができたら少しずつ基準容量に戻す
+type Governor struct {
+	limit  int64
+	mutex  sync.Mutex
+	caches []budgetedCache
+	scale  float64
+	usage  func() int64
+}
+
+// limitはプロセスが使ってよいメモリ量（バイト）
+func NewGovernor(limit int64) *Governor {
+	return &Governor{limit: limit, scale: 1, usage: residentBytes}
+}
+
+// キャッシュを基準容量baseで登録する
+func (g *Governor) Register(name string, base int64, cache Budgeted) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.caches = append(g.caches, budgetedCache{name: name, base: base, cache: cache})
+	cache.SetBudget(int64(float64(base) * g.scale))
+}
+
+// ctxがキャンセルされるまで、interval毎に使用量を確認する（呼び出し元をブロックする）
+func (g *Governor) Run(ctx context.Context, interval time.Duration) {
+	task.Loop(ctx, "MemoryGovernor", interval, func(context.Context) error {
+		g.Check()
+		return nil
+	})
+}
+
+// 使用量を確認し、必要であればキャッシュの容量を変更する
+func (g *Governor) Check() {
+	usage := g.usage()
+	ratio := float64(usage) / float64(g.limit)
+
+	g.mutex.Lock()
+	prev := g.scale
+	switch {
+	case ratio > highWatermark:
+		g.scale = max(g.scale/2, minScale)
+	case ratio < lowWatermark && g.scale < 1:
+		g.scale = min(g.scale*1.25, 1)
+	}
+	changed := g.scale != prev
+	if changed {
+		for _, c := range g.caches {
+			c.cache.SetBudget(int64(float64(c.base) * g.scale))
+		}
+	}
+	scale := g.scale
+	g.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	log.Printf("[MemoryGovernor] usage %d MiB / limit %d MiB, cache budgets scaled %.2f -> %.2f", usage>>20, g.limit>>20, prev, scale)
+	if scale < prev {
+		// 破棄したキャッシュ分のメモリをOSに返す
+		debug.FreeOSMemory()
+	}
+}
+
+var residentSamples = []metrics.Sample{
+	{Name: "/memory/classes/total:bytes"},
+	{Name: "/memory/classes/heap/released:bytes"},
+}
+
+// ランタイムがOSから確保しているメモリのうち、返却済みでないもの（RSSの近似値）
+func residentBytes() int64 {
+	samples := make([]metrics.Sample, len(residentSamples))
+	copy(samples, residentSamples)
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
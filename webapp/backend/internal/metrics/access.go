@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// キーごとのアクセス回数
+type AccessCount struct {
+	Key  string
+	Hits int64
+}
+
+// キー（画像パス等）ごとのアクセス回数を数える
+// 集計するキー数はmaxKeysまでで、それを超えた新しいキーは合計にだけ数える
+type AccessCounter struct {
+	mutex   sync.RWMutex
+	counts  map[string]*atomic.Int64
+	maxKeys int
+	total   atomic.Int64
+}
+
+func NewAccessCounter(maxKeys int) *AccessCounter {
+	return &AccessCounter{counts: make(map[string]*atomic.Int64), maxKeys: maxKeys}
+}
+
+func (c *AccessCounter) Hit(key string) {
+	c.total.Add(1)
+
+	c.mutex.RLock()
+	n, ok := c.counts[key]
+	c.mutex.RUnlock()
+	if ok {
+		n.Add(1)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if n, ok := c.counts[key]; ok {
+		n.Add(1)
+		return
+	}
+	if len(c.counts) >= c.maxKeys {
+		return
+	}
+	n = new(atomic.Int64)
+	n.Add(1)
+	c.counts[key] = n
+}
+
+// アクセス回数の多い順に最大limit件と、全体のアクセス回数・集計中のキー数を返す
+func (c *AccessCounter) Top(limit int) (top []AccessCount, total int64, tracked int) {
+	c.mutex.RLock()
+	all := make([]AccessCount, 0, len(c.counts))
+	for key, n := range c.counts {
+		all = append(all, AccessCount{Key: key, Hits: n.Load()})
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Hits != all[j].Hits {
+			return all[i].Hits > all[j].Hits
+		}
+		return all[i].Key < all[j].Key
+	})
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all, c.total.Load(), len(c.counts)
+}
+
+// 1つのカウンタで集計するキー数の上限（画像の種類数より十分大きい値）
+const maxAccessKeys = 100000
+
+var (
+	accessMutex    sync.Mutex
+	accessCounters = map[string]*AccessCounter{}
+)
+
+// 名前付きのアクセスカウンタを取得（未登録なら作成）
+func Access(name string) *AccessCounter {
+	accessMutex.Lock()
+	defer accessMutex.Unlock()
+	c, ok := accessCounters[name]
+	if !ok {
+		c = NewAccessCounter(maxAccessKeys)
+		accessCounters[name] = c
+	}
+	return c
+}
@@ -0,0 +1,87 @@
+// プロセス内のキャッシュヒット率やレイテンシを集計する
+package metrics
+
+import (
+	"backend/internal/model"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// キャッシュのヒット・ミス回数と、容量の超過で破棄した件数
+type HitCounter struct {
+	name      string
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	// 破棄の方式（方式ごとのヒット率を比べるためにCacheStatsに含める）
+	policy atomic.Pointer[string]
+}
+
+func (c *HitCounter) Hit()  { c.hits.Add(1) }
+func (c *HitCounter) Miss() { c.misses.Add(1) }
+
+// nilのカウンタでは何もしない
+func (c *HitCounter) Evicted(n int) {
+	if c != nil && n > 0 {
+		c.evictions.Add(int64(n))
+	}
+}
+
+func (c *HitCounter) SetPolicy(policy string) {
+	c.policy.Store(&policy)
+}
+
+func (c *HitCounter) Stat() model.CacheStat {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	stat := model.CacheStat{Name: c.name, Hits: hits, Misses: misses, Evictions: c.evictions.Load()}
+	if policy := c.policy.Load(); policy != nil {
+		stat.Policy = *policy
+	}
+	if total := hits + misses; total > 0 {
+		stat.HitRate = float64(hits) / float64(total)
+	}
+	return stat
+}
+
+var (
+	countersMutex sync.Mutex
+	counters      = map[string]*HitCounter{}
+	usages        = map[string]func() (bytes int64, entries int, budget int64){}
+)
+
+// 名前付きのカウンタを取得（未登録なら作成）
+// 同じ名前で呼ばれた場合は同じカウンタを返す
+func Cache(name string) *HitCounter {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	c, ok := counters[name]
+	if !ok {
+		c = &HitCounter{name: name}
+		counters[name] = c
+	}
+	return c
+}
+
+// 名前付きのキャッシュの使用量を返す関数を登録する（CacheStatsに含める）
+func RegisterUsage(name string, usage func() (bytes int64, entries int, budget int64)) {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	usages[name] = usage
+}
+
+// 登録された全カウンタのスナップショットを名前順に返す
+func CacheStats() []model.CacheStat {
+	countersMutex.Lock()
+	stats := make([]model.CacheStat, 0, len(counters))
+	for name, c := range counters {
+		stat := c.Stat()
+		if usage, ok := usages[name]; ok {
+			stat.Bytes, stat.Entries, stat.BudgetBytes = usage()
+		}
+		stats = append(stats, stat)
+	}
+	countersMutex.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
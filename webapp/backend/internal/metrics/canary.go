@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"backend/internal/model"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 段階的な切り替えのコホートごとのレイテンシの集計期間
+const canaryWindow = 5 * time.Minute
+
+// 段階的な切り替え（experiment）の1つのコホートの件数・失敗数・レイテンシ
+type CanaryCohort struct {
+	experiment string
+	cohort     string
+	latency    *LatencyWindow
+	requests   atomic.Int64
+	errors     atomic.Int64
+}
+
+func (c *CanaryCohort) Observe(d time.Duration, failed bool) {
+	c.requests.Add(1)
+	if failed {
+		c.errors.Add(1)
+	}
+	c.latency.Observe(d)
+}
+
+type canaryKey struct {
+	experiment string
+	cohort     string
+}
+
+var (
+	canaryMutex   sync.Mutex
+	canaryCohorts = map[canaryKey]*CanaryCohort{}
+)
+
+// コホートごとの集計を取得（未登録なら作成）
+func Canary(experiment, cohort string) *CanaryCohort {
+	canaryMutex.Lock()
+	defer canaryMutex.Unlock()
+	key := canaryKey{experiment: experiment, cohort: cohort}
+	c, ok := canaryCohorts[key]
+	if !ok {
+		c = &CanaryCohort{experiment: experiment, cohort: cohort, latency: NewLatencyWindow(canaryWindow, 10*time.Second)}
+		canaryCohorts[key] = c
+	}
+	return c
+}
+
+// 全コホートの集計を切り替え・コホートの名前順に返す（件数・失敗数は起動時からの累計、p50・p95は直近5分）
+func CanaryStats() []model.CanaryStat {
+	canaryMutex.Lock()
+	cohorts := make([]*CanaryCohort, 0, len(canaryCohorts))
+	for _, c := range canaryCohorts {
+		cohorts = append(cohorts, c)
+	}
+	canaryMutex.Unlock()
+
+	stats := make([]model.CanaryStat, 0, len(cohorts))
+	for _, c := range cohorts {
+		_, p50 := c.latency.Percentile(0.5)
+		_, p95 := c.latency.Percentile(0.95)
+		stats = append(stats, model.CanaryStat{
+			Experiment: c.experiment,
+			Cohort:     c.cohort,
+			Requests:   c.requests.Load(),
+			Errors:     c.errors.Load(),
+			P50Ms:      p50,
+			P95Ms:      p95,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Experiment != stats[j].Experiment {
+			return stats[i].Experiment < stats[j].Experiment
+		}
+		return stats[i].Cohort < stats[j].Cohort
+	})
+	return stats
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"backend/internal/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 定期実行する処理の実行回数・失敗数・直近の実行結果
+type JobCounter struct {
+	name string
+
+	mutex        sync.Mutex
+	spec         string
+	runs         int64
+	failures     int64
+	skipped      int64
+	running      bool
+	lastStarted  time.Time
+	lastDuration time.Duration
+	lastError    string
+	next         time.Time
+}
+
+// 実行予定の設定値と次の実行時刻を記録する
+func (c *JobCounter) Scheduled(spec string, next time.Time) {
+	c.mutex.Lock()
+	c.spec = spec
+	c.next = next
+	c.mutex.Unlock()
+}
+
+func (c *JobCounter) Started(at time.Time) {
+	c.mutex.Lock()
+	c.running = true
+	c.lastStarted = at
+	c.mutex.Unlock()
+}
+
+// 実行の終了を記録する（errがnilでなければ失敗として数える）
+func (c *JobCounter) Finished(d time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.running = false
+	c.runs++
+	c.lastDuration = d
+	c.lastError = ""
+	if err != nil {
+		c.failures++
+		c.lastError = err.Error()
+	}
+}
+
+// 前回の実行が終わっていないため実行しなかったことを記録する
+func (c *JobCounter) Skipped() {
+	c.mutex.Lock()
+	c.skipped++
+	c.mutex.Unlock()
+}
+
+func (c *JobCounter) Stat() model.JobStat {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stat := model.JobStat{
+		Name:           c.name,
+		Spec:           c.spec,
+		Runs:           c.runs,
+		Failures:       c.failures,
+		Skipped:        c.skipped,
+		Running:        c.running,
+		LastDurationMs: float64(c.lastDuration.Microseconds()) / 1000,
+		LastError:      c.lastError,
+	}
+	if !c.lastStarted.IsZero() {
+		t := c.lastStarted
+		stat.LastStartedAt = &t
+	}
+	if !c.next.IsZero() {
+		t := c.next
+		stat.NextRunAt = &t
+	}
+	return stat
+}
+
+var (
+	jobMutex    sync.Mutex
+	jobCounters = map[string]*JobCounter{}
+)
+
+// 処理ごとの集計を取得（未登録なら作成）
+func Job(name string) *JobCounter {
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+	c, ok := jobCounters[name]
+	if !ok {
+		c = &JobCounter{name: name}
+		jobCounters[name] = c
+	}
+	return c
+}
+
+// 全処理の集計を名前順に返す（回数は起動時からの累計）
+func JobStats() []model.JobStat {
+	jobMutex.Lock()
+	counters := make([]*JobCounter, 0, len(jobCounters))
+	for _, c := range jobCounters {
+		counters = append(counters, c)
+	}
+	jobMutex.Unlock()
+
+	stats := make([]model.JobStat, 0, len(counters))
+	for _, c := range counters {
+		stats = append(stats, c.Stat())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
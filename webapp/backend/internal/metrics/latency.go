@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// レイテンシのヒストグラムの境界（ミリ秒）
+var latencyBoundsMs = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+type latencySlot struct {
+	start  time.Time
+	counts []int64
+}
+
+// 直近windowのレイテンシを時間スロットごとのヒストグラムで保持する
+type LatencyWindow struct {
+	mutex    sync.Mutex
+	slotSize time.Duration
+	slots    []latencySlot
+}
+
+func NewLatencyWindow(window, slotSize time.Duration) *LatencyWindow {
+	n := int(window / slotSize)
+	if n < 1 {
+		n = 1
+	}
+	slots := make([]latencySlot, n)
+	for i := range slots {
+		slots[i].counts = make([]int64, len(latencyBoundsMs)+1)
+	}
+	return &LatencyWindow{slotSize: slotSize, slots: slots}
+}
+
+func (w *LatencyWindow) Observe(d time.Duration) {
+	now := time.Now()
+	ms := float64(d) / float64(time.Millisecond)
+	bucket := len(latencyBoundsMs)
+	for i, bound := range latencyBoundsMs {
+		if ms <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	start := now.Truncate(w.slotSize)
+	idx := int(start.UnixNano()/int64(w.slotSize)) % len(w.slots)
+
+	w.mutex.Lock()
+	slot := &w.slots[idx]
+	if !slot.start.Equal(start) {
+		// 古いスロットを再利用する
+		slot.start = start
+		clear(slot.counts)
+	}
+	slot.counts[bucket]++
+	w.mutex.Unlock()
+}
+
+// 直近windowの件数と指定パーセンタイルのレイテンシ（ミリ秒、ヒストグラムの境界値）を返す
+// 上限を超えたものは最大の境界値として扱う
+func (w *LatencyWindow) Percentile(p float64) (count int64, ms float64) {
+	merged := make([]int64, len(latencyBoundsMs)+1)
+	oldest := time.Now().Truncate(w.slotSize).Add(-w.slotSize * time.Duration(len(w.slots)-1))
+
+	w.mutex.Lock()
+	for _, slot := range w.slots {
+		if slot.start.Before(oldest) {
+			continue
+		}
+		for i, c := range slot.counts {
+			merged[i] += c
+			count += c
+		}
+	}
+	w.mutex.Unlock()
+
+	if count == 0 {
+		return 0, 0
+	}
+	rank := int64(float64(count)*p + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range merged {
+		seen += c
+		if seen >= rank {
+			if i < len(latencyBoundsMs) {
+				return count, latencyBoundsMs[i]
+			}
+			break
+		}
+	}
+	return count, latencyBoundsMs[len(latencyBoundsMs)-1]
+}
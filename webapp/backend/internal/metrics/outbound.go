@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"backend/internal/model"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 外部ホストごとのレイテンシの集計期間
+const outboundWindow = 5 * time.Minute
+
+// 外部ホストへのリクエストの件数・失敗数・レイテンシ
+type OutboundHost struct {
+	host     string
+	latency  *LatencyWindow
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+// 通信エラー・5xxの場合はfailed=trueで記録する
+func (h *OutboundHost) Observe(d time.Duration, failed bool) {
+	h.requests.Add(1)
+	if failed {
+		h.errors.Add(1)
+	}
+	h.latency.Observe(d)
+}
+
+var (
+	outboundMutex sync.Mutex
+	outboundHosts = map[string]*OutboundHost{}
+)
+
+// ホストごとの集計を取得（未登録なら作成）
+func Outbound(host string) *OutboundHost {
+	outboundMutex.Lock()
+	defer outboundMutex.Unlock()
+	h, ok := outboundHosts[host]
+	if !ok {
+		h = &OutboundHost{host: host, latency: NewLatencyWindow(outboundWindow, 10*time.Second)}
+		outboundHosts[host] = h
+	}
+	return h
+}
+
+// 全ホストの集計をホスト名順に返す（件数・失敗数は起動時からの累計、p95は直近5分）
+func OutboundStats() []model.OutboundStat {
+	outboundMutex.Lock()
+	hosts := make([]*OutboundHost, 0, len(outboundHosts))
+	for _, h := range outboundHosts {
+		hosts = append(hosts, h)
+	}
+	outboundMutex.Unlock()
+
+	stats := make([]model.OutboundStat, 0, len(hosts))
+	for _, h := range hosts {
+		_, p95 := h.latency.Percentile(0.95)
+		stats = append(stats, model.OutboundStat{
+			Host:     h.host,
+			Requests: h.requests.Load(),
+			Errors:   h.errors.Load(),
+			P95Ms:    p95,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"backend/internal/model"
+	"context"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// サイズ・件数のヒストグラムのバケット数（0, 1, 2-3, 4-7, ... と2のべき乗で区切る）
+const shapeBuckets = 40
+
+// 値の分布を2のべき乗の区間ごとの件数で保持する（起動時からの累計）
+type Histogram struct {
+	mutex  sync.Mutex
+	counts [shapeBuckets]int64
+	count  int64
+	sum    int64
+	max    int64
+}
+
+func (h *Histogram) Observe(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	bucket := min(bits.Len64(uint64(v)), shapeBuckets-1)
+	h.mutex.Lock()
+	h.counts[bucket]++
+	h.count++
+	h.sum += v
+	h.max = max(h.max, v)
+	h.mutex.Unlock()
+}
+
+// 件数・合計・最大値と、パーセンタイル（区間の上限値）
+func (h *Histogram) Stat() model.HistogramStat {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	stat := model.HistogramStat{Count: h.count, Sum: h.sum, Max: h.max}
+	if h.count == 0 {
+		return stat
+	}
+	stat.P50 = h.percentile(0.5)
+	stat.P95 = h.percentile(0.95)
+	stat.P99 = h.percentile(0.99)
+	return stat
+}
+
+func (h *Histogram) percentile(p float64) int64 {
+	rank := max(int64(float64(h.count)*p+0.5), 1)
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// 区間の上限（最大値を超える場合は最大値）
+			if i == 0 {
+				return 0
+			}
+			return min(int64(1)<<i-1, h.max)
+		}
+	}
+	return h.max
+}
+
+// エンドポイントごとのリクエスト・レスポンスのサイズと、1リクエストで扱った件数
+type EndpointShape struct {
+	endpoint      string
+	requestBytes  Histogram
+	responseBytes Histogram
+
+	mutex sync.Mutex
+	// 件数の種類（"orders"・"rows"など）ごとの分布
+	items map[string]*Histogram
+}
+
+func (s *EndpointShape) ObserveBytes(request, response int64) {
+	s.requestBytes.Observe(request)
+	s.responseBytes.Observe(response)
+}
+
+func (s *EndpointShape) ObserveItems(kind string, n int) {
+	s.mutex.Lock()
+	h, ok := s.items[kind]
+	if !ok {
+		h = &Histogram{}
+		s.items[kind] = h
+	}
+	s.mutex.Unlock()
+	h.Observe(int64(n))
+}
+
+var (
+	shapeMutex sync.Mutex
+	shapes     = map[string]*EndpointShape{}
+)
+
+// エンドポイント（"POST /api/v1/orders"の形式）の集計を取得（未登録なら作成）
+func Shape(endpoint string) *EndpointShape {
+	shapeMutex.Lock()
+	defer shapeMutex.Unlock()
+	s, ok := shapes[endpoint]
+	if !ok {
+		s = &EndpointShape{endpoint: endpoint, items: map[string]*Histogram{}}
+		shapes[endpoint] = s
+	}
+	return s
+}
+
+// 全エンドポイントの集計をエンドポイント順に返す
+func ShapeStats() []model.EndpointShapeStat {
+	shapeMutex.Lock()
+	list := make([]*EndpointShape, 0, len(shapes))
+	for _, s := range shapes {
+		list = append(list, s)
+	}
+	shapeMutex.Unlock()
+
+	stats := make([]model.EndpointShapeStat, 0, len(list))
+	for _, s := range list {
+		stat := model.EndpointShapeStat{
+			Endpoint:      s.endpoint,
+			RequestBytes:  s.requestBytes.Stat(),
+			ResponseBytes: s.responseBytes.Stat(),
+		}
+		s.mutex.Lock()
+		if len(s.items) > 0 {
+			stat.Items = make(map[string]model.HistogramStat, len(s.items))
+			for kind, h := range s.items {
+				stat.Items[kind] = h.Stat()
+			}
+		}
+		s.mutex.Unlock()
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Endpoint < stats[j].Endpoint })
+	return stats
+}
+
+// リクエストの処理中に記録した件数（エンドポイントが決まるルーティング後にまとめて集計する）
+type ShapeRecorder struct {
+	mutex sync.Mutex
+	items map[string]int
+}
+
+type shapeRecorderKey struct{}
+
+func WithShapeRecorder(ctx context.Context, rec *ShapeRecorder) context.Context {
+	return context.WithValue(ctx, shapeRecorderKey{}, rec)
+}
+
+// リクエストで扱った件数を記録する（集計が無効な場合は何もしない）
+// 同じ種類を複数回記録した場合は合計する
+func RecordItems(ctx context.Context, kind string, n int) {
+	rec, ok := ctx.Value(shapeRecorderKey{}).(*ShapeRecorder)
+	if !ok {
+		return
+	}
+	rec.mutex.Lock()
+	if rec.items == nil {
+		rec.items = make(map[string]int, 1)
+	}
+	rec.items[kind] += n
+	rec.mutex.Unlock()
+}
+
+// 記録した件数をエンドポイントの集計に反映する
+func (rec *ShapeRecorder) Flush(s *EndpointShape) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	for kind, n := range rec.items {
+		s.ObserveItems(kind, n)
+	}
+}
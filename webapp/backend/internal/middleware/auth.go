@@ -2,28 +2,43 @@ package middleware
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 
+	"backend/internal/i18n"
 	"backend/internal/repository"
 )
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey       contextKey = "user"
+	adminScopeContextKey contextKey = "admin_scope"
+)
+
+// 個人情報を伏せ字にせずに参照できる管理者の権限
+const AdminScopePII = "pii"
 
-func UserAuthMiddleware(sessionRepo *repository.SessionRepository) func(http.Handler) http.Handler {
+// bindFingerprintがtrueの場合、セッション作成時と異なるクライアント指紋からのアクセスを拒否する
+// 指紋を記録していないセッションは照合しない
+func UserAuthMiddleware(sessionRepo repository.Sessions, bindFingerprint bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
 			if err != nil {
-				http.Error(w, "Unauthorized: No session cookie", http.StatusUnauthorized)
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.NoSessionCookie)
 				return
 			}
 			sessionID := cookie.Value
 
-			userID, err := sessionRepo.FindUserBySessionID(r.Context(), sessionID)
+			userID, fingerprint, err := sessionRepo.FindUserBySessionID(r.Context(), sessionID)
 			if err != nil {
-				http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidSession)
+				return
+			}
+			if bindFingerprint && fingerprint != "" && fingerprint != ClientFingerprint(r) {
+				slog.WarnContext(r.Context(), "[UserAuth] セッションの指紋不一致", "user_id", userID, "ip", clientIP(r), "user_agent", r.UserAgent())
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidSession)
 				return
 			}
 
@@ -39,7 +54,7 @@ func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
 			apiKey := r.Header.Get("X-API-KEY")
 
 			if apiKey == "" || apiKey != validAPIKey {
-				http.Error(w, "Forbidden: Invalid or missing API key", http.StatusForbidden)
+				i18n.Error(w, r, http.StatusForbidden, i18n.InvalidRobotKey)
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -47,6 +62,32 @@ func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
 	}
 }
 
+// elevatedAPIKeyで認証したリクエストにはAdminScopePIIを与える（空の場合は誰にも与えない）
+func AdminAuthMiddleware(validAPIKey, elevatedAPIKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get("X-ADMIN-KEY")
+
+			if elevatedAPIKey != "" && apiKey == elevatedAPIKey {
+				ctx := context.WithValue(r.Context(), adminScopeContextKey, AdminScopePII)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			if apiKey == "" || apiKey != validAPIKey {
+				i18n.Error(w, r, http.StatusForbidden, i18n.InvalidAdminKey)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 管理者が指定した権限を持つか（AdminAuthMiddlewareで設定される）
+func HasAdminScope(ctx context.Context, scope string) bool {
+	s, _ := ctx.Value(adminScopeContextKey).(string)
+	return s == scope
+}
+
 // コンテキストからユーザー情報を取得
 // ユーザ情報はUserAuthMiddleware
 func GetUserFromContext(ctx context.Context) (int, bool) {
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// リクエストの処理に使える時間（デッドライン）を設定する
+// クライアントはX-Request-Budget（ミリ秒）で短くでき、maxBudgetまで長くできる
+// 設定したデッドラインは各段階でbudget.Requireにより確かめ、間に合わない重い処理は始めない
+// defaultBudgetが0以下でヘッダーもない場合はデッドラインを設定しない（接続し続けるSSEは除く）
+func DeadlineBudgetMiddleware(defaultBudget, maxBudget time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			budget := defaultBudget
+			if v := r.Header.Get("X-Request-Budget"); v != "" {
+				if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+					budget = time.Duration(ms) * time.Millisecond
+				}
+			}
+			if maxBudget > 0 && budget > maxBudget {
+				budget = maxBudget
+			}
+			if budget <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
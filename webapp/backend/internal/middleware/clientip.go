@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// X-Real-IPを信頼するプロキシ（nginxなど）のアドレス範囲
+// 未設定の場合はX-Real-IPを使わず、接続元のアドレスをクライアントIPとする
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// X-Real-IPを信頼するプロキシをCIDR（192.168.0.0/16など）またはIPアドレスで指定する
+// 不正な値を含む場合はエラーを返し、設定を変えない
+func SetTrustedProxies(entries []string) error {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if !strings.Contains(e, "/") {
+			addr, err := netip.ParseAddr(e)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", e, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(e)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", e, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// 接続元が信頼するプロキシの場合のみX-Real-IPを使ってクライアントIPを取得する
+// それ以外の接続元が送ったX-Real-IPは、IPごとの制限を回避できてしまうため無視する
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" && isTrustedProxy(host) {
+		return ip
+	}
+	return host
+}
+
+func isTrustedProxy(host string) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range *prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
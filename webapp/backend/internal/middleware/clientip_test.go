@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"172.16.0.0/12", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       string
+	}{
+		{name: "trusted proxy", remoteAddr: "172.18.0.5:40000", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "trusted proxy by address", remoteAddr: "127.0.0.1:40000", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "untrusted client spoofing X-Real-IP", remoteAddr: "198.51.100.9:40000", realIP: "203.0.113.7", want: "198.51.100.9"},
+		{name: "trusted proxy without header", remoteAddr: "172.18.0.5:40000", want: "172.18.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Fatalf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	SetTrustedProxies(nil)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("X-Real-IP", "203.0.113.7")
+	if got := clientIP(r); got != "127.0.0.1" {
+		t.Fatalf("clientIP() = %q, want the remote address", got)
+	}
+}
+
+func TestSetTrustedProxiesRejectsInvalid(t *testing.T) {
+	if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("SetTrustedProxies() accepted an invalid entry")
+	}
+}
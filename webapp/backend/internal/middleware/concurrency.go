@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"backend/internal/i18n"
+	"net/http"
+	"sync"
+)
+
+// ユーザーごとの同時実行数
+type concurrencyLimiter struct {
+	limit    int
+	mutex    sync.Mutex
+	inFlight map[int]int
+}
+
+func (l *concurrencyLimiter) acquire(userID int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight[userID] >= l.limit {
+		return false
+	}
+	l.inFlight[userID]++
+	return true
+}
+
+func (l *concurrencyLimiter) release(userID int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	// 実行中のユーザーのみ保持し、マップが増え続けないようにする
+	if l.inFlight[userID] <= 1 {
+		delete(l.inFlight, userID)
+		return
+	}
+	l.inFlight[userID]--
+}
+
+// 重いAPIについて、1ユーザーが同時に実行できるリクエスト数を制限する
+// 超えた場合は待たせずに429を返す（UserAuthMiddlewareの後に置くこと）
+// limitが0以下の場合は制限しない
+func UserConcurrencyLimitMiddleware(limit int) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiter := &concurrencyLimiter{limit: limit, inFlight: make(map[int]int)}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !limiter.acquire(userID) {
+				w.Header().Set("Retry-After", "1")
+				i18n.Error(w, r, http.StatusTooManyRequests, i18n.TooManyConcurrent, limit)
+				return
+			}
+			defer limiter.release(userID)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
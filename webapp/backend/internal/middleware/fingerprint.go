@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// User-AgentとIPアドレスの上位部分（IPv4は/24、IPv6は/48）から求めるクライアント指紋
+// 同じネットワーク内でのアドレスの変化は許容し、別のネットワークからの利用を区別する
+func ClientFingerprint(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.UserAgent() + "|" + ipPrefix(clientIP(r))))
+	return hex.EncodeToString(sum[:])
+}
+
+func ipPrefix(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"backend/internal/metrics"
+)
+
+// リクエストごとの処理時間を記録する（ヘルスチェックと、接続し続けるSSEは除く）
+func LatencyMiddleware(window *metrics.LatencyWindow) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/health" || r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			window.Observe(time.Since(start))
+		})
+	}
+}
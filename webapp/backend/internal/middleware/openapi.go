@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"backend/internal/i18n"
+	"backend/internal/openapi"
+)
+
+// OpenAPI定義に沿わないリクエストの400レスポンス
+type validationErrorResponse struct {
+	Code    i18n.Code            `json:"code"`
+	Message string               `json:"message"`
+	Errors  []openapi.FieldError `json:"errors"`
+}
+
+// リクエストのボディ・クエリパラメーターをOpenAPI定義で検証する
+// enforceがfalseの場合は問題をログに出すだけでハンドラーに渡す（定義とクライアントのずれを確かめるため）
+func OpenAPIValidationMiddleware(validator *openapi.Validator, enforce bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			errs := validator.Validate(r)
+			if len(errs) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !enforce {
+				slog.WarnContext(r.Context(), "[OpenAPI] request does not match the spec", "method", r.Method, "path", r.URL.Path, "errors", errs)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			lang := i18n.Language(r)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Language", lang)
+			w.Header().Set("X-Error-Code", string(i18n.RequestValidationFailed))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(validationErrorResponse{
+				Code:    i18n.RequestValidationFailed,
+				Message: i18n.Message(lang, i18n.RequestValidationFailed),
+				Errors:  errs,
+			})
+		})
+	}
+}
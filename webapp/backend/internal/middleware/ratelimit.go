@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend/internal/i18n"
+)
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// キーごとのトークンバケット
+type rateLimiter struct {
+	rate    float64 // 1秒あたりに補充されるトークン数
+	burst   float64
+	buckets map[string]*tokenBucket
+	mutex   sync.Mutex
+}
+
+func newRateLimiter(ratePerSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    ratePerSecond,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// レート制限の判定結果
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	// バケットが満タンに戻るまでの時間
+	reset time.Duration
+	// 次のトークンが補充されるまでの時間（allowed=falseの場合のみ）
+	retryAfter time.Duration
+}
+
+// トークンを1つ消費できればallowed=trueを返す
+func (l *rateLimiter) allow(key string) rateLimitResult {
+	now := time.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+		if len(l.buckets) > 10000 {
+			l.cleanup(now)
+		}
+	}
+
+	b.tokens += now.Sub(b.lastSeen).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.lastSeen = now
+
+	var res rateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		res.allowed = true
+	} else {
+		res.retryAfter = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	res.remaining = int(b.tokens)
+	res.reset = time.Duration((l.burst - b.tokens) / l.rate * float64(time.Second))
+	return res
+}
+
+// バケットが満タンに戻っているキーを削除する
+func (l *rateLimiter) cleanup(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// クライアントIPごとにリクエスト数を制限する
+func IPRateLimitMiddleware(ratePerSecond float64, burst int) func(http.Handler) http.Handler {
+	return rateLimit(newRateLimiter(ratePerSecond, burst), clientIP, true)
+}
+
+// クライアントIPごとの残りリクエスト数をヘッダーで通知するが、制限はしない
+// クライアントが429を受ける前に自主的に流量を調整できるようにするためのもの
+func SoftIPRateLimitMiddleware(ratePerSecond float64, burst int) func(http.Handler) http.Handler {
+	return rateLimit(newRateLimiter(ratePerSecond, burst), clientIP, false)
+}
+
+// セッション（セッションCookieがない場合はクライアントIP）ごとにリクエスト数を制限する
+// ルートのグループごとに別のミドルウェアを作り、グループごとに制限する
+// ratePerSecondが0以下の場合は制限しない（burstが1未満の場合は1秒分を上限とする）
+func SessionRateLimitMiddleware(ratePerSecond float64, burst int) func(http.Handler) http.Handler {
+	return groupRateLimit(ratePerSecond, burst, sessionKey)
+}
+
+// クライアントIPごとにリクエスト数を制限する（設定値の扱いはSessionRateLimitMiddlewareと同じ）
+// Cookieは検証前では毎回変えて制限を逃れられるため、認証の前に置くグループではこちらを使う
+func GroupIPRateLimitMiddleware(ratePerSecond float64, burst int) func(http.Handler) http.Handler {
+	return groupRateLimit(ratePerSecond, burst, clientIP)
+}
+
+func groupRateLimit(ratePerSecond float64, burst int, key func(*http.Request) string) func(http.Handler) http.Handler {
+	if ratePerSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if burst < 1 {
+		burst = max(int(math.Ceil(ratePerSecond)), 1)
+	}
+	return rateLimit(newRateLimiter(ratePerSecond, burst), key, true)
+}
+
+// セッションIDとIPアドレスが同じキーにならないよう接頭辞を付ける
+func sessionKey(r *http.Request) string {
+	if cookie, err := r.Cookie("session_id"); err == nil && cookie.Value != "" {
+		return "session:" + cookie.Value
+	}
+	return "ip:" + clientIP(r)
+}
+
+func rateLimit(limiter *rateLimiter, key func(*http.Request) string, enforce bool) func(http.Handler) http.Handler {
+	limit := strconv.Itoa(int(limiter.burst))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.allow(key(r))
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", limit)
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.reset)))
+			if !res.allowed && enforce {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.retryAfter)))
+				i18n.Error(w, r, http.StatusTooManyRequests, i18n.TooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 秒単位に切り上げる
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
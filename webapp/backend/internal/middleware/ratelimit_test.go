@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGroupIPRateLimitIgnoresSessionCookie(t *testing.T) {
+	SetTrustedProxies(nil)
+	limit := GroupIPRateLimitMiddleware(1, 2)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	codes := make([]int, 3)
+	for i := range codes {
+		r := httptest.NewRequest("GET", "/api/robot/delivery-plan", nil)
+		r.RemoteAddr = "198.51.100.9:40000"
+		// リクエストごとに異なる（検証していない）Cookieを送っても同じIPとして数える
+		r.AddCookie(&http.Cookie{Name: "session_id", Value: strconv.Itoa(i)})
+		w := httptest.NewRecorder()
+		limit.ServeHTTP(w, r)
+		codes[i] = w.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("status codes = %v, want [200 200 429]", codes)
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/i18n"
+	"backend/internal/model"
+)
+
+// 書き込みを止める読み取り専用モード（プライマリDBのメンテナンス・レプリケーションの修復中に使う）
+// 管理APIで実行中に切り替える。切り替えはこのインスタンスのみに効く
+type ReadOnlyMode struct {
+	mutex sync.RWMutex
+	state model.ReadOnlyState
+}
+
+func NewReadOnlyMode(enabled bool, reason string) *ReadOnlyMode {
+	m := &ReadOnlyMode{}
+	m.Set(enabled, reason)
+	return m
+}
+
+// 読み取り専用モードを切り替え、切り替え後の状態を返す
+func (m *ReadOnlyMode) Set(enabled bool, reason string) model.ReadOnlyState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if enabled != m.state.Enabled {
+		now := time.Now()
+		m.state.Since = &now
+	}
+	m.state.Enabled = enabled
+	m.state.Reason = ""
+	if enabled {
+		m.state.Reason = reason
+	}
+	return m.state
+}
+
+func (m *ReadOnlyMode) State() model.ReadOnlyState {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.state
+}
+
+func (m *ReadOnlyMode) Enabled() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.state.Enabled
+}
+
+// 読み取り専用モードの間、書き込みを行うルートを503で拒否する
+// GETでも書き込むルート（配送計画の作成など）があるため、メソッドではなく書き込むルートに付けて使う
+func (m *ReadOnlyMode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.Enabled() {
+			w.Header().Set("Retry-After", "30")
+			i18n.Error(w, r, http.StatusServiceUnavailable, i18n.ReadOnlyMode)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+
+	"backend/internal/redact"
+)
+
+// JSONのレスポンスに含まれる個人情報をpolicyに従って伏せ字にする
+// AdminScopePIIを持つ管理者のリクエストはそのまま返す
+func RedactMiddleware(policy *redact.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if HasAdminScope(r.Context(), AdminScopePII) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rw := &redactWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+			rw.finish(policy)
+		})
+	}
+}
+
+// JSONのレスポンスは本文を溜めておき、ハンドラーの完了後に伏せ字にして書き込む
+// JSON以外（CSVなど）はそのまま書き込む
+type redactWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	passthrough bool
+	body        bytes.Buffer
+}
+
+func (w *redactWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType != "application/json" {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *redactWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// JSON以外のレスポンスのみ送信する（JSONはfinishでまとめて送る）
+func (w *redactWriter) FlushError() error {
+	if !w.passthrough {
+		return nil
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *redactWriter) finish(policy *redact.Policy) {
+	if !w.wroteHeader || w.passthrough {
+		return
+	}
+	body := w.body.Bytes()
+	if masked, ok := policy.ApplyJSON(body); ok {
+		body = masked
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend/internal/i18n"
+)
+
+// 署名の検証のために読み込む本文の上限
+const maxSignedBodyBytes = 1 << 20
+
+// 使用済みのnonce（期限が切れたものは次の記録時にまとめて削除する）
+type nonceCache struct {
+	ttl   time.Duration
+	seen  map[string]time.Time
+	mutex sync.Mutex
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// 未使用のnonceであれば記録してtrueを返す
+func (c *nonceCache) remember(nonce string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if expiresAt, ok := c.seen[nonce]; ok && now.Before(expiresAt) {
+		return false
+	}
+	c.seen[nonce] = now.Add(c.ttl)
+	if len(c.seen) > 10000 {
+		c.cleanup(now)
+	}
+	return true
+}
+
+func (c *nonceCache) cleanup(now time.Time) {
+	for nonce, expiresAt := range c.seen {
+		if !now.Before(expiresAt) {
+			delete(c.seen, nonce)
+		}
+	}
+}
+
+// ロボットのリクエストの署名・時刻・nonceを検証し、盗聴したリクエストの再送を拒否する
+// ロボットは次のヘッダーを付けて送る
+//
+//	X-Robot-Timestamp: UNIX時刻（秒）
+//	X-Robot-Nonce:     リクエストごとに異なる文字列
+//	X-Robot-Signature: base64url(HMAC-SHA256(secret, メソッド\nパス?クエリ\nTimestamp\nNonce\nhex(SHA256(本文))))
+//
+// 時刻がwindow以上ずれたリクエストは拒否し、window内に同じnonceを使ったリクエストは再送とみなす
+func RobotReplayProtectionMiddleware(secret []byte, window time.Duration) func(http.Handler) http.Handler {
+	// 時刻のずれは前後どちらにも許すため、nonceはその両方の幅だけ覚えておく
+	nonces := newNonceCache(2 * window)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timestamp := r.Header.Get("X-Robot-Timestamp")
+			nonce := r.Header.Get("X-Robot-Nonce")
+			signature := r.Header.Get("X-Robot-Signature")
+			if timestamp == "" || nonce == "" || signature == "" {
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidRobotSignature)
+				return
+			}
+
+			now := time.Now()
+			sec, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidRobotSignature)
+				return
+			}
+			if skew := now.Sub(time.Unix(sec, 0)); skew > window || skew < -window {
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.StaleRobotRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+			if err != nil || len(body) > maxSignedBodyBytes {
+				i18n.Error(w, r, http.StatusBadRequest, i18n.InvalidRequestBody)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			bodyHash := sha256.Sum256(body)
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+			expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+			if !hmac.Equal([]byte(signature), []byte(expected)) {
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.InvalidRobotSignature)
+				return
+			}
+
+			// 署名が正しいリクエストのnonceだけを記録する（不正なリクエストでnonceを使い切らせない）
+			if !nonces.remember(nonce, now) {
+				slog.WarnContext(r.Context(), "[RobotReplay] 使用済みのnonceによるリクエストを拒否しました", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r))
+				i18n.Error(w, r, http.StatusUnauthorized, i18n.ReplayedRobotRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
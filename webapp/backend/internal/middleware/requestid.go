@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/internal/logging"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// リクエストごとにIDを決めてctxに入れ、X-Request-IDヘッダーで返す
+// クライアント（nginxなど）がX-Request-IDを付けている場合はその値を引き継ぐ
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"backend/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// エンドポイントごとにリクエスト・レスポンスの本文のサイズと、ハンドラーが記録した件数を集計する
+// エンドポイントはルートのパターンで区別し、どのルートにも一致しなかったリクエストとSSEは除く
+func ShapeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/health" || r.Header.Get("Accept") == "text/event-stream" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		cw := &countingWriter{ResponseWriter: w}
+		rec := &metrics.ShapeRecorder{}
+		r = r.WithContext(metrics.WithShapeRecorder(r.Context(), rec))
+
+		next.ServeHTTP(cw, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		// ハンドラーが本文を読み切らなかった場合も、宣言されたサイズを使う
+		requestBytes := max(body.n, r.ContentLength)
+		shape := metrics.Shape(r.Method + " " + rctx.RoutePattern())
+		shape.ObserveBytes(requestBytes, cw.n)
+		rec.Flush(shape)
+	})
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// http.ResponseControllerから元のResponseWriterの機能（Flushなど）を使えるようにする
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP INDEX idx_orders_external_ref,
+    DROP COLUMN external_ref;
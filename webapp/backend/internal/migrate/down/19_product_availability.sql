@@ -0,0 +1,5 @@
+ALTER TABLE products
+    DROP INDEX idx_products_available_from,
+    DROP INDEX idx_products_available_until,
+    DROP COLUMN available_from,
+    DROP COLUMN available_until;
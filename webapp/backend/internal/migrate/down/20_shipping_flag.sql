@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP INDEX idx_orders_is_shipping,
+    DROP COLUMN is_shipping;
@@ -0,0 +1,3 @@
+-- 制約だけを外す（1に直した価値・重量と変更履歴は戻さない）
+ALTER TABLE products
+    DROP CONSTRAINT chk_products_value_weight;
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP INDEX idx_orders_recipient_created,
+    DROP COLUMN recipient_user_id;
@@ -0,0 +1,163 @@
+// バイナリに埋め込んだSQLでスキーマを移行する
+// 適用するSQL（migrations/）は採点前にも実行される webapp/mysql/migration/ の写しで、go generateで同期する
+// 戻すSQL（down/）はこのパッケージにのみ置く（mysql/migration/に置くと採点前に実行されてしまうため）
+// mysql/migration/0_sample.sqlは各自の作業用でリポジトリでは管理しないため、バージョン1以降のみ埋め込む
+package migrate
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:generate sh -c "rm -f migrations/*.sql && cp ../../../mysql/migration/[1-9]*_*.sql migrations/"
+
+//go:embed migrations/*.sql down/*.sql
+var files embed.FS
+
+// {バージョン}_{名前}.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// 戻すSQL（用意していない場合は空）
+	Down string
+}
+
+// 埋め込んだマイグレーションをバージョン順に返す
+func Load() ([]Migration, error) {
+	ups, err := readDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	downs, err := readDir("down")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(ups))
+	for version, up := range ups {
+		m := Migration{Version: version, Name: up.name, Up: up.sql}
+		if down, ok := downs[version]; ok {
+			if down.name != up.name {
+				return nil, fmt.Errorf("down migration %d_%s does not match %d_%s", version, down.name, version, up.name)
+			}
+			m.Down = down.sql
+			delete(downs, version)
+		}
+		migrations = append(migrations, m)
+	}
+	for version, down := range downs {
+		return nil, fmt.Errorf("down migration %d_%s has no up migration", version, down.name)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+type sqlFile struct {
+	name string
+	sql  string
+}
+
+func readDir(dir string) (map[int]sqlFile, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]sqlFile, len(entries))
+	for _, e := range entries {
+		m := fileNamePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %s/%s", dir, e.Name())
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s/%s: %w", dir, e.Name(), err)
+		}
+		if dup, ok := result[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, dup.name, m[2])
+		}
+		body, err := fs.ReadFile(files, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result[version] = sqlFile{name: m[2], sql: string(body)}
+	}
+	return result, nil
+}
+
+// SQLファイルを文ごとに分ける（ドライバーは複数文の一括実行を許可していないため）
+// 文字列・識別子の引用符とコメントの中の;では区切らない。空の文は除く
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" && !isCommentOnly(s) {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) && script[end] != c {
+				if script[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end, len(script)-1)
+			current.WriteString(script[i : end+1])
+			i = end
+		case isLineComment(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			current.WriteString(script[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			current.WriteString(script[i : i+end+4])
+			i += end + 3
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// MySQLの行コメント（#、または--の後に空白）で始まるか
+func isLineComment(s string) bool {
+	if strings.HasPrefix(s, "#") {
+		return true
+	}
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2])))
+}
+
+// コメントと空白だけの文か
+func isCommentOnly(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "simple",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "semicolon_in_quotes",
+			script: `INSERT INTO a VALUES ('x;y', "p;q", 'it\'s;');` + "\nALTER TABLE `a;b` ADD c INT;",
+			want:   []string{`INSERT INTO a VALUES ('x;y', "p;q", 'it\'s;')`, "ALTER TABLE `a;b` ADD c INT"},
+		},
+		{
+			name:   "semicolon_in_comments",
+			script: "-- drop; later\nCREATE TABLE a (id INT); # note; here\n/* a; b */ CREATE TABLE b (id INT);",
+			want:   []string{"-- drop; later\nCREATE TABLE a (id INT)", "# note; here\n/* a; b */ CREATE TABLE b (id INT)"},
+		},
+		{
+			// --の後に空白がないものはMySQLでは行コメントにならない
+			name:   "double_dash_without_space",
+			script: "SELECT 1--1;SELECT 2;",
+			want:   []string{"SELECT 1--1", "SELECT 2"},
+		},
+		{
+			name:   "trailing_statement_without_terminator",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT)\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "comment_only_tail",
+			script: "CREATE TABLE a (id INT);\n-- end of file\n# done\n",
+			want:   []string{"CREATE TABLE a (id INT)"},
+		},
+		{
+			name:   "empty",
+			script: " \n;;\n",
+			want:   nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := splitStatements(c.script); !slices.Equal(got, c.want) {
+				t.Fatalf("splitStatements() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+var (
+	createTablePattern = regexp.MustCompile("(?i)^CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?`?(\\w+)`?")
+	inlineIndexPattern = regexp.MustCompile("(?i)\\b(?:UNIQUE\\s+|FULLTEXT\\s+)?(?:INDEX|KEY)\\s+`?(\\w+)`?\\s*\\(")
+	createIndexPattern = regexp.MustCompile("(?i)\\bCREATE\\s+(?:UNIQUE\\s+|FULLTEXT\\s+)?INDEX\\s+`?(\\w+)`?\\s+ON\\s+`?(\\w+)`?")
+	dropIndexPattern   = regexp.MustCompile("(?i)^DROP\\s+INDEX\\s+`?(\\w+)`?\\s+ON\\s+`?(\\w+)`?")
+	alterTablePattern  = regexp.MustCompile("(?i)^ALTER\\s+TABLE\\s+`?(\\w+)`?")
+	addIndexPattern    = regexp.MustCompile("(?i)\\bADD\\s+(?:UNIQUE\\s+|FULLTEXT\\s+)?(?:INDEX|KEY)\\s+`?(\\w+)`?")
+	alterDropIndex     = regexp.MustCompile("(?i)\\bDROP\\s+(?:INDEX|KEY)\\s+`?(\\w+)`?")
+	addColumnPattern   = regexp.MustCompile("(?i)\\bADD\\s+COLUMN\\s+`?(\\w+)`?")
+	dropColumnPattern  = regexp.MustCompile("(?i)\\bDROP\\s+COLUMN\\s+`?(\\w+)`?")
+)
+
+// マイグレーションで追加したインデックスと列をテーブルごとに記録し、
+// 同名のものを再度作成する（MySQLのエラー1061/1060）、存在しないインデックスを削除する（1091）文を検出する
+type schemaModel struct {
+	indexes map[string]map[string]bool
+	columns map[string]map[string]bool
+}
+
+func (s *schemaModel) add(set map[string]map[string]bool, table, name, kind string) error {
+	if set[table] == nil {
+		set[table] = make(map[string]bool)
+	}
+	if set[table][name] {
+		return fmt.Errorf("duplicate %s %s on %s", kind, name, table)
+	}
+	set[table][name] = true
+	return nil
+}
+
+func (s *schemaModel) apply(stmt string) error {
+	var body []string
+	for _, line := range strings.Split(stmt, "\n") {
+		if !isLineComment(strings.TrimSpace(line)) {
+			body = append(body, line)
+		}
+	}
+	stmt = strings.TrimSpace(strings.Join(body, "\n"))
+
+	if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+		for _, idx := range inlineIndexPattern.FindAllStringSubmatch(stmt, -1) {
+			if err := s.add(s.indexes, m[1], idx[1], "index"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if m := createIndexPattern.FindStringSubmatch(stmt); m != nil {
+		return s.add(s.indexes, m[2], m[1], "index")
+	}
+	if m := dropIndexPattern.FindStringSubmatch(stmt); m != nil {
+		if !s.indexes[m[2]][m[1]] {
+			return fmt.Errorf("drop of unknown index %s on %s", m[1], m[2])
+		}
+		delete(s.indexes[m[2]], m[1])
+		return nil
+	}
+	m := alterTablePattern.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil
+	}
+	table := m[1]
+	for _, idx := range addIndexPattern.FindAllStringSubmatch(stmt, -1) {
+		if err := s.add(s.indexes, table, idx[1], "index"); err != nil {
+			return err
+		}
+	}
+	for _, idx := range alterDropIndex.FindAllStringSubmatch(stmt, -1) {
+		if !s.indexes[table][idx[1]] {
+			return fmt.Errorf("drop of unknown index %s on %s", idx[1], table)
+		}
+		delete(s.indexes[table], idx[1])
+	}
+	for _, col := range addColumnPattern.FindAllStringSubmatch(stmt, -1) {
+		if err := s.add(s.columns, table, col[1], "column"); err != nil {
+			return err
+		}
+	}
+	for _, col := range dropColumnPattern.FindAllStringSubmatch(stmt, -1) {
+		delete(s.columns[table], col[1])
+	}
+	return nil
+}
+
+// 埋め込んだマイグレーションをすべて順に適用し、続けて戻すSQLを新しい順に適用しても矛盾しないこと
+func TestEmbeddedMigrationsApplyInOrder(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	// 0_sample.sqlは作業用のため埋め込まない。バージョンは1から欠番なく並ぶ
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d_%s at position %d, want version %d", m.Version, m.Name, i, i+1)
+		}
+	}
+
+	schema := &schemaModel{indexes: map[string]map[string]bool{}, columns: map[string]map[string]bool{}}
+	for _, m := range migrations {
+		statements := splitStatements(m.Up)
+		if len(statements) == 0 {
+			t.Fatalf("migration %d_%s has no statements", m.Version, m.Name)
+		}
+		for i, stmt := range statements {
+			if err := schema.apply(stmt); err != nil {
+				t.Fatalf("up %d_%s statement %d: %v", m.Version, m.Name, i+1, err)
+			}
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		for j, stmt := range splitStatements(m.Down) {
+			if err := schema.apply(stmt); err != nil {
+				t.Fatalf("down %d_%s statement %d: %v", m.Version, m.Name, j+1, err)
+			}
+		}
+	}
+}
+
+func TestSchemaModelRejectsDuplicateIndex(t *testing.T) {
+	schema := &schemaModel{indexes: map[string]map[string]bool{}, columns: map[string]map[string]bool{}}
+	script := "ALTER TABLE users ADD INDEX idx_users_user_name (user_name);\nCREATE INDEX idx_users_user_name ON users(user_name);"
+	var err error
+	for _, stmt := range splitStatements(script) {
+		if err = schema.apply(stmt); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		t.Fatal("apply() error = nil, want duplicate index error")
+	}
+}
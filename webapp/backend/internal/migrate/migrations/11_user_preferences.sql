@@ -0,0 +1,8 @@
+-- ユーザーごとの一覧表示の既定値
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id INT NOT NULL PRIMARY KEY,
+    page_size INT NULL,
+    sort_order VARCHAR(4) NULL,
+    locale VARCHAR(16) NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
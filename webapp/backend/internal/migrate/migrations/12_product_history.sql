@@ -0,0 +1,13 @@
+-- 商品の価値・重量の変更履歴
+-- 価値・重量は配送計画の結果を左右するため、変更を追跡できるようにする
+CREATE TABLE IF NOT EXISTS product_history (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    product_id INT UNSIGNED NOT NULL,
+    old_value INT UNSIGNED NOT NULL,
+    new_value INT UNSIGNED NOT NULL,
+    old_weight INT UNSIGNED NOT NULL,
+    new_weight INT UNSIGNED NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    changed_at DATETIME NOT NULL,
+    INDEX idx_product_history_product_changed_at (product_id, changed_at)
+);
@@ -0,0 +1,3 @@
+-- セッション作成時のクライアント指紋（User-AgentとIPアドレスの上位部分のハッシュ）
+-- 既存のセッションはNULLのままとし、照合しない
+ALTER TABLE user_sessions ADD COLUMN fingerprint CHAR(64) NULL;
@@ -0,0 +1,30 @@
+-- 現在のクエリが前提としている複合インデックス（起動時にinternal/server/startup.goで存在を確認する）
+-- 同名のインデックスが既にある場合は作成しない（MySQLのCREATE INDEXにはIF NOT EXISTSがないため、存在を確認してから実行する）
+
+-- 注文一覧を注文日時順に読む（WHERE user_id = ? ORDER BY created_at）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'orders' AND index_name = 'idx_orders_user_created'),
+    'DO 0', 'CREATE INDEX idx_orders_user_created ON orders(user_id, created_at)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- 配送待ち注文を古い順に取り出す（WHERE shipped_status = ? ORDER BY created_at）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'orders' AND index_name = 'idx_orders_status_created'),
+    'DO 0', 'CREATE INDEX idx_orders_status_created ON orders(shipped_status, created_at)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- セッションの照合（WHERE session_uuid = ? AND expires_at > ?）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'user_sessions' AND index_name = 'idx_user_sessions_uuid_expires'),
+    'DO 0', 'CREATE INDEX idx_user_sessions_uuid_expires ON user_sessions(session_uuid, expires_at)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- 商品名での並び替え（ORDER BY name）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'products' AND index_name = 'idx_products_name'),
+    'DO 0', 'CREATE INDEX idx_products_name ON products(name)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
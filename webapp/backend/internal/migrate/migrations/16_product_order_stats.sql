@@ -0,0 +1,8 @@
+-- 商品ごとの注文数と最終注文時刻の集計
+-- 商品一覧で毎回ordersを集計しないよう、定期的にまとめて更新する
+CREATE TABLE IF NOT EXISTS product_order_stats (
+    product_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    order_count INT UNSIGNED NOT NULL,
+    last_ordered_at DATETIME NULL,
+    updated_at DATETIME NOT NULL
+);
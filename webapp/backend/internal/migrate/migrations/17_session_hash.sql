@@ -0,0 +1,5 @@
+-- セッションIDはSHA-256のハッシュ（16進64文字）だけを保存し、元の値はクライアントのCookieにのみ残す
+-- DBのダンプが漏れても、保存された値をそのままセッションとして使えないようにする
+ALTER TABLE user_sessions MODIFY session_uuid CHAR(64) NOT NULL;
+-- 既存のセッションは保存済みのUUIDをハッシュに置き換える（発行済みのCookieは引き続き使える）
+UPDATE user_sessions SET session_uuid = SHA2(session_uuid, 256) WHERE CHAR_LENGTH(session_uuid) = 36;
@@ -0,0 +1,7 @@
+-- 商品を注文できる期間（季節商品など）。NULLの場合はその側の期限なし
+-- 期間外の商品は一覧に表示せず、注文も受け付けない
+ALTER TABLE products
+    ADD COLUMN available_from DATETIME NULL,
+    ADD COLUMN available_until DATETIME NULL,
+    ADD INDEX idx_products_available_from (available_from),
+    ADD INDEX idx_products_available_until (available_until);
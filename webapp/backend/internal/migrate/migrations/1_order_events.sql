@@ -0,0 +1,17 @@
+-- 注文ステータスの変遷を記録するイベントテーブル
+-- 配送失敗の理由コードや再キュー投入の履歴を保持し、管理者向け統計の集計元として使用する
+CREATE TABLE IF NOT EXISTS order_events (
+    event_id BIGINT NOT NULL AUTO_INCREMENT,
+    order_id INT UNSIGNED NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    reason VARCHAR(64),
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (event_id),
+    INDEX idx_order_events_order (order_id, event_type),
+    INDEX idx_order_events_type_created (event_type, created_at),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
+
+-- 配送失敗した注文を自動で再キュー投入する時刻
+ALTER TABLE orders ADD COLUMN retry_at DATETIME NULL;
+CREATE INDEX idx_orders_status_retry ON orders(shipped_status, retry_at);
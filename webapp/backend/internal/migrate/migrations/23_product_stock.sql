@@ -0,0 +1,4 @@
+-- 商品の在庫数。NULLの場合は在庫を管理しない（従来どおり数量に関係なく注文を受け付ける）
+-- 注文の作成時に注文数量分を減らし、注文のキャンセル時に1つ戻す
+ALTER TABLE products
+    ADD COLUMN stock INT NULL;
@@ -0,0 +1,4 @@
+-- 商品の発注点。在庫数がこれを下回った商品を管理者に通知し、在庫が少ない商品の一覧に含める
+-- NULLの場合は通知しない（在庫を管理していない商品もstockがNULLのため対象外）
+ALTER TABLE products
+    ADD COLUMN reorder_threshold INT NULL;
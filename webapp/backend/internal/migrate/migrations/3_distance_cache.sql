@@ -0,0 +1,13 @@
+-- 座標間の移動時間キャッシュ（ルート最適化で使用）
+-- 座標は小数点以下5桁(約1m)に丸めてキーとする
+CREATE TABLE IF NOT EXISTS distance_cache (
+    from_lat DECIMAL(8,5) NOT NULL,
+    from_lng DECIMAL(8,5) NOT NULL,
+    to_lat DECIMAL(8,5) NOT NULL,
+    to_lng DECIMAL(8,5) NOT NULL,
+    travel_seconds INT UNSIGNED NOT NULL,
+    computed_at DATETIME NOT NULL,
+    PRIMARY KEY (from_lat, from_lng, to_lat, to_lng)
+);
+
+CREATE INDEX idx_orders_coordinates ON orders(latitude, longitude);
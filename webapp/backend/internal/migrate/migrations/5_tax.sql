@@ -0,0 +1,18 @@
+-- 税率の判定に使用する商品カテゴリ
+ALTER TABLE products ADD COLUMN category VARCHAR(32) NOT NULL DEFAULT 'general';
+
+-- カテゴリ・地域（配送ゾーン）ごとの税率（basis point: 1000 = 10%）
+-- '*' はすべてに一致し、より具体的な行が優先される
+CREATE TABLE IF NOT EXISTS tax_rates (
+    category VARCHAR(32) NOT NULL,
+    region VARCHAR(32) NOT NULL,
+    rate_bp INT UNSIGNED NOT NULL,
+    PRIMARY KEY (category, region)
+);
+
+INSERT INTO tax_rates (category, region, rate_bp) VALUES
+    ('*', '*', 1000),
+    ('food', '*', 800);
+
+-- 注文行ごとの税額（商品価格＋送料に対して課税）
+ALTER TABLE orders ADD COLUMN tax_amount INT UNSIGNED NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- 部分一致検索用のngram全文検索カラム
+-- 生成カラム(STORED)なので商品の書き込み時にMySQLが自動で更新する
+ALTER TABLE products
+    ADD COLUMN search_name VARCHAR(255) GENERATED ALWAYS AS (LOWER(name)) STORED,
+    ADD COLUMN search_text TEXT GENERATED ALWAYS AS (LOWER(CONCAT_WS(' ', name, description))) STORED;
+
+ALTER TABLE products ADD FULLTEXT INDEX ft_products_search_name (search_name) WITH PARSER ngram;
+ALTER TABLE products ADD FULLTEXT INDEX ft_products_search_text (search_text) WITH PARSER ngram;
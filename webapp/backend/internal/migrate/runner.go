@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 複数のインスタンスが同時に起動しても1つずつ適用するための名前付きロック
+const (
+	lockName    = "schema_migrations"
+	lockTimeout = 60 * time.Second
+)
+
+const createVersionsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+	)`
+
+var ErrNoDownMigration = errors.New("no down migration")
+
+// マイグレーションの適用状況
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// 適用済みのバージョンはschema_migrationsに記録する
+// MySQLのDDLはトランザクションで戻せないため、途中の文で失敗したファイルは記録せずにエラーを返す（手で直してから再実行する）
+type Runner struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func New(db *sqlx.DB) (*Runner, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: migrations}, nil
+}
+
+// 未適用のマイグレーションをすべてバージョン順に適用し、適用したものを返す
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn, done map[int]time.Time) error {
+		for _, m := range r.migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			log.Printf("[migrate] applying %d_%s", m.Version, m.Name)
+			if err := execScript(ctx, conn, m.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// 適用済みのマイグレーションを新しい順にsteps件戻し、戻したものを返す
+// 戻すSQLがないマイグレーションに当たった場合は、そこで止めてErrNoDownMigrationを返す
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn, done map[int]time.Time) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("%w for %d_%s", ErrNoDownMigration, m.Version, m.Name)
+			}
+			log.Printf("[migrate] reverting %d_%s", m.Version, m.Name)
+			if err := execScript(ctx, conn, m.Down); err != nil {
+				return fmt.Errorf("down migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// version以下のマイグレーションを、実行せずに適用済みとして記録する
+// restore_and_migration.shや採点前の処理など、この仕組みの外で適用済みのDBに使う
+func (r *Runner) Baseline(ctx context.Context, version int) (int, error) {
+	recorded := 0
+	err := r.withLock(ctx, func(conn *sql.Conn, done map[int]time.Time) error {
+		for _, m := range r.migrations {
+			if _, ok := done[m.Version]; ok || m.Version > version {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+				return err
+			}
+			recorded++
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// すべてのマイグレーションの適用状況
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(conn *sql.Conn, done map[int]time.Time) error {
+		statuses = make([]Status, len(r.migrations))
+		for i, m := range r.migrations {
+			statuses[i] = Status{Migration: m}
+			if at, ok := done[m.Version]; ok {
+				statuses[i].Applied = true
+				statuses[i].AppliedAt = &at
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// 1つの接続で名前付きロックを取ってfnを実行する（doneは適用済みのバージョンと適用日時）
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn, done map[int]time.Time) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout/time.Second)).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock after %s", lockTimeout)
+	}
+	defer func() {
+		// 解放に失敗しても接続を閉じればロックは外れる
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			log.Printf("[migrate] failed to release lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, createVersionsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for i, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
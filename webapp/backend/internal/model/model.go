@@ -18,6 +18,121 @@ type Product struct {
 	Weight      int    `db:"weight"       json:"weight"`
 	Image       string `db:"image"        json:"image"`
 	Description string `db:"description"  json:"description"`
+	Category    string `db:"category"     json:"category,omitempty"`
+	// 注文できる期間（nilの場合はその側の期限なし）
+	AvailableFrom  *time.Time `db:"available_from"  json:"available_from,omitempty"`
+	AvailableUntil *time.Time `db:"available_until" json:"available_until,omitempty"`
+	// 在庫数（nilの場合は在庫を管理しない）。注文時の確認用で、商品一覧には含めない
+	Stock *int `db:"stock" json:"stock,omitempty"`
+	// 発注点（nilの場合は在庫が少なくなっても通知しない）
+	ReorderThreshold *int `db:"reorder_threshold" json:"reorder_threshold,omitempty"`
+	// 商品一覧でinclude=statsを指定した場合のみ設定する
+	OrderCount    *int       `db:"-" json:"order_count,omitempty"`
+	LastOrderedAt *time.Time `db:"-" json:"last_ordered_at,omitempty"`
+}
+
+// 時刻tに注文できるか（available_from以上、available_until未満）
+func (p Product) AvailableAt(t time.Time) bool {
+	if p.AvailableFrom != nil && t.Before(*p.AvailableFrom) {
+		return false
+	}
+	return p.AvailableUntil == nil || t.Before(*p.AvailableUntil)
+}
+
+// 注文できる商品とその注文数（注文のない商品は0）。おすすめ商品の重み付き抽出用
+type ProductPopularity struct {
+	ProductID  int `db:"product_id"`
+	OrderCount int `db:"order_count"`
+}
+
+// 商品ごとの注文数の集計
+type ProductOrderStats struct {
+	ProductID     int          `db:"product_id"`
+	OrderCount    int          `db:"order_count"`
+	LastOrderedAt sql.NullTime `db:"last_ordered_at"`
+}
+
+// 商品の価値・重量の変更（nilの項目は変更しない）
+type ProductUpdateRequest struct {
+	Value  *int `json:"value,omitempty"`
+	Weight *int `json:"weight,omitempty"`
+}
+
+// 商品の価値・重量の一括補正（省略した項目は変更しない）
+type ProductRecalibrationRequest struct {
+	Adjustments []ProductAdjustment `json:"adjustments"`
+}
+
+type ProductAdjustment struct {
+	ProductID int  `json:"product_id"`
+	Value     *int `json:"value,omitempty"`
+	Weight    *int `json:"weight,omitempty"`
+}
+
+// 一括補正の結果（DryRunの場合は更新せず、Projectionに配送待ち注文への影響を含める）
+type ProductRecalibrationReport struct {
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// 更新した（DryRunの場合は更新する）商品数
+	Updated int `json:"updated"`
+	// 現在の値と同じため更新しなかった商品数
+	Unchanged int `json:"unchanged"`
+	// 不正なため更新しなかった行数（Errorsは先頭の一部のみ）
+	Invalid    int                             `json:"invalid"`
+	Errors     []ProductRecalibrationError     `json:"errors"`
+	Batches    int                             `json:"batches"`
+	Projection *ProductRecalibrationProjection `json:"projection,omitempty"`
+}
+
+// Rowは、CSVの場合は行番号、JSONの場合はadjustmentsの1から始まる位置
+type ProductRecalibrationError struct {
+	Row       int    `json:"row"`
+	ProductID int    `json:"product_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// 補正による現在の配送待ち注文の価値・重量の変化
+type ProductRecalibrationProjection struct {
+	ShippingOrders    int `json:"shipping_orders"`
+	AffectedOrders    int `json:"affected_orders"`
+	TotalValueBefore  int `json:"total_value_before"`
+	TotalValueAfter   int `json:"total_value_after"`
+	TotalWeightBefore int `json:"total_weight_before"`
+	TotalWeightAfter  int `json:"total_weight_after"`
+	// 積載量を指定した場合、その積載量で計画した場合の価値（計算量が大きすぎる場合は省略してWarningsに理由を含める）
+	Capacity        int      `json:"capacity,omitempty"`
+	PlanValueBefore *int     `json:"plan_value_before,omitempty"`
+	PlanValueAfter  *int     `json:"plan_value_after,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// 商品ごとの配送待ちの注文数
+type ShippingProductCount struct {
+	ProductID int `db:"product_id"`
+	Value     int `db:"value"`
+	Weight    int `db:"weight"`
+	Orders    int `db:"orders"`
+}
+
+// 価値・重量が不正な商品（Problemsはvalue_not_positive・weight_not_positive）
+type InvalidProduct struct {
+	ProductID int      `json:"product_id"`
+	Name      string   `json:"name"`
+	Value     int      `json:"value"`
+	Weight    int      `json:"weight"`
+	Problems  []string `json:"problems"`
+}
+
+// 商品の価値・重量の変更履歴
+type ProductHistory struct {
+	ID        int64     `db:"id"         json:"id"`
+	ProductID int       `db:"product_id" json:"product_id"`
+	OldValue  int       `db:"old_value"  json:"old_value"`
+	NewValue  int       `db:"new_value"  json:"new_value"`
+	OldWeight int       `db:"old_weight" json:"old_weight"`
+	NewWeight int       `db:"new_weight" json:"new_weight"`
+	Source    string    `db:"source"     json:"source"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
 }
 
 type Order struct {
@@ -30,13 +145,73 @@ type Order struct {
 	Value         int          `db:"value"           json:"value"`
 	CreatedAt     time.Time    `db:"created_at"      json:"created_at"`
 	ArrivedAt     sql.NullTime `db:"arrived_at"      json:"arrived_at"`
+	Address       *string      `db:"address"         json:"address,omitempty"`
+	Latitude      *float64     `db:"latitude"        json:"latitude,omitempty"`
+	Longitude     *float64     `db:"longitude"       json:"longitude,omitempty"`
+	ShippingCost  int          `db:"shipping_cost"   json:"shipping_cost,omitempty"`
+	ShippingZone  *string      `db:"shipping_zone"   json:"shipping_zone,omitempty"`
+	TaxAmount     int          `db:"tax_amount"      json:"tax_amount,omitempty"`
+	TrackingToken *string      `db:"tracking_token"  json:"tracking_token,omitempty"`
+	RobotID       *string      `db:"robot_id"        json:"robot_id,omitempty"`
+	DeliveringAt  *time.Time   `db:"delivering_at"   json:"delivering_at,omitempty"`
+	// ギフトの受取人（通常の注文はnil）
+	RecipientUserID *int `db:"recipient_user_id" json:"recipient_user_id,omitempty"`
+}
+
+// 配送計画を作れるだけの配送待ち注文があるか（/api/robot/plan/wait）
+type PlanAvailability struct {
+	Ready    bool `json:"ready"`
+	Capacity int  `json:"capacity"`
+	// 積載量に収まる重量の配送待ち注文の数と、その重量の合計
+	ShippingOrders  int `json:"shipping_orders"`
+	AvailableWeight int `json:"available_weight"`
+	// AvailableWeightがこの値以上になるとReadyになる
+	RequiredWeight int   `json:"required_weight"`
+	WaitedMs       int64 `json:"waited_ms"`
 }
 
+// 配送待ち注文の数と重量の合計
+type ShippingLoad struct {
+	Orders int `db:"orders"`
+	Weight int `db:"weight"`
+}
+
+// 配送計画
+// 積載量(capacity)は注文の重量(Order.Weight)と同じ単位（グラム）で表す
 type DeliveryPlan struct {
 	RobotID     string  `json:"robot_id"`
 	TotalWeight int     `json:"total_weight"`
 	TotalValue  int     `json:"total_value"`
 	Orders      []Order `json:"orders"`
+	// 最適化した訪問順での推定移動時間（座標を持つ注文のみ対象）
+	EstimatedTravelSeconds int `json:"estimated_travel_seconds,omitempty"`
+	// 積載量を上限に丸めた場合などの警告
+	Warnings []string `json:"warnings,omitempty"`
+	// 計画の内訳（debugパラメータ指定時のみ）
+	Diagnostics *PlanDiagnostics `json:"diagnostics,omitempty"`
+	// ステータス報告時に提示するトークン（ROBOT_CLAIM_SECRET設定時のみ）
+	ClaimToken string `json:"claim_token,omitempty"`
+}
+
+// 配送計画を作成したアルゴリズムと、その実行コスト
+type PlanDiagnostics struct {
+	// 計画の候補とした配送待ち注文の数
+	Candidates int `json:"candidates"`
+	// 除外中のため候補としなかった配送待ち注文の数
+	Excluded int `json:"excluded,omitempty"`
+	// "dp"（動的計画法）または "greedy"（価値密度順の貪欲法）
+	Algorithm string  `json:"algorithm"`
+	RuntimeMs float64 `json:"runtime_ms"`
+	// アルゴリズムが確保する作業領域の推定サイズ
+	MemoryEstimateBytes int64 `json:"memory_estimate_bytes"`
+	// 最適解であることが保証されているか
+	Optimal bool `json:"optimal"`
+	// 貪欲法の場合の価値の上限（分割可能とみなした場合の最大値）
+	UpperBound int `json:"upper_bound,omitempty"`
+	// 動的計画法で前回の計画から引き継いだ行数
+	ReusedRows int `json:"reused_rows,omitempty"`
+	// 動的計画法がリクエストの残り時間内に終わらず、貪欲法に切り替えた
+	BudgetFallback bool `json:"budget_fallback,omitempty"`
 }
 
 type LoginRequest struct {
@@ -45,7 +220,66 @@ type LoginRequest struct {
 }
 
 type CreateOrderRequest struct {
-	Items []RequestItem `json:"items"`
+	Items   []RequestItem `json:"items"`
+	Address string        `json:"address,omitempty"`
+	// 指定した場合は、このユーザーへのギフトとして注文する
+	RecipientUserID *int `json:"recipient_user_id,omitempty"`
+}
+
+// 注文に保存する配送先住所と座標、ギフトの受取人
+// 座標はジオコーディングに失敗した場合nilのまま保存される
+type DeliveryAddress struct {
+	Address   string
+	Latitude  *float64
+	Longitude *float64
+	// ギフトの受取人（通常の注文はnil）
+	RecipientUserID *int
+}
+
+// 受け取ったギフト（受取人向けの表示）
+// 価格・送料・税額は贈り主だけが見られるため含めない
+type ReceivedGift struct {
+	OrderID       int64      `json:"order_id"`
+	ProductID     int        `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	ShippedStatus string     `json:"shipped_status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ArrivedAt     *time.Time `json:"arrived_at,omitempty"`
+	// 配送状況の公開追跡用（/api/track/{token}）
+	TrackingToken *string `json:"tracking_token,omitempty"`
+}
+
+type ReceivedGiftList struct {
+	Data  []ReceivedGift `json:"data"`
+	Total int            `json:"total"`
+}
+
+// 在庫数が発注点を下回った商品
+type LowStockProduct struct {
+	ProductID        int    `db:"product_id"        json:"product_id"`
+	Name             string `db:"name"              json:"name"`
+	Stock            int    `db:"stock"             json:"stock"`
+	ReorderThreshold int    `db:"reorder_threshold" json:"reorder_threshold"`
+}
+
+type LowStockList struct {
+	Data  []LowStockProduct `json:"data"`
+	Total int               `json:"total"`
+}
+
+// 注文作成・再注文のレスポンス（注文IDは作成順）
+// 注文IDは従来のクライアントとの互換のため文字列で返す
+type CreateOrdersResponse struct {
+	Message  string   `json:"message"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// 注文数量の上限超過時のレスポンス
+type OrderLimitResponse struct {
+	Error     string `json:"error"`
+	Limit     string `json:"limit"`
+	Max       int    `json:"max"`
+	Requested int    `json:"requested"`
 }
 
 type RequestItem struct {
@@ -53,9 +287,39 @@ type RequestItem struct {
 	Quantity  int `json:"quantity"`
 }
 
+// 注文前チェックの商品ごとの結果
+type OrderItemDiagnostic struct {
+	ProductID int      `json:"product_id"`
+	Quantity  int      `json:"quantity"`
+	Valid     bool     `json:"valid"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// 注文前チェックの結果（Errorsは数量の上限など注文全体の問題）
+type OrderValidationResponse struct {
+	Valid  bool                  `json:"valid"`
+	Items  []OrderItemDiagnostic `json:"items"`
+	Errors []string              `json:"errors,omitempty"`
+}
+
+// 注文作成時に1商品ごとに確定する内容
+// Quantity分の注文行が同じ内容で作成される
+type OrderLine struct {
+	ProductID    int
+	Quantity     int
+	Weight       int
+	Value        int
+	ShippingCost int
+	ShippingZone string
+	TaxAmount    int
+	// 在庫を管理している商品か（注文時に在庫数を減らす）
+	Stocked bool
+}
+
 type UpdateOrderStatusRequest struct {
-	OrderID   int64  `json:"order_id"`
-	NewStatus string `json:"new_status"`
+	OrderID    int64  `json:"order_id"`
+	NewStatus  string `json:"new_status"`
+	ClaimToken string `json:"claim_token,omitempty"`
 }
 
 type ListRequest struct {
@@ -66,4 +330,511 @@ type ListRequest struct {
 	SortField string `json:"sort_field"`
 	SortOrder string `json:"sort_order"`
 	Offset    int    `json:"-"`
+	// 同義語展開後の検索語（Searchを含む）。空の場合はSearchのみで検索する
+	SearchTerms []string `json:"-"`
+	// 外部検索バックエンドが設定されている場合のみ有効なオプション
+	Fuzzy  bool `json:"fuzzy,omitempty"`
+	Facets bool `json:"facets,omitempty"`
+	// レスポンスに含めるフィールド（空の場合は全フィールド）
+	Fields []string `json:"fields,omitempty"`
+	// 追加で含める情報（商品一覧では"stats"で注文数・最終注文時刻を含める）
+	Include []string `json:"include,omitempty"`
+	// 注文一覧のキーセットページング（前のページのnext_cursorを指定する）
+	// 最初のページはPaginationに"cursor"を指定する。指定した場合PageとSortField・SortOrderは使わない
+	Cursor     string `json:"cursor,omitempty"`
+	Pagination string `json:"pagination,omitempty"`
+	// Cursorを復元した位置（この注文より後ろを返す）
+	After *OrderCursor `json:"-"`
+	// 商品検索の方式（空の場合はFULLTEXTインデックスがあれば全文検索）。シャドウでの比較・段階的な切り替え用
+	SearchMethod string `json:"-"`
+	// シャドウでの比較用のクエリ（キャッシュを読み書きしない）
+	Shadow bool `json:"-"`
+}
+
+// 全文検索を使わずLIKEで商品を検索する
+const SearchMethodLike = "like"
+
+// 注文一覧をキーセットページングで取得する
+const PaginationCursor = "cursor"
+
+// 注文一覧のキーセットページングの位置（前のページの最後の注文）
+// クライアントには不透明な文字列（next_cursor）として渡す
+type OrderCursor struct {
+	SortField string `json:"f"`
+	SortOrder string `json:"o"`
+	OrderID   int64  `json:"id"`
+	// 並び替えの列の値（order_idで並べる場合・arrived_atがNULLの場合はnil）
+	Key *string `json:"k,omitempty"`
+}
+
+// 商品一覧のincludeに指定すると、商品ごとの注文数・最終注文時刻を含める
+const ProductIncludeStats = "stats"
+
+// 一覧表示の既定値（nilの項目は未設定）
+type UserPreferences struct {
+	PageSize  *int    `db:"page_size"  json:"page_size,omitempty"`
+	SortOrder *string `db:"sort_order" json:"sort_order,omitempty"`
+	Locale    *string `db:"locale"     json:"locale,omitempty"`
+}
+
+// 注文一覧でfieldsに指定できるフィールド
+var OrderListFields = []string{"order_id", "product_id", "product_name", "shipped_status", "created_at", "arrived_at"}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type ProductList struct {
+	Data   []Product                `json:"data"`
+	Total  int                      `json:"total"`
+	Facets map[string][]FacetBucket `json:"facets,omitempty"`
+}
+
+type OutboxEntry struct {
+	ID        int64 `db:"id"`
+	ProductID int   `db:"product_id"`
+}
+
+// 配送失敗の理由コード
+const (
+	FailureReasonAbsent          = "recipient_absent"
+	FailureReasonAddressNotFound = "address_not_found"
+	FailureReasonDamaged         = "damaged"
+	FailureReasonRefused         = "refused"
+	FailureReasonOther           = "other"
+)
+
+type DeliveryFailedRequest struct {
+	OrderID    int64  `json:"order_id"`
+	Reason     string `json:"reason"`
+	ClaimToken string `json:"claim_token,omitempty"`
+}
+
+type OrderCancelResponse struct {
+	OrderID       int64  `json:"order_id"`
+	ShippedStatus string `json:"shipped_status"`
+}
+
+type DeliveryFailedResponse struct {
+	OrderID int64     `json:"order_id"`
+	Status  string    `json:"status"`
+	RetryAt time.Time `json:"retry_at"`
+}
+
+type OrderEvent struct {
+	EventID   int64          `db:"event_id"   json:"event_id"`
+	OrderID   int64          `db:"order_id"   json:"order_id"`
+	EventType string         `db:"event_type" json:"event_type"`
+	Reason    sql.NullString `db:"reason"     json:"reason"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+type FailureReasonCount struct {
+	Reason string `db:"reason" json:"reason"`
+	Count  int    `db:"count"  json:"count"`
+}
+
+type DeliveryFailureStats struct {
+	TotalFailures   int                  `json:"total_failures"`
+	TotalRequeued   int                  `json:"total_requeued"`
+	CurrentlyFailed int                  `json:"currently_failed"`
+	ByReason        []FailureReasonCount `json:"by_reason"`
+}
+
+type SLADailyStat struct {
+	Day        string  `db:"day"       json:"day"`
+	Completed  int     `db:"completed" json:"completed"`
+	Breached   int     `db:"breached"  json:"breached"`
+	BreachRate float64 `db:"-"         json:"breach_rate"`
+}
+
+type SLAStats struct {
+	SLASeconds int            `json:"sla_seconds"`
+	Daily      []SLADailyStat `json:"daily"`
+}
+
+type AdminStats struct {
+	DeliveryFailures DeliveryFailureStats `json:"delivery_failures"`
+	Revenue          RevenueStats         `json:"revenue"`
+	SLA              SLAStats             `json:"sla"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `db:"latitude"  json:"latitude"`
+	Longitude float64 `db:"longitude" json:"longitude"`
+}
+
+// 2地点間の移動時間
+type TravelTime struct {
+	FromLat       float64   `db:"from_lat"`
+	FromLng       float64   `db:"from_lng"`
+	ToLat         float64   `db:"to_lat"`
+	ToLng         float64   `db:"to_lng"`
+	TravelSeconds int       `db:"travel_seconds"`
+	ComputedAt    time.Time `db:"computed_at"`
+}
+
+type PrecomputeDistancesResponse struct {
+	Coordinates int `json:"coordinates"`
+	Pairs       int `json:"pairs"`
+}
+
+// 注文のステータス変更の通知（注文一覧のストリームで送る）
+type OrderStatusEvent struct {
+	OrderID       int64     `json:"order_id"`
+	ShippedStatus string    `json:"shipped_status"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// 注文のウォッチ・解除の結果
+type OrderWatchResponse struct {
+	OrderID  int64 `json:"order_id"`
+	Watching bool  `json:"watching"`
+	// ユーザーがウォッチ中の注文数
+	Watches int `json:"watches"`
+}
+
+// テストデータのリセット（注文数・シードが同じであれば同じ注文を作る）
+type TestdataResetRequest struct {
+	Seed int64 `json:"seed"`
+	// 作成する注文数（0の場合は既定値）
+	Orders int `json:"orders"`
+}
+
+type TestdataResetResponse struct {
+	Seed            int64 `json:"seed"`
+	DeletedOrders   int64 `json:"deleted_orders"`
+	DeletedSessions int64 `json:"deleted_sessions"`
+	InsertedOrders  int   `json:"inserted_orders"`
+}
+
+// CSVから取り込む注文
+type ImportedOrder struct {
+	ExternalRef   string
+	UserID        int
+	ProductID     int
+	ShippedStatus string
+	CreatedAt     time.Time
+	ArrivedAt     sql.NullTime
+}
+
+// 注文のCSV取り込みの結果
+type OrderImportReport struct {
+	// ヘッダーを除いた行数
+	Rows     int `json:"rows"`
+	Imported int `json:"imported"`
+	// 取り込み済みの外部参照IDのため取り込まなかった行数
+	Skipped int `json:"skipped"`
+	// 不正なため取り込まなかった行数（Errorsは先頭の一部のみ）
+	Invalid int                `json:"invalid"`
+	Errors  []OrderImportError `json:"errors"`
+}
+
+type OrderImportError struct {
+	Line        int    `json:"line"`
+	ExternalRef string `json:"external_ref,omitempty"`
+	Message     string `json:"message"`
+}
+
+// 不整合な状態の注文を修復した結果
+type StatusRepairReport struct {
+	// ロボットが割り当てられていない配送中の注文（配送待ちに戻した）
+	DeliveringWithoutRobot StatusRepairResult `json:"delivering_without_robot"`
+	// 到着時刻のない配送完了注文（到着時刻を補った）
+	CompletedWithoutArrival StatusRepairResult `json:"completed_without_arrival"`
+}
+
+// /api/readyzのレスポンス
+type ReadinessResponse struct {
+	Ready    bool          `json:"ready"`
+	ReadOnly ReadOnlyState `json:"read_only"`
+}
+
+// 読み取り専用モードの状態
+type ReadOnlyState struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	// 最後に切り替えた時刻（起動後に切り替えていなければ省略）
+	Since *time.Time `json:"since,omitempty"`
+}
+
+type ReadOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// 配送中のまま期限を過ぎた注文を配送待ちに戻した結果
+type StaleDeliveryRequeueResponse struct {
+	Requeued         int `json:"requeued"`
+	OlderThanSeconds int `json:"older_than_seconds"`
+}
+
+type StatusRepairResult struct {
+	Repaired int     `json:"repaired"`
+	Batches  int     `json:"batches"`
+	OrderIDs []int64 `json:"order_ids"`
+}
+
+type ShippingZone struct {
+	ZoneCode string  `db:"zone_code"`
+	MinLat   float64 `db:"min_lat"`
+	MaxLat   float64 `db:"max_lat"`
+	MinLng   float64 `db:"min_lng"`
+	MaxLng   float64 `db:"max_lng"`
+	Priority int     `db:"priority"`
+}
+
+type ShippingRate struct {
+	ZoneCode  string `db:"zone_code"`
+	MaxWeight int64  `db:"max_weight"`
+	Cost      int    `db:"cost"`
+}
+
+// 1注文行あたりの送料見積もり
+type ShippingQuote struct {
+	Zone string
+	Cost int
+}
+
+type StatusCount struct {
+	Status string `db:"shipped_status" json:"status"`
+	Count  int    `db:"count"          json:"count"`
+}
+
+// ユーザーの商品ごとの注文数（ステータス別）
+type ProductOrderCounts struct {
+	ProductID   int           `json:"product_id"`
+	ProductName string        `json:"product_name"`
+	Total       int           `json:"total"`
+	ByStatus    []StatusCount `json:"by_status"`
+}
+
+type OrderSummary struct {
+	TotalOrders       int           `json:"total_orders"`
+	TotalValue        int           `json:"total_value"`
+	TotalShippingCost int           `json:"total_shipping_cost"`
+	TotalTax          int           `json:"total_tax"`
+	TotalPreTax       int           `json:"total_pre_tax"`
+	TotalPostTax      int           `json:"total_post_tax"`
+	ByStatus          []StatusCount `json:"by_status"`
+}
+
+type TaxRate struct {
+	Category string `db:"category"`
+	Region   string `db:"region"`
+	RateBP   int    `db:"rate_bp"`
+}
+
+// 注文1行の請求内容
+type Invoice struct {
+	OrderID      int64     `json:"order_id"`
+	ProductName  string    `json:"product_name"`
+	Subtotal     int       `json:"subtotal"`
+	ShippingCost int       `json:"shipping_cost"`
+	PreTaxTotal  int       `json:"pre_tax_total"`
+	TaxAmount    int       `json:"tax_amount"`
+	Total        int       `json:"total"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RevenueStats struct {
+	PreTax  int `db:"pre_tax"  json:"pre_tax"`
+	Tax     int `db:"tax"      json:"tax"`
+	PostTax int `db:"post_tax" json:"post_tax"`
+}
+
+type Synonym struct {
+	GroupID int    `db:"group_id"`
+	Term    string `db:"term"`
+}
+
+type PlanAckRequest struct {
+	RobotID string `json:"robot_id"`
+}
+
+type PlanAckResponse struct {
+	RobotID      string `json:"robot_id"`
+	Acknowledged int    `json:"acknowledged"`
+}
+
+type RobotPositionRequest struct {
+	RobotID   string  `json:"robot_id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// 公開追跡APIのレスポンス
+// 第三者に共有される前提のため、ユーザーや住所に関する情報は含めない
+type TrackingInfo struct {
+	Status        string       `json:"status"`
+	ETA           *time.Time   `json:"eta,omitempty"`
+	ArrivedAt     *time.Time   `json:"arrived_at,omitempty"`
+	RobotPosition *Coordinates `json:"robot_position,omitempty"`
+}
+
+// キャッシュのヒット率
+type CacheStat struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+	// 破棄の方式と、容量の超過で破棄した件数（方式を設定したキャッシュのみ）
+	Policy    string `json:"policy,omitempty"`
+	Evictions int64  `json:"evictions,omitempty"`
+	// 使用量を登録したキャッシュのみ
+	Entries     int   `json:"entries,omitempty"`
+	Bytes       int64 `json:"bytes,omitempty"`
+	BudgetBytes int64 `json:"budget_bytes,omitempty"`
+}
+
+type DBPoolStats struct {
+	MaxOpen      int   `json:"max_open"`
+	Open         int   `json:"open"`
+	InUse        int   `json:"in_use"`
+	Idle         int   `json:"idle"`
+	WaitCount    int64 `json:"wait_count"`
+	WaitDuration int64 `json:"wait_duration_ms"`
+}
+
+// 読み取り用のレプリカの状態（DATABASE_READ_URLを設定した場合のみ）
+type ReadReplicaStats struct {
+	// レプリカから読んでいるか（使えない間はプライマリから読む）
+	Available bool  `json:"available"`
+	LagMs     int64 `json:"lag_ms"`
+	// 起動時からの状態の確認の回数と、そのうち使えないと判定した回数
+	Checks   int64       `json:"checks"`
+	Failures int64       `json:"failures"`
+	Pool     DBPoolStats `json:"pool"`
+}
+
+type LatencyStats struct {
+	WindowSeconds int     `json:"window_seconds"`
+	Requests      int64   `json:"requests"`
+	P95Ms         float64 `json:"p95_ms"`
+}
+
+// 管理画面向けの現在の稼働状況
+type AdminDashboard struct {
+	OrdersByStatus []StatusCount `json:"orders_by_status"`
+	ActiveRobots   int           `json:"active_robots"`
+	Caches         []CacheStat   `json:"caches"`
+	DBPool         DBPoolStats   `json:"db_pool"`
+	Latency        LatencyStats  `json:"latency"`
+	// 外部APIの呼び出し状況（呼び出しがあったホストのみ）
+	Outbound []OutboundStat `json:"outbound,omitempty"`
+	// 定期実行する処理の実行状況
+	Jobs []JobStat `json:"jobs,omitempty"`
+	// エンドポイントごとのリクエスト・レスポンスのサイズと件数（REQUEST_SHAPE_METRICS=1の場合のみ）
+	EndpointShapes []EndpointShapeStat `json:"endpoint_shapes,omitempty"`
+	// 新しい実装への段階的な切り替えのコホートごとの比較（切り替えを設定した場合のみ）
+	Canaries []CanaryStat `json:"canaries,omitempty"`
+	// 読み取り用のレプリカ（DATABASE_READ_URLを設定した場合のみ）
+	ReadReplica *ReadReplicaStats `json:"read_replica,omitempty"`
+}
+
+// サイズ（バイト）・件数の分布（起動時からの累計。パーセンタイルは2のべき乗の区間の上限値）
+type HistogramStat struct {
+	Count int64 `json:"count"`
+	Sum   int64 `json:"sum"`
+	Max   int64 `json:"max"`
+	P50   int64 `json:"p50"`
+	P95   int64 `json:"p95"`
+	P99   int64 `json:"p99"`
+}
+
+type EndpointShapeStat struct {
+	// "POST /api/v1/orders"の形式（パスはルートのパターン）
+	Endpoint      string        `json:"endpoint"`
+	RequestBytes  HistogramStat `json:"request_bytes"`
+	ResponseBytes HistogramStat `json:"response_bytes"`
+	// 1リクエストで扱った件数（作成した注文数・返した行数など）の種類ごとの分布
+	Items map[string]HistogramStat `json:"items,omitempty"`
+}
+
+type OutboundStat struct {
+	Host     string  `json:"host"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	P95Ms    float64 `json:"p95_ms"`
+}
+
+// 段階的な切り替えのコホートごとの実行状況
+type CanaryStat struct {
+	Experiment string `json:"experiment"`
+	// "canary"（新しい実装）または"control"（従来の実装）
+	Cohort   string  `json:"cohort"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	P50Ms    float64 `json:"p50_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+}
+
+// 定期実行する処理の実行状況（回数は起動時からの累計）
+type JobStat struct {
+	Name string `json:"name"`
+	// 実行予定の設定値（cron形式または@every）
+	Spec     string `json:"spec"`
+	Runs     int64  `json:"runs"`
+	Failures int64  `json:"failures"`
+	// 前回の実行が終わっていないため実行しなかった回数
+	Skipped        int64      `json:"skipped"`
+	Running        bool       `json:"running"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastDurationMs float64    `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+}
+
+// よくアクセスされる画像
+type ImageHotStat struct {
+	Path string `json:"path"`
+	Hits int64  `json:"hits"`
+	// プロセス内の画像キャッシュに載っているか
+	Cached bool `json:"cached"`
+}
+
+type ImageHotReport struct {
+	TotalHits    int64          `json:"total_hits"`
+	TrackedPaths int            `json:"tracked_paths"`
+	Images       []ImageHotStat `json:"images"`
+}
+
+// 商品一覧キャッシュの無効化（どちらも空の場合は全件）
+type ProductCacheInvalidateRequest struct {
+	Prefix string   `json:"prefix,omitempty"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+type ProductCacheInvalidateResponse struct {
+	Prefix      string `json:"prefix,omitempty"`
+	DeletedKeys int    `json:"deleted_keys"`
+}
+
+// 停止時に保存し、次の起動時に実行し直してキャッシュを温めるクエリ（結果は保存しない）
+type CacheSnapshot struct {
+	SavedAt      time.Time          `json:"saved_at"`
+	ProductLists []ProductListQuery `json:"product_lists"`
+	OrderCounts  []OrderCountQuery  `json:"order_counts"`
+}
+
+// 商品一覧キャッシュの1つのキーの条件
+type ProductListQuery struct {
+	// 正規化済みの検索語（同義語の展開前）
+	Search       string `json:"search,omitempty"`
+	SortField    string `json:"sort_field"`
+	SortOrder    string `json:"sort_order"`
+	PageSize     int    `json:"page_size"`
+	Offset       int    `json:"offset"`
+	SearchMethod string `json:"search_method,omitempty"`
+	// キャッシュに保存してからの参照回数
+	Hits int64 `json:"hits"`
+}
+
+// 注文一覧の総件数キャッシュの1つのキーの条件
+type OrderCountQuery struct {
+	UserID int    `json:"user_id"`
+	Search string `json:"search,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Hits   int64  `json:"hits"`
 }
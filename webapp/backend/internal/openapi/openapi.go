@@ -0,0 +1,258 @@
+// APIのOpenAPI定義と、定義に沿ったリクエストの検証
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// 検証するリクエストボディの上限（超える場合は検証せずハンドラーに渡す）
+const maxBodyBytes = 1 << 20
+
+// OpenAPI定義のJSON
+func Spec() []byte {
+	return spec
+}
+
+// 検証で見つかった問題（Inはbody・query・pathのいずれか、Fieldはitems[0].quantityのような位置）
+type FieldError struct {
+	In      string `json:"in"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type operation struct {
+	Parameters  []parameter  `json:"parameters"`
+	RequestBody *requestBody `json:"requestBody"`
+}
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type route struct {
+	segments []string
+	// {id}のようなパラメーターでないセグメントの数（複数のパスに一致した場合に多い方を選ぶ）
+	literals   int
+	operations map[string]*operation
+}
+
+// OpenAPI定義に沿ってリクエストを検証する
+// 定義にないパス・メソッドのリクエストは検証しない
+type Validator struct {
+	routes []route
+}
+
+// 埋め込んだOpenAPI定義を読み込む
+func Load() (*Validator, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi spec: %w", err)
+	}
+	v := &Validator{}
+	for path, ops := range doc.Paths {
+		rt := route{segments: strings.Split(strings.Trim(path, "/"), "/"), operations: make(map[string]*operation)}
+		for _, seg := range rt.segments {
+			if !isParam(seg) {
+				rt.literals++
+			}
+		}
+		for method, op := range ops {
+			for _, p := range op.Parameters {
+				if err := resolve(p.Schema, doc.Components.Schemas); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+				}
+			}
+			if op.RequestBody != nil {
+				for _, c := range op.RequestBody.Content {
+					if err := resolve(c.Schema, doc.Components.Schemas); err != nil {
+						return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+					}
+				}
+			}
+			rt.operations[strings.ToUpper(method)] = op
+		}
+		v.routes = append(v.routes, rt)
+	}
+	return v, nil
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+// リクエストのパス・メソッドに対応する定義（パスパラメーターの値も返す）
+func (v *Validator) lookup(method, path string) (*operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var best *route
+	for i := range v.routes {
+		rt := &v.routes[i]
+		if len(rt.segments) != len(segments) || rt.operations[method] == nil {
+			continue
+		}
+		matched := true
+		for j, seg := range rt.segments {
+			if !isParam(seg) && seg != segments[j] {
+				matched = false
+				break
+			}
+		}
+		if matched && (best == nil || rt.literals > best.literals) {
+			best = rt
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	params := make(map[string]string)
+	for j, seg := range best.segments {
+		if isParam(seg) {
+			params[strings.Trim(seg, "{}")] = segments[j]
+		}
+	}
+	return best.operations[method], params
+}
+
+// リクエストのパスパラメーター・クエリパラメーター・JSONのボディを検証する
+// ボディは読み込んだ後に元に戻すため、ハンドラーはそのまま読める
+func (v *Validator) Validate(r *http.Request) []FieldError {
+	op, pathParams := v.lookup(r.Method, r.URL.Path)
+	if op == nil {
+		return nil
+	}
+
+	var errs []FieldError
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = pathParams[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				errs = append(errs, FieldError{In: p.In, Field: p.Name, Message: "is required"})
+			}
+			continue
+		}
+		value, err := parseParam(raw, p.Schema)
+		if err != nil {
+			errs = append(errs, FieldError{In: p.In, Field: p.Name, Message: err.Error()})
+			continue
+		}
+		for _, e := range p.Schema.validate(value, p.Name) {
+			errs = append(errs, FieldError{In: p.In, Field: e.Field, Message: e.Message})
+		}
+	}
+
+	if op.RequestBody != nil {
+		errs = append(errs, validateBody(r, op.RequestBody)...)
+	}
+	return errs
+}
+
+// クエリ・パスパラメーターの文字列をスキーマの型の値に変換する
+func parseParam(raw string, schema *Schema) (any, error) {
+	if schema == nil {
+		return raw, nil
+	}
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("must be a boolean")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+func validateBody(r *http.Request, body *requestBody) []FieldError {
+	media, ok := body.Content["application/json"]
+	if !ok || media.Schema == nil {
+		return nil
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		return nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		if body.Required {
+			return []FieldError{{In: "body", Message: "request body is required"}}
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	// 読み込んだ分を戻し、残りは元のボディから読めるようにする
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	if err != nil || len(data) > maxBodyBytes {
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []FieldError{{In: "body", Message: "request body is required"}}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []FieldError{{In: "body", Message: "malformed JSON"}}
+	}
+	var errs []FieldError
+	for _, e := range media.Schema.validate(value, "") {
+		errs = append(errs, FieldError{In: "body", Field: e.Field, Message: e.Message})
+	}
+	return errs
+}
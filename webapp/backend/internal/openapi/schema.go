@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OpenAPIのスキーマのうち、リクエストの検証に使うキーワード
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+}
+
+// $refを参照先のスキーマに置き換える（#/components/schemas/のみ対応）
+func resolve(s *Schema, components map[string]*Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		target := components[name]
+		if !ok || target == nil {
+			return fmt.Errorf("unresolved $ref %q", s.Ref)
+		}
+		*s = *target
+	}
+	for _, p := range s.Properties {
+		if err := resolve(p, components); err != nil {
+			return err
+		}
+	}
+	return resolve(s.Items, components)
+}
+
+// 子要素の位置（items・items[0]・items[0].quantity）
+func childPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// json.Decoder（UseNumber）でデコードした値を検証する
+func (s *Schema) validate(value any, path string) []FieldError {
+	if s == nil {
+		return nil
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []FieldError{{Field: path, Message: "must not be null"}}
+	}
+
+	var errs []FieldError
+	fail := func(format string, args ...any) {
+		errs = append(errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return errs
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, FieldError{Field: childPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		// 同じリクエストには毎回同じ順で問題を返す
+		slices.Sort(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, FieldError{Field: childPath(path, name), Message: "is not a known field"})
+				}
+				continue
+			}
+			errs = append(errs, prop.validate(obj[name], childPath(path, name))...)
+		}
+		return errs
+
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return errs
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			fail("must contain at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			fail("must contain at most %d items", *s.MaxItems)
+		}
+		for i, item := range arr {
+			errs = append(errs, s.Items.validate(item, path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return errs
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be a %s", s.Type)
+			return errs
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				fail("must be an integer")
+				return errs
+			}
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be a number")
+			return errs
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be at least %s", formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be at most %s", formatNumber(*s.Maximum))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return errs
+		}
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprintf("%q", fmt.Sprint(e))
+		}
+		fail("must be one of %s", strings.Join(allowed, ", "))
+	}
+	return errs
+}
+
+func (s *Schema) inEnum(value any) bool {
+	for _, e := range s.Enum {
+		switch v := value.(type) {
+		case json.Number:
+			if f, err := v.Float64(); err == nil && e == f {
+				return true
+			}
+		default:
+			if e == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,160 @@
+// 配送計画の候補となる注文を価値密度（価値/重量）順に保持する
+package planner
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"cmp"
+	"slices"
+	"sync"
+)
+
+type entry struct {
+	orderID int64
+	weight  int
+	value   int
+}
+
+// 密度の降順（同じ密度なら注文IDの昇順）
+func compareEntries(a, b entry) int {
+	// a.value/a.weight と b.value/b.weight の比較を乗算で行う（重量0は密度無限大とみなす）
+	switch {
+	case a.weight == 0 && b.weight == 0:
+	case a.weight == 0:
+		return -1
+	case b.weight == 0:
+		return 1
+	default:
+		if c := cmp.Compare(b.value*a.weight, a.value*b.weight); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.orderID, b.orderID)
+}
+
+// 配送待ち注文の価値密度順インデックス
+// ステータス変更のたびに差分を反映し、計画のたびに全件をソートし直さずに済むようにする
+type DensityIndex struct {
+	mutex   sync.RWMutex
+	entries []entry
+	byID    map[int64]entry
+}
+
+func NewDensityIndex() *DensityIndex {
+	return &DensityIndex{byID: make(map[int64]entry)}
+}
+
+// 注文を追加する（既に存在する場合は置き換える）
+func (x *DensityIndex) Add(orders ...model.Order) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for _, o := range orders {
+		if old, ok := x.byID[o.OrderID]; ok {
+			x.removeLocked(old)
+		}
+		e := entry{orderID: o.OrderID, weight: o.Weight, value: o.Value}
+		i, _ := slices.BinarySearchFunc(x.entries, e, compareEntries)
+		x.entries = slices.Insert(x.entries, i, e)
+		x.byID[e.orderID] = e
+	}
+}
+
+// 注文を取り除く（存在しないIDは無視する）
+func (x *DensityIndex) Remove(orderIDs ...int64) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for _, id := range orderIDs {
+		if e, ok := x.byID[id]; ok {
+			x.removeLocked(e)
+		}
+	}
+}
+
+func (x *DensityIndex) removeLocked(e entry) {
+	if i, found := slices.BinarySearchFunc(x.entries, e, compareEntries); found {
+		x.entries = slices.Delete(x.entries, i, i+1)
+	}
+	delete(x.byID, e.orderID)
+}
+
+// 配送待ち以外になった注文を取り除く（OrderStatusChangedの購読用）
+// 配送待ちに戻った注文は重量・価値が分からないため、呼び出し元がAddする
+func (x *DensityIndex) OnOrderStatusChanged(ev events.Event) {
+	if ev.Status != "shipping" {
+		x.Remove(ev.IDs...)
+	}
+}
+
+// 配送待ち注文の一覧で置き換える（このときだけ全件をソートする）
+func (x *DensityIndex) Replace(orders []model.Order) {
+	entries := make([]entry, len(orders))
+	byID := make(map[int64]entry, len(orders))
+	for i, o := range orders {
+		entries[i] = entry{orderID: o.OrderID, weight: o.Weight, value: o.Value}
+		byID[o.OrderID] = entries[i]
+	}
+	slices.SortFunc(entries, compareEntries)
+
+	x.mutex.Lock()
+	x.entries = entries
+	x.byID = byID
+	x.mutex.Unlock()
+}
+
+// ordersがすべて同じ重量・価値でインデックスに含まれるか
+// インデックスはordersにない注文を含んでいてもよい（他の計画がロックしている注文など）
+func (x *DensityIndex) Contains(orders []model.Order) bool {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	for _, o := range orders {
+		e, ok := x.byID[o.OrderID]
+		if !ok || e.weight != o.Weight || e.value != o.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// 密度の高い順に、積載量に収まる注文を貪欲に選ぶ
+// candidateがnilでない場合、candidateがtrueを返す注文だけを選ぶ（除外された注文の重量で積載量を使わない）
+func (x *DensityIndex) Greedy(capacity int, candidate func(orderID int64) bool) (orderIDs []int64, totalWeight, totalValue int) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	for _, e := range x.entries {
+		if candidate != nil && !candidate(e.orderID) {
+			continue
+		}
+		if totalWeight+e.weight > capacity {
+			continue
+		}
+		orderIDs = append(orderIDs, e.orderID)
+		totalWeight += e.weight
+		totalValue += e.value
+	}
+	return orderIDs, totalWeight, totalValue
+}
+
+// 分割可能ナップサックとしての最適値（0-1ナップサックの最適値の上界）
+// 端数は切り捨てる（整数解はこれを超えない）
+// candidateはGreedyと同じく、nilでない場合にtrueを返す注文だけを対象にする
+func (x *DensityIndex) UpperBound(capacity int, candidate func(orderID int64) bool) int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	remaining := capacity
+	bound := 0
+	for _, e := range x.entries {
+		if candidate != nil && !candidate(e.orderID) {
+			continue
+		}
+		if e.weight <= remaining {
+			remaining -= e.weight
+			bound += e.value
+			continue
+		}
+		if remaining > 0 {
+			bound += e.value * remaining / e.weight
+		}
+		break
+	}
+	return bound
+}
@@ -0,0 +1,50 @@
+package planner
+
+import (
+	"backend/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestGreedySkipsNonCandidates(t *testing.T) {
+	x := NewDensityIndex()
+	x.Replace([]model.Order{
+		// 最も密度が高いが候補ではない（除外・他の計画がロック中）
+		{OrderID: 1, Weight: 10, Value: 100},
+		{OrderID: 2, Weight: 5, Value: 20},
+		{OrderID: 3, Weight: 5, Value: 15},
+	})
+	candidate := func(id int64) bool { return id != 1 }
+
+	ids, weight, value := x.Greedy(10, candidate)
+	if !slices.Equal(ids, []int64{2, 3}) || weight != 10 || value != 35 {
+		t.Fatalf("Greedy() = %v, %d, %d, want [2 3], 10, 35", ids, weight, value)
+	}
+	if bound := x.UpperBound(10, candidate); bound != 35 {
+		t.Fatalf("UpperBound() = %d, want 35", bound)
+	}
+
+	// candidateがnilの場合はすべての注文が対象
+	if ids, _, _ := x.Greedy(10, nil); !slices.Equal(ids, []int64{1}) {
+		t.Fatalf("Greedy(nil) = %v, want [1]", ids)
+	}
+	if bound := x.UpperBound(10, nil); bound != 100 {
+		t.Fatalf("UpperBound(nil) = %d, want 100", bound)
+	}
+}
+
+func TestContainsAllowsExtraOrders(t *testing.T) {
+	x := NewDensityIndex()
+	x.Replace([]model.Order{{OrderID: 1, Weight: 1, Value: 1}, {OrderID: 2, Weight: 2, Value: 2}})
+
+	// 他の計画がロックしている注文（2）がインデックスにあっても一致とみなす
+	if !x.Contains([]model.Order{{OrderID: 1, Weight: 1, Value: 1}}) {
+		t.Fatal("Contains() = false for a subset of the index")
+	}
+	if x.Contains([]model.Order{{OrderID: 1, Weight: 3, Value: 1}}) {
+		t.Fatal("Contains() = true for an order whose weight changed")
+	}
+	if x.Contains([]model.Order{{OrderID: 3, Weight: 1, Value: 1}}) {
+		t.Fatal("Contains() = true for an order missing from the index")
+	}
+}
@@ -0,0 +1,175 @@
+package planner
+
+import (
+	"backend/internal/model"
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// 前回から変わった注文数が候補全体のこの割合を超える場合は、引き継がずに全体を計算する
+const warmStartMaxDeltaRatio = 0.5
+
+type knapsackItem struct {
+	orderID int64
+	weight  int
+	value   int
+}
+
+// キャンセルを確認するまでに計算するテーブルのセル数の目安
+const cancelCheckCells = 1 << 20
+
+// 動的計画法で何行ごとにキャンセルを確認するか（積載量が大きいほど1行が重いため、セル数で間隔を揃える）
+func CancelCheckRows(width int) int {
+	return max(cancelCheckCells/max(width, 1), 1)
+}
+
+// 前回の計算結果
+// rows[i][w] = 先頭i件の注文で重さw以下の最大価値
+type knapsackTable struct {
+	capacity int
+	items    []knapsackItem
+	rows     [][]int
+}
+
+type KnapsackResult struct {
+	Orders      []model.Order
+	TotalWeight int
+	TotalValue  int
+	// 前回の計算から引き継いだ行数（0の場合は全体を計算した）
+	ReusedRows int
+}
+
+// 動的計画法のテーブルを前回の計算から引き継ぐ0-1ナップサック
+// 注文を注文ID順に並べて計算するため、前回から注文が追加されただけ（末尾に並ぶ）であれば追加分の行だけを計算する
+// 途中の注文が減った・変わった場合はその位置以降を計算し直す
+type WarmKnapsack struct {
+	mutex sync.Mutex
+	prev  *knapsackTable
+	// 保持するテーブルの上限（注文数×積載量）。超える場合は保持しない
+	maxCells int
+}
+
+func NewWarmKnapsack(maxCells int) *WarmKnapsack {
+	return &WarmKnapsack{maxCells: maxCells}
+}
+
+// テーブル全体を確保して計算できる大きさか（上限を超える場合は1次元の動的計画法で計算する）
+func (k *WarmKnapsack) Fits(n, capacity int) bool {
+	return k.maxCells <= 0 || n*(capacity+1) <= k.maxCells
+}
+
+// 積載量に収まる価値最大の注文の組を返す
+func (k *WarmKnapsack) Solve(ctx context.Context, orders []model.Order, capacity int) (KnapsackResult, error) {
+	sorted := slices.Clone(orders)
+	slices.SortFunc(sorted, func(a, b model.Order) int { return cmp.Compare(a.OrderID, b.OrderID) })
+	items := make([]knapsackItem, len(sorted))
+	for i, o := range sorted {
+		items[i] = knapsackItem{orderID: o.OrderID, weight: o.Weight, value: o.Value}
+	}
+
+	// 同時に呼ばれた場合、テーブルを取得できなかった方は全体を計算する
+	k.mutex.Lock()
+	prev := k.prev
+	k.prev = nil
+	k.mutex.Unlock()
+
+	table, reused, err := fillKnapsack(ctx, prev, items, capacity)
+	if err != nil {
+		return KnapsackResult{}, err
+	}
+	result := reconstructKnapsack(table, sorted)
+	// 引き継いだ行が今回の注文と食い違っていれば結果が壊れるため、検算して合わなければ全体を計算し直す
+	if reused > 0 && !validKnapsack(result, table) {
+		slog.WarnContext(ctx, "[WarmKnapsack] 引き継いだ計算結果が一致しないため全体を計算し直します", "reused", reused, "orders", len(items))
+		if table, _, err = fillKnapsack(ctx, nil, items, capacity); err != nil {
+			return KnapsackResult{}, err
+		}
+		result = reconstructKnapsack(table, sorted)
+		reused = 0
+	}
+	result.ReusedRows = reused
+
+	if k.Fits(len(items), capacity) {
+		k.mutex.Lock()
+		k.prev = table
+		k.mutex.Unlock()
+	}
+	return result, nil
+}
+
+// prevの先頭から今回と同じ注文が続く行を引き継ぎ、残りの行を計算する
+func fillKnapsack(ctx context.Context, prev *knapsackTable, items []knapsackItem, capacity int) (*knapsackTable, int, error) {
+	n := len(items)
+	reused := 0
+	if prev != nil && prev.capacity == capacity {
+		for reused < min(len(prev.items), n) && prev.items[reused] == items[reused] {
+			reused++
+		}
+		// 変わった注文が多い場合は引き継ぐ意味が薄いため全体を計算する
+		delta := (len(prev.items) - reused) + (n - reused)
+		if float64(delta) > float64(n)*warmStartMaxDeltaRatio {
+			reused = 0
+		}
+	}
+
+	rows := make([][]int, n+1)
+	if reused > 0 {
+		copy(rows, prev.rows[:reused+1])
+	} else {
+		rows[0] = make([]int, capacity+1)
+	}
+	checkEvery := CancelCheckRows(capacity + 1)
+	for i := reused + 1; i <= n; i++ {
+		item := items[i-1]
+		row := make([]int, capacity+1)
+		above := rows[i-1]
+		for w := 0; w <= capacity; w++ {
+			row[w] = above[w]
+			if item.weight <= w {
+				if v := above[w-item.weight] + item.value; v > row[w] {
+					row[w] = v
+				}
+			}
+		}
+		rows[i] = row
+
+		if i%checkEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			default:
+			}
+		}
+	}
+	return &knapsackTable{capacity: capacity, items: items, rows: rows}, reused, nil
+}
+
+// テーブルから選んだ注文を復元する（ordersはテーブルと同じ並び）
+func reconstructKnapsack(table *knapsackTable, orders []model.Order) KnapsackResult {
+	n := len(table.items)
+	result := KnapsackResult{Orders: []model.Order{}, TotalValue: table.rows[n][table.capacity]}
+	w := table.capacity
+	// 重さ0の注文も選ばれるため、残りの重さが0になっても先頭まで確認する
+	for i := n; i > 0; i-- {
+		item := table.items[i-1]
+		if w >= item.weight && table.rows[i-1][w-item.weight]+item.value == table.rows[i][w] {
+			result.Orders = append(result.Orders, orders[i-1])
+			result.TotalWeight += item.weight
+			w -= item.weight
+		}
+	}
+	slices.Reverse(result.Orders)
+	return result
+}
+
+// 選んだ注文の合計がテーブルの最大価値と一致し、積載量に収まっているか
+func validKnapsack(result KnapsackResult, table *knapsackTable) bool {
+	value := 0
+	for _, o := range result.Orders {
+		value += o.Value
+	}
+	return value == result.TotalValue && result.TotalWeight <= table.capacity
+}
@@ -0,0 +1,135 @@
+package planner
+
+import (
+	"backend/internal/model"
+	"context"
+	"slices"
+	"testing"
+)
+
+func knapsackOrders(n int) []model.Order {
+	orders := make([]model.Order, n)
+	for i := range orders {
+		orders[i] = model.Order{OrderID: int64(i + 1), Weight: i%4 + 1, Value: (i*7)%11 + 1}
+	}
+	return orders
+}
+
+// 前回のテーブルを持たない状態で計算した結果
+func freshSolve(t *testing.T, orders []model.Order, capacity int) KnapsackResult {
+	t.Helper()
+	result, err := NewWarmKnapsack(0).Solve(context.Background(), orders, capacity)
+	if err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+	return result
+}
+
+func sameResult(a, b KnapsackResult) bool {
+	return a.TotalValue == b.TotalValue && a.TotalWeight == b.TotalWeight && slices.Equal(a.Orders, b.Orders)
+}
+
+func TestWarmKnapsackMatchesFreshSolve(t *testing.T) {
+	base := knapsackOrders(10)
+	withChange := func(i int, fn func(o *model.Order)) []model.Order {
+		orders := slices.Clone(base)
+		fn(&orders[i])
+		return orders
+	}
+	cases := []struct {
+		name       string
+		next       []model.Order
+		capacity   int
+		wantReused int
+	}{
+		{name: "appended", next: append(slices.Clone(base), model.Order{OrderID: 11, Weight: 2, Value: 9}, model.Order{OrderID: 12, Weight: 1, Value: 3}), capacity: 12, wantReused: 10},
+		{name: "unchanged", next: base, capacity: 12, wantReused: 10},
+		{name: "removed_from_middle", next: slices.Delete(slices.Clone(base), 8, 9), capacity: 12, wantReused: 8},
+		{name: "weight_changed", next: withChange(9, func(o *model.Order) { o.Weight = 7 }), capacity: 12, wantReused: 9},
+		{name: "value_changed", next: withChange(8, func(o *model.Order) { o.Value = 40 }), capacity: 12, wantReused: 8},
+		// 入力の並びによらず注文ID順に計算する
+		{name: "shuffled_input", next: append([]model.Order{{OrderID: 11, Weight: 3, Value: 8}}, base...), capacity: 12, wantReused: 10},
+		{name: "capacity_changed", next: base, capacity: 9, wantReused: 0},
+		// 変わった注文が全体の半分を超える
+		{name: "large_delta", next: slices.Delete(slices.Clone(base), 2, 3), capacity: 12, wantReused: 0},
+		{name: "all_removed", next: nil, capacity: 12, wantReused: 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := NewWarmKnapsack(0)
+			if _, err := k.Solve(context.Background(), base, 12); err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+			got, err := k.Solve(context.Background(), c.next, c.capacity)
+			if err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+			if got.ReusedRows != c.wantReused {
+				t.Fatalf("ReusedRows = %d, want %d", got.ReusedRows, c.wantReused)
+			}
+			if want := freshSolve(t, c.next, c.capacity); !sameResult(got, want) {
+				t.Fatalf("Solve() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWarmKnapsackSequence(t *testing.T) {
+	k := NewWarmKnapsack(0)
+	orders := knapsackOrders(20)
+	for step := 0; step < 10; step++ {
+		// 末尾に追加し、ときどき途中の注文を変える
+		orders = append(orders, model.Order{OrderID: int64(21 + step), Weight: step%5 + 1, Value: step*3 + 2})
+		if step%3 == 2 {
+			orders[len(orders)-4].Value++
+		}
+		got, err := k.Solve(context.Background(), orders, 15)
+		if err != nil {
+			t.Fatalf("step %d: Solve() error = %v", step, err)
+		}
+		// 最初の計算は引き継ぐテーブルがない
+		if step > 0 && got.ReusedRows == 0 {
+			t.Fatalf("step %d: ReusedRows = 0, want rows reused for a small delta", step)
+		}
+		if want := freshSolve(t, orders, 15); !sameResult(got, want) {
+			t.Fatalf("step %d: Solve() = %+v, want %+v", step, got, want)
+		}
+	}
+}
+
+// 引き継いだ行が壊れていても、検算で検出して全体を計算し直す
+func TestWarmKnapsackFallsBackOnInvalidTable(t *testing.T) {
+	base := knapsackOrders(10)
+	k := NewWarmKnapsack(0)
+	if _, err := k.Solve(context.Background(), base, 12); err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+	for w := range k.prev.rows[len(base)] {
+		k.prev.rows[len(base)][w] += 1000
+	}
+
+	next := append(slices.Clone(base), model.Order{OrderID: 11, Weight: 2, Value: 9})
+	got, err := k.Solve(context.Background(), next, 12)
+	if err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+	if got.ReusedRows != 0 {
+		t.Fatalf("ReusedRows = %d, want 0 after falling back", got.ReusedRows)
+	}
+	if want := freshSolve(t, next, 12); !sameResult(got, want) {
+		t.Fatalf("Solve() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWarmKnapsackZeroWeight(t *testing.T) {
+	orders := []model.Order{
+		{OrderID: 1, Weight: 0, Value: 5},
+		{OrderID: 2, Weight: 1, Value: 10},
+		{OrderID: 3, Weight: 0, Value: 2},
+		{OrderID: 4, Weight: 2, Value: 7},
+	}
+	got := freshSolve(t, orders, 1)
+	if got.TotalValue != 17 || got.TotalWeight != 1 || len(got.Orders) != 3 {
+		t.Fatalf("Solve() = %+v, want orders 1, 2 and 3 with value 17", got)
+	}
+}
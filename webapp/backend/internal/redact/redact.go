@@ -0,0 +1,102 @@
+// レスポンスに含まれる個人情報の伏せ字
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// 文字列の値を置き換える伏せ字（数値などの値はnullにする）
+const Mask = "***"
+
+// 個人情報を含むJSONのフィールド名（ネストしたオブジェクト・配列の中も対象）
+var DefaultFields = []string{"user_id", "user_name", "address", "latitude", "longitude"}
+
+// 伏せ字にするフィールドの定義
+// 管理APIのレスポンスはすべてこのポリシーで伏せ字にし、ハンドラーごとには定義しない
+type Policy struct {
+	fields map[string]bool
+}
+
+// fieldsが空の場合はDefaultFieldsを使う
+func NewPolicy(fields []string) *Policy {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	p := &Policy{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			p.fields[f] = true
+		}
+	}
+	return p
+}
+
+// JSONの対象フィールドを伏せ字にする
+// 対象のフィールドがない場合（JSONでない場合を含む）はfalseを返し、呼び出し元は元の内容をそのまま使う
+func (p *Policy) ApplyJSON(data []byte) ([]byte, bool) {
+	if len(p.fields) == 0 || !p.mayContain(data) {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+	if !p.apply(v) {
+		return nil, false
+	}
+	masked, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return append(masked, '\n'), true
+}
+
+// デコードせずに済むよう、フィールド名が含まれるかを先に調べる
+func (p *Policy) mayContain(data []byte) bool {
+	for f := range p.fields {
+		if bytes.Contains(data, []byte(`"`+f+`"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) apply(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if p.fields[key] {
+				if value == nil || value == "" {
+					continue
+				}
+				v[key] = maskValue(value)
+				changed = true
+				continue
+			}
+			if p.apply(value) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if p.apply(value) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func maskValue(value any) any {
+	switch value.(type) {
+	case string:
+		return Mask
+	default:
+		return nil
+	}
+}
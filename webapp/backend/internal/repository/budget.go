@@ -0,0 +1,18 @@
+package repository
+
+import "time"
+
+const (
+	// OFFSETで読み飛ばす行数がこれ以上のクエリは、残り時間が足りなければ実行しない
+	deepOffsetRows = 10000
+	// 読み飛ばすdeepOffsetRows行あたりに見込む時間
+	deepOffsetCost = 100 * time.Millisecond
+)
+
+// OFFSETを使うクエリの見込み時間（浅いページは見込まない）
+func offsetQueryBudget(offset int) time.Duration {
+	if offset < deepOffsetRows {
+		return 0
+	}
+	return time.Duration(offset/deepOffsetRows) * deepOffsetCost
+}
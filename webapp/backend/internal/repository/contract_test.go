@@ -0,0 +1,552 @@
+package repository
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// リポジトリの実装によらず満たすべき振る舞い（ページング・絞り込み・ステータスの遷移）
+// メモリ上の実装は常に、MySQLの実装はTEST_DATABASE_URL（マイグレーション適用済みのDB、例: user:password@tcp(127.0.0.1:4306)/42Tokyo2508-db）を設定した場合に検証する
+// MySQLでは固定データのIDの範囲（contractBaseID以降）のユーザー・商品と、その注文・セッションだけを作り直す
+
+const contractBaseID = 900000
+
+var (
+	contractUserA = contractBaseID + 1
+	contractUserB = contractBaseID + 2
+)
+
+// 商品IDを固定データのIDに変換する
+func contractProductID(n int) int {
+	return contractBaseID + n
+}
+
+func contractFixtures() MemoryFixtures {
+	stock := 3
+	expired := time.Now().Add(-time.Hour)
+	return MemoryFixtures{
+		Users: []model.User{
+			{UserID: contractUserA, UserName: "zqcontract-a", PasswordHash: "hash-a"},
+			{UserID: contractUserB, UserName: "zqcontract-b", PasswordHash: "hash-b"},
+		},
+		Products: []model.Product{
+			{ProductID: contractProductID(1), Name: "zqcontract apple", Value: 300, Weight: 3},
+			{ProductID: contractProductID(2), Name: "zqcontract banana", Value: 100, Weight: 1},
+			{ProductID: contractProductID(3), Name: "zqcontract cherry", Value: 200, Weight: 2},
+			// 注文できる期間を過ぎた商品は一覧に含めない
+			{ProductID: contractProductID(4), Name: "zqcontract durian", Value: 200, Weight: 5, AvailableUntil: &expired},
+			{ProductID: contractProductID(5), Name: "zqcontract elder", Value: 500, Weight: 4, Stock: &stock},
+		},
+	}
+}
+
+// 発行された変更イベントを記録する
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []events.Event
+}
+
+func (r *eventRecorder) Publish(ev events.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// topicのイベントで通知された注文IDと、最後に通知されたステータス
+func (r *eventRecorder) statuses(topic events.Topic) map[int64]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	statuses := make(map[int64]string)
+	for _, ev := range r.events {
+		if ev.Topic != topic {
+			continue
+		}
+		for _, id := range ev.IDs {
+			statuses[id] = ev.Status
+		}
+	}
+	return statuses
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T, pub events.Publisher) *Store {
+		return NewMemoryStore(pub, contractFixtures())
+	})
+}
+
+func TestMySQLStoreContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Open("mysql", dsn+"?charset=utf8mb4&parseTime=True&loc=Local")
+	if err != nil {
+		t.Fatalf("sqlx.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runStoreContract(t, func(t *testing.T, pub events.Publisher) *Store {
+		seedMySQL(t, db, contractFixtures())
+		return NewStore(db, pub)
+	})
+}
+
+// 固定データのユーザー・商品を作り直す（注文・セッションは外部キーで一緒に消える）
+func seedMySQL(t *testing.T, db *sqlx.DB, fixtures MemoryFixtures) {
+	t.Helper()
+	ctx := context.Background()
+	userIDs := []int{}
+	for _, u := range fixtures.Users {
+		userIDs = append(userIDs, u.UserID)
+	}
+	productIDs := []int{}
+	for _, p := range fixtures.Products {
+		productIDs = append(productIDs, p.ProductID)
+	}
+	for _, q := range []struct {
+		query string
+		ids   []int
+	}{
+		{"DELETE FROM orders WHERE user_id IN (?)", userIDs},
+		{"DELETE FROM orders WHERE product_id IN (?)", productIDs},
+		{"DELETE FROM user_sessions WHERE user_id IN (?)", userIDs},
+		{"DELETE FROM users WHERE user_id IN (?)", userIDs},
+		{"DELETE FROM products WHERE product_id IN (?)", productIDs},
+	} {
+		query, args, err := sqlx.In(q.query, q.ids)
+		if err != nil {
+			t.Fatalf("sqlx.In(%q) error = %v", q.query, err)
+		}
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", q.query, err)
+		}
+	}
+	for _, u := range fixtures.Users {
+		if _, err := db.ExecContext(ctx, "INSERT INTO users (user_id, password_hash, user_name) VALUES (?, ?, ?)",
+			u.UserID, u.PasswordHash, u.UserName); err != nil {
+			t.Fatalf("insert user %d: %v", u.UserID, err)
+		}
+	}
+	for _, p := range fixtures.Products {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO products (product_id, name, value, weight, image, description, stock, available_until)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.ProductID, p.Name, p.Value, p.Weight, p.Image, p.Description, p.Stock, p.AvailableUntil); err != nil {
+			t.Fatalf("insert product %d: %v", p.ProductID, err)
+		}
+	}
+}
+
+// newStoreは固定データ（contractFixtures）を持つStoreを作る
+func runStoreContract(t *testing.T, newStore func(t *testing.T, pub events.Publisher) *Store) {
+	t.Run("users", func(t *testing.T) {
+		testUsersContract(t, newStore(t, &eventRecorder{}).UserRepo)
+	})
+	t.Run("products", func(t *testing.T) {
+		testProductsContract(t, newStore(t, &eventRecorder{}).ProductRepo)
+	})
+	t.Run("sessions", func(t *testing.T) {
+		testSessionsContract(t, newStore(t, &eventRecorder{}).SessionRepo)
+	})
+	t.Run("order_pagination", func(t *testing.T) {
+		testOrderPaginationContract(t, newStore(t, &eventRecorder{}).OrderRepo)
+	})
+	t.Run("order_status", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		testOrderStatusContract(t, newStore(t, recorder).OrderRepo, recorder)
+	})
+}
+
+func testUsersContract(t *testing.T, users Users) {
+	ctx := context.Background()
+	user, err := users.FindByUserName(ctx, "zqcontract-b")
+	if err != nil || user.UserID != contractUserB || user.PasswordHash != "hash-b" {
+		t.Fatalf("FindByUserName(zqcontract-b) = %+v, %v, want user %d", user, err, contractUserB)
+	}
+	if _, err := users.FindByUserName(ctx, "zqcontract-missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindByUserName(missing) error = %v, want sql.ErrNoRows", err)
+	}
+	existing, err := users.ExistingIDs(ctx, []int{contractUserA, contractBaseID + 99, contractUserB})
+	slices.Sort(existing)
+	if err != nil || !slices.Equal(existing, []int{contractUserA, contractUserB}) {
+		t.Fatalf("ExistingIDs() = %v, %v, want %v", existing, err, []int{contractUserA, contractUserB})
+	}
+	if err := users.LockByID(ctx, contractBaseID+99); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("LockByID(missing) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func productIDs(products []model.Product) []int {
+	ids := []int{}
+	for _, p := range products {
+		ids = append(ids, p.ProductID)
+	}
+	return ids
+}
+
+func testProductsContract(t *testing.T, products Products) {
+	ctx := context.Background()
+	// 全文検索はインデックスの有無で結果が変わるため、LIKE検索で比べる
+	list := func(req model.ListRequest) model.ListRequest {
+		req.SearchMethod = model.SearchMethodLike
+		if req.Search == "" {
+			req.Search = "zqcontract"
+		}
+		return req
+	}
+	tests := []struct {
+		name      string
+		req       model.ListRequest
+		wantIDs   []int
+		wantTotal int
+	}{
+		{
+			name:      "first_page",
+			req:       list(model.ListRequest{SortField: "value", SortOrder: "asc", PageSize: 2}),
+			wantIDs:   []int{contractProductID(2), contractProductID(3)},
+			wantTotal: 4,
+		},
+		{
+			name:      "second_page",
+			req:       list(model.ListRequest{SortField: "value", SortOrder: "asc", PageSize: 2, Offset: 2}),
+			wantIDs:   []int{contractProductID(1), contractProductID(5)},
+			wantTotal: 4,
+		},
+		{
+			name:      "past_last_page",
+			req:       list(model.ListRequest{SortField: "value", SortOrder: "asc", PageSize: 2, Offset: 4}),
+			wantIDs:   []int{},
+			wantTotal: 0,
+		},
+		{
+			name:      "name_desc",
+			req:       list(model.ListRequest{SortField: "name", SortOrder: "desc", PageSize: 10}),
+			wantIDs:   []int{contractProductID(5), contractProductID(3), contractProductID(2), contractProductID(1)},
+			wantTotal: 4,
+		},
+		{
+			name:      "case_insensitive_search",
+			req:       list(model.ListRequest{Search: "zqcontract BANANA", SortField: "product_id", SortOrder: "asc", PageSize: 10}),
+			wantIDs:   []int{contractProductID(2)},
+			wantTotal: 1,
+		},
+		{
+			name:      "unavailable_excluded",
+			req:       list(model.ListRequest{Search: "zqcontract durian", SortField: "product_id", SortOrder: "asc", PageSize: 10}),
+			wantIDs:   []int{},
+			wantTotal: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := products.ListProducts(ctx, contractUserA, tt.req)
+			if err != nil {
+				t.Fatalf("ListProducts() error = %v", err)
+			}
+			if ids := productIDs(got); !slices.Equal(ids, tt.wantIDs) || total != tt.wantTotal {
+				t.Fatalf("ListProducts() = %v, total %d, want %v, total %d", ids, total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+
+	t.Run("stock", func(t *testing.T) {
+		id := contractProductID(5)
+		if ok, err := products.DecrementStock(ctx, id, 2); err != nil || !ok {
+			t.Fatalf("DecrementStock(2) = %v, %v, want true", ok, err)
+		}
+		if ok, err := products.DecrementStock(ctx, id, 2); err != nil || ok {
+			t.Fatalf("DecrementStock(2) over stock = %v, %v, want false", ok, err)
+		}
+		if err := products.RestoreStock(ctx, id, 1); err != nil {
+			t.Fatalf("RestoreStock() error = %v", err)
+		}
+		p, err := products.LockByID(ctx, id)
+		if err != nil || p.Stock == nil || *p.Stock != 2 {
+			t.Fatalf("LockByID() = %+v, %v, want stock 2", p, err)
+		}
+		// 在庫を管理しない商品は減らせない
+		if ok, err := products.DecrementStock(ctx, contractProductID(1), 1); err != nil || ok {
+			t.Fatalf("DecrementStock() without stock = %v, %v, want false", ok, err)
+		}
+	})
+
+	t.Run("find_by_ids", func(t *testing.T) {
+		got, err := products.FindByIDs(ctx, []int{contractProductID(3), contractBaseID + 99, contractProductID(1)})
+		ids := productIDs(got)
+		slices.Sort(ids)
+		if err != nil || !slices.Equal(ids, []int{contractProductID(1), contractProductID(3)}) {
+			t.Fatalf("FindByIDs() = %v, %v, want existing products only", ids, err)
+		}
+		if _, err := products.LockByID(ctx, contractBaseID+99); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("LockByID(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func testSessionsContract(t *testing.T, sessions Sessions) {
+	ctx := context.Background()
+	sessionID, expiresAt, err := sessions.Create(ctx, contractUserA, time.Hour, "fp")
+	if err != nil || !expiresAt.After(time.Now()) {
+		t.Fatalf("Create() = %q, %v, %v, want a session expiring later", sessionID, expiresAt, err)
+	}
+	userID, fingerprint, err := sessions.FindUserBySessionID(ctx, sessionID)
+	if err != nil || userID != contractUserA || fingerprint != "fp" {
+		t.Fatalf("FindUserBySessionID() = %d, %q, %v, want %d, fp", userID, fingerprint, err, contractUserA)
+	}
+	if _, _, err := sessions.FindUserBySessionID(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindUserBySessionID(unknown) error = %v, want sql.ErrNoRows", err)
+	}
+
+	expiredID, _, err := sessions.Create(ctx, contractUserB, -time.Minute, "")
+	if err != nil {
+		t.Fatalf("Create() expired error = %v", err)
+	}
+	if _, _, err := sessions.FindUserBySessionID(ctx, expiredID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindUserBySessionID(expired) error = %v, want sql.ErrNoRows", err)
+	}
+	// 他のデータの期限切れのセッションも消えるため、件数は1件以上であることだけ確かめる
+	if deleted, err := sessions.DeleteExpired(ctx); err != nil || deleted < 1 {
+		t.Fatalf("DeleteExpired() = %d, %v, want >= 1", deleted, err)
+	}
+	if _, _, err := sessions.FindUserBySessionID(ctx, sessionID); err != nil {
+		t.Fatalf("FindUserBySessionID() after DeleteExpired error = %v, want the live session kept", err)
+	}
+}
+
+func orderIDs(orders []model.Order) []int64 {
+	ids := []int64{}
+	for _, o := range orders {
+		ids = append(ids, o.OrderID)
+	}
+	return ids
+}
+
+// 注文を作り、作成順の注文IDを返す
+func createContractOrders(t *testing.T, orders Orders, userID int, lines ...model.OrderLine) []int64 {
+	t.Helper()
+	ids, err := orders.CreateBulk(context.Background(), userID, lines, model.DeliveryAddress{})
+	if err != nil {
+		t.Fatalf("CreateBulk() error = %v", err)
+	}
+	want := 0
+	for _, line := range lines {
+		want += line.Quantity
+	}
+	if len(ids) != want {
+		t.Fatalf("CreateBulk() = %d orders, want %d", len(ids), want)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func testOrderPaginationContract(t *testing.T, orders Orders) {
+	ctx := context.Background()
+	// apple×2・cherry×1・banana×1
+	a := createContractOrders(t, orders, contractUserA,
+		model.OrderLine{ProductID: contractProductID(1), Quantity: 2},
+		model.OrderLine{ProductID: contractProductID(3), Quantity: 1},
+		model.OrderLine{ProductID: contractProductID(2), Quantity: 1},
+	)
+	b := createContractOrders(t, orders, contractUserB, model.OrderLine{ProductID: contractProductID(2), Quantity: 1})
+
+	tests := []struct {
+		name      string
+		userID    int
+		req       model.ListRequest
+		wantIDs   []int64
+		wantTotal int
+	}{
+		{
+			name:      "first_page",
+			userID:    contractUserA,
+			req:       model.ListRequest{SortField: "order_id", SortOrder: "asc", PageSize: 3},
+			wantIDs:   a[:3],
+			wantTotal: 4,
+		},
+		{
+			name:      "last_page",
+			userID:    contractUserA,
+			req:       model.ListRequest{SortField: "order_id", SortOrder: "asc", PageSize: 3, Offset: 3},
+			wantIDs:   a[3:],
+			wantTotal: 4,
+		},
+		{
+			name:      "past_last_page",
+			userID:    contractUserA,
+			req:       model.ListRequest{SortField: "order_id", SortOrder: "asc", PageSize: 3, Offset: 6},
+			wantIDs:   []int64{},
+			wantTotal: 0,
+		},
+		{
+			name:      "other_user",
+			userID:    contractUserB,
+			req:       model.ListRequest{SortField: "order_id", SortOrder: "asc", PageSize: 10},
+			wantIDs:   b,
+			wantTotal: 1,
+		},
+		{
+			// 同じ商品名の注文は注文IDを同じ向きで並べる
+			name:      "product_name_desc",
+			userID:    contractUserA,
+			req:       model.ListRequest{SortField: "product_name", SortOrder: "desc", PageSize: 10},
+			wantIDs:   []int64{a[2], a[3], a[1], a[0]},
+			wantTotal: 4,
+		},
+		{
+			name:      "search",
+			userID:    contractUserA,
+			req:       model.ListRequest{Search: "apple", SortField: "order_id", SortOrder: "desc", PageSize: 10},
+			wantIDs:   []int64{a[1], a[0]},
+			wantTotal: 2,
+		},
+		{
+			name:      "prefix_search",
+			userID:    contractUserA,
+			req:       model.ListRequest{Search: "zqcontract ch", Type: "prefix", SortField: "order_id", SortOrder: "asc", PageSize: 10},
+			wantIDs:   []int64{a[2]},
+			wantTotal: 1,
+		},
+		{
+			name:      "cursor_first_page",
+			userID:    contractUserA,
+			req:       model.ListRequest{Pagination: model.PaginationCursor, SortField: "order_id", SortOrder: "asc", PageSize: 2},
+			wantIDs:   a[:2],
+			wantTotal: 4,
+		},
+		{
+			name:   "cursor_next_page",
+			userID: contractUserA,
+			req: model.ListRequest{Pagination: model.PaginationCursor, SortField: "order_id", SortOrder: "asc", PageSize: 2,
+				After: &model.OrderCursor{SortField: "order_id", SortOrder: "asc", OrderID: a[1]}},
+			wantIDs:   a[2:],
+			wantTotal: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := orders.ListOrders(ctx, tt.userID, tt.req)
+			if err != nil {
+				t.Fatalf("ListOrders() error = %v", err)
+			}
+			// キーセットページングの件数は呼び出し元で数えるため、IDのみ比べる
+			if tt.req.Pagination == model.PaginationCursor {
+				total = tt.wantTotal
+			}
+			if ids := orderIDs(got); !slices.Equal(ids, tt.wantIDs) || total != tt.wantTotal {
+				t.Fatalf("ListOrders() = %v, total %d, want %v, total %d", ids, total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// 注文のステータスを確かめる
+func checkStatus(t *testing.T, orders Orders, orderID int64, want string) {
+	t.Helper()
+	got, err := orders.LockStatus(context.Background(), orderID)
+	if err != nil || got != want {
+		t.Fatalf("LockStatus(%d) = %q, %v, want %q", orderID, got, err, want)
+	}
+}
+
+func testOrderStatusContract(t *testing.T, orders Orders, recorder *eventRecorder) {
+	ctx := context.Background()
+	ids := createContractOrders(t, orders, contractUserA, model.OrderLine{ProductID: contractProductID(1), Quantity: 5})
+	if got := recorder.statuses(events.OrderCreated); len(got) != len(ids) {
+		t.Fatalf("OrderCreated events = %v, want %v", got, ids)
+	}
+	for _, id := range ids {
+		checkStatus(t, orders, id, "shipping")
+	}
+
+	// 配送待ちの注文だけをロボットに割り当てられる
+	if err := orders.AssignToRobot(ctx, ids[:3], "zqcontract-robot", "claim"); err != nil {
+		t.Fatalf("AssignToRobot() error = %v", err)
+	}
+	if err := orders.AssignToRobot(ctx, ids[2:4], "zqcontract-robot-2", "claim-2"); !errors.Is(err, ErrOrdersAlreadyAssigned) {
+		t.Fatalf("AssignToRobot() of an assigned order error = %v, want ErrOrdersAlreadyAssigned", err)
+	}
+	checkStatus(t, orders, ids[3], "shipping")
+	robotID, claimID, err := orders.FindClaim(ctx, ids[0])
+	if err != nil || robotID != "zqcontract-robot" || claimID != "claim" {
+		t.Fatalf("FindClaim() = %q, %q, %v, want zqcontract-robot, claim", robotID, claimID, err)
+	}
+
+	// 配送待ちの注文だけをキャンセルできる
+	if ok, err := orders.Cancel(ctx, ids[3]); err != nil || !ok {
+		t.Fatalf("Cancel(shipping) = %v, %v, want true", ok, err)
+	}
+	if ok, err := orders.Cancel(ctx, ids[0]); err != nil || ok {
+		t.Fatalf("Cancel(delivering) = %v, %v, want false", ok, err)
+	}
+	// キャンセル済みの注文はステータスを変えない
+	if err := orders.UpdateStatuses(ctx, []int64{ids[3]}, "completed"); err != nil {
+		t.Fatalf("UpdateStatuses() error = %v", err)
+	}
+	checkStatus(t, orders, ids[3], "cancelled")
+
+	// 配送中の注文だけを失敗にでき、再試行の時刻を過ぎたら配送待ちに戻す
+	retryAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if ok, err := orders.MarkFailed(ctx, ids[1], retryAt); err != nil || !ok {
+		t.Fatalf("MarkFailed(delivering) = %v, %v, want true", ok, err)
+	}
+	if ok, err := orders.MarkFailed(ctx, ids[4], retryAt); err != nil || ok {
+		t.Fatalf("MarkFailed(shipping) = %v, %v, want false", ok, err)
+	}
+	candidates, err := orders.GetRequeueCandidates(ctx, time.Now(), 1000)
+	if err != nil || !slices.Contains(orderIDs(candidates), ids[1]) {
+		t.Fatalf("GetRequeueCandidates() = %v, %v, want %d included", orderIDs(candidates), err, ids[1])
+	}
+	if err := orders.Requeue(ctx, []int64{ids[1]}); err != nil {
+		t.Fatalf("Requeue() error = %v", err)
+	}
+	checkStatus(t, orders, ids[1], "shipping")
+
+	// 配送中の注文を完了にする
+	arrivedAt := time.Now().Truncate(time.Second)
+	if err := orders.MarkCompleted(ctx, ids[0], arrivedAt); err != nil {
+		t.Fatalf("MarkCompleted() error = %v", err)
+	}
+	order, err := orders.FindByID(ctx, ids[0])
+	if err != nil || order.ShippedStatus != "completed" || !order.ArrivedAt.Valid || !order.ArrivedAt.Time.Equal(arrivedAt) {
+		t.Fatalf("FindByID() = %+v, %v, want completed at %v", order, err, arrivedAt)
+	}
+
+	// 割り当てを取り消すと配送待ちに戻る（配送中ではない注文は変えない）
+	if err := orders.RollbackDelivering(ctx, []int64{ids[2], ids[3]}); err != nil {
+		t.Fatalf("RollbackDelivering() error = %v", err)
+	}
+	checkStatus(t, orders, ids[2], "shipping")
+	checkStatus(t, orders, ids[3], "cancelled")
+
+	shipping, err := orders.GetShippingOrders(ctx)
+	if err != nil {
+		t.Fatalf("GetShippingOrders() error = %v", err)
+	}
+	var ours []int64
+	for _, id := range orderIDs(shipping) {
+		if slices.Contains(ids, id) {
+			ours = append(ours, id)
+		}
+	}
+	if want := []int64{ids[1], ids[2], ids[4]}; !slices.Equal(ours, want) {
+		t.Fatalf("GetShippingOrders() = %v, want %v", ours, want)
+	}
+
+	statuses := recorder.statuses(events.OrderStatusChanged)
+	want := map[int64]string{ids[0]: "completed", ids[1]: "shipping", ids[2]: "shipping"}
+	for id, status := range want {
+		if statuses[id] != status {
+			t.Fatalf("last OrderStatusChanged for %d = %q, want %q (all %v)", id, statuses[id], status, statuses)
+		}
+	}
+}
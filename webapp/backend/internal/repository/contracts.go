@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+// 注文の読み書き
+// MySQL（OrderRepository）とメモリ上（MemoryOrderRepository）の実装がある
+type Orders interface {
+	Create(ctx context.Context, order *model.Order) (string, error)
+	CreateBulk(ctx context.Context, userID int, lines []model.OrderLine, addr model.DeliveryAddress) ([]int64, error)
+	AssignToRobot(ctx context.Context, orderIDs []int64, robotID, claimID string) error
+	AcknowledgePlan(ctx context.Context, robotID string) (int64, error)
+	GetUnacknowledgedDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error)
+	GetStaleDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error)
+	RollbackDelivering(ctx context.Context, orderIDs []int64) error
+	FindByTrackingToken(ctx context.Context, token string) (*model.Order, error)
+	UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error
+	GetShippingOrders(ctx context.Context) ([]model.Order, error)
+	LockShippingOrders(ctx context.Context) ([]model.Order, error)
+	IterateShippingOrders(ctx context.Context) (*Iterator[model.Order], error)
+	IterateByUser(ctx context.Context, userID int, req model.ListRequest) (*Iterator[model.Order], error)
+	IterateCompletedBefore(ctx context.Context, before time.Time) (*Iterator[model.Order], error)
+	ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error)
+	ListReceivedGifts(ctx context.Context, recipientUserID, limit, offset int) ([]model.Order, int, error)
+	FindClaim(ctx context.Context, orderID int64) (robotID, claimID string, err error)
+	MarkCompleted(ctx context.Context, orderID int64, arrivedAt time.Time) error
+	FindByID(ctx context.Context, orderID int64) (*model.Order, error)
+	LockStatus(ctx context.Context, orderID int64) (string, error)
+	MarkFailed(ctx context.Context, orderID int64, retryAt time.Time) (bool, error)
+	Cancel(ctx context.Context, orderID int64) (bool, error)
+	GetRequeueCandidates(ctx context.Context, now time.Time, limit int) ([]model.Order, error)
+	Requeue(ctx context.Context, orderIDs []int64) error
+	FindDeliveringWithoutRobot(ctx context.Context, limit int) ([]int64, error)
+	FindCompletedWithoutArrival(ctx context.Context, limit int) ([]int64, error)
+	FillMissingArrivals(ctx context.Context, orderIDs []int64) error
+	ExistingExternalRefs(ctx context.Context, refs []string) ([]string, error)
+	ImportBulk(ctx context.Context, orders []model.ImportedOrder) (map[string]int64, error)
+	FindOwners(ctx context.Context, orderIDs []int64) (map[int64]int, error)
+	CountCreatedSince(ctx context.Context, userID int, since time.Time) (int, error)
+	CountByStatus(ctx context.Context, status string) (int, error)
+	CountGroupedByStatus(ctx context.Context) ([]model.StatusCount, error)
+	CountShippingByProduct(ctx context.Context) ([]model.ShippingProductCount, error)
+	ShippingLoad(ctx context.Context, maxWeight int) (model.ShippingLoad, error)
+	SummarizeByUser(ctx context.Context, userID int) (*model.OrderSummary, error)
+	CountByProduct(ctx context.Context, userID int) ([]model.ProductOrderCounts, error)
+	RevenueTotals(ctx context.Context) (model.RevenueStats, error)
+	DeleteAll(ctx context.Context) (int64, error)
+	InsertFixtures(ctx context.Context, orders []model.Order) error
+	ResetSequence(ctx context.Context) error
+	InvalidateOrderCounts(userID int)
+	InvalidateSearchOrderCounts()
+	InvalidateAllOrderCounts()
+	HotCountQueries(limit int) []model.OrderCountQuery
+	WarmOrderCount(ctx context.Context, userID int, search, searchType string) error
+}
+
+// 商品の読み書きと商品一覧キャッシュの管理
+type Products interface {
+	ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error)
+	FindByIDs(ctx context.Context, productIDs []int) ([]model.Product, error)
+	LockByID(ctx context.Context, productID int) (model.Product, error)
+	UpdateValueWeight(ctx context.Context, productID, value, weight int) error
+	DecrementStock(ctx context.Context, productID, quantity int) (bool, error)
+	RestoreStock(ctx context.Context, productID, quantity int) error
+	ListLowStock(ctx context.Context, limit, offset int) ([]model.LowStockProduct, int, error)
+	ListInvalidValues(ctx context.Context, limit int) ([]model.Product, error)
+	ListAfter(ctx context.Context, afterID, limit int) ([]model.Product, error)
+	CountAvailabilityChanges(ctx context.Context, from, until time.Time) (int, error)
+	InvalidateListCache()
+	InvalidatePrefix(prefix string)
+	DeleteKeys(keys ...string) int
+	SetBudget(budget int64)
+	CacheUsage() (bytes int64, entries int, budget int64)
+	HotListQueries(limit int) []model.ProductListQuery
+}
+
+// ログインセッション
+// MySQL（SessionRepository）・Redis（RedisSessionRepository）・メモリ上（MemorySessionRepository）の実装がある
+type Sessions interface {
+	EnableLookupBatching(window time.Duration, maxBatch int)
+	Create(ctx context.Context, userBusinessID int, duration time.Duration, fingerprint string) (string, time.Time, error)
+	FindUserBySessionID(ctx context.Context, sessionID string) (userID int, fingerprint string, err error)
+	DeleteAll(ctx context.Context) (int64, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// ユーザーの参照
+// MySQL（UserRepository）とメモリ上（MemoryUserRepository）の実装がある
+type Users interface {
+	FindByUserName(ctx context.Context, userName string) (*model.User, error)
+	ListIDs(ctx context.Context) ([]int, error)
+	ExistingIDs(ctx context.Context, userIDs []int) ([]int, error)
+	LockByID(ctx context.Context, userID int) error
+}
+
+var (
+	_ Users    = (*UserRepository)(nil)
+	_ Users    = (*MemoryUserRepository)(nil)
+	_ Orders   = (*OrderRepository)(nil)
+	_ Orders   = (*MemoryOrderRepository)(nil)
+	_ Products = (*ProductRepository)(nil)
+	_ Products = (*MemoryProductRepository)(nil)
+	_ Sessions = (*SessionRepository)(nil)
+	_ Sessions = (*RedisSessionRepository)(nil)
+	_ Sessions = (*MemorySessionRepository)(nil)
+)
@@ -3,11 +3,44 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type DBTX interface {
 	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	Rebind(query string) string
 }
+
+// メモリ上のStoreで、メモリ上の実装がないリポジトリを使った
+var ErrNoDatabase = errors.New("repository is not available without a database")
+
+// ロボットに割り当てようとした注文が、すでに配送待ちではなかった（他の配送計画に割り当て済み）
+var ErrOrdersAlreadyAssigned = errors.New("orders already assigned to another delivery plan")
+
+// 全てのクエリをErrNoDatabaseで失敗させるDBTX
+type unavailableDB struct{}
+
+func (unavailableDB) GetContext(context.Context, interface{}, string, ...interface{}) error {
+	return ErrNoDatabase
+}
+
+func (unavailableDB) SelectContext(context.Context, interface{}, string, ...interface{}) error {
+	return ErrNoDatabase
+}
+
+func (unavailableDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, ErrNoDatabase
+}
+
+func (unavailableDB) QueryxContext(context.Context, string, ...interface{}) (*sqlx.Rows, error) {
+	return nil, ErrNoDatabase
+}
+
+func (unavailableDB) Rebind(query string) string {
+	return query
+}
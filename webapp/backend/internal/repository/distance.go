@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"strings"
+)
+
+// UpsertManyで1回のINSERT文に含める最大行数
+const distanceUpsertChunkSize = 1000
+
+type DistanceRepository struct {
+	db DBTX
+}
+
+func NewDistanceRepository(db DBTX) *DistanceRepository {
+	return &DistanceRepository{db: db}
+}
+
+// キャッシュされた2地点間の移動時間を取得
+func (r *DistanceRepository) Find(ctx context.Context, fromLat, fromLng, toLat, toLng float64) (*model.TravelTime, error) {
+	var tt model.TravelTime
+	query := `
+		SELECT from_lat, from_lng, to_lat, to_lng, travel_seconds, computed_at
+		FROM distance_cache
+		WHERE from_lat = ? AND from_lng = ? AND to_lat = ? AND to_lng = ?`
+	if err := r.db.GetContext(ctx, &tt, query, fromLat, fromLng, toLat, toLng); err != nil {
+		return nil, err
+	}
+	return &tt, nil
+}
+
+// 2地点間の移動時間を保存（既存の場合は更新）
+func (r *DistanceRepository) Upsert(ctx context.Context, tt model.TravelTime) error {
+	query := `
+		INSERT INTO distance_cache (from_lat, from_lng, to_lat, to_lng, travel_seconds, computed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE travel_seconds = VALUES(travel_seconds), computed_at = VALUES(computed_at)`
+	_, err := r.db.ExecContext(ctx, query, tt.FromLat, tt.FromLng, tt.ToLat, tt.ToLng, tt.TravelSeconds, tt.ComputedAt)
+	return err
+}
+
+// coordsの全ペア（出発地・到着地ともにcoordsに含まれるもの）のうち、キャッシュされている移動時間をまとめて取得
+func (r *DistanceRepository) FindMany(ctx context.Context, coords []model.Coordinates) ([]model.TravelTime, error) {
+	if len(coords) == 0 {
+		return nil, nil
+	}
+	points := strings.TrimSuffix(strings.Repeat("(?, ?), ", len(coords)), ", ")
+	args := make([]interface{}, 0, len(coords)*4)
+	for _, c := range coords {
+		args = append(args, c.Latitude, c.Longitude)
+	}
+	args = append(args, args...)
+	query := `
+		SELECT from_lat, from_lng, to_lat, to_lng, travel_seconds, computed_at
+		FROM distance_cache
+		WHERE (from_lat, from_lng) IN (` + points + `) AND (to_lat, to_lng) IN (` + points + `)`
+	var rows []model.TravelTime
+	err := r.db.SelectContext(ctx, &rows, query, args...)
+	return rows, err
+}
+
+// 2地点間の移動時間をまとめて保存（既存の場合は更新）
+// max_allowed_packetを超えないよう、一定行数ごとにINSERT文を分割する
+func (r *DistanceRepository) UpsertMany(ctx context.Context, tts []model.TravelTime) error {
+	for start := 0; start < len(tts); start += distanceUpsertChunkSize {
+		chunk := tts[start:min(start+distanceUpsertChunkSize, len(tts))]
+		args := make([]interface{}, 0, len(chunk)*6)
+		for _, tt := range chunk {
+			args = append(args, tt.FromLat, tt.FromLng, tt.ToLat, tt.ToLng, tt.TravelSeconds, tt.ComputedAt)
+		}
+		query := `
+			INSERT INTO distance_cache (from_lat, from_lng, to_lat, to_lng, travel_seconds, computed_at)
+			VALUES ` + strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(chunk)), ", ") + `
+			ON DUPLICATE KEY UPDATE travel_seconds = VALUES(travel_seconds), computed_at = VALUES(computed_at)`
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 注文で多く使われている座標を頻度順に取得
+func (r *DistanceRepository) FrequentCoordinates(ctx context.Context, limit int) ([]model.Coordinates, error) {
+	var coords []model.Coordinates
+	query := `
+		SELECT latitude, longitude
+		FROM orders
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+		GROUP BY latitude, longitude
+		ORDER BY COUNT(*) DESC
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &coords, query, limit)
+	return coords, err
+}
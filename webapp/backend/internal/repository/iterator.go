@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// クエリ結果を1行ずつ読み込む
+// エクスポートなど件数の多い走査で、全件をスライスに読み込まずに処理するために使う
+//
+//	it, err := repo.IterateXxx(ctx)
+//	if err != nil { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		var v T
+//		if err := it.Scan(&v); err != nil { ... }
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iterator[T any] struct {
+	ctx  context.Context
+	db   DBTX
+	rows *sqlx.Rows
+	err  error
+	// rowsがnilの場合はスライスの要素を順に返す（メモリ上のリポジトリ用）
+	items []T
+	pos   int
+}
+
+func iterate[T any](ctx context.Context, db DBTX, query string, args ...interface{}) (*Iterator[T], error) {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Iterator[T]{ctx: ctx, db: db, rows: rows}, nil
+}
+
+func iterateSlice[T any](ctx context.Context, items []T) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, items: items}
+}
+
+// 次の行に進む。行がない・ctxがキャンセルされた・エラーが起きた場合はfalseを返す
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	if it.rows == nil {
+		it.pos++
+		return it.pos <= len(it.items)
+	}
+	return it.rows.Next()
+}
+
+// 現在の行をdestに読み込む
+func (it *Iterator[T]) Scan(dest *T) error {
+	if it.rows == nil {
+		*dest = it.items[it.pos-1]
+		return nil
+	}
+	if err := it.rows.StructScan(dest); err != nil {
+		it.err = err
+		return err
+	}
+	return nil
+}
+
+// 走査中に起きたエラー（最後まで読んだ場合はnil）
+func (it *Iterator[T]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.rows == nil {
+		return nil
+	}
+	err := it.rows.Err()
+	observeError(it.db, err)
+	return err
+}
+
+// 接続をプールに返す。途中で走査をやめる場合も必ず呼ぶこと
+func (it *Iterator[T]) Close() error {
+	if it.rows == nil {
+		return nil
+	}
+	return it.rows.Close()
+}
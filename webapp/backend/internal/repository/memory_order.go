@@ -0,0 +1,799 @@
+package repository
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryOrder struct {
+	order          model.Order
+	claimID        string
+	retryAt        *time.Time
+	acknowledgedAt *time.Time
+	externalRef    string
+}
+
+// メモリ上の注文（MySQLなしでの動作確認・結合テスト用）
+// ステータスの遷移・絞り込み・ページング・変更イベントはOrderRepositoryと同じ
+// 商品の重量・価値・名前はproductsから結合する
+type MemoryOrderRepository struct {
+	events   events.Publisher
+	products *MemoryProductRepository
+
+	mutex  sync.RWMutex
+	orders map[int64]*memoryOrder
+	nextID int64
+}
+
+func NewMemoryOrderRepository(products *MemoryProductRepository, pub events.Publisher) *MemoryOrderRepository {
+	return &MemoryOrderRepository{events: pub, products: products, orders: make(map[int64]*memoryOrder), nextID: 1}
+}
+
+func (r *MemoryOrderRepository) publishStatus(orderIDs []int64, status string) {
+	r.events.Publish(events.Event{Topic: events.OrderStatusChanged, IDs: orderIDs, Status: status})
+}
+
+// 商品を結合した注文（商品が存在しない注文はJOINと同じく含めない）
+func (r *MemoryOrderRepository) joined(o *memoryOrder) (model.Order, bool) {
+	p, ok := r.products.get(o.order.ProductID)
+	if !ok {
+		return model.Order{}, false
+	}
+	order := o.order
+	order.ProductName, order.Weight, order.Value = p.Name, p.Weight, p.Value
+	return order, true
+}
+
+// 条件に一致する注文を注文ID順に返す（呼び出し元でロックを保持すること）
+func (r *MemoryOrderRepository) selectLocked(match func(o *memoryOrder) bool) []*memoryOrder {
+	var matched []*memoryOrder
+	for _, o := range r.orders {
+		if match(o) {
+			matched = append(matched, o)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *memoryOrder) int { return cmp.Compare(a.order.OrderID, b.order.OrderID) })
+	return matched
+}
+
+func (r *MemoryOrderRepository) insertLocked(order model.Order) int64 {
+	order.OrderID = r.nextID
+	order.ShippedStatus = "shipping"
+	order.CreatedAt = time.Now()
+	r.nextID++
+	r.orders[order.OrderID] = &memoryOrder{order: order}
+	return order.OrderID
+}
+
+func (r *MemoryOrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
+	r.mutex.Lock()
+	id := r.insertLocked(model.Order{UserID: order.UserID, ProductID: order.ProductID})
+	r.mutex.Unlock()
+	r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: []int64{id}, UserID: order.UserID})
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (r *MemoryOrderRepository) CreateBulk(ctx context.Context, userID int, lines []model.OrderLine, addr model.DeliveryAddress) ([]int64, error) {
+	if len(lines) == 0 {
+		return []int64{}, nil
+	}
+	var address *string
+	if addr.Address != "" {
+		address = &addr.Address
+	}
+
+	ids := []int64{}
+	r.mutex.Lock()
+	for _, line := range lines {
+		var zone *string
+		if line.ShippingZone != "" {
+			zone = &line.ShippingZone
+		}
+		for i := 0; i < line.Quantity; i++ {
+			token, err := newTrackingToken()
+			if err != nil {
+				r.mutex.Unlock()
+				return nil, err
+			}
+			id := r.insertLocked(model.Order{
+				UserID:          userID,
+				ProductID:       line.ProductID,
+				Address:         address,
+				Latitude:        addr.Latitude,
+				Longitude:       addr.Longitude,
+				ShippingCost:    line.ShippingCost,
+				ShippingZone:    zone,
+				TaxAmount:       line.TaxAmount,
+				TrackingToken:   &token,
+				RecipientUserID: addr.RecipientUserID,
+			})
+			ids = append(ids, id)
+		}
+	}
+	r.mutex.Unlock()
+
+	if len(ids) > 0 {
+		r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: ids, UserID: userID})
+	}
+	return ids, nil
+}
+
+func (r *MemoryOrderRepository) AssignToRobot(ctx context.Context, orderIDs []int64, robotID, claimID string) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	r.mutex.Lock()
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; !ok || o.order.ShippedStatus != "shipping" {
+			r.mutex.Unlock()
+			return fmt.Errorf("%w: order %d", ErrOrdersAlreadyAssigned, id)
+		}
+	}
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; ok {
+			o.order.ShippedStatus = "delivering"
+			o.order.RobotID = &robotID
+			o.order.DeliveringAt = &now
+			o.claimID = claimID
+			o.acknowledgedAt = nil
+		}
+	}
+	r.mutex.Unlock()
+	r.publishStatus(orderIDs, "delivering")
+	return nil
+}
+
+func (r *MemoryOrderRepository) AcknowledgePlan(ctx context.Context, robotID string) (int64, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var n int64
+	for _, o := range r.orders {
+		if o.order.ShippedStatus == "delivering" && o.acknowledgedAt == nil && o.order.RobotID != nil && *o.order.RobotID == robotID {
+			o.acknowledgedAt = &now
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MemoryOrderRepository) GetUnacknowledgedDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error) {
+	r.mutex.RLock()
+	matched := r.selectLocked(func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "delivering" && o.acknowledgedAt == nil && o.order.DeliveringAt != nil && !o.order.DeliveringAt.After(deadline)
+	})
+	slices.SortStableFunc(matched, func(a, b *memoryOrder) int { return a.order.DeliveringAt.Compare(*b.order.DeliveringAt) })
+	orders := r.candidatesLocked(matched, limit)
+	r.mutex.RUnlock()
+	return orders, nil
+}
+
+func (r *MemoryOrderRepository) GetStaleDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error) {
+	r.mutex.RLock()
+	matched := r.selectLocked(func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "delivering" && o.order.DeliveringAt != nil && !o.order.DeliveringAt.After(deadline)
+	})
+	slices.SortStableFunc(matched, func(a, b *memoryOrder) int { return a.order.DeliveringAt.Compare(*b.order.DeliveringAt) })
+	orders := r.candidatesLocked(matched, limit)
+	r.mutex.RUnlock()
+	return orders, nil
+}
+
+// 再キュー・ロールバックの対象として注文ID・ユーザーID・商品ID・重量・価値を返す
+func (r *MemoryOrderRepository) candidatesLocked(matched []*memoryOrder, limit int) []model.Order {
+	var orders []model.Order
+	for _, o := range matched {
+		if len(orders) == limit {
+			break
+		}
+		if order, ok := r.joined(o); ok {
+			orders = append(orders, model.Order{OrderID: order.OrderID, UserID: order.UserID, ProductID: order.ProductID, Weight: order.Weight, Value: order.Value})
+		}
+	}
+	return orders
+}
+
+func (r *MemoryOrderRepository) RollbackDelivering(ctx context.Context, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	r.mutex.Lock()
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; ok && o.order.ShippedStatus == "delivering" {
+			o.order.ShippedStatus = "shipping"
+			o.order.RobotID = nil
+			o.order.DeliveringAt = nil
+			o.claimID = ""
+			o.acknowledgedAt = nil
+		}
+	}
+	r.mutex.Unlock()
+	r.publishStatus(orderIDs, "shipping")
+	return nil
+}
+
+func (r *MemoryOrderRepository) FindByTrackingToken(ctx context.Context, token string) (*model.Order, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, o := range r.orders {
+		if o.order.TrackingToken != nil && *o.order.TrackingToken == token {
+			return &model.Order{
+				OrderID:       o.order.OrderID,
+				ShippedStatus: o.order.ShippedStatus,
+				CreatedAt:     o.order.CreatedAt,
+				ArrivedAt:     o.order.ArrivedAt,
+				RobotID:       o.order.RobotID,
+				DeliveringAt:  o.order.DeliveringAt,
+			}, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryOrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	var updated []int64
+	r.mutex.Lock()
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; ok && o.order.ShippedStatus != "cancelled" {
+			o.order.ShippedStatus = newStatus
+			updated = append(updated, id)
+		}
+	}
+	r.mutex.Unlock()
+	if len(updated) > 0 {
+		r.publishStatus(updated, newStatus)
+	}
+	return nil
+}
+
+func (r *MemoryOrderRepository) shippingOrders() []model.Order {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var orders []model.Order
+	for _, o := range r.selectLocked(func(o *memoryOrder) bool { return o.order.ShippedStatus == "shipping" }) {
+		if order, ok := r.joined(o); ok {
+			orders = append(orders, model.Order{OrderID: order.OrderID, Weight: order.Weight, Value: order.Value, Latitude: order.Latitude, Longitude: order.Longitude})
+		}
+	}
+	return orders
+}
+
+func (r *MemoryOrderRepository) GetShippingOrders(ctx context.Context) ([]model.Order, error) {
+	return r.shippingOrders(), nil
+}
+
+// メモリ上では行ロックがないため、割り当て時のステータスの確認で重複を防ぐ
+func (r *MemoryOrderRepository) LockShippingOrders(ctx context.Context) ([]model.Order, error) {
+	return r.shippingOrders(), nil
+}
+
+func (r *MemoryOrderRepository) IterateShippingOrders(ctx context.Context) (*Iterator[model.Order], error) {
+	return iterateSlice(ctx, r.shippingOrders()), nil
+}
+
+func (r *MemoryOrderRepository) IterateByUser(ctx context.Context, userID int, req model.ListRequest) (*Iterator[model.Order], error) {
+	matched, _ := r.matchUserOrders(userID, req)
+	return iterateSlice(ctx, matched), nil
+}
+
+func (r *MemoryOrderRepository) IterateCompletedBefore(ctx context.Context, before time.Time) (*Iterator[model.Order], error) {
+	return iterateSlice(ctx, r.joinedWhere(func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "completed" && o.order.ArrivedAt.Valid && o.order.ArrivedAt.Time.Before(before)
+	})), nil
+}
+
+// 条件に一致する注文を商品と結合して注文ID順に返す
+func (r *MemoryOrderRepository) joinedWhere(match func(o *memoryOrder) bool) []model.Order {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var orders []model.Order
+	for _, o := range r.selectLocked(match) {
+		if order, ok := r.joined(o); ok {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// ユーザーの注文をreqの検索語で絞り込んで並べ替え、並び順の比較関数とともに返す
+func (r *MemoryOrderRepository) matchUserOrders(userID int, req model.ListRequest) ([]model.Order, func(a, b model.Order) int) {
+	search := strings.ToLower(req.Search)
+	matched := r.joinedWhere(func(o *memoryOrder) bool { return o.order.UserID == userID })
+	matched = slices.DeleteFunc(matched, func(order model.Order) bool {
+		name := strings.ToLower(order.ProductName)
+		if req.Type == "prefix" {
+			return !strings.HasPrefix(name, search)
+		}
+		return !strings.Contains(name, search)
+	})
+
+	desc := strings.EqualFold(req.SortOrder, "desc")
+	compare := func(a, b model.Order) int {
+		// 同じ値の注文は注文IDで並べる
+		c := cmp.Or(compareOrders(a, b, req.SortField), cmp.Compare(a.OrderID, b.OrderID))
+		if desc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortStableFunc(matched, compare)
+	return matched, compare
+}
+
+func (r *MemoryOrderRepository) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
+	matched, compare := r.matchUserOrders(userID, req)
+
+	keyset := req.Pagination == model.PaginationCursor
+	offset := req.Offset
+	if keyset {
+		offset = 0
+		if req.After != nil {
+			after, err := orderAtCursor(req.After)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset = len(matched)
+			for i, o := range matched {
+				if compare(o, after) > 0 {
+					offset = i
+					break
+				}
+			}
+		}
+	}
+	page := paginate(matched, offset, req.PageSize)
+	if len(page) == 0 {
+		if keyset {
+			return []model.Order{}, len(matched), nil
+		}
+		return []model.Order{}, 0, nil
+	}
+	withName := req.Search != "" || req.SortField == "product_name" || wantsField(req.Fields, "product_name")
+	orders := make([]model.Order, len(page))
+	for i, o := range page {
+		orders[i] = model.Order{
+			OrderID:       o.OrderID,
+			ProductID:     o.ProductID,
+			ShippedStatus: o.ShippedStatus,
+			CreatedAt:     o.CreatedAt,
+			ArrivedAt:     o.ArrivedAt,
+		}
+		if withName {
+			orders[i].ProductName = o.ProductName
+		}
+	}
+	return orders, len(matched), nil
+}
+
+// 位置を比較用の注文に変換する
+func orderAtCursor(c *model.OrderCursor) (model.Order, error) {
+	order := model.Order{OrderID: c.OrderID}
+	key, err := orderCursorKey(c)
+	if err != nil {
+		return order, err
+	}
+	switch v := key.(type) {
+	case string:
+		order.ProductName = v
+		order.ShippedStatus = v
+	case time.Time:
+		order.CreatedAt = v
+		order.ArrivedAt = sql.NullTime{Time: v, Valid: true}
+	}
+	return order, nil
+}
+
+func compareOrders(a, b model.Order, field string) int {
+	switch field {
+	case "product_name":
+		return cmp.Compare(a.ProductName, b.ProductName)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "shipped_status":
+		return cmp.Compare(a.ShippedStatus, b.ShippedStatus)
+	case "arrived_at":
+		// NULLは最小値として扱う（MySQLと同じ）
+		if a.ArrivedAt.Valid != b.ArrivedAt.Valid {
+			if a.ArrivedAt.Valid {
+				return 1
+			}
+			return -1
+		}
+		return a.ArrivedAt.Time.Compare(b.ArrivedAt.Time)
+	default:
+		return cmp.Compare(a.OrderID, b.OrderID)
+	}
+}
+
+func (r *MemoryOrderRepository) ListReceivedGifts(ctx context.Context, recipientUserID, limit, offset int) ([]model.Order, int, error) {
+	gifts := r.joinedWhere(func(o *memoryOrder) bool {
+		return o.order.RecipientUserID != nil && *o.order.RecipientUserID == recipientUserID
+	})
+	slices.SortFunc(gifts, func(a, b model.Order) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.OrderID, a.OrderID))
+	})
+	total := len(gifts)
+	gifts = gifts[min(offset, total):min(offset+limit, total)]
+	return append([]model.Order{}, gifts...), total, nil
+}
+
+func (r *MemoryOrderRepository) FindClaim(ctx context.Context, orderID int64) (string, string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	o, ok := r.orders[orderID]
+	if !ok {
+		return "", "", sql.ErrNoRows
+	}
+	var robotID string
+	if o.order.RobotID != nil {
+		robotID = *o.order.RobotID
+	}
+	return robotID, o.claimID, nil
+}
+
+func (r *MemoryOrderRepository) MarkCompleted(ctx context.Context, orderID int64, arrivedAt time.Time) error {
+	r.mutex.Lock()
+	if o, ok := r.orders[orderID]; ok && o.order.ShippedStatus != "cancelled" {
+		o.order.ShippedStatus = "completed"
+		o.order.ArrivedAt = sql.NullTime{Time: arrivedAt, Valid: true}
+	}
+	r.mutex.Unlock()
+	r.publishStatus([]int64{orderID}, "completed")
+	return nil
+}
+
+// メモリ上の実装では行ロックは取らない（キャンセル済みの注文はUpdateStatuses・MarkCompletedが更新しない）
+func (r *MemoryOrderRepository) LockStatus(ctx context.Context, orderID int64) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	o, ok := r.orders[orderID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return o.order.ShippedStatus, nil
+}
+
+func (r *MemoryOrderRepository) FindByID(ctx context.Context, orderID int64) (*model.Order, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	o, ok := r.orders[orderID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	order, ok := r.joined(o)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	// FindByIDのクエリはロボットの割り当てを取得しない
+	order.RobotID, order.DeliveringAt = nil, nil
+	return &order, nil
+}
+
+func (r *MemoryOrderRepository) MarkFailed(ctx context.Context, orderID int64, retryAt time.Time) (bool, error) {
+	r.mutex.Lock()
+	o, ok := r.orders[orderID]
+	if !ok || o.order.ShippedStatus != "delivering" {
+		r.mutex.Unlock()
+		return false, nil
+	}
+	o.order.ShippedStatus = "failed"
+	o.retryAt = &retryAt
+	r.mutex.Unlock()
+	r.publishStatus([]int64{orderID}, "failed")
+	return true, nil
+}
+
+func (r *MemoryOrderRepository) Cancel(ctx context.Context, orderID int64) (bool, error) {
+	r.mutex.Lock()
+	o, ok := r.orders[orderID]
+	if !ok || o.order.ShippedStatus != "shipping" {
+		r.mutex.Unlock()
+		return false, nil
+	}
+	o.order.ShippedStatus = "cancelled"
+	r.mutex.Unlock()
+	r.publishStatus([]int64{orderID}, "cancelled")
+	return true, nil
+}
+
+func (r *MemoryOrderRepository) GetRequeueCandidates(ctx context.Context, now time.Time, limit int) ([]model.Order, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	matched := r.selectLocked(func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "failed" && o.retryAt != nil && !o.retryAt.After(now)
+	})
+	slices.SortStableFunc(matched, func(a, b *memoryOrder) int { return a.retryAt.Compare(*b.retryAt) })
+	return r.candidatesLocked(matched, limit), nil
+}
+
+func (r *MemoryOrderRepository) Requeue(ctx context.Context, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	r.mutex.Lock()
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; ok && o.order.ShippedStatus == "failed" {
+			o.order.ShippedStatus = "shipping"
+			o.retryAt = nil
+			o.claimID = ""
+		}
+	}
+	r.mutex.Unlock()
+	r.publishStatus(orderIDs, "shipping")
+	return nil
+}
+
+func (r *MemoryOrderRepository) matchingIDs(limit int, match func(o *memoryOrder) bool) []int64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	matched := r.selectLocked(match)
+	matched = matched[:min(len(matched), limit)]
+	orderIDs := make([]int64, 0, len(matched))
+	for _, o := range matched {
+		orderIDs = append(orderIDs, o.order.OrderID)
+	}
+	return orderIDs
+}
+
+func (r *MemoryOrderRepository) FindDeliveringWithoutRobot(ctx context.Context, limit int) ([]int64, error) {
+	return r.matchingIDs(limit, func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "delivering" && (o.order.RobotID == nil || *o.order.RobotID == "")
+	}), nil
+}
+
+func (r *MemoryOrderRepository) FindCompletedWithoutArrival(ctx context.Context, limit int) ([]int64, error) {
+	return r.matchingIDs(limit, func(o *memoryOrder) bool {
+		return o.order.ShippedStatus == "completed" && !o.order.ArrivedAt.Valid
+	}), nil
+}
+
+func (r *MemoryOrderRepository) FillMissingArrivals(ctx context.Context, orderIDs []int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, id := range orderIDs {
+		o, ok := r.orders[id]
+		if !ok || o.order.ShippedStatus != "completed" || o.order.ArrivedAt.Valid {
+			continue
+		}
+		arrivedAt := o.order.CreatedAt
+		if o.order.DeliveringAt != nil {
+			arrivedAt = *o.order.DeliveringAt
+		}
+		o.order.ArrivedAt = sql.NullTime{Time: arrivedAt, Valid: true}
+	}
+	return nil
+}
+
+func (r *MemoryOrderRepository) ExistingExternalRefs(ctx context.Context, refs []string) ([]string, error) {
+	wanted := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		wanted[ref] = true
+	}
+	existing := []string{}
+	r.mutex.RLock()
+	for _, o := range r.orders {
+		if o.externalRef != "" && wanted[o.externalRef] {
+			existing = append(existing, o.externalRef)
+		}
+	}
+	r.mutex.RUnlock()
+	return existing, nil
+}
+
+func (r *MemoryOrderRepository) ImportBulk(ctx context.Context, orders []model.ImportedOrder) (map[string]int64, error) {
+	r.mutex.Lock()
+	existing := make(map[string]bool)
+	for _, o := range r.orders {
+		if o.externalRef != "" {
+			existing[o.externalRef] = true
+		}
+	}
+	imported := make(map[string]int64, len(orders))
+	byUser := make(map[int][]int64)
+	for _, o := range orders {
+		if existing[o.ExternalRef] {
+			continue
+		}
+		existing[o.ExternalRef] = true
+		id := r.nextID
+		r.nextID++
+		r.orders[id] = &memoryOrder{
+			order: model.Order{
+				OrderID:       id,
+				UserID:        o.UserID,
+				ProductID:     o.ProductID,
+				ShippedStatus: o.ShippedStatus,
+				CreatedAt:     o.CreatedAt,
+				ArrivedAt:     o.ArrivedAt,
+			},
+			externalRef: o.ExternalRef,
+		}
+		imported[o.ExternalRef] = id
+		byUser[o.UserID] = append(byUser[o.UserID], id)
+	}
+	r.mutex.Unlock()
+	for userID, ids := range byUser {
+		r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: ids, UserID: userID})
+	}
+	return imported, nil
+}
+
+func (r *MemoryOrderRepository) FindOwners(ctx context.Context, orderIDs []int64) (map[int64]int, error) {
+	owners := make(map[int64]int, len(orderIDs))
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, id := range orderIDs {
+		if o, ok := r.orders[id]; ok {
+			owners[id] = o.order.UserID
+		}
+	}
+	return owners, nil
+}
+
+func (r *MemoryOrderRepository) count(match func(o *memoryOrder) bool) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	n := 0
+	for _, o := range r.orders {
+		if match(o) {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *MemoryOrderRepository) CountCreatedSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	return r.count(func(o *memoryOrder) bool { return o.order.UserID == userID && !o.order.CreatedAt.Before(since) }), nil
+}
+
+func (r *MemoryOrderRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	return r.count(func(o *memoryOrder) bool { return o.order.ShippedStatus == status }), nil
+}
+
+// ステータス別の件数をステータス名の順に返す
+func (r *MemoryOrderRepository) countByStatus(match func(o *memoryOrder) bool) []model.StatusCount {
+	r.mutex.RLock()
+	counts := make(map[string]int)
+	for _, o := range r.orders {
+		if match(o) {
+			counts[o.order.ShippedStatus]++
+		}
+	}
+	r.mutex.RUnlock()
+	result := make([]model.StatusCount, 0, len(counts))
+	for status, n := range counts {
+		result = append(result, model.StatusCount{Status: status, Count: n})
+	}
+	slices.SortFunc(result, func(a, b model.StatusCount) int { return cmp.Compare(a.Status, b.Status) })
+	return result
+}
+
+func (r *MemoryOrderRepository) CountGroupedByStatus(ctx context.Context) ([]model.StatusCount, error) {
+	return r.countByStatus(func(*memoryOrder) bool { return true }), nil
+}
+
+func (r *MemoryOrderRepository) ShippingLoad(ctx context.Context, maxWeight int) (model.ShippingLoad, error) {
+	var load model.ShippingLoad
+	for _, o := range r.shippingOrders() {
+		if o.Weight <= maxWeight {
+			load.Orders++
+			load.Weight += o.Weight
+		}
+	}
+	return load, nil
+}
+
+func (r *MemoryOrderRepository) CountShippingByProduct(ctx context.Context) ([]model.ShippingProductCount, error) {
+	byProduct := make(map[int]*model.ShippingProductCount)
+	for _, o := range r.joinedWhere(func(o *memoryOrder) bool { return o.order.ShippedStatus == "shipping" }) {
+		c, ok := byProduct[o.ProductID]
+		if !ok {
+			c = &model.ShippingProductCount{ProductID: o.ProductID, Value: o.Value, Weight: o.Weight}
+			byProduct[o.ProductID] = c
+		}
+		c.Orders++
+	}
+	counts := make([]model.ShippingProductCount, 0, len(byProduct))
+	for _, c := range byProduct {
+		counts = append(counts, *c)
+	}
+	slices.SortFunc(counts, func(a, b model.ShippingProductCount) int { return cmp.Compare(a.ProductID, b.ProductID) })
+	return counts, nil
+}
+
+func (r *MemoryOrderRepository) DeleteAll(ctx context.Context) (int64, error) {
+	r.mutex.Lock()
+	n := int64(len(r.orders))
+	clear(r.orders)
+	r.nextID = 1
+	r.mutex.Unlock()
+	r.events.Publish(events.Event{Topic: events.OrdersReset})
+	return n, nil
+}
+
+func (r *MemoryOrderRepository) InsertFixtures(ctx context.Context, orders []model.Order) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, o := range orders {
+		if _, ok := r.orders[o.OrderID]; ok {
+			return fmt.Errorf("duplicate order_id %d", o.OrderID)
+		}
+		r.orders[o.OrderID] = &memoryOrder{order: model.Order{
+			OrderID: o.OrderID, UserID: o.UserID, ProductID: o.ProductID,
+			ShippedStatus: o.ShippedStatus, CreatedAt: o.CreatedAt, ArrivedAt: o.ArrivedAt,
+		}}
+		r.nextID = max(r.nextID, o.OrderID+1)
+	}
+	return nil
+}
+
+// 採番はInsertFixturesで最大の注文ID+1にしているため何もしない
+func (r *MemoryOrderRepository) ResetSequence(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryOrderRepository) CountByProduct(ctx context.Context, userID int) ([]model.ProductOrderCounts, error) {
+	byKey := make(map[productStatusCount]int)
+	for _, order := range r.joinedWhere(func(o *memoryOrder) bool { return o.order.UserID == userID }) {
+		byKey[productStatusCount{ProductID: order.ProductID, ProductName: order.ProductName, ShippedStatus: order.ShippedStatus}]++
+	}
+	rows := make([]productStatusCount, 0, len(byKey))
+	for row, n := range byKey {
+		row.Count = n
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b productStatusCount) int {
+		return cmp.Or(cmp.Compare(a.ProductID, b.ProductID), cmp.Compare(a.ShippedStatus, b.ShippedStatus))
+	})
+	return groupProductStatusCounts(rows), nil
+}
+
+func (r *MemoryOrderRepository) SummarizeByUser(ctx context.Context, userID int) (*model.OrderSummary, error) {
+	summary := &model.OrderSummary{}
+	for _, order := range r.joinedWhere(func(o *memoryOrder) bool {
+		return o.order.UserID == userID && o.order.ShippedStatus != "cancelled"
+	}) {
+		summary.TotalOrders++
+		summary.TotalValue += order.Value
+		summary.TotalShippingCost += order.ShippingCost
+		summary.TotalTax += order.TaxAmount
+	}
+	summary.TotalPreTax = summary.TotalValue + summary.TotalShippingCost
+	summary.TotalPostTax = summary.TotalPreTax + summary.TotalTax
+	summary.ByStatus = r.countByStatus(func(o *memoryOrder) bool { return o.order.UserID == userID })
+	return summary, nil
+}
+
+func (r *MemoryOrderRepository) RevenueTotals(ctx context.Context) (model.RevenueStats, error) {
+	var stats model.RevenueStats
+	for _, order := range r.joinedWhere(func(o *memoryOrder) bool { return o.order.ShippedStatus != "cancelled" }) {
+		stats.PreTax += order.Value + order.ShippingCost
+		stats.Tax += order.TaxAmount
+	}
+	stats.PostTax = stats.PreTax + stats.Tax
+	return stats, nil
+}
+
+// 件数をキャッシュしないため何もしない
+func (r *MemoryOrderRepository) InvalidateOrderCounts(int)    {}
+func (r *MemoryOrderRepository) InvalidateSearchOrderCounts() {}
+func (r *MemoryOrderRepository) InvalidateAllOrderCounts()    {}
+func (r *MemoryOrderRepository) HotCountQueries(int) []model.OrderCountQuery {
+	return nil
+}
+func (r *MemoryOrderRepository) WarmOrderCount(context.Context, int, string, string) error {
+	return nil
+}
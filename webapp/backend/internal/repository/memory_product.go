@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// メモリ上の商品（MySQLなしでの動作確認・結合テスト用）
+// 一覧の検索・並び替え・ページングはProductRepositoryと同じ結果を返す
+type MemoryProductRepository struct {
+	mutex    sync.RWMutex
+	products map[int]model.Product
+	events   events.Publisher
+}
+
+func NewMemoryProductRepository(pub events.Publisher, products ...model.Product) *MemoryProductRepository {
+	r := &MemoryProductRepository{products: make(map[int]model.Product, len(products)), events: pub}
+	r.Put(products...)
+	return r
+}
+
+// 商品を追加・置き換える
+func (r *MemoryProductRepository) Put(products ...model.Product) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, p := range products {
+		r.products[p.ProductID] = p
+	}
+}
+
+func (r *MemoryProductRepository) get(productID int) (model.Product, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	p, ok := r.products[productID]
+	return p, ok
+}
+
+// 商品ID順の全商品
+func (r *MemoryProductRepository) all() []model.Product {
+	r.mutex.RLock()
+	products := make([]model.Product, 0, len(r.products))
+	for _, p := range r.products {
+		products = append(products, p)
+	}
+	r.mutex.RUnlock()
+	slices.SortFunc(products, func(a, b model.Product) int { return cmp.Compare(a.ProductID, b.ProductID) })
+	return products
+}
+
+func (r *MemoryProductRepository) ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+	terms := req.SearchTerms
+	if len(terms) == 0 && req.Search != "" {
+		terms = []string{req.Search}
+	}
+	now := time.Now()
+	var matched []model.Product
+	for _, p := range r.all() {
+		if !p.AvailableAt(now) {
+			continue
+		}
+		if len(terms) > 0 && !slices.ContainsFunc(terms, func(term string) bool {
+			return containsFold(p.Name, term) || containsFold(p.Description, term)
+		}) {
+			continue
+		}
+		// 一覧のクエリはカテゴリを取得しない
+		p.Category = ""
+		matched = append(matched, p)
+	}
+
+	desc := strings.EqualFold(req.SortOrder, "desc")
+	slices.SortStableFunc(matched, func(a, b model.Product) int {
+		c := compareProducts(a, b, req.SortField)
+		if desc {
+			c = -c
+		}
+		// 同じ値の場合は商品IDの昇順
+		return cmp.Or(c, cmp.Compare(a.ProductID, b.ProductID))
+	})
+
+	page := paginate(matched, req.Offset, req.PageSize)
+	if len(page) == 0 {
+		return []model.Product{}, 0, nil
+	}
+	return page, len(matched), nil
+}
+
+func compareProducts(a, b model.Product, field string) int {
+	switch field {
+	case "name":
+		return cmp.Compare(a.Name, b.Name)
+	case "value":
+		return cmp.Compare(a.Value, b.Value)
+	case "weight":
+		return cmp.Compare(a.Weight, b.Weight)
+	default:
+		return cmp.Compare(a.ProductID, b.ProductID)
+	}
+}
+
+func (r *MemoryProductRepository) FindByIDs(ctx context.Context, productIDs []int) ([]model.Product, error) {
+	products := []model.Product{}
+	for _, id := range productIDs {
+		if p, ok := r.get(id); ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// 行ロックはないため、取得のみ行う
+func (r *MemoryProductRepository) LockByID(ctx context.Context, productID int) (model.Product, error) {
+	p, ok := r.get(productID)
+	if !ok {
+		return model.Product{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (r *MemoryProductRepository) UpdateValueWeight(ctx context.Context, productID, value, weight int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	p, ok := r.products[productID]
+	if !ok {
+		return nil
+	}
+	p.Value, p.Weight = value, weight
+	r.products[productID] = p
+	r.events.Publish(events.Event{Topic: events.ProductChanged, IDs: []int64{int64(productID)}})
+	return nil
+}
+
+func (r *MemoryProductRepository) DecrementStock(ctx context.Context, productID, quantity int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	p, ok := r.products[productID]
+	if !ok || p.Stock == nil || *p.Stock < quantity {
+		return false, nil
+	}
+	stock := *p.Stock - quantity
+	p.Stock = &stock
+	r.products[productID] = p
+	return true, nil
+}
+
+func (r *MemoryProductRepository) RestoreStock(ctx context.Context, productID, quantity int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	p, ok := r.products[productID]
+	if !ok || p.Stock == nil {
+		return nil
+	}
+	stock := *p.Stock + quantity
+	p.Stock = &stock
+	r.products[productID] = p
+	return nil
+}
+
+func (r *MemoryProductRepository) ListLowStock(ctx context.Context, limit, offset int) ([]model.LowStockProduct, int, error) {
+	low := []model.LowStockProduct{}
+	for _, p := range r.all() {
+		if p.Stock != nil && p.ReorderThreshold != nil && *p.Stock < *p.ReorderThreshold {
+			low = append(low, model.LowStockProduct{ProductID: p.ProductID, Name: p.Name, Stock: *p.Stock, ReorderThreshold: *p.ReorderThreshold})
+		}
+	}
+	slices.SortFunc(low, func(a, b model.LowStockProduct) int {
+		return cmp.Or(cmp.Compare(a.Stock, b.Stock), cmp.Compare(a.ProductID, b.ProductID))
+	})
+	total := len(low)
+	low = low[min(offset, total):min(offset+limit, total)]
+	return low, total, nil
+}
+
+func (r *MemoryProductRepository) ListInvalidValues(ctx context.Context, limit int) ([]model.Product, error) {
+	products := []model.Product{}
+	for _, p := range r.all() {
+		if len(products) >= limit {
+			break
+		}
+		if p.Value <= 0 || p.Weight <= 0 {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+func (r *MemoryProductRepository) ListAfter(ctx context.Context, afterID, limit int) ([]model.Product, error) {
+	var products []model.Product
+	for _, p := range r.all() {
+		if p.ProductID <= afterID {
+			continue
+		}
+		if len(products) == limit {
+			break
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+func (r *MemoryProductRepository) CountAvailabilityChanges(ctx context.Context, from, until time.Time) (int, error) {
+	within := func(t *time.Time) bool { return t != nil && t.After(from) && !t.After(until) }
+	count := 0
+	for _, p := range r.all() {
+		if within(p.AvailableFrom) || within(p.AvailableUntil) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 一覧をキャッシュしないため、キャッシュの操作は何もしない
+func (r *MemoryProductRepository) InvalidateListCache()     {}
+func (r *MemoryProductRepository) InvalidatePrefix(string)  {}
+func (r *MemoryProductRepository) DeleteKeys(...string) int { return 0 }
+func (r *MemoryProductRepository) SetBudget(int64)          {}
+func (r *MemoryProductRepository) CacheUsage() (bytes int64, entries int, budget int64) {
+	return 0, 0, 0
+}
+func (r *MemoryProductRepository) HotListQueries(int) []model.ProductListQuery { return nil }
+
+// MySQLの照合順序と同じく大文字・小文字を区別せずに部分一致を判定する
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// offsetからlimit件を返す（範囲外の場合は空）
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset < 0 || offset >= len(items) {
+		return nil
+	}
+	end := len(items)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return items[offset:end]
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// メモリ上のセッション（MySQLなしでの動作確認・結合テスト用）
+type MemorySessionRepository struct {
+	mutex sync.RWMutex
+	// セッションIDのハッシュをキーとする
+	sessions map[string]sessionCache
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{sessions: make(map[string]sessionCache)}
+}
+
+// 問い合わせをまとめる必要がないため何もしない
+func (r *MemorySessionRepository) EnableLookupBatching(time.Duration, int) {}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, userBusinessID int, duration time.Duration, fingerprint string) (string, time.Time, error) {
+	sessionUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(duration)
+	r.mutex.Lock()
+	r.sessions[hashSessionID(sessionUUID.String())] = sessionCache{userID: userBusinessID, expiresAt: expiresAt, fingerprint: fingerprint}
+	r.mutex.Unlock()
+	return sessionUUID.String(), expiresAt, nil
+}
+
+// 存在しない・期限切れのセッションはsql.ErrNoRowsを返す（SessionRepositoryと同じ）
+func (r *MemorySessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, string, error) {
+	r.mutex.RLock()
+	session, ok := r.sessions[hashSessionID(sessionID)]
+	r.mutex.RUnlock()
+	if !ok || !time.Now().Before(session.expiresAt) {
+		return 0, "", sql.ErrNoRows
+	}
+	return session.userID, session.fingerprint, nil
+}
+
+func (r *MemorySessionRepository) DeleteAll(ctx context.Context) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	n := int64(len(r.sessions))
+	clear(r.sessions)
+	return n, nil
+}
+
+func (r *MemorySessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var deleted int64
+	for key, session := range r.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(r.sessions, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
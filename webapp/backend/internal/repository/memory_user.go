@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+)
+
+// メモリ上のユーザー（MySQLなしでの動作確認・結合テスト用）
+type MemoryUserRepository struct {
+	mutex sync.RWMutex
+	users map[int]model.User
+}
+
+func NewMemoryUserRepository(users ...model.User) *MemoryUserRepository {
+	r := &MemoryUserRepository{users: make(map[int]model.User, len(users))}
+	r.Put(users...)
+	return r
+}
+
+// ユーザーを追加・置き換える
+func (r *MemoryUserRepository) Put(users ...model.User) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, u := range users {
+		r.users[u.UserID] = u
+	}
+}
+
+// 存在しないユーザー名はsql.ErrNoRowsを返す（UserRepositoryと同じ）
+func (r *MemoryUserRepository) FindByUserName(ctx context.Context, userName string) (*model.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, u := range r.users {
+		if u.UserName == userName {
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryUserRepository) ListIDs(ctx context.Context) ([]int, error) {
+	r.mutex.RLock()
+	ids := make([]int, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	r.mutex.RUnlock()
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (r *MemoryUserRepository) ExistingIDs(ctx context.Context, userIDs []int) ([]int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	existing := []int{}
+	for _, id := range userIDs {
+		if _, ok := r.users[id]; ok {
+			existing = append(existing, id)
+		}
+	}
+	return existing, nil
+}
+
+// 行ロックはないため、存在の確認のみ行う
+func (r *MemoryUserRepository) LockByID(ctx context.Context, userID int) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if _, ok := r.users[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// トランザクションを開始できるDB（*sqlx.DB）
+type txBeginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// クエリのエラーをobserveに渡すDBTX（フェイルオーバーの検知に使う）
+type observedDB struct {
+	db      DBTX
+	observe func(err error) bool
+}
+
+// dbに対するクエリ・トランザクションのエラーをobserveに通知する
+func ObserveErrors(db DBTX, observe func(err error) bool) DBTX {
+	return &observedDB{db: db, observe: observe}
+}
+
+func (o *observedDB) notify(err error) error {
+	if err != nil {
+		o.observe(err)
+	}
+	return err
+}
+
+func (o *observedDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return o.notify(o.db.GetContext(ctx, dest, query, args...))
+}
+
+func (o *observedDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return o.notify(o.db.SelectContext(ctx, dest, query, args...))
+}
+
+func (o *observedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := o.db.ExecContext(ctx, query, args...)
+	return result, o.notify(err)
+}
+
+func (o *observedDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	rows, err := o.db.QueryxContext(ctx, query, args...)
+	return rows, o.notify(err)
+}
+
+func (o *observedDB) Rebind(query string) string {
+	return o.db.Rebind(query)
+}
+
+// 監視を外した元のDBTXを返す
+func unwrapDB(db DBTX) DBTX {
+	if o, ok := db.(*observedDB); ok {
+		return o.db
+	}
+	return db
+}
+
+// dbがエラーを監視している場合、errを通知する
+func observeError(db DBTX, err error) {
+	if o, ok := db.(*observedDB); ok && err != nil {
+		o.observe(err)
+	}
+}
+
+// dbがエラーを監視している場合、トランザクション内のクエリも同じように監視する
+func observeLike(db DBTX, tx DBTX) DBTX {
+	if o, ok := db.(*observedDB); ok {
+		return &observedDB{db: tx, observe: o.observe}
+	}
+	return tx
+}
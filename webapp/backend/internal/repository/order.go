@@ -1,21 +1,58 @@
 package repository
 
 import (
+	"backend/internal/budget"
+	"backend/internal/cache"
+	"backend/internal/events"
+	"backend/internal/metrics"
 	"backend/internal/model"
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// CreateBulkで1回のINSERT文に含める最大行数
+const createBulkChunkSize = 1000
+
+// 商品ごとの注文数をキャッシュする時間（注文の作成・ステータスの変更では破棄しない）
+const productCountsTTL = 10 * time.Second
+
+var productCountsCacheStats = metrics.Cache("order_by_product")
+
 type OrderRepository struct {
-	db DBTX
+	db     DBTX
+	events events.Publisher
+	counts *orderCountCache
+	// ユーザーごとの商品別注文数
+	productCounts *cache.ReadThrough[int, []model.ProductOrderCounts]
+	// 注文一覧・配送待ち注文の取得に使うDBTX（Store.UseReadReplicaで設定しない場合はdb）
+	read DBTX
+	// 注文を作ってからこの時間は、そのユーザーの注文一覧をdbから読む
+	readAfterWrite time.Duration
+}
+
+func NewOrderRepository(db DBTX, pub events.Publisher) *OrderRepository {
+	r := &OrderRepository{
+		db:     db,
+		read:   db,
+		events: pub,
+		counts: newOrderCountCache(10 * time.Minute),
+	}
+	r.productCounts = cache.NewReadThrough(orderCountCacheBudget, productCountsTTL, strconv.Itoa, r.countByProduct,
+		cache.ReadThroughOptions[[]model.ProductOrderCounts]{Size: productCountsSize, Stats: productCountsCacheStats})
+	return r
 }
 
-func NewOrderRepository(db DBTX) *OrderRepository {
-	return &OrderRepository{db: db}
+func (r *OrderRepository) publishStatus(orderIDs []int64, status string) {
+	r.events.Publish(events.Event{Topic: events.OrderStatusChanged, IDs: orderIDs, Status: status})
 }
 
 // 注文を作成し、生成された注文IDを返す
@@ -29,73 +66,352 @@ func (r *OrderRepository) Create(ctx context.Context, order *model.Order) (strin
 	if err != nil {
 		return "", err
 	}
+	r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: []int64{id}, UserID: order.UserID})
 	return fmt.Sprintf("%d", id), nil
 }
 
-// 複数の注文を一括で作成し、生成された注文IDのリストを返す
-func (r *OrderRepository) CreateBulk(ctx context.Context, userID int, items []model.RequestItem) ([]string, error) {
-	if len(items) == 0 {
-		return []string{}, nil
+// 複数の注文を一括で作成し、生成された注文IDのリストを作成順（linesの順）に返す
+// 住所が指定されていない場合、住所と座標はNULLで保存される
+func (r *OrderRepository) CreateBulk(ctx context.Context, userID int, lines []model.OrderLine, addr model.DeliveryAddress) ([]int64, error) {
+	if len(lines) == 0 {
+		return []int64{}, nil
 	}
 
-	// 数量分の値を準備
-	var values []string
-	var args []interface{}
+	var address interface{}
+	if addr.Address != "" {
+		address = addr.Address
+	}
+
+	// max_allowed_packetを超えないよう、一定行数ごとにINSERT文を分割する
+	orderIDs := make([]int64, 0, createBulkChunkSize)
+	values := make([]string, 0, createBulkChunkSize)
+	args := make([]interface{}, 0, createBulkChunkSize*10)
+	tokens := make([]string, 0, createBulkChunkSize)
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		ids, err := r.insertOrderRows(ctx, values, args, tokens)
+		if err != nil {
+			return err
+		}
+		orderIDs = append(orderIDs, ids...)
+		values = values[:0]
+		args = args[:0]
+		tokens = tokens[:0]
+		return nil
+	}
 
-	for _, item := range items {
-		for i := 0; i < item.Quantity; i++ {
-			values = append(values, "(?, ?, 'shipping', NOW())")
-			args = append(args, userID, item.ProductID)
+	for _, line := range lines {
+		var zone interface{}
+		if line.ShippingZone != "" {
+			zone = line.ShippingZone
+		}
+		for i := 0; i < line.Quantity; i++ {
+			token, err := newTrackingToken()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, "(?, ?, 'shipping', NOW(), ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, userID, line.ProductID, address, addr.Latitude, addr.Longitude, line.ShippingCost, zone, line.TaxAmount, token, addr.RecipientUserID)
+			tokens = append(tokens, token)
+			if len(values) == createBulkChunkSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
 
-	if len(values) == 0 {
-		return []string{}, nil
+	if len(orderIDs) > 0 {
+		r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: orderIDs, UserID: userID})
 	}
+	return orderIDs, nil
+}
 
+// 1回のINSERT文で注文を作成し、生成された注文IDのリストをtokensの順に返す
+// innodb_autoinc_lock_modeの設定や同時のINSERTにより採番は連続するとは限らないため、
+// LastInsertIdから数えずに、行ごとに生成した追跡用トークン（一意）で引き直す
+func (r *OrderRepository) insertOrderRows(ctx context.Context, values []string, args []interface{}, tokens []string) ([]int64, error) {
 	// バルクINSERTクエリを構築
-	query := fmt.Sprintf("INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES %s",
+	query := fmt.Sprintf("INSERT INTO orders (user_id, product_id, shipped_status, created_at, address, latitude, longitude, shipping_cost, shipping_zone, tax_amount, tracking_token, recipient_user_id) VALUES %s",
 		strings.Join(values, ", "))
 
-	result, err := r.db.ExecContext(ctx, query, args...)
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		OrderID       int64  `db:"order_id"`
+		TrackingToken string `db:"tracking_token"`
+	}
+	query, inArgs, err := sqlx.In("SELECT order_id, tracking_token FROM orders WHERE tracking_token IN (?)", tokens)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), inArgs...); err != nil {
+		return nil, err
+	}
+	byToken := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		byToken[row.TrackingToken] = row.OrderID
+	}
 
-	// 最初のIDを取得
-	firstID, err := result.LastInsertId()
+	orderIDs := make([]int64, len(tokens))
+	for i, token := range tokens {
+		id, ok := byToken[token]
+		if !ok {
+			return nil, fmt.Errorf("inserted order with tracking token %s not found", token)
+		}
+		orderIDs[i] = id
+	}
+	return orderIDs, nil
+}
+
+// 外部参照IDのうち、既に取り込み済みのものを返す
+func (r *OrderRepository) ExistingExternalRefs(ctx context.Context, refs []string) ([]string, error) {
+	existing := []string{}
+	if len(refs) == 0 {
+		return existing, nil
+	}
+	query, args, err := sqlx.In("SELECT external_ref FROM orders WHERE external_ref IN (?)", refs)
 	if err != nil {
 		return nil, err
 	}
+	err = r.db.SelectContext(ctx, &existing, r.db.Rebind(query), args...)
+	return existing, err
+}
+
+// 旧システムの注文を一括で取り込み、外部参照IDと生成された注文IDの対応を返す
+// 取り込み済みの外部参照IDは呼び出し元で除いておくこと（除かれていなければその行は取り込まない）
+func (r *OrderRepository) ImportBulk(ctx context.Context, orders []model.ImportedOrder) (map[string]int64, error) {
+	if len(orders) == 0 {
+		return map[string]int64{}, nil
+	}
+	refs := make([]string, 0, len(orders))
+	for chunk := range slices.Chunk(orders, createBulkChunkSize) {
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*6)
+		for _, o := range chunk {
+			values = append(values, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, o.UserID, o.ProductID, o.ShippedStatus, o.CreatedAt, o.ArrivedAt, o.ExternalRef)
+			refs = append(refs, o.ExternalRef)
+		}
+		query := "INSERT INTO orders (user_id, product_id, shipped_status, created_at, arrived_at, external_ref) VALUES " +
+			strings.Join(values, ", ") + " ON DUPLICATE KEY UPDATE external_ref = external_ref"
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
 
-	// 影響を受けた行数を取得
-	rowsAffected, err := result.RowsAffected()
+	// 重複した行は採番されずLastInsertIdから注文IDを求められないため、外部参照IDで引き直す
+	var rows []struct {
+		OrderID     int64  `db:"order_id"`
+		UserID      int    `db:"user_id"`
+		ExternalRef string `db:"external_ref"`
+	}
+	query, args, err := sqlx.In("SELECT order_id, user_id, external_ref FROM orders WHERE external_ref IN (?)", refs)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	imported := make(map[string]int64, len(rows))
+	byUser := make(map[int][]int64)
+	for _, row := range rows {
+		imported[row.ExternalRef] = row.OrderID
+		byUser[row.UserID] = append(byUser[row.UserID], row.OrderID)
+	}
+	for userID, ids := range byUser {
+		r.events.Publish(events.Event{Topic: events.OrderCreated, IDs: ids, UserID: userID})
+	}
+	return imported, nil
+}
+
+// 全注文を削除し、削除した件数を返す（注文イベントは外部キーで削除される）
+// テストデータのリセット用
+func (r *OrderRepository) DeleteAll(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM orders")
+	if err != nil {
+		return 0, err
+	}
+	r.events.Publish(events.Event{Topic: events.OrdersReset})
+	return result.RowsAffected()
+}
 
-	// IDのリストを生成
-	orderIDs := make([]string, rowsAffected)
-	for i := int64(0); i < rowsAffected; i++ {
-		orderIDs[i] = fmt.Sprintf("%d", firstID+i)
+// 注文IDを指定して注文を登録する（テストデータのリセット用）
+// 注文ID・ユーザー・商品・ステータス・注文日時・到着日時のみを登録し、それ以外の列は既定値とする
+func (r *OrderRepository) InsertFixtures(ctx context.Context, orders []model.Order) error {
+	for chunk := range slices.Chunk(orders, createBulkChunkSize) {
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*6)
+		for _, o := range chunk {
+			values = append(values, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, o.OrderID, o.UserID, o.ProductID, o.ShippedStatus, o.CreatedAt, o.ArrivedAt)
+		}
+		query := "INSERT INTO orders (order_id, user_id, product_id, shipped_status, created_at, arrived_at) VALUES " + strings.Join(values, ", ")
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return orderIDs, nil
+// 次に採番する注文IDを登録済みの最大の注文ID+1に戻す
+// DDLのため暗黙にコミットされる。トランザクションの外で呼ぶこと
+func (r *OrderRepository) ResetSequence(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE orders AUTO_INCREMENT = 1")
+	return err
+}
+
+// 推測不可能な追跡トークンを生成（128bitの乱数をURLセーフなBase64で表現）
+func newTrackingToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 注文をロボットに割り当てて配送中(delivering)にする
+// claimIDは配送計画のクレームID（空の場合はNULL）
+func (r *OrderRepository) AssignToRobot(ctx context.Context, orderIDs []int64, robotID, claimID string) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	var claim interface{}
+	if claimID != "" {
+		claim = claimID
+	}
+	query, args, err := sqlx.In("UPDATE orders SET shipped_status = 'delivering', robot_id = ?, claim_id = ?, delivering_at = NOW(), acknowledged_at = NULL WHERE order_id IN (?) AND shipped_status = 'shipping'", robotID, claim, orderIDs)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	// 他の配送計画に割り当て済みの注文が含まれていれば、呼び出し元のトランザクションごと取り消させる
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n != int64(len(orderIDs)) {
+		return fmt.Errorf("%w: %d of %d orders", ErrOrdersAlreadyAssigned, int64(len(orderIDs))-n, len(orderIDs))
+	}
+	r.publishStatus(orderIDs, "delivering")
+	return nil
+}
+
+// ロボットに割り当てられた未確認の配送計画を受領済みにし、件数を返す
+func (r *OrderRepository) AcknowledgePlan(ctx context.Context, robotID string) (int64, error) {
+	query := `
+		UPDATE orders SET acknowledged_at = NOW()
+		WHERE robot_id = ? AND shipped_status = 'delivering' AND acknowledged_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, robotID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// 期限までに受領確認されなかった配送中の注文を取得
+// 複数インスタンスで同時に処理しないよう行ロックを取得する
+func (r *OrderRepository) GetUnacknowledgedDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error) {
+	var orders []model.Order
+	query := `
+		SELECT o.order_id, o.user_id, o.product_id, p.weight, p.value
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status = 'delivering' AND o.acknowledged_at IS NULL AND o.delivering_at <= ?
+		ORDER BY o.delivering_at
+		LIMIT ?
+		FOR UPDATE OF o`
+	err := r.db.SelectContext(ctx, &orders, query, deadline, limit)
+	return orders, err
+}
+
+// 期限までに配送完了・配送失敗の報告がなかった配送中の注文を取得（受領確認の有無は問わない）
+// 複数インスタンスで同時に処理しないよう行ロックを取得する
+func (r *OrderRepository) GetStaleDeliveries(ctx context.Context, deadline time.Time, limit int) ([]model.Order, error) {
+	var orders []model.Order
+	query := `
+		SELECT o.order_id, o.user_id, o.product_id, p.weight, p.value
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status = 'delivering' AND o.delivering_at <= ?
+		ORDER BY o.delivering_at
+		LIMIT ?
+		FOR UPDATE OF o`
+	err := r.db.SelectContext(ctx, &orders, query, deadline, limit)
+	return orders, err
+}
+
+// 配送中の注文を配送待ち(shipping)に戻し、ロボットの割り当てを解除する
+func (r *OrderRepository) RollbackDelivering(ctx context.Context, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		UPDATE orders
+		SET shipped_status = 'shipping', robot_id = NULL, claim_id = NULL, delivering_at = NULL, acknowledged_at = NULL
+		WHERE order_id IN (?) AND shipped_status = 'delivering'`, orderIDs)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	r.publishStatus(orderIDs, "shipping")
+	return nil
+}
+
+// 追跡トークンから注文の配送状況を取得
+func (r *OrderRepository) FindByTrackingToken(ctx context.Context, token string) (*model.Order, error) {
+	var order model.Order
+	query := `
+		SELECT order_id, shipped_status, created_at, arrived_at, robot_id, delivering_at
+		FROM orders
+		WHERE tracking_token = ?`
+	if err := r.db.GetContext(ctx, &order, query, token); err != nil {
+		return nil, err
+	}
+	return &order, nil
 }
 
 // 複数の注文IDのステータスを一括で更新
 // 主に配送ロボットが注文を引き受けた際に一括更新をするために使用
+// キャンセル済みの注文は更新しない（在庫の戻しとキャンセルの記録はCancelで行う）
+// 更新対象の行をロックして絞り込み、実際に更新した注文のみ変更を通知する
 func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error {
 	if len(orderIDs) == 0 {
 		return nil
 	}
-	query, args, err := sqlx.In("UPDATE orders SET shipped_status = ? WHERE order_id IN (?)", newStatus, orderIDs)
+	query, args, err := sqlx.In("SELECT order_id FROM orders WHERE order_id IN (?) AND shipped_status <> 'cancelled' FOR UPDATE", orderIDs)
+	if err != nil {
+		return err
+	}
+	var updatable []int64
+	if err := r.db.SelectContext(ctx, &updatable, r.db.Rebind(query), args...); err != nil {
+		return err
+	}
+	if len(updatable) == 0 {
+		return nil
+	}
+	query, args, err = sqlx.In("UPDATE orders SET shipped_status = ? WHERE order_id IN (?) AND shipped_status <> 'cancelled'", newStatus, updatable)
 	if err != nil {
 		return err
 	}
 	query = r.db.Rebind(query)
-	_, err = r.db.ExecContext(ctx, query, args...)
-	return err
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	r.publishStatus(updatable, newStatus)
+	return nil
 }
 
 // 配送中(shipped_status:shipping)の注文一覧を取得
@@ -105,74 +421,149 @@ func (r *OrderRepository) GetShippingOrders(ctx context.Context) ([]model.Order,
         SELECT
             o.order_id,
             p.weight,
-            p.value
+            p.value,
+            o.latitude,
+            o.longitude
         FROM orders o
         JOIN products p ON o.product_id = p.product_id
-        WHERE o.shipped_status = 'shipping'
+        WHERE o.is_shipping = 1
     `
+	err := r.read.SelectContext(ctx, &orders, query)
+	return orders, err
+}
+
+// 配送計画のために配送待ちの注文を行ロック付きで取得する（GetShippingOrdersと同じ列）
+// 同時に計画している他のトランザクションがロックした注文は待たずに除くため、計画同士で同じ注文を選ばない
+// トランザクション内で呼び、割り当てまで同じトランザクションで行うこと
+func (r *OrderRepository) LockShippingOrders(ctx context.Context) ([]model.Order, error) {
+	var orders []model.Order
+	query := `
+		SELECT o.order_id, p.weight, p.value, o.latitude, o.longitude
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.is_shipping = 1
+		FOR UPDATE OF o SKIP LOCKED`
 	err := r.db.SelectContext(ctx, &orders, query)
 	return orders, err
 }
 
+// 配送待ち(shipped_status:shipping)の注文を1件ずつ読み込む（GetShippingOrdersと同じ列）
+func (r *OrderRepository) IterateShippingOrders(ctx context.Context) (*Iterator[model.Order], error) {
+	return iterate[model.Order](ctx, r.db, `
+		SELECT o.order_id, p.weight, p.value, o.latitude, o.longitude
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.is_shipping = 1`)
+}
+
+// ユーザーの全注文を1件ずつ読み込む
+// reqの検索・並び替えはListOrdersと同じ（ページングの指定は使わない。未指定の場合は注文ID順）
+func (r *OrderRepository) IterateByUser(ctx context.Context, userID int, req model.ListRequest) (*Iterator[model.Order], error) {
+	searchCondition, searchArgs := orderSearchCondition(req)
+	query := fmt.Sprintf(`
+		SELECT
+			o.order_id, o.user_id, o.product_id, p.name AS product_name, p.weight, p.value,
+			o.shipped_status, o.created_at, o.arrived_at, o.address, o.shipping_cost, o.tax_amount
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.user_id = ?
+		%s
+		%s`, searchCondition, orderSort.orderBy(req.SortField, req.SortOrder))
+	return iterate[model.Order](ctx, r.db, query, append([]interface{}{userID}, searchArgs...)...)
+}
+
+// 指定時刻より前に配送完了した注文を注文ID順に1件ずつ読み込む（アーカイブ用）
+func (r *OrderRepository) IterateCompletedBefore(ctx context.Context, before time.Time) (*Iterator[model.Order], error) {
+	return iterate[model.Order](ctx, r.db, `
+		SELECT order_id, user_id, product_id, shipped_status, created_at, arrived_at
+		FROM orders
+		WHERE shipped_status = 'completed' AND arrived_at < ?
+		ORDER BY order_id`, before)
+}
+
+// 商品名で注文を絞り込む条件（商品テーブルをpとして結合すること。検索語がなければ空）
+func orderSearchCondition(req model.ListRequest) (string, []interface{}) {
+	switch {
+	case req.Search == "":
+		return "", nil
+	case req.Type == "prefix":
+		// 前方一致検索（インデックス活用）
+		return "AND p.name LIKE ?", []interface{}{req.Search + "%"}
+	}
+	if ftQuery, ok := ngramBooleanQuery([]string{req.Search}); ok {
+		// 部分一致検索（ngram全文検索インデックス使用）
+		return "AND MATCH(p.search_name) AGAINST(? IN BOOLEAN MODE)", []interface{}{ftQuery}
+	}
+	// 1文字の部分一致検索（LIKE検索使用）
+	return "AND p.name LIKE ?", []interface{}{"%" + req.Search + "%"}
+}
+
 func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
-	var searchCondition string
-	var searchArgs []interface{}
-	args := []interface{}{userID}
+	searchCondition, searchArgs := orderSearchCondition(req)
+	args := append([]interface{}{userID}, searchArgs...)
 
-	if req.Search != "" {
-		if req.Type == "prefix" {
-			// 前方一致検索（インデックス活用）
-			searchCondition = "AND p.name LIKE ?"
-			searchArgs = append(searchArgs, req.Search+"%")
-		} else {
-			// 部分一致検索（LIKE検索使用）
-			searchCondition = "AND p.name LIKE ?"
-			searchArgs = append(searchArgs, "%"+req.Search+"%")
+	// 同じ値の注文は注文IDで並べ、ページ間で順序が入れ替わらないようにする
+	orderByClause := orderSort.orderBy(req.SortField, req.SortOrder)
+
+	// キーセットページングでは前のページの最後の注文より後ろから取得する
+	keyset := req.Pagination == model.PaginationCursor
+	seekCondition := ""
+	limitClause := "LIMIT ? OFFSET ?"
+	if keyset {
+		if req.After != nil {
+			condition, seekArgs, err := orderSeekCondition(req.After)
+			if err != nil {
+				return nil, 0, err
+			}
+			seekCondition = condition
+			args = append(args, seekArgs...)
 		}
-		args = append(args, searchArgs...)
+		limitClause = "LIMIT ?"
 	}
 
-	var orderByClause string
-	switch req.SortField {
-	case "product_name":
-		orderByClause = "ORDER BY p.name"
-	case "created_at":
-		orderByClause = "ORDER BY o.created_at"
-	case "shipped_status":
-		orderByClause = "ORDER BY o.shipped_status"
-	case "arrived_at":
-		orderByClause = "ORDER BY o.arrived_at"
-	case "order_id":
-		fallthrough
-	default:
-		orderByClause = "ORDER BY o.order_id"
+	// 商品名の表示・検索・並び替えのいずれにも使わない場合は商品テーブルを結合しない
+	productName := "'' as product_name"
+	productJoin := ""
+	if req.Search != "" || req.SortField == "product_name" || wantsField(req.Fields, "product_name") {
+		productName = "p.name as product_name"
+		productJoin = "JOIN products p ON o.product_id = p.product_id"
 	}
 
-	if strings.ToUpper(req.SortOrder) == "DESC" {
-		orderByClause += " DESC"
-	} else {
-		orderByClause += " ASC"
+	// 総件数がキャッシュにあればデータのみ、なければ1回のクエリでデータとカウントの両方を取得（ウィンドウ関数使用）
+	// 取得中に注文が作られた場合に古い件数を保存しないよう、世代はクエリ前に取る
+	generation := r.counts.generation.Load()
+	cachedTotal, totalCached := r.counts.get(userID, req.Search, req.Type)
+	totalCount := "COUNT(*) OVER() as total_count"
+	// 位置より後ろに絞り込むと件数が変わるため、キーセットページングでは別に数える
+	if totalCached || keyset {
+		totalCount = "0 as total_count"
 	}
-
-	// 1回のクエリでデータとカウントの両方を取得（ウィンドウ関数使用）
 	query := fmt.Sprintf(`
 		SELECT
 			o.order_id,
 			o.product_id,
-			p.name as product_name,
+			%s,
 			o.shipped_status,
 			o.created_at,
 			o.arrived_at,
-			COUNT(*) OVER() as total_count
+			%s
 		FROM orders o
-		JOIN products p ON o.product_id = p.product_id
+		%s
 		WHERE o.user_id = ?
 		%s
 		%s
-		LIMIT ? OFFSET ?
-	`, searchCondition, orderByClause)
+		%s
+		%s
+	`, productName, totalCount, productJoin, searchCondition, seekCondition, orderByClause, limitClause)
 
-	args = append(args, req.PageSize, req.Offset)
+	args = append(args, req.PageSize)
+	if !keyset {
+		args = append(args, req.Offset)
+		// 深いページは、残り時間が足りずに途中でキャンセルされる見込みであれば実行しない
+		if err := budget.Require(ctx, offsetQueryBudget(req.Offset)); err != nil {
+			return nil, 0, err
+		}
+	}
 
 	type orderRowWithCount struct {
 		OrderID       int          `db:"order_id"`
@@ -185,17 +576,29 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
 	}
 
 	var ordersRaw []orderRowWithCount
-	err := r.db.SelectContext(ctx, &ordersRaw, query, args...)
+	err := r.listDB(userID, req.Search != "").SelectContext(ctx, &ordersRaw, query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	if len(ordersRaw) == 0 {
+	if len(ordersRaw) == 0 && !keyset {
 		return []model.Order{}, 0, nil
 	}
 
-	// 最初の行からtotal_countを取得
-	total := ordersRaw[0].TotalCount
+	var total int
+	switch {
+	case totalCached:
+		total = cachedTotal
+	case keyset:
+		if total, err = r.countOrders(ctx, userID, req); err != nil {
+			return nil, 0, err
+		}
+		r.counts.set(userID, req.Search, req.Type, generation, total)
+	default:
+		// 最初の行からtotal_countを取得
+		total = ordersRaw[0].TotalCount
+		r.counts.set(userID, req.Search, req.Type, generation, total)
+	}
 
 	orders := make([]model.Order, len(ordersRaw))
 	for i, o := range ordersRaw {
@@ -211,3 +614,483 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
 
 	return orders, total, nil
 }
+
+// 商品名で絞り込んだユーザーの注文数を数える
+func (r *OrderRepository) countOrders(ctx context.Context, userID int, req model.ListRequest) (int, error) {
+	searchCondition, searchArgs := orderSearchCondition(req)
+	productJoin := ""
+	if req.Search != "" {
+		productJoin = "JOIN products p ON o.product_id = p.product_id"
+	}
+	var total int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM orders o %s WHERE o.user_id = ? %s", productJoin, searchCondition)
+	err := r.listDB(userID, req.Search != "").GetContext(ctx, &total, query, append([]interface{}{userID}, searchArgs...)...)
+	return total, err
+}
+
+// userIDの注文一覧を読むDBTX
+// 注文を作った直後は、レプリカに反映される前の一覧を返したり件数をキャッシュしたりしないようdbから読む
+func (r *OrderRepository) listDB(userID int, searched bool) DBTX {
+	if r.read == r.db || r.counts.changedWithin(userID, searched, r.readAfterWrite) {
+		return r.db
+	}
+	return r.read
+}
+
+// キーセットページングで位置より後ろの注文に絞り込む条件
+// 並び替えの列と注文IDの組で比較する（arrived_atのNULLは最小値として扱う）
+func orderSeekCondition(c *model.OrderCursor) (string, []interface{}, error) {
+	op := ">"
+	if c.SortOrder == "desc" {
+		op = "<"
+	}
+	var column string
+	switch c.SortField {
+	case "product_name":
+		column = "p.name"
+	case "created_at":
+		column = "o.created_at"
+	case "shipped_status":
+		column = "o.shipped_status"
+	case "arrived_at":
+		column = "o.arrived_at"
+	default:
+		return "AND o.order_id " + op + " ?", []interface{}{c.OrderID}, nil
+	}
+
+	key, err := orderCursorKey(c)
+	if err != nil {
+		return "", nil, err
+	}
+	if key == nil {
+		// NULLの後ろは、昇順なら残りのNULLとNULLでないすべて、降順なら残りのNULLのみ
+		if op == ">" {
+			return fmt.Sprintf("AND ((%s IS NULL AND o.order_id > ?) OR %s IS NOT NULL)", column, column), []interface{}{c.OrderID}, nil
+		}
+		return fmt.Sprintf("AND %s IS NULL AND o.order_id < ?", column), []interface{}{c.OrderID}, nil
+	}
+	if c.SortField == "arrived_at" && op == "<" {
+		return fmt.Sprintf("AND ((%s, o.order_id) < (?, ?) OR %s IS NULL)", column, column), []interface{}{key, c.OrderID}, nil
+	}
+	return fmt.Sprintf("AND (%s, o.order_id) %s (?, ?)", column, op), []interface{}{key, c.OrderID}, nil
+}
+
+// 位置の並び替えの列の値を列の型に変換する（NULL・注文IDで並べる場合はnil）
+func orderCursorKey(c *model.OrderCursor) (interface{}, error) {
+	if c.Key == nil {
+		if c.SortField != "arrived_at" && c.SortField != "order_id" {
+			return nil, fmt.Errorf("cursor for %s has no key", c.SortField)
+		}
+		return nil, nil
+	}
+	switch c.SortField {
+	case "created_at", "arrived_at":
+		return time.Parse(time.RFC3339Nano, *c.Key)
+	default:
+		return *c.Key, nil
+	}
+}
+
+// fieldsが空（全フィールド）またはfieldを含む場合にtrueを返す
+func wantsField(fields []string, field string) bool {
+	return len(fields) == 0 || slices.Contains(fields, field)
+}
+
+// recipientUserIDが受取人のギフトを新しい順に取得し、総件数とともに返す
+func (r *OrderRepository) ListReceivedGifts(ctx context.Context, recipientUserID, limit, offset int) ([]model.Order, int, error) {
+	var rows []struct {
+		model.Order
+		TotalCount int `db:"total_count"`
+	}
+	query := `
+		SELECT
+			o.order_id,
+			o.user_id,
+			o.product_id,
+			p.name as product_name,
+			o.shipped_status,
+			o.created_at,
+			o.arrived_at,
+			o.tracking_token,
+			o.recipient_user_id,
+			COUNT(*) OVER() as total_count
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.recipient_user_id = ?
+		ORDER BY o.created_at DESC, o.order_id DESC
+		LIMIT ? OFFSET ?`
+	if err := r.db.SelectContext(ctx, &rows, query, recipientUserID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []model.Order{}, 0, nil
+	}
+	orders := make([]model.Order, len(rows))
+	for i, row := range rows {
+		orders[i] = row.Order
+	}
+	return orders, rows[0].TotalCount, nil
+}
+
+// 注文を配送完了にし、到着時刻を記録する（キャンセル済みの注文は更新しない）
+func (r *OrderRepository) MarkCompleted(ctx context.Context, orderID int64, arrivedAt time.Time) error {
+	query := `UPDATE orders SET shipped_status = 'completed', arrived_at = ? WHERE order_id = ? AND shipped_status <> 'cancelled'`
+	if _, err := r.db.ExecContext(ctx, query, arrivedAt, orderID); err != nil {
+		return err
+	}
+	r.publishStatus([]int64{orderID}, "completed")
+	return nil
+}
+
+// 注文のステータスを行ロックを取得して取得（存在しない場合はsql.ErrNoRows）
+// ステータスの確認から更新までの間にキャンセルされないようにする
+func (r *OrderRepository) LockStatus(ctx context.Context, orderID int64) (string, error) {
+	var status string
+	err := r.db.GetContext(ctx, &status, "SELECT shipped_status FROM orders WHERE order_id = ? FOR UPDATE", orderID)
+	return status, err
+}
+
+// 注文を割り当てたロボットIDと配送計画のクレームIDを取得（未割り当ての場合は空文字）
+func (r *OrderRepository) FindClaim(ctx context.Context, orderID int64) (robotID, claimID string, err error) {
+	var row struct {
+		RobotID string `db:"robot_id"`
+		ClaimID string `db:"claim_id"`
+	}
+	query := `SELECT COALESCE(robot_id, '') AS robot_id, COALESCE(claim_id, '') AS claim_id FROM orders WHERE order_id = ?`
+	if err := r.db.GetContext(ctx, &row, query, orderID); err != nil {
+		return "", "", err
+	}
+	return row.RobotID, row.ClaimID, nil
+}
+
+// 注文IDから注文を1件取得
+func (r *OrderRepository) FindByID(ctx context.Context, orderID int64) (*model.Order, error) {
+	var order model.Order
+	query := `
+		SELECT
+			o.order_id,
+			o.user_id,
+			o.product_id,
+			p.name as product_name,
+			p.weight,
+			p.value,
+			o.shipped_status,
+			o.created_at,
+			o.arrived_at,
+			o.address,
+			o.latitude,
+			o.longitude,
+			o.shipping_cost,
+			o.shipping_zone,
+			o.tax_amount,
+			o.tracking_token,
+			o.recipient_user_id
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.order_id = ?`
+	if err := r.db.GetContext(ctx, &order, query, orderID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// 配送中(delivering)の注文を配送失敗(failed)にし、再キュー投入時刻を設定する
+// 対象の注文が配送中でなかった場合はfalseを返す
+func (r *OrderRepository) MarkFailed(ctx context.Context, orderID int64, retryAt time.Time) (bool, error) {
+	query := `UPDATE orders SET shipped_status = 'failed', retry_at = ? WHERE order_id = ? AND shipped_status = 'delivering'`
+	result, err := r.db.ExecContext(ctx, query, retryAt, orderID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	r.publishStatus([]int64{orderID}, "failed")
+	return true, nil
+}
+
+// 配送待ち(shipping)の注文をキャンセル(cancelled)にする
+// 対象の注文が配送待ちでなかった場合（ロボットに割り当て済みなど）はfalseを返す
+func (r *OrderRepository) Cancel(ctx context.Context, orderID int64) (bool, error) {
+	query := `UPDATE orders SET shipped_status = 'cancelled' WHERE order_id = ? AND shipped_status = 'shipping'`
+	result, err := r.db.ExecContext(ctx, query, orderID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	r.publishStatus([]int64{orderID}, "cancelled")
+	return true, nil
+}
+
+// 再キュー投入時刻を過ぎた配送失敗注文を取得
+// 複数インスタンスで同時に処理しないよう行ロックを取得する
+func (r *OrderRepository) GetRequeueCandidates(ctx context.Context, now time.Time, limit int) ([]model.Order, error) {
+	var orders []model.Order
+	query := `
+		SELECT o.order_id, o.user_id, o.product_id, p.weight, p.value
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status = 'failed' AND o.retry_at <= ?
+		ORDER BY o.retry_at
+		LIMIT ?
+		FOR UPDATE OF o`
+	err := r.db.SelectContext(ctx, &orders, query, now, limit)
+	return orders, err
+}
+
+// 配送失敗注文を配送待ち(shipping)に戻す
+func (r *OrderRepository) Requeue(ctx context.Context, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("UPDATE orders SET shipped_status = 'shipping', retry_at = NULL, claim_id = NULL WHERE order_id IN (?) AND shipped_status = 'failed'", orderIDs)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	r.publishStatus(orderIDs, "shipping")
+	return nil
+}
+
+// ロボットが割り当てられていない配送中の注文IDを取得（状態の修復用）
+// 複数インスタンスで同時に処理しないよう行ロックを取得する
+func (r *OrderRepository) FindDeliveringWithoutRobot(ctx context.Context, limit int) ([]int64, error) {
+	var orderIDs []int64
+	query := `
+		SELECT order_id FROM orders
+		WHERE shipped_status = 'delivering' AND (robot_id IS NULL OR robot_id = '')
+		ORDER BY order_id
+		LIMIT ?
+		FOR UPDATE`
+	err := r.db.SelectContext(ctx, &orderIDs, query, limit)
+	return orderIDs, err
+}
+
+// 到着時刻が記録されていない配送完了注文のIDを取得（状態の修復用）
+// 複数インスタンスで同時に処理しないよう行ロックを取得する
+func (r *OrderRepository) FindCompletedWithoutArrival(ctx context.Context, limit int) ([]int64, error) {
+	var orderIDs []int64
+	query := `
+		SELECT order_id FROM orders
+		WHERE shipped_status = 'completed' AND arrived_at IS NULL
+		ORDER BY order_id
+		LIMIT ?
+		FOR UPDATE`
+	err := r.db.SelectContext(ctx, &orderIDs, query, limit)
+	return orderIDs, err
+}
+
+// 到着時刻が記録されていない配送完了注文に到着時刻を補う
+// 実際の到着時刻は分からないため、配送開始時刻（なければ注文時刻）を到着時刻とする
+func (r *OrderRepository) FillMissingArrivals(ctx context.Context, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		UPDATE orders SET arrived_at = COALESCE(delivering_at, created_at)
+		WHERE order_id IN (?) AND shipped_status = 'completed' AND arrived_at IS NULL`, orderIDs)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+	_, err = r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// 注文IDから注文したユーザーIDへの対応を返す（存在しない注文は含まれない）
+func (r *OrderRepository) FindOwners(ctx context.Context, orderIDs []int64) (map[int64]int, error) {
+	owners := make(map[int64]int, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return owners, nil
+	}
+	var rows []struct {
+		OrderID int64 `db:"order_id"`
+		UserID  int   `db:"user_id"`
+	}
+	query, args, err := sqlx.In("SELECT order_id, user_id FROM orders WHERE order_id IN (?)", orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		owners[row.OrderID] = row.UserID
+	}
+	return owners, nil
+}
+
+// 指定時刻以降にユーザーが作成した注文数を取得
+func (r *OrderRepository) CountCreatedSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM orders WHERE user_id = ? AND created_at >= ?", userID, since)
+	return count, err
+}
+
+// 指定ステータスの注文数を取得
+func (r *OrderRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM orders WHERE shipped_status = ?`, status)
+	return count, err
+}
+
+// 全注文をステータス別に集計
+func (r *OrderRepository) CountGroupedByStatus(ctx context.Context) ([]model.StatusCount, error) {
+	var counts []model.StatusCount
+	query := `SELECT shipped_status, COUNT(*) AS count FROM orders GROUP BY shipped_status ORDER BY shipped_status`
+	err := r.db.SelectContext(ctx, &counts, query)
+	return counts, err
+}
+
+// 1件の重量がmaxWeight以下の配送待ち注文の数と重量の合計（積載量maxWeightのロボットが運べる注文の量の目安）
+func (r *OrderRepository) ShippingLoad(ctx context.Context, maxWeight int) (model.ShippingLoad, error) {
+	var load model.ShippingLoad
+	query := `
+		SELECT COUNT(*) AS orders, COALESCE(SUM(p.weight), 0) AS weight
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.is_shipping = 1 AND p.weight <= ?`
+	err := r.db.GetContext(ctx, &load, query, maxWeight)
+	return load, err
+}
+
+// 配送待ちの注文を商品ごとに集計（商品の価値・重量の変更が配送計画に与える影響の見積もり用）
+func (r *OrderRepository) CountShippingByProduct(ctx context.Context) ([]model.ShippingProductCount, error) {
+	counts := []model.ShippingProductCount{}
+	query := `
+		SELECT o.product_id, p.value, p.weight, COUNT(*) AS orders
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.is_shipping = 1
+		GROUP BY o.product_id, p.value, p.weight
+		ORDER BY o.product_id`
+	err := r.db.SelectContext(ctx, &counts, query)
+	return counts, err
+}
+
+// ユーザーの注文をステータス別に集計
+// 件数・金額の合計はRevenueTotalsと同じくキャンセル済みの注文を除く（ステータス別の件数には含める）
+func (r *OrderRepository) SummarizeByUser(ctx context.Context, userID int) (*model.OrderSummary, error) {
+	var totals struct {
+		TotalOrders       int `db:"total_orders"`
+		TotalValue        int `db:"total_value"`
+		TotalShippingCost int `db:"total_shipping_cost"`
+		TotalTax          int `db:"total_tax"`
+	}
+	query := `
+		SELECT
+			COUNT(*) as total_orders,
+			COALESCE(SUM(p.value), 0) as total_value,
+			COALESCE(SUM(o.shipping_cost), 0) as total_shipping_cost,
+			COALESCE(SUM(o.tax_amount), 0) as total_tax
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.user_id = ? AND o.shipped_status <> 'cancelled'`
+	if err := r.db.GetContext(ctx, &totals, query, userID); err != nil {
+		return nil, err
+	}
+
+	var byStatus []model.StatusCount
+	query = `
+		SELECT shipped_status, COUNT(*) as count
+		FROM orders
+		WHERE user_id = ?
+		GROUP BY shipped_status
+		ORDER BY shipped_status`
+	if err := r.db.SelectContext(ctx, &byStatus, query, userID); err != nil {
+		return nil, err
+	}
+	if byStatus == nil {
+		byStatus = []model.StatusCount{}
+	}
+
+	preTax := totals.TotalValue + totals.TotalShippingCost
+	return &model.OrderSummary{
+		TotalOrders:       totals.TotalOrders,
+		TotalValue:        totals.TotalValue,
+		TotalShippingCost: totals.TotalShippingCost,
+		TotalTax:          totals.TotalTax,
+		TotalPreTax:       preTax,
+		TotalPostTax:      preTax + totals.TotalTax,
+		ByStatus:          byStatus,
+	}, nil
+}
+
+// ユーザーの注文を商品ごと・ステータスごとに数える（商品ID順）
+// 短時間キャッシュするため、直前の注文・ステータスの変更が反映されない場合がある
+func (r *OrderRepository) CountByProduct(ctx context.Context, userID int) ([]model.ProductOrderCounts, error) {
+	return r.productCounts.Get(ctx, userID)
+}
+
+func (r *OrderRepository) countByProduct(ctx context.Context, userID int) ([]model.ProductOrderCounts, error) {
+	var rows []productStatusCount
+	query := `
+		SELECT o.product_id, p.name AS product_name, o.shipped_status, COUNT(*) AS count
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.user_id = ?
+		GROUP BY o.product_id, p.name, o.shipped_status
+		ORDER BY o.product_id, o.shipped_status`
+	if err := r.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, err
+	}
+	return groupProductStatusCounts(rows), nil
+}
+
+// 商品別の注文数の推定サイズ（ステータス1件あたり64バイトとみなす）
+func productCountsSize(counts []model.ProductOrderCounts) int64 {
+	size := int64(0)
+	for _, c := range counts {
+		size += int64(len(c.ByStatus))*64 + int64(len(c.ProductName))
+	}
+	return size
+}
+
+type productStatusCount struct {
+	ProductID     int    `db:"product_id"`
+	ProductName   string `db:"product_name"`
+	ShippedStatus string `db:"shipped_status"`
+	Count         int    `db:"count"`
+}
+
+// 商品ID・ステータス順の行を商品ごとにまとめる
+func groupProductStatusCounts(rows []productStatusCount) []model.ProductOrderCounts {
+	counts := []model.ProductOrderCounts{}
+	for _, row := range rows {
+		if len(counts) == 0 || counts[len(counts)-1].ProductID != row.ProductID {
+			counts = append(counts, model.ProductOrderCounts{ProductID: row.ProductID, ProductName: row.ProductName, ByStatus: []model.StatusCount{}})
+		}
+		last := &counts[len(counts)-1]
+		last.Total += row.Count
+		last.ByStatus = append(last.ByStatus, model.StatusCount{Status: row.ShippedStatus, Count: row.Count})
+	}
+	return counts
+}
+
+// 全注文（キャンセルを除く）の税抜・税額・税込の売上合計を取得
+func (r *OrderRepository) RevenueTotals(ctx context.Context) (model.RevenueStats, error) {
+	var stats model.RevenueStats
+	query := `
+		SELECT
+			COALESCE(SUM(p.value + o.shipping_cost), 0) as pre_tax,
+			COALESCE(SUM(o.tax_amount), 0) as tax,
+			COALESCE(SUM(p.value + o.shipping_cost + o.tax_amount), 0) as post_tax
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status <> 'cancelled'`
+	err := r.db.GetContext(ctx, &stats, query)
+	return stats, err
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 注文一覧の総件数キャッシュの容量
+const orderCountCacheBudget = 8 << 20
+
+var orderCountCacheStats = metrics.Cache("order_count")
+
+type orderCountEntry struct {
+	total      int
+	generation uint64
+	// 起動時にキャッシュを温め直すための条件と、保存してからの参照回数
+	query model.OrderCountQuery
+	hits  *atomic.Int64
+}
+
+// ユーザー・絞り込み条件ごとの注文一覧の総件数
+// 件数はページや並び順によらないため、2ページ目以降はCOUNT(*) OVER()を省略できる
+type orderCountCache struct {
+	entries *cache.Sharded[orderCountEntry]
+	// 無効化は世代を進めて記録するだけにし、それより前に取得した件数は参照時に無効とみなす
+	generation atomic.Uint64
+	mutex      sync.RWMutex
+	users      map[int]countInvalidation
+	search     countInvalidation
+	all        countInvalidation
+}
+
+// 無効化した世代と時刻
+type countInvalidation struct {
+	generation uint64
+	at         time.Time
+}
+
+func newOrderCountCache(ttl time.Duration) *orderCountCache {
+	return &orderCountCache{
+		entries: cache.NewSharded[orderCountEntry](orderCountCacheBudget, ttl),
+		users:   make(map[int]countInvalidation),
+	}
+}
+
+func orderCountKey(userID int, search, searchType string) string {
+	if search == "" {
+		return fmt.Sprintf("%d:", userID)
+	}
+	return fmt.Sprintf("%d:%s:%s", userID, searchType, search)
+}
+
+func (c *orderCountCache) get(userID int, search, searchType string) (int, bool) {
+	entry, ok := c.entries.Get(orderCountKey(userID, search, searchType))
+	if !ok || c.invalidated(userID, search != "", entry.generation) {
+		orderCountCacheStats.Miss()
+		return 0, false
+	}
+	orderCountCacheStats.Hit()
+	entry.hits.Add(1)
+	return entry.total, true
+}
+
+// generationはクエリ前に取得した世代（取得中に無効化された件数は保存しない）
+func (c *orderCountCache) set(userID int, search, searchType string, generation uint64, total int) {
+	if c.invalidated(userID, search != "", generation) {
+		return
+	}
+	key := orderCountKey(userID, search, searchType)
+	query := model.OrderCountQuery{UserID: userID, Search: search, Type: searchType}
+	c.entries.Set(key, orderCountEntry{total: total, generation: generation, query: query, hits: new(atomic.Int64)}, int64(len(key))+64)
+}
+
+func (c *orderCountCache) invalidated(userID int, searched bool, generation uint64) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if generation < c.users[userID].generation || generation < c.all.generation {
+		return true
+	}
+	return searched && generation < c.search.generation
+}
+
+// userIDの件数がwindow以内に無効化されたか（注文が作られた直後か）
+func (c *orderCountCache) changedWithin(userID int, searched bool, window time.Duration) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	since := time.Now().Add(-window)
+	if c.users[userID].at.After(since) || c.all.at.After(since) {
+		return true
+	}
+	return searched && c.search.at.After(since)
+}
+
+func (c *orderCountCache) next() countInvalidation {
+	return countInvalidation{generation: c.generation.Add(1), at: time.Now()}
+}
+
+func (c *orderCountCache) invalidateUser(userID int) {
+	c.mutex.Lock()
+	c.users[userID] = c.next()
+	c.mutex.Unlock()
+}
+
+func (c *orderCountCache) invalidateAll() {
+	c.mutex.Lock()
+	c.all = c.next()
+	clear(c.users)
+	c.mutex.Unlock()
+}
+
+func (c *orderCountCache) invalidateSearch() {
+	c.mutex.Lock()
+	c.search = c.next()
+	c.mutex.Unlock()
+}
+
+// 有効なキーの条件を参照回数の多い順に最大limit件返す
+func (c *orderCountCache) hot(limit int) []model.OrderCountQuery {
+	var queries []model.OrderCountQuery
+	c.entries.Range(func(key string, entry orderCountEntry) {
+		if c.invalidated(entry.query.UserID, entry.query.Search != "", entry.generation) {
+			return
+		}
+		query := entry.query
+		query.Hits = entry.hits.Load()
+		queries = append(queries, query)
+	})
+	sort.SliceStable(queries, func(i, j int) bool { return queries[i].Hits > queries[j].Hits })
+	return queries[:min(len(queries), limit)]
+}
+
+// ユーザーの注文一覧の総件数キャッシュを破棄する
+// 件数は注文の作成でのみ変わる（ステータスの変更は絞り込み条件に含まれない）
+func (r *OrderRepository) InvalidateOrderCounts(userID int) {
+	r.counts.invalidateUser(userID)
+}
+
+// 商品名で絞り込んだ総件数キャッシュを全ユーザー分破棄する（商品名の変更で件数が変わるため）
+func (r *OrderRepository) InvalidateSearchOrderCounts() {
+	r.counts.invalidateSearch()
+}
+
+// 全ユーザーの総件数・商品別の注文数のキャッシュを破棄する（テストデータのリセット用）
+func (r *OrderRepository) InvalidateAllOrderCounts() {
+	r.counts.invalidateAll()
+	r.productCounts.Purge()
+}
+
+// 総件数キャッシュの有効なキーの条件を、参照回数の多い順に最大limit件返す
+func (r *OrderRepository) HotCountQueries(limit int) []model.OrderCountQuery {
+	return r.counts.hot(limit)
+}
+
+// 総件数を数えてキャッシュに載せる（起動時にキャッシュを温め直す用）
+func (r *OrderRepository) WarmOrderCount(ctx context.Context, userID int, search, searchType string) error {
+	generation := r.counts.generation.Load()
+	total, err := r.countOrders(ctx, userID, model.ListRequest{Search: search, Type: searchType})
+	if err != nil {
+		return err
+	}
+	r.counts.set(userID, search, searchType, generation, total)
+	return nil
+}
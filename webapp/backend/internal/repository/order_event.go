@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"strings"
+	"time"
+)
+
+// 注文イベントの種別
+const (
+	OrderEventDeliveryFailed = "delivery_failed"
+	OrderEventRequeued       = "requeued"
+	OrderEventSLABreached    = "sla_breached"
+	OrderEventPlanRolledBack = "plan_rolled_back"
+	OrderEventStatusRepaired = "status_repaired"
+	OrderEventCancelled      = "cancelled"
+	// 配送中のまま期限を過ぎたため配送待ちに戻した
+	OrderEventDeliveryTimedOut = "delivery_timed_out"
+)
+
+type OrderEventRepository struct {
+	db DBTX
+}
+
+func NewOrderEventRepository(db DBTX) *OrderEventRepository {
+	return &OrderEventRepository{db: db}
+}
+
+// 注文イベントを記録する
+// reasonが空文字の場合はNULLとして保存
+func (r *OrderEventRepository) Create(ctx context.Context, orderID int64, eventType, reason string) error {
+	var reasonArg interface{}
+	if reason != "" {
+		reasonArg = reason
+	}
+	query := `INSERT INTO order_events (order_id, event_type, reason, created_at) VALUES (?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, orderID, eventType, reasonArg, time.Now())
+	return err
+}
+
+// 複数の注文に同じイベントを一括で記録する
+func (r *OrderEventRepository) CreateBulk(ctx context.Context, orderIDs []int64, eventType string) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	values := make([]string, 0, len(orderIDs))
+	args := make([]interface{}, 0, len(orderIDs)*3)
+	for _, id := range orderIDs {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, id, eventType, now)
+	}
+	query := "INSERT INTO order_events (order_id, event_type, created_at) VALUES " + strings.Join(values, ", ")
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// 指定した注文に記録された特定種別のイベント数を返す
+func (r *OrderEventRepository) CountByOrder(ctx context.Context, orderID int64, eventType string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM order_events WHERE order_id = ? AND event_type = ?`
+	err := r.db.GetContext(ctx, &count, query, orderID, eventType)
+	return count, err
+}
+
+// 指定種別のイベント総数を返す
+func (r *OrderEventRepository) CountByType(ctx context.Context, eventType string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM order_events WHERE event_type = ?`
+	err := r.db.GetContext(ctx, &count, query, eventType)
+	return count, err
+}
+
+// 配送失敗の理由コードごとの件数を返す（件数の多い順）
+func (r *OrderEventRepository) CountFailuresByReason(ctx context.Context) ([]model.FailureReasonCount, error) {
+	var counts []model.FailureReasonCount
+	query := `
+		SELECT
+			COALESCE(reason, 'other') as reason,
+			COUNT(*) as count
+		FROM order_events
+		WHERE event_type = ?
+		GROUP BY COALESCE(reason, 'other')
+		ORDER BY count DESC`
+	err := r.db.SelectContext(ctx, &counts, query, OrderEventDeliveryFailed)
+	if err != nil {
+		return nil, err
+	}
+	if counts == nil {
+		counts = []model.FailureReasonCount{}
+	}
+	return counts, nil
+}
+
+// 指定日時以降に配送完了した注文の日別SLA超過率を取得
+func (r *OrderEventRepository) DailySLABreaches(ctx context.Context, since time.Time) ([]model.SLADailyStat, error) {
+	var stats []model.SLADailyStat
+	query := `
+		SELECT
+			DATE_FORMAT(o.arrived_at, '%Y-%m-%d') as day,
+			COUNT(*) as completed,
+			COUNT(e.event_id) as breached
+		FROM orders o
+		LEFT JOIN order_events e ON e.order_id = o.order_id AND e.event_type = ?
+		WHERE o.shipped_status = 'completed' AND o.arrived_at >= ?
+		GROUP BY day
+		ORDER BY day`
+	if err := r.db.SelectContext(ctx, &stats, query, OrderEventSLABreached, since); err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = []model.SLADailyStat{}
+	}
+	for i := range stats {
+		if stats[i].Completed > 0 {
+			stats[i].BreachRate = float64(stats[i].Breached) / float64(stats[i].Completed)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db DBTX) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// 同期待ちの商品変更を古い順に取得
+func (r *OutboxRepository) Fetch(ctx context.Context, limit int) ([]model.OutboxEntry, error) {
+	var entries []model.OutboxEntry
+	err := r.db.SelectContext(ctx, &entries, `SELECT id, product_id FROM search_outbox ORDER BY id LIMIT ?`, limit)
+	return entries, err
+}
+
+// 同期済みの変更を削除
+func (r *OutboxRepository) Delete(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("DELETE FROM search_outbox WHERE id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(query), args...)
+	return err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type PreferenceRepository struct {
+	db DBTX
+}
+
+func NewPreferenceRepository(db DBTX) *PreferenceRepository {
+	return &PreferenceRepository{db: db}
+}
+
+// ユーザーの設定を取得（未設定の場合は空の設定を返す）
+func (r *PreferenceRepository) Find(ctx context.Context, userID int) (model.UserPreferences, error) {
+	var prefs model.UserPreferences
+	query := "SELECT page_size, sort_order, locale FROM user_preferences WHERE user_id = ?"
+	err := r.db.GetContext(ctx, &prefs, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.UserPreferences{}, nil
+	}
+	return prefs, err
+}
+
+// ユーザーの設定を保存（既存の場合は置き換え）
+func (r *PreferenceRepository) Upsert(ctx context.Context, userID int, prefs model.UserPreferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, page_size, sort_order, locale)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE page_size = VALUES(page_size), sort_order = VALUES(sort_order), locale = VALUES(locale)`
+	_, err := r.db.ExecContext(ctx, query, userID, prefs.PageSize, prefs.SortOrder, prefs.Locale)
+	return err
+}
@@ -1,47 +1,121 @@
 package repository
 
 import (
+	"backend/internal/budget"
+	"backend/internal/cache"
+	"backend/internal/events"
+	"backend/internal/metrics"
 	"backend/internal/model"
+	"backend/internal/task"
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
+
+	"github.com/jmoiron/sqlx"
 	"golang.org/x/sync/singleflight"
 )
 
+// singleflightで共有するクエリのタイムアウト
+const sharedQueryTimeout = 10 * time.Second
+
+// 商品一覧キャッシュのデフォルトの容量
+const DefaultProductCacheBudget = 100 << 20
+
+// 商品一覧キャッシュのキーの先頭（検索語・並び順・ページが続く）
+const ProductListKeyPrefix = "products:"
+
+var productListCacheStats = metrics.Cache("product_list")
+
 type cacheEntry struct {
-	result    productResult
-	timestamp time.Time
+	result     productResult
+	generation uint64
+	// 起動時にキャッシュを温め直すための条件と、保存してからの参照回数
+	query model.ProductListQuery
+	hits  *atomic.Int64
+}
+
+// prefixで始まるキーのうち、generationより前に取得したものは無効
+type prefixInvalidation struct {
+	prefix     string
+	generation uint64
+	at         time.Time
 }
 
 type ProductRepository struct {
-	db    DBTX
-	sf    singleflight.Group
-	cache map[string]cacheEntry
-	mutex sync.RWMutex
-	ttl   time.Duration
+	db     DBTX
+	events events.Publisher
+	sf     singleflight.Group
+	cache  *cache.Sharded[cacheEntry] // 推定サイズの合計が容量を超えたら古いものから破棄する
+	ttl    time.Duration
+	sets   atomic.Int64
+	// 前方一致の無効化は世代を進めて記録するだけにし、該当するエントリは参照時に無効とみなす
+	generation    atomic.Uint64
+	invalidations atomic.Pointer[[]prefixInvalidation]
+	invalidateMu  sync.Mutex
+	// 商品一覧の取得に使うDBTX（Store.UseReadReplicaで設定しない場合はdb）
+	read DBTX
+	// 一覧のキャッシュを無効化してからこの時間は、一覧をdbから読む
+	readAfterWrite time.Duration
 }
 
-func NewProductRepository(db DBTX) *ProductRepository {
-	return &ProductRepository{
-		db:    db,
-		cache: make(map[string]cacheEntry),
-		ttl:   5 * time.Minute, // 5分キャッシュ
+// 商品を変更するとpubにProductChangedを発行する
+func NewProductRepository(db DBTX, pub events.Publisher) *ProductRepository {
+	ttl := 5 * time.Minute // 5分キャッシュ
+	r := &ProductRepository{
+		db:     db,
+		read:   db,
+		events: pub,
+		cache:  cache.NewSharded[cacheEntry](DefaultProductCacheBudget, ttl),
+		ttl:    ttl,
 	}
+	r.invalidations.Store(&[]prefixInvalidation{})
+	return r
 }
 
+// 注文できる期間内の商品に絞り込む条件（引数は現在時刻を2つ）
+const productAvailableCondition = "(available_from IS NULL OR available_from <= ?) AND (available_until IS NULL OR available_until > ?)"
+
 // 商品一覧をDBレベルでページングして取得（キャッシュ＋シングルフライト対応）
 func (r *ProductRepository) ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+	// 同じ並び順になる指定は同じキャッシュを使う
+	req.SortField, req.SortOrder = productSort.normalize(req.SortField, req.SortOrder)
+	// 比較用のクエリはキャッシュを読み書きしない
+	if req.Shadow {
+		result, err := r.listProductsInternal(ctx, userID, req)
+		return result.products, result.total, err
+	}
 	// Create unique key for cache and singleflight
-	key := fmt.Sprintf("products:%s:%s:%s:%d:%d", req.Search, req.SortField, req.SortOrder, req.PageSize, req.Offset)
+	key := fmt.Sprintf("%s%s:%s:%s:%d:%d", ProductListKeyPrefix, req.Search, req.SortField, req.SortOrder, req.PageSize, req.Offset)
+	// 検索方式によって結果が異なる場合があるため、方式を指定した検索は別のキーにする
+	if req.SearchMethod != "" {
+		key += ":" + req.SearchMethod
+	}
 
 	// Check cache first
 	if cached := r.getFromCache(key); cached != nil {
+		productListCacheStats.Hit()
 		return cached.products, cached.total, nil
 	}
+	productListCacheStats.Miss()
+
+	// 深いページは、残り時間が足りずに途中でキャンセルされる見込みであれば実行しない
+	if err := budget.Require(ctx, offsetQueryBudget(req.Offset)); err != nil {
+		return nil, 0, err
+	}
+
+	// 取得中に無効化された結果を新しいものとして保存しないよう、世代はクエリ前に取る
+	// 無効化後のリクエストは無効化前に始まったクエリに相乗りさせない
+	generation := r.generation.Load()
+	sfKey := fmt.Sprintf("%s@%d", key, generation)
 
 	// Use singleflight for database queries
-	result, err, _ := r.sf.Do(key, func() (interface{}, error) {
+	// 待機は呼び出し元のctxに従い、共有クエリは最初の呼び出し元のキャンセルに巻き込まない
+	result, err := task.Shared(ctx, &r.sf, sfKey, sharedQueryTimeout, func(ctx context.Context) (interface{}, error) {
 		return r.listProductsInternal(ctx, userID, req)
 	})
 
@@ -52,49 +126,119 @@ func (r *ProductRepository) ListProducts(ctx context.Context, userID int, req mo
 	productResult := result.(productResult)
 
 	// Store in cache
-	r.setCache(key, productResult)
+	r.setCache(key, generation, productResult, model.ProductListQuery{
+		Search:       req.Search,
+		SortField:    req.SortField,
+		SortOrder:    req.SortOrder,
+		PageSize:     req.PageSize,
+		Offset:       req.Offset,
+		SearchMethod: req.SearchMethod,
+	})
 
 	return productResult.products, productResult.total, nil
 }
 
 func (r *ProductRepository) getFromCache(key string) *productResult {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-
-	entry, exists := r.cache[key]
-	if !exists {
+	entry, exists := r.cache.Get(key)
+	if !exists || r.invalidated(key, entry.generation) {
 		return nil
 	}
+	entry.hits.Add(1)
+	return &entry.result
+}
 
-	// Check if cache entry is expired
-	if time.Since(entry.timestamp) > r.ttl {
-		return nil
+// 商品一覧のキャッシュを破棄する
+// 商品の追加・削除・名前の変更は全ページの件数・並び・検索結果に影響するため、キーを絞らずに全件を破棄する
+func (r *ProductRepository) InvalidateListCache() {
+	r.InvalidatePrefix(ProductListKeyPrefix)
+}
+
+// prefixで始まるキーのキャッシュをまとめて無効にする
+// マップは走査せず、該当するエントリは参照時・掃除時に破棄される
+func (r *ProductRepository) InvalidatePrefix(prefix string) {
+	r.invalidateMu.Lock()
+	defer r.invalidateMu.Unlock()
+
+	now := time.Now()
+	current := *r.invalidations.Load()
+	next := make([]prefixInvalidation, 0, len(current)+1)
+	for _, inv := range current {
+		// 新しい無効化に含まれるもの・TTLを過ぎて該当するエントリが残っていないものは不要
+		if strings.HasPrefix(inv.prefix, prefix) || now.Sub(inv.at) > r.ttl {
+			continue
+		}
+		next = append(next, inv)
 	}
+	next = append(next, prefixInvalidation{prefix: prefix, generation: r.generation.Add(1), at: now})
+	r.invalidations.Store(&next)
+}
 
-	return &entry.result
+// 商品一覧を読むDBTX
+// 商品を変更した直後は、レプリカに反映される前の一覧をキャッシュしないようdbから読む
+func (r *ProductRepository) listDB() DBTX {
+	if r.read == r.db {
+		return r.db
+	}
+	since := time.Now().Add(-r.readAfterWrite)
+	for _, inv := range *r.invalidations.Load() {
+		if inv.at.After(since) {
+			return r.db
+		}
+	}
+	return r.read
 }
 
-func (r *ProductRepository) setCache(key string, result productResult) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// 指定したキーのキャッシュを破棄し、破棄した件数を返す
+func (r *ProductRepository) DeleteKeys(keys ...string) int {
+	return r.cache.Delete(keys...)
+}
 
-	r.cache[key] = cacheEntry{
-		result:    result,
-		timestamp: time.Now(),
+// generationの世代で取得したkeyのキャッシュが、その後の無効化の対象になっているか
+func (r *ProductRepository) invalidated(key string, generation uint64) bool {
+	for _, inv := range *r.invalidations.Load() {
+		if generation < inv.generation && strings.HasPrefix(key, inv.prefix) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Simple cache cleanup - remove expired entries occasionally
-	if len(r.cache) > 1000 { // Limit cache size
-		r.cleanupCache()
-	}
+// キャッシュの容量を変更し、超えている分を古いものから破棄する
+func (r *ProductRepository) SetBudget(budget int64) {
+	r.cache.SetBudget(budget)
 }
 
-func (r *ProductRepository) cleanupCache() {
-	now := time.Now()
-	for key, entry := range r.cache {
-		if now.Sub(entry.timestamp) > r.ttl {
-			delete(r.cache, key)
+// 商品一覧キャッシュの推定サイズ・件数・容量
+func (r *ProductRepository) CacheUsage() (bytes int64, entries int, budget int64) {
+	return r.cache.Usage()
+}
+
+// 商品一覧キャッシュの有効なキーの条件を、参照回数の多い順に最大limit件返す
+func (r *ProductRepository) HotListQueries(limit int) []model.ProductListQuery {
+	var queries []model.ProductListQuery
+	r.cache.Range(func(key string, entry cacheEntry) {
+		if r.invalidated(key, entry.generation) {
+			return
 		}
+		query := entry.query
+		query.Hits = entry.hits.Load()
+		queries = append(queries, query)
+	})
+	sort.SliceStable(queries, func(i, j int) bool { return queries[i].Hits > queries[j].Hits })
+	return queries[:min(len(queries), limit)]
+}
+
+func (r *ProductRepository) setCache(key string, generation uint64, result productResult, query model.ProductListQuery) {
+	if r.invalidated(key, generation) {
+		return
+	}
+	r.cache.Set(key, cacheEntry{result: result, generation: generation, query: query, hits: new(atomic.Int64)}, result.estimateSize())
+
+	// Simple cache cleanup - remove expired entries occasionally
+	if r.sets.Add(1)%1000 == 0 {
+		r.cache.RemoveStale(func(key string, entry cacheEntry) bool {
+			return r.invalidated(key, entry.generation)
+		})
 	}
 }
 
@@ -103,25 +247,55 @@ type productResult struct {
 	total    int
 }
 
+// キャッシュに保持した場合のおおよそのメモリ使用量
+func (p productResult) estimateSize() int64 {
+	size := int64(unsafe.Sizeof(p)) + int64(cap(p.products))*int64(unsafe.Sizeof(model.Product{}))
+	for _, product := range p.products {
+		size += int64(len(product.Name) + len(product.Image) + len(product.Description) + len(product.Category))
+	}
+	return size
+}
+
 func (r *ProductRepository) listProductsInternal(ctx context.Context, userID int, req model.ListRequest) (productResult, error) {
 	var products []model.Product
 
 	// 単一クエリでデータとカウントの両方を取得
 	var query string
 	var args []interface{}
+	now := time.Now()
+	orderBy := productSort.orderBy(req.SortField, req.SortOrder)
 
 	if req.Search != "" {
-		// LIKE検索を使用（フルテキストインデックスが利用できない場合のフォールバック）
+		// 同義語に展開された検索語のいずれかに一致する商品を対象とする
+		terms := req.SearchTerms
+		if len(terms) == 0 {
+			terms = []string{req.Search}
+		}
+		var condition string
+		if ftQuery, ok := ngramBooleanQuery(terms); ok && req.SearchMethod != model.SearchMethodLike {
+			// ngram全文検索インデックスで部分一致検索
+			condition = "MATCH(search_text) AGAINST(? IN BOOLEAN MODE)"
+			args = append(args, ftQuery)
+		} else {
+			// 1文字の検索語はngramで引けないためLIKE検索にフォールバック（LIKE検索を指定した場合も）
+			conditions := make([]string, len(terms))
+			for i, term := range terms {
+				conditions[i] = "(name LIKE ? OR description LIKE ?)"
+				searchPattern := "%" + term + "%"
+				args = append(args, searchPattern, searchPattern)
+			}
+			condition = strings.Join(conditions, " OR ")
+		}
+
 		query = `
 			SELECT
 				product_id, name, value, weight, image, description,
 				COUNT(*) OVER() as total_count
 			FROM products
-			WHERE (name LIKE ? OR description LIKE ?)
-			ORDER BY ` + req.SortField + ` ` + req.SortOrder + `, product_id ASC
+			WHERE (` + condition + `) AND ` + productAvailableCondition + `
+			` + orderBy + `
 			LIMIT ? OFFSET ?`
-		searchPattern := "%" + req.Search + "%"
-		args = append(args, searchPattern, searchPattern, req.PageSize, req.Offset)
+		args = append(args, now, now, req.PageSize, req.Offset)
 	} else {
 		// 検索条件がない場合
 		query = `
@@ -129,9 +303,10 @@ func (r *ProductRepository) listProductsInternal(ctx context.Context, userID int
 				product_id, name, value, weight, image, description,
 				COUNT(*) OVER() as total_count
 			FROM products
-			ORDER BY ` + req.SortField + ` ` + req.SortOrder + `, product_id ASC
+			WHERE ` + productAvailableCondition + `
+			` + orderBy + `
 			LIMIT ? OFFSET ?`
-		args = append(args, req.PageSize, req.Offset)
+		args = append(args, now, now, req.PageSize, req.Offset)
 	}
 
 	type productRowWithCount struct {
@@ -145,7 +320,7 @@ func (r *ProductRepository) listProductsInternal(ctx context.Context, userID int
 	}
 
 	var productsRaw []productRowWithCount
-	err := r.db.SelectContext(ctx, &productsRaw, query, args...)
+	err := r.listDB().SelectContext(ctx, &productsRaw, query, args...)
 	if err != nil {
 		return productResult{}, err
 	}
@@ -172,3 +347,115 @@ func (r *ProductRepository) listProductsInternal(ctx context.Context, userID int
 	return productResult{products: products, total: total}, nil
 }
 
+// 商品IDのリストから商品を取得
+func (r *ProductRepository) FindByIDs(ctx context.Context, productIDs []int) ([]model.Product, error) {
+	if len(productIDs) == 0 {
+		return []model.Product{}, nil
+	}
+	query, args, err := sqlx.In("SELECT product_id, name, value, weight, image, description, category, available_from, available_until, stock FROM products WHERE product_id IN (?)", productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var products []model.Product
+	err = r.db.SelectContext(ctx, &products, r.db.Rebind(query), args...)
+	return products, err
+}
+
+// 商品を1件取得し、トランザクション終了まで行をロックする
+func (r *ProductRepository) LockByID(ctx context.Context, productID int) (model.Product, error) {
+	var product model.Product
+	query := "SELECT product_id, name, value, weight, image, description, category, available_from, available_until, stock FROM products WHERE product_id = ? FOR UPDATE"
+	err := r.db.GetContext(ctx, &product, query, productID)
+	return product, err
+}
+
+// 在庫数をquantity減らす
+// 在庫が足りない場合（在庫を管理していない商品・存在しない商品も）は減らさずにfalseを返す
+func (r *ProductRepository) DecrementStock(ctx context.Context, productID, quantity int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE products SET stock = stock - ? WHERE product_id = ? AND stock >= ?", quantity, productID, quantity)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// 在庫数をquantity戻す（在庫を管理していない商品は何もしない）
+func (r *ProductRepository) RestoreStock(ctx context.Context, productID, quantity int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE products SET stock = stock + ? WHERE product_id = ? AND stock IS NOT NULL", quantity, productID)
+	return err
+}
+
+// 在庫数が発注点を下回った商品を、在庫数の少ない順に取得し、総件数とともに返す
+// 在庫・発注点のどちらかを管理していない商品は含めない
+func (r *ProductRepository) ListLowStock(ctx context.Context, limit, offset int) ([]model.LowStockProduct, int, error) {
+	var rows []struct {
+		model.LowStockProduct
+		TotalCount int `db:"total_count"`
+	}
+	query := `
+		SELECT product_id, name, stock, reorder_threshold, COUNT(*) OVER() AS total_count
+		FROM products
+		WHERE stock < reorder_threshold
+		ORDER BY stock, product_id
+		LIMIT ? OFFSET ?`
+	if err := r.db.SelectContext(ctx, &rows, query, limit, offset); err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []model.LowStockProduct{}, 0, nil
+	}
+	products := make([]model.LowStockProduct, len(rows))
+	for i, row := range rows {
+		products[i] = row.LowStockProduct
+	}
+	return products, rows[0].TotalCount, nil
+}
+
+// 商品の価値・重量を更新する
+// 変更履歴は呼び出し元が同じトランザクションでProductHistoryRepositoryに記録する
+func (r *ProductRepository) UpdateValueWeight(ctx context.Context, productID, value, weight int) error {
+	query := "UPDATE products SET value = ?, weight = ? WHERE product_id = ?"
+	if _, err := r.db.ExecContext(ctx, query, value, weight, productID); err != nil {
+		return err
+	}
+	r.events.Publish(events.Event{Topic: events.ProductChanged, IDs: []int64{int64(productID)}})
+	return nil
+}
+
+// 価値・重量が0の商品を商品IDの昇順に取得（配送計画の動的計画法が正しく動かないため）
+func (r *ProductRepository) ListInvalidValues(ctx context.Context, limit int) ([]model.Product, error) {
+	products := []model.Product{}
+	query := `
+		SELECT product_id, name, value, weight, image, description, category, available_from, available_until
+		FROM products
+		WHERE value = 0 OR weight = 0
+		ORDER BY product_id
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &products, query, limit)
+	return products, err
+}
+
+// 商品IDの昇順に、指定IDより後の商品を取得（全件走査用）
+func (r *ProductRepository) ListAfter(ctx context.Context, afterID, limit int) ([]model.Product, error) {
+	var products []model.Product
+	query := `
+		SELECT product_id, name, value, weight, image, description, category, available_from, available_until
+		FROM products
+		WHERE product_id > ?
+		ORDER BY product_id
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &products, query, afterID, limit)
+	return products, err
+}
+
+// fromより後、until以前に注文できる期間の始まり・終わりを迎えた商品の数
+// 商品一覧のキャッシュに期間外の商品が残らないよう、件数が0でなければ一覧を無効化する
+func (r *ProductRepository) CountAvailabilityChanges(ctx context.Context, from, until time.Time) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*) FROM products
+		WHERE (available_from > ? AND available_from <= ?) OR (available_until > ? AND available_until <= ?)`
+	err := r.db.GetContext(ctx, &count, query, from, until, from, until)
+	return count, err
+}
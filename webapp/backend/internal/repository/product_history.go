@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+// 商品の変更元
+const (
+	ProductChangeSourceAdminAPI = "admin_api"
+	// 管理APIでの一括補正（/api/admin/products/recalibrate）
+	ProductChangeSourceRecalibration = "recalibration"
+	// 価値・重量が0の商品を1に直したマイグレーション（21_product_value_weight.sql）
+	ProductChangeSourceRepair = "repair_migration"
+)
+
+type ProductHistoryRepository struct {
+	db DBTX
+}
+
+func NewProductHistoryRepository(db DBTX) *ProductHistoryRepository {
+	return &ProductHistoryRepository{db: db}
+}
+
+// 商品の価値・重量の変更を記録する
+func (r *ProductHistoryRepository) Create(ctx context.Context, before, after model.Product, source string) error {
+	query := `
+		INSERT INTO product_history (product_id, old_value, new_value, old_weight, new_weight, source, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, before.ProductID, before.Value, after.Value, before.Weight, after.Weight, source, time.Now())
+	return err
+}
+
+// 商品の変更履歴を新しい順に取得
+func (r *ProductHistoryRepository) ListByProduct(ctx context.Context, productID, limit int) ([]model.ProductHistory, error) {
+	history := []model.ProductHistory{}
+	query := `
+		SELECT id, product_id, old_value, new_value, old_weight, new_weight, source, changed_at
+		FROM product_history
+		WHERE product_id = ?
+		ORDER BY changed_at DESC, id DESC
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &history, query, productID, limit)
+	return history, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ProductStatsRepository struct {
+	db DBTX
+}
+
+func NewProductStatsRepository(db DBTX) *ProductStatsRepository {
+	return &ProductStatsRepository{db: db}
+}
+
+// ordersを商品ごとに集計し直し、更新した商品数を返す
+func (r *ProductStatsRepository) Refresh(ctx context.Context) (int64, error) {
+	query := `
+		INSERT INTO product_order_stats (product_id, order_count, last_ordered_at, updated_at)
+		SELECT product_id, COUNT(*), MAX(created_at), ?
+		FROM orders
+		GROUP BY product_id
+		ON DUPLICATE KEY UPDATE
+			order_count = VALUES(order_count),
+			last_ordered_at = VALUES(last_ordered_at),
+			updated_at = VALUES(updated_at)`
+	result, err := r.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// 集計を削除してから集計し直す（注文のない商品の集計も消える）
+// テストデータのリセット用
+func (r *ProductStatsRepository) Rebuild(ctx context.Context) (int64, error) {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM product_order_stats"); err != nil {
+		return 0, err
+	}
+	return r.Refresh(ctx)
+}
+
+// 現在注文できる全商品の注文数を、集計がない商品は0として取得する
+func (r *ProductStatsRepository) ListPopularity(ctx context.Context) ([]model.ProductPopularity, error) {
+	popularity := []model.ProductPopularity{}
+	now := time.Now()
+	query := `
+		SELECT p.product_id, COALESCE(s.order_count, 0) AS order_count
+		FROM products p
+		LEFT JOIN product_order_stats s ON s.product_id = p.product_id
+		WHERE ` + productAvailableCondition + `
+		ORDER BY p.product_id`
+	err := r.db.SelectContext(ctx, &popularity, query, now, now)
+	return popularity, err
+}
+
+// 指定した商品の集計を取得（集計がない商品は含まれない）
+func (r *ProductStatsRepository) FindByProductIDs(ctx context.Context, productIDs []int) ([]model.ProductOrderStats, error) {
+	stats := []model.ProductOrderStats{}
+	if len(productIDs) == 0 {
+		return stats, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT product_id, order_count, last_ordered_at
+		FROM product_order_stats
+		WHERE product_id IN (?)`, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	query = r.db.Rebind(query)
+	err = r.db.SelectContext(ctx, &stats, query, args...)
+	return stats, err
+}
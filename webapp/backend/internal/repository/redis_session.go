@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redisのキーの既定の接頭辞
+const DefaultSessionKeyPrefix = "session:"
+
+type redisSession struct {
+	UserID      int       `json:"user_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+}
+
+// Redisに保存するセッション（複数インスタンスで共有し、再起動後も残る）
+// 有効期限はRedisのキーの期限として設定するため、期限切れのセッションは自動で消える
+type RedisSessionRepository struct {
+	client redis.UniversalClient
+	prefix string
+	// 期限までは内容が変わらないため、取得したセッションはプロセス内にも保持する（セッションIDのハッシュがキー）
+	cache map[string]sessionCache
+	mutex sync.RWMutex
+}
+
+func NewRedisSessionRepository(client redis.UniversalClient, prefix string) *RedisSessionRepository {
+	return &RedisSessionRepository{client: client, prefix: prefix, cache: make(map[string]sessionCache)}
+}
+
+// Redisへの問い合わせは十分速いため、まとめない
+func (r *RedisSessionRepository) EnableLookupBatching(time.Duration, int) {}
+
+func (r *RedisSessionRepository) Create(ctx context.Context, userBusinessID int, duration time.Duration, fingerprint string) (string, time.Time, error) {
+	sessionUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	sessionID := sessionUUID.String()
+	key := hashSessionID(sessionID)
+	expiresAt := time.Now().Add(duration)
+	data, err := json.Marshal(redisSession{UserID: userBusinessID, ExpiresAt: expiresAt, Fingerprint: fingerprint})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := r.client.Set(ctx, r.prefix+key, data, duration).Err(); err != nil {
+		return "", time.Time{}, err
+	}
+
+	r.mutex.Lock()
+	r.cache[key] = sessionCache{userID: userBusinessID, expiresAt: expiresAt, fingerprint: fingerprint}
+	r.mutex.Unlock()
+	return sessionID, expiresAt, nil
+}
+
+// 存在しない・期限切れのセッションはsql.ErrNoRowsを返す（SessionRepositoryと同じ）
+func (r *RedisSessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, string, error) {
+	key := hashSessionID(sessionID)
+	r.mutex.RLock()
+	cached, exists := r.cache[key]
+	r.mutex.RUnlock()
+	if exists {
+		if time.Now().Before(cached.expiresAt) {
+			sessionCacheStats.Hit()
+			return cached.userID, cached.fingerprint, nil
+		}
+		r.mutex.Lock()
+		delete(r.cache, key)
+		r.mutex.Unlock()
+	}
+
+	sessionCacheStats.Miss()
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return 0, "", sql.ErrNoRows
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	var session redisSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return 0, "", err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return 0, "", sql.ErrNoRows
+	}
+
+	r.mutex.Lock()
+	r.cache[key] = sessionCache{userID: session.UserID, expiresAt: session.ExpiresAt, fingerprint: session.Fingerprint}
+	r.mutex.Unlock()
+	return session.UserID, session.Fingerprint, nil
+}
+
+// 接頭辞に一致する全セッションを削除し、削除した件数を返す（テストデータのリセット用）
+func (r *RedisSessionRepository) DeleteAll(ctx context.Context) (int64, error) {
+	var deleted int64
+	iter := r.client.Scan(ctx, 0, r.prefix+"*", 1000).Iterator()
+	keys := make([]string, 0, 1000)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		n, err := r.client.Del(ctx, keys...).Result()
+		deleted += n
+		keys = keys[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == cap(keys) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	if err := flush(); err != nil {
+		return deleted, err
+	}
+	r.mutex.Lock()
+	clear(r.cache)
+	r.mutex.Unlock()
+	return deleted, nil
+}
+
+// Redisのセッションは有効期限で消えるため、キャッシュの期限切れのエントリのみを破棄する（削除件数は常に0）
+func (r *RedisSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for key, session := range r.cache {
+		if !now.Before(session.expiresAt) {
+			delete(r.cache, key)
+		}
+	}
+	return 0, nil
+}
+
+// Redisに接続できるか確認する
+func (r *RedisSessionRepository) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisSessionRepository) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 読み取りを、使える間はレプリカに送るDBTX
+// 書き込み（ExecContext）は常にプライマリに送る
+type replicaDB struct {
+	primary   DBTX
+	replica   DBTX
+	available func() bool
+	// レプリカのエラーが接続断などによるものか判定する（trueの場合はプライマリで実行し直す）
+	observe func(err error) bool
+}
+
+// availableがtrueの間、読み取りをreplicaに送り、それ以外はprimaryに送る
+// レプリカで接続断などにより失敗したクエリ（observeがtrueを返したもの）は、プライマリで実行し直す
+func WithReplica(primary, replica DBTX, available func() bool, observe func(err error) bool) DBTX {
+	return &replicaDB{primary: primary, replica: replica, available: available, observe: observe}
+}
+
+// レプリカで失敗したクエリをプライマリで実行し直すか
+func (d *replicaDB) fallback(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil && d.observe(err)
+}
+
+func (d *replicaDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if !d.available() {
+		return d.primary.GetContext(ctx, dest, query, args...)
+	}
+	err := d.replica.GetContext(ctx, dest, query, args...)
+	if d.fallback(ctx, err) {
+		return d.primary.GetContext(ctx, dest, query, args...)
+	}
+	return err
+}
+
+func (d *replicaDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if !d.available() {
+		return d.primary.SelectContext(ctx, dest, query, args...)
+	}
+	err := d.replica.SelectContext(ctx, dest, query, args...)
+	if d.fallback(ctx, err) {
+		return d.primary.SelectContext(ctx, dest, query, args...)
+	}
+	return err
+}
+
+func (d *replicaDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.primary.ExecContext(ctx, query, args...)
+}
+
+func (d *replicaDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	if !d.available() {
+		return d.primary.QueryxContext(ctx, query, args...)
+	}
+	rows, err := d.replica.QueryxContext(ctx, query, args...)
+	if d.fallback(ctx, err) {
+		return d.primary.QueryxContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (d *replicaDB) Rebind(query string) string {
+	return d.primary.Rebind(query)
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SchemaRepository struct {
+	db DBTX
+}
+
+func NewSchemaRepository(db DBTX) *SchemaRepository {
+	return &SchemaRepository{db: db}
+}
+
+// 接続中のデータベースに存在しないテーブルを返す
+func (r *SchemaRepository) MissingTables(ctx context.Context, tables []string) ([]string, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name IN (?)`, tables)
+	if err != nil {
+		return nil, err
+	}
+	var existing []string
+	if err := r.db.SelectContext(ctx, &existing, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+	var missing []string
+	for _, name := range tables {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// テーブルに必要なインデックス（Columnsを先頭の列に持つインデックスがあればよい）
+type IndexSpec struct {
+	Table   string
+	Columns []string
+}
+
+// 接続中のデータベースで、条件を満たすインデックスが存在しないものを返す
+func (r *SchemaRepository) MissingIndexes(ctx context.Context, specs []IndexSpec) ([]IndexSpec, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	tables := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		tables = append(tables, spec.Table)
+	}
+	query, args, err := sqlx.In(`
+		SELECT table_name AS table_name, index_name AS index_name, COALESCE(column_name, '') AS column_name
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name IN (?)
+		ORDER BY table_name, index_name, seq_in_index`, tables)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Table  string `db:"table_name"`
+		Index  string `db:"index_name"`
+		Column string `db:"column_name"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	// テーブルごとに、各インデックスの列を順に並べる
+	indexes := make(map[string]map[string][]string)
+	for _, row := range rows {
+		if indexes[row.Table] == nil {
+			indexes[row.Table] = make(map[string][]string)
+		}
+		indexes[row.Table][row.Index] = append(indexes[row.Table][row.Index], row.Column)
+	}
+	var missing []IndexSpec
+	for _, spec := range specs {
+		found := false
+		for _, columns := range indexes[spec.Table] {
+			if len(columns) >= len(spec.Columns) && slices.Equal(columns[:len(spec.Columns)], spec.Columns) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, spec)
+		}
+	}
+	return missing, nil
+}
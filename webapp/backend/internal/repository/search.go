@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ngramのトークン長（MySQLのngram_token_sizeのデフォルト値）
+const ngramTokenSize = 2
+
+// 検索語をngram全文検索用のBOOLEAN MODEクエリに変換する
+// いずれかの語に一致すればよいため、各語をフレーズとして並べる
+// トークン長未満の語が含まれる場合はngramでは検索できないためfalseを返す
+func ngramBooleanQuery(terms []string) (string, bool) {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if utf8.RuneCountInString(term) < ngramTokenSize {
+			return "", false
+		}
+		// フレーズ内のダブルクォートは区切りとして扱われるため空白に置き換える
+		term = strings.ReplaceAll(term, `"`, " ")
+		phrases = append(phrases, `"`+term+`"`)
+	}
+	if len(phrases) == 0 {
+		return "", false
+	}
+	return strings.Join(phrases, " "), true
+}
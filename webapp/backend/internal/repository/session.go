@@ -1,22 +1,40 @@
 package repository
 
 import (
+	"backend/internal/metrics"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var sessionCacheStats = metrics.Cache("session")
+
+// セッションIDの保存用のハッシュ
+// DB・Redis・キャッシュにはこの値だけを保存し、元のセッションIDはクライアントのCookieにのみ残す
+func hashSessionID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
 type sessionCache struct {
-	userID    int
-	expiresAt time.Time
+	userID      int
+	expiresAt   time.Time
+	fingerprint string
 }
 
 type SessionRepository struct {
-	db    DBTX
+	db DBTX
+	// セッションIDのハッシュをキーとする
 	cache map[string]sessionCache
 	mutex sync.RWMutex
+	// キャッシュミス時の問い合わせをまとめる（nilの場合は1件ずつ問い合わせる）
+	batcher *sessionBatcher
+	// セッションの作成を後からまとめて書き込む（nilの場合は作成時に書き込む）
+	writer *sessionWriter
 }
 
 func NewSessionRepository(db DBTX) *SessionRepository {
@@ -26,74 +44,173 @@ func NewSessionRepository(db DBTX) *SessionRepository {
 	}
 }
 
+// キャッシュミス時のセッション検索を、window内に集中したもの同士でまとめて問い合わせるようにする
+// トランザクション外のリポジトリでのみ使用すること
+func (r *SessionRepository) EnableLookupBatching(window time.Duration, maxBatch int) {
+	r.batcher = newSessionBatcher(r.db, window, maxBatch)
+}
+
+// セッションの作成時にINSERTせず、溜めておいてmaxBatch件ずつまとめて書き込むようにする
+// 書き込みはRunWriteBehindで行う。トランザクション外のリポジトリでのみ使用すること
+//
+// 書き込むまでのセッションはこのプロセスのキャッシュにしかないため、次の制約がある
+//   - プロセスが異常終了すると書き込む前のセッションは失われ、再ログインが必要になる
+//   - 複数インスタンスで動かす場合、他のインスタンスでは書き込まれるまで無効なセッションとして扱われる
+func (r *SessionRepository) EnableWriteBehind(maxBatch int) {
+	r.writer = newSessionWriter(r.db, maxBatch)
+}
+
+// ctxがキャンセルされるまで、interval毎に溜めているセッションを書き込む（呼び出し元をブロックする）
+// キャンセル後は残りを書き込んでから戻る。EnableWriteBehindを呼んでいない場合は何もしない
+func (r *SessionRepository) RunWriteBehind(ctx context.Context, interval time.Duration) {
+	if r.writer == nil {
+		return
+	}
+	r.writer.run(ctx, interval)
+}
+
 // セッションを作成し、セッションIDと有効期限を返す
-func (r *SessionRepository) Create(ctx context.Context, userBusinessID int, duration time.Duration) (string, time.Time, error) {
+// fingerprintはクライアント指紋（空文字の場合は記録しない）
+func (r *SessionRepository) Create(ctx context.Context, userBusinessID int, duration time.Duration, fingerprint string) (string, time.Time, error) {
 	sessionUUID, err := uuid.NewRandom()
 	if err != nil {
 		return "", time.Time{}, err
 	}
 	expiresAt := time.Now().Add(duration)
 	sessionIDStr := sessionUUID.String()
+	key := hashSessionID(sessionIDStr)
 
-	query := "INSERT INTO user_sessions (session_uuid, user_id, expires_at) VALUES (?, ?, ?)"
-	_, err = r.db.ExecContext(ctx, query, sessionIDStr, userBusinessID, expiresAt)
-	if err != nil {
-		return "", time.Time{}, err
+	session := sessionCache{
+		userID:      userBusinessID,
+		expiresAt:   expiresAt,
+		fingerprint: fingerprint,
 	}
 
-	// キャッシュに保存
-	r.mutex.Lock()
-	r.cache[sessionIDStr] = sessionCache{
-		userID:    userBusinessID,
-		expiresAt: expiresAt,
+	if r.writer == nil {
+		var fingerprintArg interface{}
+		if fingerprint != "" {
+			fingerprintArg = fingerprint
+		}
+		query := "INSERT INTO user_sessions (session_uuid, user_id, expires_at, fingerprint) VALUES (?, ?, ?, ?)"
+		_, err = r.db.ExecContext(ctx, query, key, userBusinessID, expiresAt, fingerprintArg)
+		if err != nil {
+			return "", time.Time{}, err
+		}
 	}
+
+	// キャッシュに保存（write-behindの場合は書き込むまでキャッシュだけで認証する）
+	r.mutex.Lock()
+	r.cache[key] = session
 	r.mutex.Unlock()
+	if r.writer != nil {
+		r.writer.add(key, session)
+	}
 
 	return sessionIDStr, expiresAt, nil
 }
 
-// セッションIDからユーザーIDを取得（キャッシュ優先）
-func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, error) {
+// セッションIDからユーザーIDと作成時のクライアント指紋を取得（キャッシュ優先）
+func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, string, error) {
+	key := hashSessionID(sessionID)
 	// まずキャッシュをチェック
 	r.mutex.RLock()
-	cached, exists := r.cache[sessionID]
+	cached, exists := r.cache[key]
 	r.mutex.RUnlock()
 
 	if exists {
 		// キャッシュが有効かチェック
 		if time.Now().Before(cached.expiresAt) {
-			return cached.userID, nil
+			sessionCacheStats.Hit()
+			return cached.userID, cached.fingerprint, nil
 		}
 		// 期限切れの場合はキャッシュから削除
 		r.mutex.Lock()
-		delete(r.cache, sessionID)
+		delete(r.cache, key)
 		r.mutex.Unlock()
 	}
 
 	// キャッシュにない場合はDBから取得（1回のクエリで両方を取得）
-	var sessionData struct {
-		UserID    int       `db:"user_id"`
-		ExpiresAt time.Time `db:"expires_at"`
+	sessionCacheStats.Miss()
+	session, err := r.lookup(ctx, key)
+	if err != nil {
+		return 0, "", err
 	}
-	query := `
-		SELECT 
-			u.user_id,
-			s.expires_at
-		FROM users u
-		JOIN user_sessions s ON u.user_id = s.user_id
-		WHERE s.session_uuid = ? AND s.expires_at > ?`
-	err := r.db.GetContext(ctx, &sessionData, query, sessionID, time.Now())
+
+	// DBから取得したセッション情報をキャッシュに保存
+	r.mutex.Lock()
+	r.cache[key] = session
+	r.mutex.Unlock()
+
+	return session.userID, session.fingerprint, nil
+}
+
+// 全セッションを削除し、削除した件数を返す（テストデータのリセット用）
+func (r *SessionRepository) DeleteAll(ctx context.Context) (int64, error) {
+	if r.writer != nil {
+		r.writer.discard()
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM user_sessions")
 	if err != nil {
 		return 0, err
 	}
+	r.mutex.Lock()
+	clear(r.cache)
+	r.mutex.Unlock()
+	return result.RowsAffected()
+}
 
-	// DBから取得したセッション情報をキャッシュに保存
+// 1回のDELETEで削除する期限切れセッションの件数（行ロックを長く持たないよう分けて削除する）
+const expiredSessionBatch = 1000
+
+// 期限切れのセッションを削除し、削除した件数を返す（キャッシュの期限切れのエントリも破棄する）
+func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
 	r.mutex.Lock()
-	r.cache[sessionID] = sessionCache{
-		userID:    sessionData.UserID,
-		expiresAt: sessionData.ExpiresAt,
+	for key, session := range r.cache {
+		if !now.Before(session.expiresAt) {
+			delete(r.cache, key)
+		}
 	}
 	r.mutex.Unlock()
 
-	return sessionData.UserID, nil
+	var deleted int64
+	for {
+		result, err := r.db.ExecContext(ctx, "DELETE FROM user_sessions WHERE expires_at <= ? LIMIT ?", now, expiredSessionBatch)
+		if err != nil {
+			return deleted, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+		if n < expiredSessionBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// keyはセッションIDのハッシュ
+func (r *SessionRepository) lookup(ctx context.Context, key string) (sessionCache, error) {
+	if r.batcher != nil {
+		return r.batcher.lookup(ctx, key)
+	}
+
+	var sessionData struct {
+		UserID      int       `db:"user_id"`
+		ExpiresAt   time.Time `db:"expires_at"`
+		Fingerprint string    `db:"fingerprint"`
+	}
+	query := `
+		SELECT 
+			u.user_id,
+			s.expires_at,
+			COALESCE(s.fingerprint, '') AS fingerprint
+		FROM users u
+		JOIN user_sessions s ON u.user_id = s.user_id
+		WHERE s.session_uuid = ? AND s.expires_at > ?`
+	if err := r.db.GetContext(ctx, &sessionData, query, key, time.Now()); err != nil {
+		return sessionCache{}, err
+	}
+	return sessionCache{userID: sessionData.UserID, expiresAt: sessionData.ExpiresAt, fingerprint: sessionData.Fingerprint}, nil
 }
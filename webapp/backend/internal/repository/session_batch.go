@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// バッチ問い合わせのタイムアウト（個々の呼び出し元はそれぞれのctxで待機を打ち切る）
+const sessionBatchQueryTimeout = 5 * time.Second
+
+type sessionLookupResult struct {
+	session sessionCache
+	err     error
+}
+
+// 短時間に集中したセッションのキャッシュミスを1回のIN句クエリにまとめる
+type sessionBatcher struct {
+	db       DBTX
+	window   time.Duration
+	maxBatch int
+
+	mutex   sync.Mutex
+	pending map[string][]chan sessionLookupResult
+	timer   *time.Timer
+}
+
+func newSessionBatcher(db DBTX, window time.Duration, maxBatch int) *sessionBatcher {
+	return &sessionBatcher{
+		db:       db,
+		window:   window,
+		maxBatch: maxBatch,
+		pending:  make(map[string][]chan sessionLookupResult),
+	}
+}
+
+// セッションIDのハッシュを次のバッチに加え、結果が返るまで待つ
+func (b *sessionBatcher) lookup(ctx context.Context, key string) (sessionCache, error) {
+	ch := make(chan sessionLookupResult, 1)
+
+	b.mutex.Lock()
+	b.pending[key] = append(b.pending[key], ch)
+	if len(b.pending) >= b.maxBatch {
+		// 上限に達したら待たずに問い合わせる
+		batch := b.takeLocked()
+		b.mutex.Unlock()
+		go b.run(batch)
+	} else {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, b.flush)
+		}
+		b.mutex.Unlock()
+	}
+
+	select {
+	case res := <-ch:
+		return res.session, res.err
+	case <-ctx.Done():
+		return sessionCache{}, ctx.Err()
+	}
+}
+
+func (b *sessionBatcher) flush() {
+	b.mutex.Lock()
+	batch := b.takeLocked()
+	b.mutex.Unlock()
+	b.run(batch)
+}
+
+// 溜まっている問い合わせを取り出す（呼び出し元でロックを保持すること）
+func (b *sessionBatcher) takeLocked() map[string][]chan sessionLookupResult {
+	batch := b.pending
+	b.pending = make(map[string][]chan sessionLookupResult)
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+func (b *sessionBatcher) run(batch map[string][]chan sessionLookupResult) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionBatchQueryTimeout)
+	defer cancel()
+
+	sessionIDs := make([]string, 0, len(batch))
+	for id := range batch {
+		sessionIDs = append(sessionIDs, id)
+	}
+
+	found, err := b.query(ctx, sessionIDs)
+	for id, chs := range batch {
+		res := sessionLookupResult{err: err}
+		if err == nil {
+			if session, ok := found[id]; ok {
+				res.session = session
+			} else {
+				res.err = sql.ErrNoRows
+			}
+		}
+		for _, ch := range chs {
+			ch <- res
+		}
+	}
+}
+
+func (b *sessionBatcher) query(ctx context.Context, sessionIDs []string) (map[string]sessionCache, error) {
+	var rows []struct {
+		SessionUUID string    `db:"session_uuid"`
+		UserID      int       `db:"user_id"`
+		ExpiresAt   time.Time `db:"expires_at"`
+		Fingerprint string    `db:"fingerprint"`
+	}
+	query, args, err := sqlx.In(`
+		SELECT
+			s.session_uuid,
+			u.user_id,
+			s.expires_at,
+			COALESCE(s.fingerprint, '') AS fingerprint
+		FROM users u
+		JOIN user_sessions s ON u.user_id = s.user_id
+		WHERE s.session_uuid IN (?) AND s.expires_at > ?`, sessionIDs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	query = b.db.Rebind(query)
+	if err := b.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]sessionCache, len(rows))
+	for _, row := range rows {
+		found[row.SessionUUID] = sessionCache{userID: row.UserID, expiresAt: row.ExpiresAt, fingerprint: row.Fingerprint}
+	}
+	return found, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 終了時に残りのセッションを書き込む際のタイムアウト
+const sessionFlushTimeout = 5 * time.Second
+
+// 書き込みを後回しにしたセッション（keyはセッションIDのハッシュ）
+type pendingSession struct {
+	key     string
+	session sessionCache
+}
+
+// セッションのINSERTを溜めておき、まとめて書き込む（write-behind）
+// 溜めている間のセッションはSessionRepositoryのキャッシュにあるため、作成直後から有効になる
+type sessionWriter struct {
+	db       DBTX
+	maxBatch int
+
+	mutex   sync.Mutex
+	pending []pendingSession
+	// 溜まった件数がmaxBatchに達したことをRunに知らせる
+	full chan struct{}
+}
+
+func newSessionWriter(db DBTX, maxBatch int) *sessionWriter {
+	return &sessionWriter{db: db, maxBatch: maxBatch, full: make(chan struct{}, 1)}
+}
+
+func (w *sessionWriter) add(key string, session sessionCache) {
+	w.mutex.Lock()
+	w.pending = append(w.pending, pendingSession{key: key, session: session})
+	full := len(w.pending) >= w.maxBatch
+	w.mutex.Unlock()
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 溜めているセッションを破棄する（全セッションの削除用）
+func (w *sessionWriter) discard() {
+	w.mutex.Lock()
+	w.pending = nil
+	w.mutex.Unlock()
+}
+
+// ctxがキャンセルされるまで、interval毎（またはmaxBatch件溜まった時）に書き込む（呼び出し元をブロックする）
+// キャンセル後は残りを書き込んでから戻る
+func (w *sessionWriter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionFlushTimeout)
+			defer cancel()
+			if err := w.flush(flushCtx); err != nil {
+				slog.ErrorContext(flushCtx, "[SessionWriteBehind] failed to flush sessions on shutdown", "err", err)
+			}
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+		if err := w.flush(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "[SessionWriteBehind] failed to flush sessions", "err", err)
+		}
+	}
+}
+
+// 溜めているセッションをmaxBatch件ずつINSERTする
+// 失敗した分は期限切れのものを除いて戻し、次回に再び書き込む
+func (w *sessionWriter) flush(ctx context.Context) error {
+	w.mutex.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.mutex.Unlock()
+
+	for len(pending) > 0 {
+		n := min(len(pending), w.maxBatch)
+		if err := w.insert(ctx, pending[:n]); err != nil {
+			w.requeue(pending)
+			return err
+		}
+		pending = pending[n:]
+	}
+	return nil
+}
+
+func (w *sessionWriter) requeue(failed []pendingSession) {
+	now := time.Now()
+	kept := make([]pendingSession, 0, len(failed))
+	for _, p := range failed {
+		if now.Before(p.session.expiresAt) {
+			kept = append(kept, p)
+		}
+	}
+	w.mutex.Lock()
+	w.pending = append(kept, w.pending...)
+	w.mutex.Unlock()
+}
+
+func (w *sessionWriter) insert(ctx context.Context, sessions []pendingSession) error {
+	values := make([]string, len(sessions))
+	args := make([]interface{}, 0, len(sessions)*4)
+	for i, p := range sessions {
+		values[i] = "(?, ?, ?, ?)"
+		var fingerprint interface{}
+		if p.session.fingerprint != "" {
+			fingerprint = p.session.fingerprint
+		}
+		args = append(args, p.key, p.session.userID, p.session.expiresAt, fingerprint)
+	}
+	query := "INSERT INTO user_sessions (session_uuid, user_id, expires_at, fingerprint) VALUES " + strings.Join(values, ", ")
+	_, err := w.db.ExecContext(ctx, query, args...)
+	return err
+}
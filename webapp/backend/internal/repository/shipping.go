@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+type ShippingRepository struct {
+	db DBTX
+}
+
+func NewShippingRepository(db DBTX) *ShippingRepository {
+	return &ShippingRepository{db: db}
+}
+
+// 配送ゾーンを判定の優先順に取得
+func (r *ShippingRepository) ListZones(ctx context.Context) ([]model.ShippingZone, error) {
+	var zones []model.ShippingZone
+	query := `SELECT zone_code, min_lat, max_lat, min_lng, max_lng, priority FROM shipping_zones ORDER BY priority, zone_code`
+	err := r.db.SelectContext(ctx, &zones, query)
+	return zones, err
+}
+
+// 全ゾーンの送料表を重量の昇順で取得
+func (r *ShippingRepository) ListRates(ctx context.Context) ([]model.ShippingRate, error) {
+	var rates []model.ShippingRate
+	query := `SELECT zone_code, max_weight, cost FROM shipping_rates ORDER BY zone_code, max_weight`
+	err := r.db.SelectContext(ctx, &rates, query)
+	return rates, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"cmp"
+	"strings"
+)
+
+// 一覧の並び替えに使える列
+// リクエストの並び替えの指定は許可した列・方向に置き換えてからSQLに組み込み、リクエストの文字列をそのまま使わない
+type sortSpec struct {
+	// リクエストで指定するフィールド名から列への対応
+	columns map[string]string
+	// 未指定・未知のフィールドの場合に使うフィールド
+	defaultField string
+	// 同じ値の行を並べる列（並び替えの列と同じ場合は付けない）
+	tiebreaker string
+	// tiebreakerの方向（空の場合は並び替えの方向に合わせる）
+	tiebreakerOrder string
+}
+
+var productSort = sortSpec{
+	columns: map[string]string{
+		"product_id": "product_id",
+		"name":       "name",
+		"value":      "value",
+		"weight":     "weight",
+	},
+	defaultField:    "product_id",
+	tiebreaker:      "product_id",
+	tiebreakerOrder: "ASC",
+}
+
+var orderSort = sortSpec{
+	columns: map[string]string{
+		"order_id":       "o.order_id",
+		"product_name":   "p.name",
+		"created_at":     "o.created_at",
+		"shipped_status": "o.shipped_status",
+		"arrived_at":     "o.arrived_at",
+	},
+	defaultField: "order_id",
+	tiebreaker:   "o.order_id",
+}
+
+// フィールド名を許可されたもの（未知の場合はdefaultField）に、方向をasc・descに置き換える
+func (s sortSpec) normalize(field, order string) (string, string) {
+	if _, ok := s.columns[field]; !ok {
+		field = s.defaultField
+	}
+	if strings.EqualFold(order, "desc") {
+		return field, "desc"
+	}
+	return field, "asc"
+}
+
+// ORDER BY句（同じ値の行はtiebreakerで並べる）
+func (s sortSpec) orderBy(field, order string) string {
+	field, order = s.normalize(field, order)
+	column := s.columns[field]
+	dir := strings.ToUpper(order)
+	clause := "ORDER BY " + column + " " + dir
+	if s.tiebreaker != "" && s.tiebreaker != column {
+		clause += ", " + s.tiebreaker + " " + cmp.Or(s.tiebreakerOrder, dir)
+	}
+	return clause
+}
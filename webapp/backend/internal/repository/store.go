@@ -1,45 +1,112 @@
 package repository
 
 import (
+	"backend/internal/events"
+	"backend/internal/model"
 	"context"
-
-	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 type Store struct {
-	db          DBTX
-	UserRepo    *UserRepository
-	SessionRepo *SessionRepository
-	ProductRepo *ProductRepository
-	OrderRepo   *OrderRepository
+	db             DBTX
+	events         events.Publisher
+	UserRepo       Users
+	SessionRepo    Sessions
+	ProductRepo    Products
+	OrderRepo      Orders
+	EventRepo      *OrderEventRepository
+	DistanceRepo   *DistanceRepository
+	ShippingRepo   *ShippingRepository
+	TaxRepo        *TaxRepository
+	SynonymRepo    *SynonymRepository
+	OutboxRepo     *OutboxRepository
+	PreferenceRepo *PreferenceRepository
+	SchemaRepo     *SchemaRepository
+	HistoryRepo    *ProductHistoryRepository
+	StatsRepo      *ProductStatsRepository
 }
 
-func NewStore(db DBTX) *Store {
+// リポジトリの変更イベントはpubに発行される
+func NewStore(db DBTX, pub events.Publisher) *Store {
 	return &Store{
-		db:          db,
-		UserRepo:    NewUserRepository(db),
-		SessionRepo: NewSessionRepository(db),
-		ProductRepo: NewProductRepository(db),
-		OrderRepo:   NewOrderRepository(db),
+		db:             db,
+		events:         pub,
+		UserRepo:       NewUserRepository(db),
+		SessionRepo:    NewSessionRepository(db),
+		ProductRepo:    NewProductRepository(db, pub),
+		OrderRepo:      NewOrderRepository(db, pub),
+		EventRepo:      NewOrderEventRepository(db),
+		DistanceRepo:   NewDistanceRepository(db),
+		ShippingRepo:   NewShippingRepository(db),
+		TaxRepo:        NewTaxRepository(db),
+		SynonymRepo:    NewSynonymRepository(db),
+		OutboxRepo:     NewOutboxRepository(db),
+		PreferenceRepo: NewPreferenceRepository(db),
+		SchemaRepo:     NewSchemaRepository(db),
+		HistoryRepo:    NewProductHistoryRepository(db),
+		StatsRepo:      NewProductStatsRepository(db),
+	}
+}
+
+// メモリ上のStoreに最初から入れておくデータ
+type MemoryFixtures struct {
+	Users    []model.User
+	Products []model.Product
+}
+
+// ユーザー・注文・商品・セッションをメモリ上に保持するStore（MySQLなしでの動作確認・結合テスト用）
+// それ以外のリポジトリはErrNoDatabaseを返し、トランザクションは使わずにfnをそのまま実行する
+func NewMemoryStore(pub events.Publisher, fixtures MemoryFixtures) *Store {
+	store := NewStore(unavailableDB{}, pub)
+	store.UserRepo = NewMemoryUserRepository(fixtures.Users...)
+	productRepo := NewMemoryProductRepository(pub, fixtures.Products...)
+	store.ProductRepo = productRepo
+	store.OrderRepo = NewMemoryOrderRepository(productRepo, pub)
+	store.SessionRepo = NewMemorySessionRepository()
+	return store
+}
+
+// 商品一覧・注文一覧・配送待ち注文の取得をreadに送る（WithReplicaでレプリカに振り分けるDBTXを渡す）
+// 関係するキャッシュを無効化してからafterWriteの間は、レプリカに反映される前の結果を返さないようプライマリから読む
+// トランザクション内のStore（ExecTxのtxStore）には設定されず、トランザクションで読む
+func (s *Store) UseReadReplica(read DBTX, afterWrite time.Duration) {
+	if r, ok := s.ProductRepo.(*ProductRepository); ok {
+		r.read, r.readAfterWrite = read, afterWrite
+	}
+	if r, ok := s.OrderRepo.(*OrderRepository); ok {
+		r.read, r.readAfterWrite = read, afterWrite
 	}
 }
 
 func (s *Store) ExecTx(ctx context.Context, fn func(txStore *Store) error) error {
-	db, ok := s.db.(*sqlx.DB)
+	db, ok := unwrapDB(s.db).(txBeginner)
 	if !ok {
 		return fn(s)
 	}
 
 	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
+		observeError(s.db, err)
 		return err
 	}
 	defer tx.Rollback()
 
-	txStore := NewStore(tx)
+	// 変更イベントはコミットが成功してから発行する
+	buffer := events.NewBuffer(s.events)
+	txStore := NewStore(observeLike(s.db, tx), buffer)
 	if err := fn(txStore); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		observeError(s.db, err)
+		return err
+	}
+	// 商品を変更したトランザクションは、イベントの購読者の有無によらずこのStoreの商品一覧キャッシュを破棄する
+	// （txStoreのProductRepoはキャッシュを共有しないため、トランザクション内の変更はここで反映する）
+	if buffer.Contains(events.ProductChanged) {
+		s.ProductRepo.InvalidateListCache()
+	}
+	buffer.Flush()
+	return nil
 }
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+type SynonymRepository struct {
+	db DBTX
+}
+
+func NewSynonymRepository(db DBTX) *SynonymRepository {
+	return &SynonymRepository{db: db}
+}
+
+// 全ての同義語を取得
+func (r *SynonymRepository) ListSynonyms(ctx context.Context) ([]model.Synonym, error) {
+	var synonyms []model.Synonym
+	err := r.db.SelectContext(ctx, &synonyms, `SELECT group_id, term FROM search_synonyms`)
+	return synonyms, err
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+type TaxRepository struct {
+	db DBTX
+}
+
+func NewTaxRepository(db DBTX) *TaxRepository {
+	return &TaxRepository{db: db}
+}
+
+// 全ての税率設定を取得
+func (r *TaxRepository) ListTaxRates(ctx context.Context) ([]model.TaxRate, error) {
+	var rates []model.TaxRate
+	err := r.db.SelectContext(ctx, &rates, `SELECT category, region, rate_bp FROM tax_rates`)
+	return rates, err
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 
 	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type UserRepository struct {
@@ -31,3 +33,31 @@ func (r *UserRepository) FindByUserName(ctx context.Context, userName string) (*
 	}
 	return &user, nil
 }
+
+// 全ユーザーのIDをID順に返す（テストデータの作成用）
+func (r *UserRepository) ListIDs(ctx context.Context) ([]int, error) {
+	ids := []int{}
+	err := r.db.SelectContext(ctx, &ids, "SELECT user_id FROM users ORDER BY user_id")
+	return ids, err
+}
+
+// 指定したユーザーIDのうち、存在するものを返す
+func (r *UserRepository) ExistingIDs(ctx context.Context, userIDs []int) ([]int, error) {
+	existing := []int{}
+	if len(userIDs) == 0 {
+		return existing, nil
+	}
+	query, args, err := sqlx.In("SELECT user_id FROM users WHERE user_id IN (?)", userIDs)
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.SelectContext(ctx, &existing, r.db.Rebind(query), args...)
+	return existing, err
+}
+
+// ユーザー単位の処理を直列化するため、ユーザー行をロックする
+// トランザクション内で使用すること
+func (r *UserRepository) LockByID(ctx context.Context, userID int) error {
+	var id int
+	return r.db.GetContext(ctx, &id, "SELECT user_id FROM users WHERE user_id = ? FOR UPDATE", userID)
+}
@@ -0,0 +1,4 @@
+// 配送ロボット向けgRPC APIの定義（robot.protoから生成する）
+package robotpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative robot.proto
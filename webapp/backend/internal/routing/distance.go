@@ -0,0 +1,277 @@
+package routing
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 2地点間の移動時間を返すインターフェース
+type DistanceProvider interface {
+	TravelTime(ctx context.Context, from, to model.Coordinates) (time.Duration, error)
+}
+
+const (
+	earthRadiusKm   = 6371.0
+	defaultSpeedKmh = 20.0
+)
+
+// 大圏距離と一定速度から移動時間を見積もる実装
+type HaversineProvider struct {
+	speedKmh float64
+}
+
+func NewHaversineProvider(speedKmh float64) *HaversineProvider {
+	if speedKmh <= 0 {
+		speedKmh = defaultSpeedKmh
+	}
+	return &HaversineProvider{speedKmh: speedKmh}
+}
+
+func (p *HaversineProvider) TravelTime(ctx context.Context, from, to model.Coordinates) (time.Duration, error) {
+	km := haversineKm(from, to)
+	return time.Duration(km / p.speedKmh * float64(time.Hour)), nil
+}
+
+func haversineKm(a, b model.Coordinates) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// 移動時間の永続キャッシュ
+type TravelTimeStore interface {
+	Find(ctx context.Context, fromLat, fromLng, toLat, toLng float64) (*model.TravelTime, error)
+	// coordsの全ペアのうち、保存されているものをまとめて返す
+	FindMany(ctx context.Context, coords []model.Coordinates) ([]model.TravelTime, error)
+	Upsert(ctx context.Context, tt model.TravelTime) error
+	UpsertMany(ctx context.Context, tts []model.TravelTime) error
+}
+
+// 複数地点間の移動時間を、個別に問い合わせる前にまとめて読み込めるDistanceProvider
+type BatchLoader interface {
+	Preload(ctx context.Context, coords []model.Coordinates) error
+}
+
+// メモリに保持する移動時間の最大件数
+const distanceCacheEntries = 200000
+
+type distanceKey struct {
+	fromLat, fromLng, toLat, toLng float64
+}
+
+func (k distanceKey) String() string {
+	return strconv.FormatFloat(k.fromLat, 'f', 5, 64) + "," + strconv.FormatFloat(k.fromLng, 'f', 5, 64) + ">" +
+		strconv.FormatFloat(k.toLat, 'f', 5, 64) + "," + strconv.FormatFloat(k.toLng, 'f', 5, 64)
+}
+
+type distanceEntry struct {
+	seconds    int
+	computedAt time.Time
+}
+
+// メモリ→DB→元のプロバイダの順に移動時間を解決するキャッシュ
+// TTLを過ぎたエントリは元のプロバイダで再計算して更新する
+// メモリにはdistanceCacheEntries件まで保持し、超えた分は参照が古いものから破棄する
+type CachedDistanceProvider struct {
+	next  DistanceProvider
+	store TravelTimeStore
+	cache *cache.Sharded[distanceEntry]
+	ttl   time.Duration
+}
+
+func NewCachedDistanceProvider(next DistanceProvider, store TravelTimeStore, ttl time.Duration) *CachedDistanceProvider {
+	return &CachedDistanceProvider{
+		next:  next,
+		store: store,
+		cache: cache.NewShardedWith[distanceEntry](distanceCacheEntries, ttl, cache.Options{Policy: cache.PolicyLRU}),
+		ttl:   ttl,
+	}
+}
+
+func (p *CachedDistanceProvider) TravelTime(ctx context.Context, from, to model.Coordinates) (time.Duration, error) {
+	key := newDistanceKey(from, to)
+	if key.fromLat == key.toLat && key.fromLng == key.toLng {
+		return 0, nil
+	}
+
+	if d, ok := p.cached(key); ok {
+		return d, nil
+	}
+
+	tt, err := p.store.Find(ctx, key.fromLat, key.fromLng, key.toLat, key.toLng)
+	if err == nil && time.Since(tt.ComputedAt) <= p.ttl {
+		p.remember(key, distanceEntry{seconds: tt.TravelSeconds, computedAt: tt.ComputedAt})
+		return time.Duration(tt.TravelSeconds) * time.Second, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		// DBキャッシュが使えなくても計算自体は続行する
+		slog.WarnContext(ctx, "[DistanceCache] キャッシュ読み込み失敗", "err", err)
+	}
+
+	return p.refresh(ctx, key, from, to)
+}
+
+// coordsの全ペアの移動時間をメモリに読み込む
+// メモリにないペアはDBから1回のクエリで読み込み、DBにもないペアは計算してまとめて保存する
+func (p *CachedDistanceProvider) Preload(ctx context.Context, coords []model.Coordinates) error {
+	coords = uniqueCoords(coords)
+	missing := make(map[distanceKey]struct{})
+	for _, key := range distancePairs(coords) {
+		if _, ok := p.cached(key); !ok {
+			missing[key] = struct{}{}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	stored, err := p.store.FindMany(ctx, coords)
+	if err != nil {
+		// DBキャッシュが使えなくても計算自体は続行する
+		slog.WarnContext(ctx, "[DistanceCache] キャッシュ一括読み込み失敗", "err", err)
+	}
+	for _, tt := range stored {
+		key := distanceKey{fromLat: tt.FromLat, fromLng: tt.FromLng, toLat: tt.ToLat, toLng: tt.ToLng}
+		if _, ok := missing[key]; ok && time.Since(tt.ComputedAt) <= p.ttl {
+			p.remember(key, distanceEntry{seconds: tt.TravelSeconds, computedAt: tt.ComputedAt})
+			delete(missing, key)
+		}
+	}
+
+	keys := make([]distanceKey, 0, len(missing))
+	for key := range missing {
+		keys = append(keys, key)
+	}
+	_, err = p.refreshAll(ctx, keys)
+	return err
+}
+
+// 指定座標の全ペアについて移動時間を計算し、キャッシュに格納する
+func (p *CachedDistanceProvider) Precompute(ctx context.Context, coords []model.Coordinates) (int, error) {
+	return p.refreshAll(ctx, distancePairs(uniqueCoords(coords)))
+}
+
+// keysの移動時間を元のプロバイダで計算してメモリに格納し、DBにまとめて保存する
+// 計算できたペアの数を返す（途中で失敗した場合も、それまでに計算した分は保存する）
+func (p *CachedDistanceProvider) refreshAll(ctx context.Context, keys []distanceKey) (int, error) {
+	tts := make([]model.TravelTime, 0, len(keys))
+	var err error
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var d time.Duration
+		if d, err = p.next.TravelTime(ctx, key.from(), key.to()); err != nil {
+			break
+		}
+		entry := distanceEntry{seconds: int(d.Round(time.Second) / time.Second), computedAt: time.Now()}
+		p.remember(key, entry)
+		tts = append(tts, key.travelTime(entry))
+	}
+	if len(tts) > 0 {
+		// 保存に使うctxが打ち切られていても、計算した分は保存する
+		if upsertErr := p.store.UpsertMany(context.WithoutCancel(ctx), tts); upsertErr != nil {
+			slog.WarnContext(ctx, "[DistanceCache] キャッシュ一括書き込み失敗", "err", upsertErr)
+		}
+	}
+	return len(tts), err
+}
+
+func (p *CachedDistanceProvider) refresh(ctx context.Context, key distanceKey, from, to model.Coordinates) (time.Duration, error) {
+	d, err := p.next.TravelTime(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := distanceEntry{seconds: int(d.Round(time.Second) / time.Second), computedAt: time.Now()}
+	p.remember(key, entry)
+
+	if err := p.store.Upsert(ctx, key.travelTime(entry)); err != nil {
+		slog.WarnContext(ctx, "[DistanceCache] キャッシュ書き込み失敗", "err", err)
+	}
+	return time.Duration(entry.seconds) * time.Second, nil
+}
+
+// メモリにある期限内の移動時間を返す
+func (p *CachedDistanceProvider) cached(key distanceKey) (time.Duration, bool) {
+	entry, ok := p.cache.Get(key.String())
+	if !ok || time.Since(entry.computedAt) > p.ttl {
+		return 0, false
+	}
+	return time.Duration(entry.seconds) * time.Second, true
+}
+
+func (p *CachedDistanceProvider) remember(key distanceKey, entry distanceEntry) {
+	p.cache.Set(key.String(), entry, 1)
+}
+
+func (k distanceKey) from() model.Coordinates {
+	return model.Coordinates{Latitude: k.fromLat, Longitude: k.fromLng}
+}
+
+func (k distanceKey) to() model.Coordinates {
+	return model.Coordinates{Latitude: k.toLat, Longitude: k.toLng}
+}
+
+func (k distanceKey) travelTime(entry distanceEntry) model.TravelTime {
+	return model.TravelTime{
+		FromLat:       k.fromLat,
+		FromLng:       k.fromLng,
+		ToLat:         k.toLat,
+		ToLng:         k.toLng,
+		TravelSeconds: entry.seconds,
+		ComputedAt:    entry.computedAt,
+	}
+}
+
+// 座標を丸め、重複を除く
+func uniqueCoords(coords []model.Coordinates) []model.Coordinates {
+	seen := make(map[model.Coordinates]struct{}, len(coords))
+	unique := make([]model.Coordinates, 0, len(coords))
+	for _, c := range coords {
+		c = model.Coordinates{Latitude: roundCoord(c.Latitude), Longitude: roundCoord(c.Longitude)}
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		unique = append(unique, c)
+	}
+	return unique
+}
+
+// 異なる2地点の全ペア（向きを区別する）
+func distancePairs(coords []model.Coordinates) []distanceKey {
+	keys := make([]distanceKey, 0, len(coords)*len(coords))
+	for i, from := range coords {
+		for j, to := range coords {
+			if i != j {
+				keys = append(keys, newDistanceKey(from, to))
+			}
+		}
+	}
+	return keys
+}
+
+// 座標を小数点以下5桁に丸めてキーにする
+func newDistanceKey(from, to model.Coordinates) distanceKey {
+	return distanceKey{
+		fromLat: roundCoord(from.Latitude),
+		fromLng: roundCoord(from.Longitude),
+		toLat:   roundCoord(to.Latitude),
+		toLng:   roundCoord(to.Longitude),
+	}
+}
+
+func roundCoord(v float64) float64 {
+	return math.Round(v*1e5) / 1e5
+}
@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+// 配送計画に含まれる注文の訪問順を決める
+type Optimizer struct {
+	distances DistanceProvider
+}
+
+func NewOptimizer(distances DistanceProvider) *Optimizer {
+	return &Optimizer{distances: distances}
+}
+
+// 最近傍法で訪問順を並び替え、合計移動時間とともに返す
+// 座標を持たない注文は元の順序のまま末尾に回す
+func (o *Optimizer) Optimize(ctx context.Context, orders []model.Order) ([]model.Order, time.Duration, error) {
+	var located, unlocated []model.Order
+	for _, order := range orders {
+		if order.Latitude != nil && order.Longitude != nil {
+			located = append(located, order)
+		} else {
+			unlocated = append(unlocated, order)
+		}
+	}
+	if len(located) <= 1 {
+		return orders, 0, nil
+	}
+
+	// ループ内で1ペアずつ問い合わせないよう、全ペアを先にまとめて読み込む
+	if loader, ok := o.distances.(BatchLoader); ok {
+		coords := make([]model.Coordinates, len(located))
+		for i, order := range located {
+			coords[i] = coordinatesOf(order)
+		}
+		if err := loader.Preload(ctx, coords); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	route := make([]model.Order, 0, len(orders))
+	visited := make([]bool, len(located))
+	current := 0
+	visited[0] = true
+	route = append(route, located[0])
+
+	var total time.Duration
+	for len(route) < len(located) {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		from := coordinatesOf(located[current])
+		next := -1
+		var nextTime time.Duration
+		for i, candidate := range located {
+			if visited[i] {
+				continue
+			}
+			d, err := o.distances.TravelTime(ctx, from, coordinatesOf(candidate))
+			if err != nil {
+				return nil, 0, err
+			}
+			if next == -1 || d < nextTime {
+				next = i
+				nextTime = d
+			}
+		}
+		visited[next] = true
+		route = append(route, located[next])
+		total += nextTime
+		current = next
+	}
+
+	return append(route, unlocated...), total, nil
+}
+
+func coordinatesOf(order model.Order) model.Coordinates {
+	return model.Coordinates{Latitude: *order.Latitude, Longitude: *order.Longitude}
+}
@@ -0,0 +1,104 @@
+// 重み付きの無作為抽出（Walkerのエイリアス法）
+// 表の作成はO(n)、1回の抽出はO(1)で、重みに比例した確率で添字を選ぶ
+package sampling
+
+import "math/rand/v2"
+
+// 作成後は読み取りのみのため、複数のgoroutineから同時に抽出してよい
+type Alias struct {
+	prob  []float64
+	alias []int
+}
+
+// weightsの各要素を重みに比例した確率で選ぶ表を作る
+// 負の重みは0として扱う。要素がない場合・重みの合計が0の場合はnil
+func NewAlias(weights []float64) *Alias {
+	n := len(weights)
+	sum := 0.0
+	for _, w := range weights {
+		sum += max(w, 0)
+	}
+	if n == 0 || sum <= 0 {
+		return nil
+	}
+
+	// 平均が1になるよう重みを拡大し、1未満（small）と1以上（large）に分ける
+	scaled := make([]float64, n)
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for i, w := range weights {
+		scaled[i] = max(w, 0) * float64(n) / sum
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	// smallの不足分をlargeから1つずつ補う
+	a := &Alias{prob: make([]float64, n), alias: make([]int, n)}
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+		a.prob[s] = scaled[s]
+		a.alias[s] = l
+		scaled[l] += scaled[s] - 1
+		if scaled[l] < 1 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+	// 残りは浮動小数点の誤差を除けばちょうど1
+	for _, i := range large {
+		a.prob[i] = 1
+	}
+	for _, i := range small {
+		a.prob[i] = 1
+	}
+	return a
+}
+
+func (a *Alias) Len() int {
+	return len(a.prob)
+}
+
+// 重みに比例した確率で添字を1つ選ぶ
+func (a *Alias) Sample() int {
+	i := rand.IntN(len(a.prob))
+	if rand.Float64() < a.prob[i] {
+		return i
+	}
+	return a.alias[i]
+}
+
+// 重複しない添字を最大n個選ぶ（重みの大きいものほど先に選ばれやすい）
+// 重みが一部に偏っていてn*maxAttemptsFactor回の抽出で集まらない場合、残りは重みによらず選ぶ
+func (a *Alias) SampleDistinct(n int) []int {
+	n = min(n, len(a.prob))
+	picked := make([]int, 0, n)
+	seen := make(map[int]struct{}, n)
+	pick := func(i int) {
+		if _, ok := seen[i]; !ok {
+			seen[i] = struct{}{}
+			picked = append(picked, i)
+		}
+	}
+	for attempts := 0; len(picked) < n && attempts < n*maxAttemptsFactor; attempts++ {
+		pick(a.Sample())
+	}
+	for attempts := 0; len(picked) < n && attempts < n*maxAttemptsFactor; attempts++ {
+		pick(rand.IntN(len(a.prob)))
+	}
+	// nが要素数に近い場合は無作為な位置から順に埋める
+	start := rand.IntN(len(a.prob))
+	for j := 0; len(picked) < n; j++ {
+		pick((start + j) % len(a.prob))
+	}
+	return picked
+}
+
+// SampleDistinctで1個あたりに試す抽出の回数
+const maxAttemptsFactor = 20
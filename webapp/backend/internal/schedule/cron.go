@@ -0,0 +1,164 @@
+// 定期実行する処理の実行予定（cron形式）と、予定に従って処理を実行するスケジューラー
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSpec = errors.New("invalid schedule spec")
+
+// 実行予定
+type Schedule interface {
+	// afterより後の次の実行時刻（予定がない場合はゼロ値）
+	Next(after time.Time) time.Time
+}
+
+// 実行予定を読み取る
+// "分 時 日 月 曜日"のcron形式（*・範囲a-b・間隔/n・カンマ区切りのリスト）のほか、
+// "@every 10s"（前回の予定からの間隔）・"@hourly"・"@daily"を受け付ける
+// cron形式の時刻はローカルタイムで解釈する
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+		}
+		return Every(d), nil
+	case spec == "@hourly":
+		spec = "0 * * * *"
+	case spec == "@daily":
+		spec = "0 0 * * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q must have 5 fields", ErrInvalidSpec, spec)
+	}
+	var s cronSchedule
+	var err error
+	for i, f := range []struct {
+		dst      *bits
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	} {
+		if *f.dst, err = parseField(fields[i], f.min, f.max); err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSpec, spec, err)
+		}
+	}
+	// 曜日の7は日曜日（0）
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+// 一定間隔の実行予定
+type Every time.Duration
+
+func (e Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// 値ごとのビット
+type bits uint64
+
+func (b bits) has(v int) bool {
+	return b&(1<<uint(v)) != 0
+}
+
+func parseField(field string, min, max int) (bits, error) {
+	var b bits
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				// "5/15"は5から最大値まで15ごと
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			b |= 1 << uint(v)
+		}
+	}
+	return b, nil
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow bits
+	// 日・曜日が*か（両方とも指定された場合はどちらかに一致すればよい）
+	domAny, dowAny bool
+}
+
+// 予定が見つからない場合に探す期間
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func (s cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !s.month.has(int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case !s.hour.has(t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom.has(t.Day())
+	dow := s.dow.has(int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"backend/internal/metrics"
+	"backend/internal/task"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errPanicked = errors.New("job panicked")
+
+// 定期実行する処理
+type Job struct {
+	Name string
+	// 実行予定（Parseの形式）
+	Spec string
+	// 各回の開始を0からJitterの間でずらす（複数インスタンスが同時にDBへ負荷をかけないようにする）
+	Jitter time.Duration
+	// 予定とは別に、起動直後にも1回実行する
+	RunAtStart bool
+	// 1回の実行の期限（0の場合はtask.RunTimeout）
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type scheduledJob struct {
+	Job
+	schedule Schedule
+	stats    *metrics.JobCounter
+	running  atomic.Bool
+}
+
+// 登録した処理を実行予定に従って実行する
+// 前回の実行が終わっていない処理は重ねて実行せず、その回を飛ばす
+// 処理ごとの実行回数・失敗数・所要時間はmetrics.JobStatsで参照できる
+type Scheduler struct {
+	jobs []*scheduledJob
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// 処理を登録する（Runより前に呼ぶこと）
+func (s *Scheduler) Add(job Job) error {
+	schedule, err := Parse(job.Spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	s.jobs = append(s.jobs, &scheduledJob{Job: job, schedule: schedule, stats: metrics.Job(job.Name)})
+	return nil
+}
+
+// ctxがキャンセルされるまで処理を実行する（呼び出し元をブロックする）
+// キャンセル後は実行中の処理の終了を待って戻る
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.loop(ctx, &wg)
+		}()
+	}
+	<-ctx.Done()
+	wg.Wait()
+}
+
+func (j *scheduledJob) loop(ctx context.Context, wg *sync.WaitGroup) {
+	if j.RunAtStart {
+		j.fire(ctx, wg)
+	}
+	next := j.schedule.Next(time.Now())
+	for !next.IsZero() {
+		j.stats.Scheduled(j.Spec, next)
+		timer := time.NewTimer(time.Until(next) + j.jitter())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		j.fire(ctx, wg)
+		// スリープ復帰などで予定から大きく遅れた場合は、過ぎた回をまとめて飛ばす
+		if next = j.schedule.Next(next); !next.IsZero() && next.Before(time.Now()) {
+			next = j.schedule.Next(time.Now())
+		}
+	}
+}
+
+func (j *scheduledJob) jitter() time.Duration {
+	if j.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(j.Jitter)
+}
+
+// 前回の実行が終わっていなければ飛ばし、終わっていればgoroutineで実行する
+func (j *scheduledJob) fire(ctx context.Context, wg *sync.WaitGroup) {
+	if !j.running.CompareAndSwap(false, true) {
+		j.stats.Skipped()
+		return
+	}
+	wg.Add(1)
+	task.Go(ctx, j.Name, func(ctx context.Context) {
+		defer wg.Done()
+		defer j.running.Store(false)
+		started := time.Now()
+		j.stats.Started(started)
+		// panicした場合（task.Goがログに出して回復する）も失敗として記録する
+		err := errPanicked
+		defer func() { j.stats.Finished(time.Since(started), err) }()
+		timeout := j.Timeout
+		if timeout <= 0 {
+			timeout = task.RunTimeout
+		}
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err = j.Run(runCtx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[%s] %v", j.Name, err)
+		}
+	})
+}
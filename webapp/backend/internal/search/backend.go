@@ -0,0 +1,12 @@
+package search
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+// MySQL以外の商品検索エンジン
+// あいまい検索やファセット集計などMySQLが苦手な検索に使用する
+type Backend interface {
+	SearchProducts(ctx context.Context, req model.ListRequest) (*model.ProductList, error)
+}
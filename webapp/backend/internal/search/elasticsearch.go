@@ -0,0 +1,242 @@
+package search
+
+import (
+	"backend/internal/model"
+	"backend/internal/telemetry"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Elasticsearch / OpenSearch のREST APIを利用する検索バックエンド
+type Elasticsearch struct {
+	baseURL string
+	index   string
+	client  *http.Client
+}
+
+func NewElasticsearch(baseURL, index string) *Elasticsearch {
+	return &Elasticsearch{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		index:   index,
+		client:  telemetry.NewHTTPClient(5 * time.Second),
+	}
+}
+
+var productIndexMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"product_id":      map[string]string{"type": "integer"},
+			"name":            map[string]interface{}{"type": "text", "fields": map[string]interface{}{"keyword": map[string]string{"type": "keyword"}}},
+			"description":     map[string]string{"type": "text"},
+			"value":           map[string]string{"type": "integer"},
+			"weight":          map[string]string{"type": "integer"},
+			"image":           map[string]interface{}{"type": "keyword", "index": false},
+			"category":        map[string]string{"type": "keyword"},
+			"available_from":  map[string]string{"type": "date"},
+			"available_until": map[string]string{"type": "date"},
+		},
+	},
+}
+
+// ソート可能なフィールドとインデックス上のフィールド名の対応
+var sortFields = map[string]string{
+	"product_id": "product_id",
+	"name":       "name.keyword",
+	"value":      "value",
+	"weight":     "weight",
+}
+
+func (e *Elasticsearch) SearchProducts(ctx context.Context, req model.ListRequest) (*model.ProductList, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if req.Search != "" {
+		terms := req.SearchTerms
+		if len(terms) == 0 {
+			terms = []string{req.Search}
+		}
+		should := make([]interface{}, len(terms))
+		for i, term := range terms {
+			match := map[string]interface{}{
+				"query":  term,
+				"fields": []string{"name^2", "description"},
+			}
+			if req.Fuzzy {
+				match["fuzziness"] = "AUTO"
+			}
+			should[i] = map[string]interface{}{"multi_match": match}
+		}
+		query = map[string]interface{}{"bool": map[string]interface{}{"should": should, "minimum_should_match": 1}}
+	}
+	// 注文できる期間外の商品は除く（期間のない商品はフィールドを持たないため除かれない）
+	query = map[string]interface{}{"bool": map[string]interface{}{
+		"must": query,
+		"must_not": []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"available_from": map[string]string{"gt": "now"}}},
+			map[string]interface{}{"range": map[string]interface{}{"available_until": map[string]string{"lte": "now"}}},
+		},
+	}}
+
+	sortField, ok := sortFields[req.SortField]
+	if !ok {
+		sortField = "product_id"
+	}
+	order := "asc"
+	if strings.EqualFold(req.SortOrder, "desc") {
+		order = "desc"
+	}
+
+	body := map[string]interface{}{
+		"from":             req.Offset,
+		"size":             req.PageSize,
+		"track_total_hits": true,
+		"query":            query,
+		"sort": []interface{}{
+			map[string]string{sortField: order},
+			map[string]string{"product_id": "asc"},
+		},
+	}
+	if req.Facets {
+		body["aggs"] = map[string]interface{}{
+			"category": map[string]interface{}{"terms": map[string]interface{}{"field": "category", "size": 50}},
+		}
+	}
+
+	var resp struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source model.Product `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	if err := e.do(ctx, http.MethodPost, "/"+e.index+"/_search", "application/json", body, &resp); err != nil {
+		return nil, err
+	}
+
+	list := &model.ProductList{
+		Data:  make([]model.Product, len(resp.Hits.Hits)),
+		Total: resp.Hits.Total.Value,
+	}
+	for i, hit := range resp.Hits.Hits {
+		list.Data[i] = hit.Source
+	}
+	if len(resp.Aggregations) > 0 {
+		list.Facets = make(map[string][]model.FacetBucket, len(resp.Aggregations))
+		for name, agg := range resp.Aggregations {
+			buckets := make([]model.FacetBucket, len(agg.Buckets))
+			for i, b := range agg.Buckets {
+				buckets[i] = model.FacetBucket{Value: b.Key, Count: b.DocCount}
+			}
+			list.Facets[name] = buckets
+		}
+	}
+	return list, nil
+}
+
+// インデックスが存在しなければマッピング付きで作成し、作成したかどうかを返す
+func (e *Elasticsearch) EnsureIndex(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, e.baseURL+"/"+e.index, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return false, nil
+	}
+	if err := e.do(ctx, http.MethodPut, "/"+e.index, "application/json", productIndexMapping, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 商品の登録・更新と削除をBulk APIでまとめて反映する
+func (e *Elasticsearch) Bulk(ctx context.Context, upserts []model.Product, deletes []int) error {
+	if len(upserts) == 0 && len(deletes) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, p := range upserts {
+		meta := map[string]interface{}{"index": map[string]string{"_index": e.index, "_id": strconv.Itoa(p.ProductID)}}
+		if err := enc.Encode(meta); err != nil {
+			return err
+		}
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	for _, id := range deletes {
+		meta := map[string]interface{}{"delete": map[string]string{"_index": e.index, "_id": strconv.Itoa(id)}}
+		if err := enc.Encode(meta); err != nil {
+			return err
+		}
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+	}
+	if err := e.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &buf, &resp); err != nil {
+		return err
+	}
+	if resp.Errors {
+		return fmt.Errorf("elasticsearch bulk request reported item errors")
+	}
+	return nil
+}
+
+// bodyがio.Readerの場合はそのまま、それ以外はJSONにして送信する
+func (e *Elasticsearch) do(ctx context.Context, method, path, contentType string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("elasticsearch %s %s returned status %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
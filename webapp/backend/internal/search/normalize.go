@@ -0,0 +1,26 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// 検索語を比較可能な形に正規化する
+//   - 全角英数記号は半角に、半角カナは全角に揃える
+//   - ひらがなはカタカナに揃える
+//   - 英字は小文字にする
+//   - 連続する空白は1つにまとめる
+//
+// 例: "ＰＣ" と "pc"、"ぱそこん" と "ﾊﾟｿｺﾝ" はそれぞれ同じ文字列になる
+func Normalize(s string) string {
+	s = width.Fold.String(s)
+	s = strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + ('ァ' - 'ぁ')
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/task"
+	"context"
+	"log"
+	"time"
+)
+
+const syncBatchSize = 500
+
+type ProductSource interface {
+	FindByIDs(ctx context.Context, productIDs []int) ([]model.Product, error)
+	ListAfter(ctx context.Context, afterID, limit int) ([]model.Product, error)
+}
+
+type Outbox interface {
+	Fetch(ctx context.Context, limit int) ([]model.OutboxEntry, error)
+	Delete(ctx context.Context, ids []int64) error
+}
+
+// アウトボックスに記録された商品変更を検索インデックスへ反映し、変更イベントとして発行する
+// 検索インデックスがnilの場合はイベントの発行のみ行う
+type Syncer struct {
+	index    *Elasticsearch
+	products ProductSource
+	outbox   Outbox
+	events   events.Publisher
+}
+
+func NewSyncer(index *Elasticsearch, products ProductSource, outbox Outbox, pub events.Publisher) *Syncer {
+	return &Syncer{index: index, products: products, outbox: outbox, events: pub}
+}
+
+// インデックスを用意し（新規作成時は全件投入）、以後はctxがキャンセルされるまで
+// 一定間隔でアウトボックスを反映する
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	if s.index != nil {
+		created, err := s.index.EnsureIndex(ctx)
+		if err != nil {
+			log.Printf("[SearchSync] インデックス作成失敗: %v", err)
+		} else if created {
+			if err := s.Reindex(ctx); err != nil {
+				log.Printf("[SearchSync] 全件インデックス失敗: %v", err)
+			}
+		}
+	}
+
+	task.Loop(ctx, "SearchSync", interval, s.SyncOnce)
+}
+
+// 全商品をインデックスに投入する
+func (s *Syncer) Reindex(ctx context.Context) error {
+	afterID := 0
+	for {
+		products, err := s.products.ListAfter(ctx, afterID, syncBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return nil
+		}
+		if err := s.index.Bulk(ctx, products, nil); err != nil {
+			return err
+		}
+		afterID = products[len(products)-1].ProductID
+	}
+}
+
+// アウトボックスの変更を1バッチ分反映する
+func (s *Syncer) SyncOnce(ctx context.Context) error {
+	entries, err := s.outbox.Fetch(ctx, syncBatchSize)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	ids := make([]int64, len(entries))
+	seen := make(map[int]bool, len(entries))
+	var productIDs []int
+	var changed []int64
+	for i, e := range entries {
+		ids[i] = e.ID
+		if !seen[e.ProductID] {
+			seen[e.ProductID] = true
+			productIDs = append(productIDs, e.ProductID)
+			changed = append(changed, int64(e.ProductID))
+		}
+	}
+
+	if s.index != nil {
+		if err := s.index.syncProducts(ctx, s.products, productIDs); err != nil {
+			return err
+		}
+	}
+	s.events.Publish(events.Event{Topic: events.ProductChanged, IDs: changed})
+	return s.outbox.Delete(ctx, ids)
+}
+
+// 商品が存在すれば登録・更新し、存在しなければ削除として扱う
+func (e *Elasticsearch) syncProducts(ctx context.Context, source ProductSource, productIDs []int) error {
+	products, err := source.FindByIDs(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+	found := make(map[int]bool, len(products))
+	for _, p := range products {
+		found[p.ProductID] = true
+	}
+	var deletes []int
+	for _, id := range productIDs {
+		if !found[id] {
+			deletes = append(deletes, id)
+		}
+	}
+
+	return e.Bulk(ctx, products, deletes)
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"backend/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+type SynonymSource interface {
+	ListSynonyms(ctx context.Context) ([]model.Synonym, error)
+}
+
+// 検索語を同義語に展開する
+// 同義語表はTTLの間メモリに保持する
+type SynonymExpander struct {
+	source   SynonymSource
+	ttl      time.Duration
+	mutex    sync.RWMutex
+	groups   map[string][]string
+	loadedAt time.Time
+}
+
+func NewSynonymExpander(source SynonymSource, ttl time.Duration) *SynonymExpander {
+	return &SynonymExpander{source: source, ttl: ttl}
+}
+
+// 正規化済みの検索語を、自身を先頭とした同義語のリストに展開する
+// 同義語が登録されていない場合は検索語のみを返す
+func (e *SynonymExpander) Expand(ctx context.Context, term string) ([]string, error) {
+	groups, err := e.table(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := []string{term}
+	for _, synonym := range groups[term] {
+		if synonym != term {
+			terms = append(terms, synonym)
+		}
+	}
+	return terms, nil
+}
+
+func (e *SynonymExpander) table(ctx context.Context) (map[string][]string, error) {
+	e.mutex.RLock()
+	if !e.loadedAt.IsZero() && time.Since(e.loadedAt) <= e.ttl {
+		groups := e.groups
+		e.mutex.RUnlock()
+		return groups, nil
+	}
+	e.mutex.RUnlock()
+
+	list, err := e.source.ListSynonyms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byGroup := make(map[int][]string)
+	for _, s := range list {
+		byGroup[s.GroupID] = append(byGroup[s.GroupID], Normalize(s.Term))
+	}
+	groups := make(map[string][]string)
+	for _, terms := range byGroup {
+		for _, term := range terms {
+			groups[term] = append(groups[term], terms...)
+		}
+	}
+
+	e.mutex.Lock()
+	e.groups, e.loadedAt = groups, time.Now()
+	e.mutex.Unlock()
+	return groups, nil
+}
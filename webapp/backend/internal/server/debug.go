@@ -0,0 +1,61 @@
+package server
+
+import (
+	"backend/internal/lifecycle"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+)
+
+// プロファイリング用のデバッグサーバーの設定（DEBUG_PORTを設定した場合のみ起動する）
+//
+//	DEBUG_PORT:           /debug/pprof/ を提供するポート（アプリケーションのポートとは別）
+//	DEBUG_BIND:           待ち受けるアドレス（デフォルト: 127.0.0.1。外部から取る場合のみ変える）
+//	PPROF_BLOCK_RATE:     ブロッキングを記録する間隔（ナノ秒、0で記録しない）
+//	PPROF_MUTEX_FRACTION: ロックの競合を記録する割合（1/n件、0で記録しない）
+//
+// 例: go tool pprof http://127.0.0.1:6060/debug/pprof/profile?seconds=30
+func newDebugComponent(port string) lifecycle.Hook {
+	bind := os.Getenv("DEBUG_BIND")
+	if bind == "" {
+		bind = "127.0.0.1"
+	}
+	addr := net.JoinHostPort(bind, port)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// CPUプロファイル・トレースは指定した秒数だけかかるため、書き込みのタイムアウトは設けない
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	return lifecycle.Hook{
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen for debug server on %s: %w", addr, err)
+			}
+			runtime.SetBlockProfileRate(envInt("PPROF_BLOCK_RATE", 0))
+			runtime.SetMutexProfileFraction(envInt("PPROF_MUTEX_FRACTION", 0))
+			log.Printf("Starting debug server on %s", addr)
+			go func() {
+				if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("Debug server stopped: %v", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			// 取得中のプロファイルは待たずに閉じる
+			return srv.Close()
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// デモモードで作る利用者・商品の数
+const (
+	demoUserCount    = 3
+	demoProductCount = 50
+)
+
+// DEMO_MODE=1 の場合、MySQLに接続せず、ユーザー・商品・注文・セッションをメモリ上に保持して起動する（動作確認用）
+// demo1〜demo3でログインできる（パスワードはDEMO_PASSWORD、未設定の場合はpassword）
+// 送料・税率の表は空（送料無料・非課税）として扱い、それ以外のDBを使うAPI（管理APIの集計など）はエラーを返す
+// 再起動するとデータは失われる
+func demoMode() bool {
+	return os.Getenv("DEMO_MODE") == "1"
+}
+
+func newDemoStore(pub events.Publisher) (*repository.Store, error) {
+	password := os.Getenv("DEMO_PASSWORD")
+	if password == "" {
+		password = "password"
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the demo password: %w", err)
+	}
+
+	var fixtures repository.MemoryFixtures
+	for i := 1; i <= demoUserCount; i++ {
+		fixtures.Users = append(fixtures.Users, model.User{UserID: i, UserName: fmt.Sprintf("demo%d", i), PasswordHash: string(hash)})
+	}
+	for i := 1; i <= demoProductCount; i++ {
+		fixtures.Products = append(fixtures.Products, model.Product{
+			ProductID:   i,
+			Name:        fmt.Sprintf("Demo product %02d", i),
+			Value:       100 * (i%10 + 1),
+			Weight:      i%7 + 1,
+			Description: "Sample product served in demo mode",
+		})
+	}
+	return repository.NewMemoryStore(pub, fixtures), nil
+}
+
+// 空の送料・税率の表（デモモード用）
+type emptyRateTables struct{}
+
+func (emptyRateTables) ListZones(context.Context) ([]model.ShippingZone, error) { return nil, nil }
+func (emptyRateTables) ListRates(context.Context) ([]model.ShippingRate, error) { return nil, nil }
+func (emptyRateTables) ListTaxRates(context.Context) ([]model.TaxRate, error)   { return nil, nil }
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 環境変数から整数を読み込む（未設定・不正な値の場合はデフォルト値）
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a valid integer. Using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// 環境変数から時間を読み込む（未設定・不正な値の場合はデフォルト値）
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a valid duration. Using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// 環境変数からカンマ区切りのリストを読み込む（未設定の場合はnil）
+func envList(key string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// 環境変数からカンマ区切りの整数のリストを読み込む（未設定・不正な値を含む場合はデフォルト値）
+func envIntList(key string, def []int) []int {
+	list := envList(key)
+	if list == nil {
+		return def
+	}
+	values := make([]int, len(list))
+	for i, v := range list {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			log.Printf("Warning: %s=%q is not a valid integer list. Using default %v", key, os.Getenv(key), def)
+			return def
+		}
+		values[i] = n
+	}
+	return values
+}
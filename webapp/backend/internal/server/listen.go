@@ -0,0 +1,70 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLS・HTTP/2の設定（いずれも未設定の場合は従来どおり平文のHTTP/1.1）
+//
+//	TLS_CERT_FILE, TLS_KEY_FILE: 証明書と秘密鍵のパス
+//	TLS_AUTOCERT_DOMAINS:        Let's Encryptで証明書を取得するドメイン（カンマ区切り）
+//	TLS_AUTOCERT_CACHE_DIR:      取得した証明書の保存先（デフォルト: autocert-cache）
+//	H2C:                         "1"の場合、平文でのHTTP/2を受け付ける（内部ネットワーク向け）
+type listenConfig struct {
+	certFile        string
+	keyFile         string
+	autocertDomains []string
+	autocertCache   string
+	h2c             bool
+}
+
+func loadListenConfig() listenConfig {
+	cfg := listenConfig{
+		certFile:      os.Getenv("TLS_CERT_FILE"),
+		keyFile:       os.Getenv("TLS_KEY_FILE"),
+		autocertCache: os.Getenv("TLS_AUTOCERT_CACHE_DIR"),
+		h2c:           os.Getenv("H2C") == "1",
+	}
+	for _, d := range strings.Split(os.Getenv("TLS_AUTOCERT_DOMAINS"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			cfg.autocertDomains = append(cfg.autocertDomains, d)
+		}
+	}
+	if cfg.autocertCache == "" {
+		cfg.autocertCache = "autocert-cache"
+	}
+	return cfg
+}
+
+// 設定に応じてHTTPS（HTTP/2対応）、h2c、HTTP/1.1のいずれかで待ち受ける
+func listenAndServe(srv *http.Server, cfg listenConfig) error {
+	switch {
+	case len(cfg.autocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.autocertDomains...),
+			Cache:      autocert.DirCache(cfg.autocertCache),
+		}
+		// TLS-ALPN-01チャレンジで検証するため、TLSの待ち受けのみで証明書を取得できる
+		srv.TLSConfig = m.TLSConfig()
+		log.Printf("Serving HTTPS with autocert for %s", strings.Join(cfg.autocertDomains, ", "))
+		return srv.ListenAndServeTLS("", "")
+	case cfg.certFile != "" || cfg.keyFile != "":
+		log.Printf("Serving HTTPS with certificate %s", cfg.certFile)
+		return srv.ListenAndServeTLS(cfg.certFile, cfg.keyFile)
+	case cfg.h2c:
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = &protocols
+		log.Printf("Serving HTTP/1.1 and h2c")
+		return srv.ListenAndServe()
+	default:
+		return srv.ListenAndServe()
+	}
+}
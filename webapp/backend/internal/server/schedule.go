@@ -0,0 +1,56 @@
+package server
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/schedule"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// 定期実行する処理をスケジューラーに登録する
+// 実行予定はSCHEDULE_<処理名>（処理名は大文字・アンダースコア区切り。例: SCHEDULE_PRODUCT_STATS="*/5 * * * *"）で変更でき、
+// offを指定すると実行しない。不正な値の場合は既定の予定を使う
+func newScheduler(jobs ...schedule.Job) *schedule.Scheduler {
+	scheduler := schedule.New()
+	for _, job := range jobs {
+		key := "SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(job.Name, "-", "_"))
+		spec := job.Spec
+		if v := os.Getenv(key); v != "" {
+			if _, err := schedule.Parse(v); err != nil && v != "off" {
+				log.Printf("Warning: %s=%q is not a valid schedule. Using default %q", key, v, spec)
+			} else {
+				spec = v
+			}
+		}
+		if spec == "off" {
+			log.Printf("[Scheduler] %s is disabled", job.Name)
+			continue
+		}
+		job.Spec = spec
+		if err := scheduler.Add(job); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return scheduler
+}
+
+// 間隔の設定値を実行予定にする（0以下の場合は実行しない）
+func everySpec(interval time.Duration) string {
+	if interval <= 0 {
+		return "off"
+	}
+	return "@every " + interval.String()
+}
+
+// 読み取り専用モードの間は実行を見送る（DBに書き込む処理に使う）
+func unlessReadOnly(readOnly *middleware.ReadOnlyMode, run func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if readOnly.Enabled() {
+			return nil
+		}
+		return run(ctx)
+	}
+}
@@ -1,53 +1,420 @@
 package server
 
 import (
+	"backend/internal/cache"
+	"backend/internal/canary"
 	"backend/internal/db"
+	"backend/internal/events"
+	"backend/internal/geocode"
+	"backend/internal/grpcapi"
 	"backend/internal/handler"
+	"backend/internal/lifecycle"
+	"backend/internal/memory"
+	"backend/internal/metrics"
 	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/planner"
+	"backend/internal/redact"
 	"backend/internal/repository"
+	"backend/internal/routing"
+	"backend/internal/schedule"
+	"backend/internal/search"
 	"backend/internal/service"
-	"log"
+	"backend/internal/shadow"
+	"backend/internal/shipping"
+	"backend/internal/startup"
+	"backend/internal/tax"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
 	"github.com/riandyrn/otelchi"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
 	Router *chi.Mux
+	// バックグラウンドで動くコンポーネント（Runで起動、Shutdownで停止）
+	Lifecycle *lifecycle.Registry
+	// HTTPの受付前に完了させる初期化処理
+	Startup *startup.Sequencer
+	// 停止時に閉じる注文ステータスのストリーム
+	statusStream *service.OrderStatusStream
 }
 
 // 実質ここがアプリケーションのエントリポイント
 func NewServer() (*Server, *sqlx.DB, error) {
-	dbConn, err := db.InitDBConnection()
+	// 埋め込んだOpenAPI定義の読み込みに失敗した場合に接続を閉じずに済むよう、DBに接続する前に読み込む
+	validator, err := openapi.Load()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	store := repository.NewStore(dbConn)
+	// リポジトリの変更をキャッシュ層に通知するイベントバス
+	bus := events.NewBus()
+	// デモモードではDBに接続しない（dbConnはnil）
+	var dbConn *sqlx.DB
+	var primary repository.DBTX
+	var store *repository.Store
+	demo := demoMode()
+	if demo {
+		slog.Warn("DEMO_MODE=1: serving in-memory demo data without MySQL. Data is lost on restart")
+		if store, err = newDemoStore(bus); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if dbConn, err = db.InitDBConnection(); err != nil {
+			return nil, nil, err
+		}
+		// フェイルオーバーによるエラーを検知したら接続プールを作り直す
+		failover := db.NewFailoverMonitor(dbConn, time.Second)
+		primary = repository.ObserveErrors(dbConn, failover.Observe)
+		store = repository.NewStore(primary, bus)
+	}
+	// 初期化に失敗した場合に接続を閉じる
+	closeDB := func() {
+		if dbConn != nil {
+			dbConn.Close()
+		}
+	}
+	components := lifecycle.NewRegistry()
+	// SESSION_REDIS_URLが設定されている場合、セッションをRedisに保存して複数インスタンスで共有する
+	redisSessions, err := newRedisSessions()
+	if err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	if redisSessions != nil {
+		store.SessionRepo = redisSessions
+		components.Register("session-redis", lifecycle.Hook{OnStop: func(context.Context) error {
+			return redisSessions.Close()
+		}})
+	}
+	// 認証のキャッシュミスが集中した際のDB往復を減らす
+	store.SessionRepo.EnableLookupBatching(2*time.Millisecond, 100)
+	// SESSION_WRITE_BEHIND=1 の場合、ログイン時のセッションのINSERTを後回しにし、
+	// SESSION_WRITE_BEHIND_INTERVAL毎（またはSESSION_WRITE_BEHIND_BATCH件溜まった時）にまとめて書き込む（MySQLのセッションストアのみ）
+	// 書き込む前のセッションはこのプロセスにしかないため、異常終了すると失われ（再ログインが必要）、他のインスタンスからは書き込まれるまで見えない
+	// ログイン時にプライマリDBへ書き込むか（読み取り専用モードでログインを止めるかの判断に使う）
+	loginWritesDB := redisSessions == nil && !demo
+	if sessions, ok := store.SessionRepo.(*repository.SessionRepository); ok && os.Getenv("SESSION_WRITE_BEHIND") == "1" {
+		loginWritesDB = false
+		sessions.EnableWriteBehind(envInt("SESSION_WRITE_BEHIND_BATCH", 500))
+		interval := envDuration("SESSION_WRITE_BEHIND_INTERVAL", 100*time.Millisecond)
+		components.Register("session-write-behind", lifecycle.NewBackground("SessionWriteBehind", func(ctx context.Context) {
+			sessions.RunWriteBehind(ctx, interval)
+		}))
+	}
+	// フェイルオーバー中に失敗した冪等な書き込みを、復旧後に再実行する
+	retryQueue := db.NewRetryQueue(envInt("DB_RETRY_QUEUE_SIZE", 10000), envDuration("DB_RETRY_MAX_AGE", 5*time.Minute))
+	components.Register("db-retry-queue", lifecycle.NewBackground("RetryQueue", func(ctx context.Context) {
+		retryQueue.Run(ctx, time.Second)
+	}))
+
+	// DATABASE_READ_URLが設定されている場合、商品一覧・注文一覧・配送待ち注文の取得をレプリカから読む
+	// 遅延がDATABASE_READ_MAX_LAGを超えた・接続できない間はプライマリから読み（DATABASE_READ_CHECK_INTERVAL毎に確認）、
+	// 商品の変更・注文の作成からDATABASE_READ_AFTER_WRITEの間は、その一覧をプライマリから読む
+	// 起動シーケンスの読み込みはレプリカの確認を始める前に行うため、プライマリから読む
+	var readConn *sqlx.DB
+	if !demo {
+		if readConn, err = db.InitReadDBConnection(); err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+	}
+	var replica *db.ReplicaMonitor
+	if readConn != nil {
+		replica = db.NewReplicaMonitor(readConn, envDuration("DATABASE_READ_MAX_LAG", time.Second))
+		store.UseReadReplica(
+			repository.WithReplica(primary, readConn, replica.Available, replica.Observe),
+			envDuration("DATABASE_READ_AFTER_WRITE", 2*time.Second))
+		components.Register("read-replica-pool", lifecycle.Hook{OnStop: func(context.Context) error {
+			return readConn.Close()
+		}})
+		interval := envDuration("DATABASE_READ_CHECK_INTERVAL", time.Second)
+		components.Register("read-replica", lifecycle.NewBackground("ReadReplica", func(ctx context.Context) {
+			replica.Run(ctx, interval)
+		}))
+	}
+
+	var shippingRates shipping.RateSource = store.ShippingRepo
+	var taxRates tax.RateSource = store.TaxRepo
+	if demo {
+		shippingRates, taxRates = emptyRateTables{}, emptyRateTables{}
+	}
 
 	authService := service.NewAuthService(store)
 	orderService := service.NewOrderService(store)
-	productService := service.NewProductService(store)
-	robotService := service.NewRobotService(store)
+	// 配送待ち注文の価値密度順インデックス（注文作成と配送計画で共有）
+	density := planner.NewDensityIndex()
+	searchIndex := newSearchIndex()
+	productService := service.NewProductService(
+		store,
+		newGeocoder(),
+		shipping.NewTieredCalculator(shippingRates, time.Minute),
+		tax.NewRateTableEngine(taxRates, time.Minute),
+		search.NewSynonymExpander(store.SynonymRepo, 5*time.Minute),
+		searchBackend(searchIndex),
+		service.OrderLimits{
+			MaxQuantityPerRequest:  envInt("ORDER_MAX_QUANTITY_PER_REQUEST", 10000),
+			MaxQuantityPerUserHour: envInt("ORDER_MAX_QUANTITY_PER_USER_HOUR", 50000),
+		},
+		density,
+	)
+	orderService.EnableShadow(newShadow("order-cursor", "SHADOW_ORDER_CURSOR_PERCENT"))
+	productService.EnableShadow(newShadow("product-search-like", "SHADOW_PRODUCT_SEARCH_PERCENT"))
+	// 一部のユーザーだけ新しい実装に切り替え、コホートごとのレイテンシ・失敗数をダッシュボードで比べる
+	// 商品検索の切り替えを設定した場合、対象外のユーザーは従来のLIKE検索になる
+	orderService.EnableCanary(newCanary("order-cursor", "CANARY_ORDER_CURSOR_PERCENT"))
+	productService.EnableCanary(newCanary("product-search-fulltext", "CANARY_PRODUCT_FULLTEXT_PERCENT"))
+	// おすすめ商品は注文数にFEATURED_BASE_WEIGHTを加えた重みで選ぶ（注文のない商品もこの重みで選ばれる）
+	productService.SetFeaturedBaseWeight(float64(envInt("FEATURED_BASE_WEIGHT", 1)))
+	// 座標間の移動時間はメモリとDBにキャッシュし、1日で再計算する
+	distances := routing.NewCachedDistanceProvider(routing.NewHaversineProvider(0), store.DistanceRepo, 24*time.Hour)
+
+	fulfillmentSLA := envDuration("ORDER_FULFILLMENT_SLA", 24*time.Hour)
+
+	robotPositions := service.NewRobotPositions()
+	// 設定時のみ配送計画にクレームトークンを付け、ステータス報告時に照合する
+	var claims *service.ClaimSigner
+	if secret := os.Getenv("ROBOT_CLAIM_SECRET"); secret != "" {
+		claims = service.NewClaimSigner([]byte(secret))
+	}
+	planSignal := service.NewPlanSignal()
+	bus.Subscribe(events.OrderCreated, planSignal.OnOrderCreated)
+	bus.Subscribe(events.OrderStatusChanged, planSignal.OnOrderStatusChanged)
+	notifier := newNotifier()
+	robotService := service.NewRobotService(store, notifier, routing.NewOptimizer(distances), robotPositions, density, retryQueue, service.RobotServiceConfig{
+		FulfillmentSLA: fulfillmentSLA,
+		// 未設定の場合は受領確認を行わないロボットとの互換のためロールバックしない
+		PlanAckTimeout:  envDuration("ROBOT_PLAN_ACK_TIMEOUT", 0),
+		DeliveryTimeout: envDuration("ROBOT_DELIVERY_TIMEOUT", 0),
+		MinCapacity:     envInt("ROBOT_MIN_CAPACITY", 1),
+		MaxCapacity:     envInt("ROBOT_MAX_CAPACITY", 100000),
+		MaxDPCells:      envInt("ROBOT_MAX_DP_CELLS", 20000000),
+		PlanBudget:      envDuration("ROBOT_PLAN_BUDGET", 200*time.Millisecond),
+		// 設定時のみ、動的計画法がリクエストのデッドラインのこの時間前までに終わらなければ貪欲法に切り替える
+		GreedyFallbackReserve: envDuration("ROBOT_PLAN_GREEDY_FALLBACK_RESERVE", 0),
+		Claims:                claims,
+		WarmStart:             newWarmStart(),
+		Exclusions:            service.NewPlanExclusions(envDuration("ROBOT_PLAN_EXCLUSION_COOLDOWN", 10*time.Minute)),
+		Shadow:                newShadow("greedy-planner", "SHADOW_PLANNER_PERCENT"),
+		// /api/robot/plan/waitは積載量のこの割合の注文が溜まるまで待つ
+		PlanWaitMinLoadPercent: envInt("ROBOT_PLAN_WAIT_MIN_LOAD_PERCENT", 50),
+		PlanWaitMax:            envDuration("ROBOT_PLAN_WAIT_MAX", 25*time.Second),
+		PlanWaitPoll:           envDuration("ROBOT_PLAN_WAIT_POLL", 2*time.Second),
+		PlanSignal:             planSignal,
+	})
+
+	// 画像・商品一覧キャッシュの容量は、MEMORY_LIMIT_MB設定時にメモリ使用量に応じて縮める
+	// 破棄の方式はlru（デフォルト）・fifo・lfu・arcから選ぶ（方式ごとのヒット率は管理APIのキャッシュ統計で比べる）
+	// 大きな画像がまとめて保存されても、よく参照される画像が保存順で押し出されないようLRUをデフォルトにする
+	imagePolicy := cache.PolicyLRU
+	if v := os.Getenv("IMAGE_CACHE_POLICY"); v != "" {
+		if imagePolicy, err = cache.ParsePolicy(v); err != nil {
+			slog.Warn("Unknown IMAGE_CACHE_POLICY, using the default", "default", cache.PolicyLRU, "err", err)
+			imagePolicy = cache.PolicyLRU
+		}
+	}
+	imageCache := cache.NewImageCache(cache.DefaultImageBudget, time.Hour, imagePolicy)
+	// IMAGE_CACHE_GZIP=1の場合、PNG・GIFをgzipで圧縮して保持し、gzipを受け付けるクライアントにはそのまま返す
+	if os.Getenv("IMAGE_CACHE_GZIP") == "1" {
+		imageCache.EnableCompression(envInt("IMAGE_CACHE_GZIP_MAX_PERCENT", 90))
+	}
+	// IMAGE_DISK_CACHE_DIR（例: /tmp/imgcache）を設定すると、読み込んだ画像をそこにも保存し、メモリから破棄された画像・
+	// 再起動後にまだ読み込んでいない画像を画像ディレクトリより先にそこから読む（読んだ画像はメモリに戻す）
+	// 容量はIMAGE_DISK_CACHE_MB、保存から破棄までの時間はIMAGE_DISK_CACHE_TTL。ディレクトリを使えない場合はメモリのみで動く
+	if dir := os.Getenv("IMAGE_DISK_CACHE_DIR"); dir != "" {
+		disk, err := cache.NewImageDiskCache(dir, int64(envInt("IMAGE_DISK_CACHE_MB", 1024))<<20, envDuration("IMAGE_DISK_CACHE_TTL", 24*time.Hour), envInt("IMAGE_DISK_CACHE_QUEUE", 256))
+		if err != nil {
+			slog.Warn("Image disk cache disabled", "err", err)
+		} else {
+			imageCache.EnableDisk(disk)
+			components.Register("image-disk-cache", lifecycle.NewBackground("ImageDiskCache", disk.Run))
+			metrics.RegisterUsage("image_disk", disk.Usage)
+		}
+	}
+	// 画像ファイルが差し替えられたらキャッシュを破棄する
+	imageWatcher := cache.NewImageWatcher(handler.ImageDir, imageCache, envDuration("IMAGE_WATCH_POLL_INTERVAL", 30*time.Second))
+	components.Register("image-watcher", lifecycle.NewBackground("ImageWatcher", imageWatcher.Run))
+	metrics.RegisterUsage("image", imageCache.Usage)
+	metrics.RegisterUsage("product_list", store.ProductRepo.CacheUsage)
+	if limitMB := envInt("MEMORY_LIMIT_MB", 0); limitMB > 0 {
+		governor := memory.NewGovernor(int64(limitMB) << 20)
+		governor.Register("image", cache.DefaultImageBudget, imageCache)
+		governor.Register("product_list", repository.DefaultProductCacheBudget, store.ProductRepo)
+		components.Register("memory-governor", lifecycle.NewBackground("MemoryGovernor", func(ctx context.Context) {
+			governor.Run(ctx, 5*time.Second)
+		}))
+	}
+
+	// CACHE_SNAPSHOT_PATHを設定した場合、停止時に商品一覧・注文一覧の総件数のキャッシュでよく参照されているキーを保存し、
+	// 次の起動時にHTTPの受付前にそのクエリを実行し直してキャッシュを温める（CACHE_SNAPSHOT_MAX_AGEより古いものは使わない）
+	var cacheSnapshot *service.CacheSnapshotService
+	if path := os.Getenv("CACHE_SNAPSHOT_PATH"); path != "" {
+		cacheSnapshot = service.NewCacheSnapshotService(store, productService, path,
+			envInt("CACHE_SNAPSHOT_MAX_KEYS", 500), envDuration("CACHE_SNAPSHOT_MAX_AGE", 24*time.Hour), envInt("CACHE_SNAPSHOT_CONCURRENCY", 4))
+		components.Register("cache-snapshot", lifecycle.Hook{OnStop: cacheSnapshot.Save})
+	}
+
+	adminService := service.NewAdminService(store, distances, fulfillmentSLA, imageCache)
+	trackingService := service.NewTrackingService(store, robotPositions, envDuration("TRACKING_AVG_DELIVERY", 30*time.Minute))
+
+	preferenceService := service.NewPreferenceService(store, time.Minute)
 
 	authHandler := handler.NewAuthHandler(authService)
-	productHandler := handler.NewProductHandler(productService)
-	orderHandler := handler.NewOrderHandler(orderService)
+	// 画像取得のw（サムネイルの幅）に指定できる値
+	productHandler := handler.NewProductHandler(productService, preferenceService, imageCache, envIntList("IMAGE_THUMBNAIL_WIDTHS", []int{100, 200, 400}))
+	// 注文のステータス変更をSSEで接続中のユーザーに送る（ウォッチできる注文は1ユーザーあたりORDER_WATCH_LIMIT件まで）
+	statusStream := service.NewOrderStatusStream(store, envInt("ORDER_WATCH_LIMIT", 20))
+	components.Register("order-status-stream", lifecycle.NewBackground("OrderStatusStream", statusStream.Run))
+	bus.Subscribe(events.OrderStatusChanged, statusStream.OnOrderStatusChanged)
+	orderHandler := handler.NewOrderHandler(orderService, preferenceService, statusStream)
+	preferenceHandler := handler.NewPreferenceHandler(preferenceService)
 	robotHandler := handler.NewRobotHandler(robotService)
+	// 直近5分間のレイテンシを10秒単位で集計する
+	latencyWindow := 5 * time.Minute
+	latency := metrics.NewLatencyWindow(latencyWindow, 10*time.Second)
+	dbStats := func() sql.DBStats { return sql.DBStats{} }
+	if dbConn != nil {
+		dbStats = dbConn.Stats
+	}
+	dashboardService := service.NewDashboardService(store, robotPositions, latency, latencyWindow, dbStats)
+	if replica != nil {
+		dashboardService.EnableReadReplica(replica, readConn.Stats)
+	}
+	// READ_ONLY=1で起動すると読み取り専用モードで始める（管理APIの/api/admin/read-onlyで切り替えられる）
+	readOnly := middleware.NewReadOnlyMode(os.Getenv("READ_ONLY") == "1", os.Getenv("READ_ONLY_REASON"))
+	adminHandler := handler.NewAdminHandler(adminService, dashboardService, robotService, readOnly)
+	trackingHandler := handler.NewTrackingHandler(trackingService)
 
-	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo)
+	// SESSION_BIND_FINGERPRINT=1 の場合、ログイン時と異なるネットワーク・ブラウザからのセッション利用を拒否する
+	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo, os.Getenv("SESSION_BIND_FINGERPRINT") == "1")
 
 	robotAPIKey := os.Getenv("ROBOT_API_KEY")
 	if robotAPIKey == "" {
-		log.Println("Warning: ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
+		slog.Warn("ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
 		robotAPIKey = "test-robot-key"
 	}
 	robotAuthMW := middleware.RobotAuthMiddleware(robotAPIKey)
+	// 設定時のみロボットのリクエストに署名を求め、再送されたリクエストを拒否する
+	if secret := os.Getenv("ROBOT_SIGNING_SECRET"); secret != "" {
+		replayMW := middleware.RobotReplayProtectionMiddleware([]byte(secret), envDuration("ROBOT_SIGNATURE_WINDOW", time.Minute))
+		apiKeyMW := robotAuthMW
+		robotAuthMW = func(next http.Handler) http.Handler { return apiKeyMW(replayMW(next)) }
+	}
+	// GRPC_PORTを設定した場合、ロボット向けAPIをgRPCでも提供する（HTTPと同じAPIキーで認証する）
+	// リクエストの署名はgRPCでは検証しないため、ROBOT_SIGNING_SECRET設定時は起動しない
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		if os.Getenv("ROBOT_SIGNING_SECRET") != "" {
+			slog.Warn("gRPC robot API is disabled because ROBOT_SIGNING_SECRET requires signed HTTP requests")
+		} else {
+			components.Register("grpc", newGRPCComponent(grpcapi.NewServer(robotService, robotAPIKey, readOnly.Enabled), ":"+grpcPort))
+		}
+	}
+
+	// DEBUG_PORTを設定した場合、別のポートでpprofのプロファイルを提供する
+	if debugPort := os.Getenv("DEBUG_PORT"); debugPort != "" {
+		components.Register("debug", newDebugComponent(debugPort))
+	}
+
+	// ADMIN_API_KEYが未設定の場合は管理APIを公開しない（既定のキーで誰でも使えるようにしない）
+	// ADMIN_PII_API_KEYで認証した管理者のみ、個人情報を伏せ字にせずに参照できる
+	var adminAuthMW func(http.Handler) http.Handler
+	if adminAPIKey := os.Getenv("ADMIN_API_KEY"); adminAPIKey != "" {
+		adminAuthMW = middleware.AdminAuthMiddleware(adminAPIKey, os.Getenv("ADMIN_PII_API_KEY"))
+	} else {
+		slog.Warn("ADMIN_API_KEY is not set. /api/admin is disabled")
+	}
+	// 伏せ字にするフィールドはADMIN_REDACT_FIELDS（カンマ区切り）で変更できる
+	redactMW := middleware.RedactMiddleware(redact.NewPolicy(envList("ADMIN_REDACT_FIELDS")))
+
+	// クライアントIPはTRUSTED_PROXIES（カンマ区切りのCIDR・IPアドレス）からの接続の場合のみX-Real-IPを使う
+	// 未設定の場合は接続元のアドレスを使う（nginxを経由しない構成でX-Real-IPを偽ってIPごとの制限を回避されないようにする）
+	if err := middleware.SetTrustedProxies(envList("TRUSTED_PROXIES")); err != nil {
+		slog.Warn("Invalid TRUSTED_PROXIES. X-Real-IP is ignored", "err", err)
+	}
+	// 認証不要の追跡APIはトークン総当たりを防ぐためIPごとに制限する
+	trackingRateLimitMW := middleware.IPRateLimitMiddleware(1, 10)
+	// 一覧・集計などの重いAPIは1ユーザーの同時実行数を制限する（未設定の場合は制限しない）
+	heavyMW := middleware.UserConcurrencyLimitMiddleware(envInt("USER_HEAVY_CONCURRENCY", 0))
+	// 商品一覧・注文作成・ロボットAPIはグループごとにセッション（ロボットはIP）単位で流量を制限する（未設定の場合は制限しない）
+	// ロボットAPIは認証の前に制限するため、検証していないCookieではなくIPで制限する
+	routeLimits := routeRateLimits{
+		productList: middleware.SessionRateLimitMiddleware(float64(envInt("RATE_LIMIT_PRODUCT_LIST_RPS", 0)), envInt("RATE_LIMIT_PRODUCT_LIST_BURST", 0)),
+		orderCreate: middleware.SessionRateLimitMiddleware(float64(envInt("RATE_LIMIT_ORDER_RPS", 0)), envInt("RATE_LIMIT_ORDER_BURST", 0)),
+		robot:       middleware.GroupIPRateLimitMiddleware(float64(envInt("RATE_LIMIT_ROBOT_RPS", 0)), envInt("RATE_LIMIT_ROBOT_BURST", 0)),
+	}
+
+	// アウトボックスの商品変更を検索インデックス（設定時のみ）に反映し、変更イベントとして発行する
+	// デモモードではアウトボックスがないため動かさない
+	if !demo {
+		outboxSyncer := search.NewSyncer(searchIndex, store.ProductRepo, store.OutboxRepo, bus)
+		components.Register("product-outbox", lifecycle.NewBackground("SearchSync", func(ctx context.Context) {
+			outboxSyncer.Run(ctx, time.Second)
+		}))
+	}
+	bus.Subscribe(events.ProductChanged, func(events.Event) {
+		store.ProductRepo.InvalidateListCache()
+		store.OrderRepo.InvalidateSearchOrderCounts()
+	})
+	bus.Subscribe(events.OrderCreated, func(ev events.Event) {
+		store.OrderRepo.InvalidateOrderCounts(ev.UserID)
+	})
+	bus.Subscribe(events.OrderStatusChanged, density.OnOrderStatusChanged)
+	bus.Subscribe(events.OrdersReset, func(events.Event) {
+		store.OrderRepo.InvalidateAllOrderCounts()
+	})
+
+	stockAlerter := service.NewStockAlerter(store, notifier)
+
+	// 定期実行する処理（実行予定はSCHEDULE_<処理名>で変更できる）
+	// DBに負荷をかける処理は、複数インスタンスで同時に実行しないよう開始をずらす
+	// DBに書き込む処理は、読み取り専用モードの間は実行しない
+	scheduler := newScheduler(
+		// 期限切れの画像をキャッシュから破棄する
+		schedule.Job{Name: "image-cache-cleanup", Spec: "@every 30m", Run: func(context.Context) error {
+			imageCache.RemoveStale()
+			return nil
+		}},
+		// 期限切れのセッションを削除する
+		schedule.Job{Name: "session-purge", Spec: "@every 10m", Jitter: 30 * time.Second, Run: unlessReadOnly(readOnly, func(ctx context.Context) error {
+			deleted, err := store.SessionRepo.DeleteExpired(ctx)
+			if deleted > 0 {
+				slog.InfoContext(ctx, "[session-purge] 期限切れセッションを削除しました", "deleted", deleted)
+			}
+			return err
+		})},
+		// 商品一覧のinclude=statsで返す注文数の集計を更新する（PRODUCT_STATS_INTERVALが0以下の場合は更新しない）
+		schedule.Job{Name: "product-stats", Spec: everySpec(envDuration("PRODUCT_STATS_INTERVAL", 5*time.Minute)), Jitter: 10 * time.Second, RunAtStart: true, Run: unlessReadOnly(readOnly, productService.RefreshOrderStats)},
+		// おすすめ商品の抽出表を注文数の集計から作り直す（FEATURED_REFRESH_INTERVALが0以下の場合は作らず、おすすめ商品APIは503を返す）
+		schedule.Job{Name: "featured-products", Spec: everySpec(envDuration("FEATURED_REFRESH_INTERVAL", 5*time.Minute)), Jitter: 10 * time.Second, RunAtStart: true, Run: productService.RefreshFeatured},
+		// 注文できる期間の始まり・終わりを迎えた商品を一覧のキャッシュから消す（PRODUCT_AVAILABILITY_INTERVALが0以下の場合は確認しない）
+		schedule.Job{Name: "product-availability", Spec: everySpec(envDuration("PRODUCT_AVAILABILITY_INTERVAL", time.Minute)), Run: productService.CheckAvailabilityChanges},
+		// 配送失敗注文の自動再キュー投入
+		schedule.Job{Name: "requeue", Spec: "@every 10s", Run: unlessReadOnly(readOnly, robotService.RunRequeue)},
+		// 受領確認されなかった配送計画のロールバック
+		schedule.Job{Name: "plan-ack-rollback", Spec: "@every 10s", Run: unlessReadOnly(readOnly, robotService.RunPlanAckRollback)},
+		// 在庫数が発注点を下回った商品を管理者に通知する（LOW_STOCK_CHECK_INTERVALが0以下の場合は確認しない）
+		schedule.Job{Name: "low-stock", Spec: everySpec(envDuration("LOW_STOCK_CHECK_INTERVAL", time.Minute)), Jitter: 10 * time.Second, Run: stockAlerter.CheckLowStock},
+		// 配送中のまま止まった注文（ロボットの停止など）を配送待ちに戻す（ROBOT_DELIVERY_TIMEOUT設定時のみ）
+		schedule.Job{Name: "stale-delivery-requeue", Spec: "@every 30s", Jitter: 5 * time.Second, Run: unlessReadOnly(readOnly, robotService.RunStaleDeliveryRequeue)},
+	)
+	components.Register("scheduler", lifecycle.NewBackground("Scheduler", scheduler.Run))
 
 	r := chi.NewRouter()
+	// 1つのリクエストのログをrequest_idでまとめて追えるようにする
+	r.Use(middleware.RequestIDMiddleware)
 	r.Use(otelchi.Middleware(
 		"backend-api",
 		otelchi.WithChiRoutes(r),
@@ -56,57 +423,346 @@ func NewServer() (*Server, *sqlx.DB, error) {
 		}),
 	))
 
+	r.Use(middleware.LatencyMiddleware(latency))
+	// REQUEST_SHAPE_METRICS=1の場合、エンドポイントごとの本文のサイズと件数を集計する（管理ダッシュボードで見る）
+	if os.Getenv("REQUEST_SHAPE_METRICS") == "1" {
+		r.Use(middleware.ShapeMiddleware)
+	}
+	// リクエストの処理時間の上限（未設定の場合はクライアントがX-Request-Budgetで指定したときのみ）
+	r.Use(middleware.DeadlineBudgetMiddleware(envDuration("REQUEST_BUDGET", 0), envDuration("REQUEST_BUDGET_MAX", 30*time.Second)))
+	// 全レスポンスにレート制限ヘッダーを付与する（制限はしない）
+	r.Use(middleware.SoftIPRateLimitMiddleware(float64(envInt("RATE_LIMIT_RPS", 100)), envInt("RATE_LIMIT_BURST", 200)))
+
+	// OPENAPI_VALIDATION=enforceでOpenAPI定義に沿わないリクエストを400で拒否する（warnはログのみ）
+	switch mode := os.Getenv("OPENAPI_VALIDATION"); mode {
+	case "warn", "enforce":
+		r.Use(middleware.OpenAPIValidationMiddleware(validator, mode == "enforce"))
+	case "", "off":
+	default:
+		slog.Warn("Unknown OPENAPI_VALIDATION, request validation disabled", "mode", mode)
+	}
+
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	// 受付できるか（読み取り専用モードでも参照は受け付けるため200を返し、状態をread_onlyで示す）
+	r.Get("/api/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model.ReadinessResponse{Ready: true, ReadOnly: readOnly.State()})
+	})
+	r.Get("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openapi.Spec())
+	})
 
 	s := &Server{
-		Router: r,
+		Router:    r,
+		Lifecycle: components,
+		Startup:   newStartupSequencer(dbConn, store, productService, robotService, redisSessions, cacheSnapshot),
+
+		statusStream: statusStream,
 	}
 
-	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, userAuthMW, robotAuthMW)
+	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, adminHandler, trackingHandler, preferenceHandler, userAuthMW, robotAuthMW, adminAuthMW, redactMW, trackingRateLimitMW, heavyMW, routeLimits, readOnly.Middleware, loginWritesDB, os.Getenv("TESTDATA_RESET_ENABLED") == "1")
 
 	return s, dbConn, nil
 }
 
+// SESSION_REDIS_URL（例: redis://:password@redis:6379/0）が設定されていればRedisのセッションストアを返す（未設定の場合はnil）
+// キーの接頭辞はSESSION_REDIS_PREFIXで変更できる
+func newRedisSessions() (*repository.RedisSessionRepository, error) {
+	redisURL := os.Getenv("SESSION_REDIS_URL")
+	if redisURL == "" {
+		return nil, nil
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SESSION_REDIS_URL: %w", err)
+	}
+	prefix := os.Getenv("SESSION_REDIS_PREFIX")
+	if prefix == "" {
+		prefix = repository.DefaultSessionKeyPrefix
+	}
+	return repository.NewRedisSessionRepository(redis.NewClient(opts), prefix), nil
+}
+
+// PLANNER_WARM_START=1 の場合、配送計画の動的計画法を前回の計算から引き継ぐ（それ以外はnil）
+// 引き継ぐためにテーブル全体を確保・保持するため、PLANNER_WARM_START_MAX_CELLSを超える大きさの計画は引き継がずに計算する
+func newWarmStart() *planner.WarmKnapsack {
+	if os.Getenv("PLANNER_WARM_START") != "1" {
+		return nil
+	}
+	return planner.NewWarmKnapsack(envInt("PLANNER_WARM_START_MAX_CELLS", 5000000))
+}
+
+// percentEnvに指定した割合（%）のリクエストで新しいアルゴリズムも実行し、結果・処理時間の差をログに出す（未設定の場合は実行しない）
+// 比較ごとに同時にSHADOW_MAX_CONCURRENT件まで、SHADOW_TIMEOUTで打ち切る
+func newShadow(name, percentEnv string) *shadow.Runner {
+	percent := envInt(percentEnv, 0)
+	if percent <= 0 {
+		return nil
+	}
+	slog.Info("Shadow enabled", "name", name, "percent", percent)
+	return shadow.New(name, percent, envInt("SHADOW_MAX_CONCURRENT", 2), envDuration("SHADOW_TIMEOUT", 5*time.Second))
+}
+
+// percentEnvに指定した割合（%）のユーザーを新しい実装に切り替える（未設定の場合は切り替えない）
+// 対象のユーザーはユーザーIDのハッシュで決まり、割合を変えない限り変わらない
+func newCanary(name, percentEnv string) *canary.Rollout {
+	percent := envInt(percentEnv, 0)
+	if percent <= 0 {
+		return nil
+	}
+	slog.Info("Canary enabled", "name", name, "percent", percent)
+	return canary.New(name, percent)
+}
+
+// 起動時にaddrで待ち受けを始め、停止時は処理中のRPCの完了を待つ（Stopのctxが切れたら打ち切る）
+func newGRPCComponent(server *grpc.Server, addr string) lifecycle.Hook {
+	return lifecycle.Hook{
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen for gRPC on %s: %w", addr, err)
+			}
+			slog.InfoContext(ctx, "Starting gRPC server", "addr", addr)
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					slog.Error("gRPC server stopped", "err", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				server.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// 通知はログに出力する。ADMIN_WEBHOOK_URLが設定されていれば、管理者への通知をそのURLにもPOSTする
+func newNotifier() service.Notifier {
+	var notifier service.Notifier = service.NewLogNotifier()
+	if url := os.Getenv("ADMIN_WEBHOOK_URL"); url != "" {
+		notifier = service.NewWebhookNotifier(notifier, url)
+	}
+	return notifier
+}
+
+// GEOCODER_URLが設定されていればHTTP実装（キャッシュ付き）を使用する
+func newGeocoder() geocode.Geocoder {
+	geocoderURL := os.Getenv("GEOCODER_URL")
+	if geocoderURL == "" {
+		return geocode.NoopGeocoder{}
+	}
+	return geocode.NewCachingGeocoder(geocode.NewHTTPGeocoder(geocoderURL), 24*time.Hour, 10000)
+}
+
+// SEARCH_BACKEND_URLが設定されていればElasticsearch/OpenSearchを使用する（未設定の場合はnil）
+func newSearchIndex() *search.Elasticsearch {
+	searchURL := os.Getenv("SEARCH_BACKEND_URL")
+	if searchURL == "" {
+		return nil
+	}
+	index := os.Getenv("SEARCH_BACKEND_INDEX")
+	if index == "" {
+		index = "products"
+	}
+	return search.NewElasticsearch(searchURL, index)
+}
+
+// nilのポインタをそのままインターフェースに入れると非nilになるため変換する
+func searchBackend(index *search.Elasticsearch) search.Backend {
+	if index == nil {
+		return nil
+	}
+	return index
+}
+
+// ルートのグループごとのレート制限
+type routeRateLimits struct {
+	productList func(http.Handler) http.Handler
+	orderCreate func(http.Handler) http.Handler
+	robot       func(http.Handler) http.Handler
+}
+
 func (s *Server) setupRoutes(
 	authHandler *handler.AuthHandler,
 	productHandler *handler.ProductHandler,
 	orderHandler *handler.OrderHandler,
 	robotHandler *handler.RobotHandler,
+	adminHandler *handler.AdminHandler,
+	trackingHandler *handler.TrackingHandler,
+	preferenceHandler *handler.PreferenceHandler,
 	userAuthMW func(http.Handler) http.Handler,
 	robotAuthMW func(http.Handler) http.Handler,
+	adminAuthMW func(http.Handler) http.Handler,
+	redactMW func(http.Handler) http.Handler,
+	trackingRateLimitMW func(http.Handler) http.Handler,
+	heavyMW func(http.Handler) http.Handler,
+	limits routeRateLimits,
+	writeMW func(http.Handler) http.Handler,
+	loginWritesDB bool,
+	testdataReset bool,
 ) {
 	// api's
-	s.Router.Post("/api/login", authHandler.Login)
+	// ログインはセッションをuser_sessionsにINSERTするため、読み取り専用モードでは止める
+	// セッションをRedisに保存する・書き込みを後回しにする（SESSION_WRITE_BEHIND）場合はDBに書き込まないため受け付ける
+	if loginWritesDB {
+		s.Router.With(writeMW).Post("/api/login", authHandler.Login)
+	} else {
+		s.Router.Post("/api/login", authHandler.Login)
+	}
+
+	// 注文の公開追跡（認証不要）
+	s.Router.With(trackingRateLimitMW).Get("/api/track/{token}", trackingHandler.Track)
 
 	s.Router.Route("/api/v1", func(r chi.Router) {
 		r.Use(userAuthMW)
 		// 商品一覧取得
-		r.Post("/product", productHandler.List)
+		r.With(limits.productList, heavyMW).Post("/product", productHandler.List)
 		// 注文処理
-		r.Post("/product/post", productHandler.CreateOrders)
+		r.With(writeMW, limits.orderCreate).Post("/product/post", productHandler.CreateOrders)
 		// 注文一覧取得
-		r.Post("/orders", orderHandler.List)
+		r.With(heavyMW).Post("/orders", orderHandler.List)
+		// 注文集計・注文詳細
+		r.With(heavyMW).Get("/orders/summary", orderHandler.Summary)
+		r.Get("/orders/stream", orderHandler.Stream)
+		r.Get("/orders/{id}", orderHandler.Get)
+		r.Get("/orders/{id}/invoice", orderHandler.Invoice)
+		// 自分が受取人のギフト
+		r.Get("/gifts/received", orderHandler.ReceivedGifts)
 		r.Get("/image", productHandler.GetImage)
 	})
 
+	s.Router.Route("/api/products", func(r chi.Router) {
+		r.Use(userAuthMW)
+		r.Get("/featured", productHandler.Featured)
+	})
+
+	s.Router.Route("/api/orders", func(r chi.Router) {
+		r.Use(userAuthMW)
+		// 注文前チェック（書き込みなし）
+		r.Post("/validate", productHandler.ValidateOrder)
+		r.With(writeMW, limits.orderCreate).Post("/{id}/reorder", productHandler.Reorder)
+		r.With(heavyMW).Get("/by-product", orderHandler.ByProduct)
+		// 注文履歴のCSV（検索・並び替えは注文一覧と同じ）
+		r.With(heavyMW).Get("/export", orderHandler.Export)
+		r.Post("/{id}/watch", orderHandler.Watch)
+		r.Delete("/{id}/watch", orderHandler.Unwatch)
+		r.With(writeMW).Delete("/{id}", orderHandler.Cancel)
+	})
+
+	s.Router.Route("/api/me", func(r chi.Router) {
+		r.Use(userAuthMW)
+		r.Get("/preferences", preferenceHandler.Get)
+		r.With(writeMW).Put("/preferences", preferenceHandler.Put)
+	})
+
 	s.Router.Route("/api/robot", func(r chi.Router) {
+		// 認証の前に制限し、不正なキーでの大量のリクエストも制限する
+		r.Use(limits.robot)
 		r.Use(robotAuthMW)
-		r.Get("/delivery-plan", robotHandler.GetDeliveryPlan)
-		r.Patch("/orders/status", robotHandler.UpdateOrderStatus)
+		// 配送計画の取得は注文を配送中にするため書き込みとして扱う
+		// 配送計画を作れるだけの注文が溜まるまで待つ（その後/delivery-planで計画を取得する）
+		r.Get("/plan/wait", robotHandler.WaitForPlan)
+		r.With(writeMW).Get("/delivery-plan", robotHandler.GetDeliveryPlan)
+		r.With(writeMW).Post("/delivery-plan/ack", robotHandler.AcknowledgePlan)
+		r.With(writeMW).Patch("/orders/status", robotHandler.UpdateOrderStatus)
+		r.With(writeMW).Post("/delivery-failed", robotHandler.ReportDeliveryFailure)
+		// 位置はメモリ上に記録するだけのため止めない
+		r.Post("/position", robotHandler.ReportPosition)
 	})
+
+	// adminAuthMWがnilの場合（ADMIN_API_KEY未設定）は管理APIを登録しない
+	if adminAuthMW != nil {
+		s.Router.Route("/api/admin", func(r chi.Router) {
+			r.Use(adminAuthMW)
+			r.Use(redactMW)
+			r.Get("/stats", adminHandler.Stats)
+			r.Get("/dashboard", adminHandler.Dashboard)
+			r.Get("/images/hot", adminHandler.HotImages)
+			// メモリ上のキャッシュを破棄するだけのため止めない
+			r.Post("/cache/products/invalidate", adminHandler.InvalidateProductCache)
+			r.Get("/products/invalid", adminHandler.InvalidProducts)
+			r.With(writeMW).Patch("/products/{id}", adminHandler.UpdateProduct)
+			r.With(writeMW).Post("/products/recalibrate", adminHandler.RecalibrateProducts)
+			r.Get("/products/{id}/history", adminHandler.ProductHistory)
+			r.Get("/stock/low", adminHandler.LowStock)
+			r.With(writeMW).Post("/distances/precompute", adminHandler.PrecomputeDistances)
+			r.With(writeMW).Post("/orders/repair-status", adminHandler.RepairOrderStatuses)
+			r.With(writeMW).Post("/orders/requeue-stale", adminHandler.RequeueStaleDeliveries)
+			r.With(writeMW).Post("/orders/import", adminHandler.ImportOrders)
+			// モードの確認・解除に使うため、読み取り専用モードでも止めない
+			r.Get("/read-only", adminHandler.ReadOnly)
+			r.Put("/read-only", adminHandler.SetReadOnly)
+			// 注文・セッションをすべて削除するため、TESTDATA_RESET_ENABLED=1 の負荷試験環境でのみ公開する
+			if testdataReset {
+				r.With(writeMW).Post("/testdata/reset", adminHandler.ResetTestdata)
+			}
+		})
+	}
 }
 
-func (s *Server) Run() {
+// HTTPサーバーを起動し、ctxがキャンセルされるまでブロックする
+// キャンセルされたら新規の受付を止めて処理中のリクエストの完了を待ち（最大SHUTDOWN_DRAIN_TIMEOUT）、
+// その後バックグラウンドのコンポーネントを停止する
+func (s *Server) Run(ctx context.Context) error {
 	appPort := os.Getenv("PORT")
 	if appPort == "" {
 		appPort = "8080"
 	}
+	drainTimeout := envDuration("SHUTDOWN_DRAIN_TIMEOUT", 5*time.Second)
+
+	if err := s.Startup.Run(ctx); err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	if err := s.Lifecycle.Start(ctx); err != nil {
+		s.Shutdown(context.Background())
+		return fmt.Errorf("failed to start components: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Starting server", "port", appPort)
+	srv := &http.Server{Addr: ":" + appPort, Handler: s.Router}
+	// SSEの接続は終わらないため、受付を止めたら閉じる
+	srv.RegisterOnShutdown(s.statusStream.Close)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- listenAndServe(srv, loadListenConfig()) }()
+
+	select {
+	case err := <-serveErr:
+		s.Shutdown(context.Background())
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.InfoContext(ctx, "Shutting down: waiting for in-flight requests", "timeout", drainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to drain in-flight requests", "err", err)
+	}
+	s.Shutdown(context.Background())
+	slog.InfoContext(ctx, "Server stopped")
+	return nil
+}
 
-	log.Printf("Starting server on :%s", appPort)
-	if err := http.ListenAndServe(":"+appPort, s.Router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// バックグラウンドのコンポーネントを登録の逆順に停止する
+func (s *Server) Shutdown(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := s.Lifecycle.Stop(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to stop components", "err", err)
 	}
 }
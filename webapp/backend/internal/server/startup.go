@@ -0,0 +1,142 @@
+package server
+
+import (
+	"backend/internal/db"
+	"backend/internal/migrate"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"backend/internal/startup"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// マイグレーション適用後に存在するはずのテーブル
+// マイグレーションでテーブルを追加した場合はここにも追加する
+var requiredTables = []string{
+	"users",
+	"user_sessions",
+	"products",
+	"orders",
+	"order_events",
+	"distance_cache",
+	"shipping_zones",
+	"shipping_rates",
+	"tax_rates",
+	"search_synonyms",
+	"search_outbox",
+	"user_preferences",
+	"product_history",
+	"product_order_stats",
+}
+
+// 現在のクエリが前提としている複合インデックス
+// 存在しなくても動作はするため、起動時に警告のみ出す
+var expectedIndexes = []repository.IndexSpec{
+	{Table: "orders", Columns: []string{"user_id", "created_at"}},
+	{Table: "orders", Columns: []string{"is_shipping", "created_at", "product_id"}},
+	{Table: "orders", Columns: []string{"shipped_status", "created_at"}},
+	{Table: "user_sessions", Columns: []string{"session_uuid", "expires_at"}},
+	{Table: "products", Columns: []string{"name"}},
+	{Table: "users", Columns: []string{"user_name"}},
+}
+
+// HTTPの受付前に、DBへの接続・マイグレーションの完了・キャッシュの温めを順に待つ
+// DBの起動が遅れても即座に落ちず、STARTUP_*_TIMEOUTの間は再試行する
+// dbConnはデモモードの場合（DBの確認・マイグレーションを行わない）、redisSessionsはRedisのセッションストアを使わない場合、
+// cacheSnapshotはキャッシュのスナップショットを使わない場合はnil
+func newStartupSequencer(dbConn *sqlx.DB, store *repository.Store, productService *service.ProductService, robotService *service.RobotService, redisSessions *repository.RedisSessionRepository, cacheSnapshot *service.CacheSnapshotService) *startup.Sequencer {
+	seq := startup.NewSequencer()
+	if dbConn != nil {
+		seq.AddWithRetry("database", startup.Backoff{
+			Initial: 200 * time.Millisecond,
+			Max:     5 * time.Second,
+			Timeout: envDuration("STARTUP_DB_TIMEOUT", 2*time.Minute),
+		}, func(ctx context.Context) error {
+			return db.Ping(ctx, dbConn, 5*time.Second)
+		})
+	}
+	if redisSessions != nil {
+		seq.AddWithRetry("session-store", startup.Backoff{
+			Initial: 200 * time.Millisecond,
+			Max:     5 * time.Second,
+			Timeout: envDuration("STARTUP_REDIS_TIMEOUT", time.Minute),
+		}, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			return redisSessions.Ping(ctx)
+		})
+	}
+	if dbConn != nil {
+		addSchemaSteps(seq, dbConn, store)
+	}
+	// 温めに失敗しても通常どおりDBから読めるため、起動は止めない
+	seq.Add("cache-warmup", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, envDuration("STARTUP_WARMUP_TIMEOUT", 30*time.Second))
+		defer cancel()
+		if err := productService.WarmUp(ctx); err != nil {
+			log.Printf("[startup] product cache warm-up failed: %v", err)
+		}
+		if err := robotService.WarmUp(ctx); err != nil {
+			log.Printf("[startup] delivery index warm-up failed: %v", err)
+		}
+		// 前回の停止時によく参照されていたキーは、時間が足りなければ途中までで打ち切る
+		if cacheSnapshot != nil {
+			if err := cacheSnapshot.Restore(ctx); err != nil {
+				log.Printf("[startup] cache snapshot restore failed: %v", err)
+			}
+		}
+		return nil
+	})
+	return seq
+}
+
+// マイグレーションの適用・完了の待機とインデックスの確認
+func addSchemaSteps(seq *startup.Sequencer, dbConn *sqlx.DB, store *repository.Store) {
+	// MIGRATE_ON_STARTUP=1 の場合、埋め込んだマイグレーションの未適用分を起動時に適用する
+	// 外部で適用済みのDBは、先に -migrate baseline で記録しておくこと
+	if os.Getenv("MIGRATE_ON_STARTUP") == "1" {
+		seq.Add("migrate", func(ctx context.Context) error {
+			runner, err := migrate.New(dbConn)
+			if err != nil {
+				return err
+			}
+			applied, err := runner.Up(ctx)
+			if len(applied) > 0 {
+				log.Printf("[startup] applied %d migrations", len(applied))
+			}
+			return err
+		})
+	}
+	// マイグレーションはrestore_and_migration.sh（またはMIGRATE_ON_STARTUP）が適用するため、完了するまで待つ
+	seq.AddWithRetry("migrations", startup.Backoff{
+		Initial: time.Second,
+		Max:     5 * time.Second,
+		Timeout: envDuration("STARTUP_MIGRATION_TIMEOUT", 2*time.Minute),
+	}, func(ctx context.Context) error {
+		missing, err := store.SchemaRepo.MissingTables(ctx, requiredTables)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing tables: %v", missing)
+		}
+		return nil
+	})
+	seq.Add("index-check", func(ctx context.Context) error {
+		missing, err := store.SchemaRepo.MissingIndexes(ctx, expectedIndexes)
+		if err != nil {
+			log.Printf("[startup] index check failed: %v", err)
+			return nil
+		}
+		for _, index := range missing {
+			log.Printf("[startup] Warning: no index on %s(%s); queries on this table may be slow", index.Table, strings.Join(index.Columns, ", "))
+		}
+		return nil
+	})
+}
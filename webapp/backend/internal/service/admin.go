@@ -0,0 +1,203 @@
+package service
+
+import (
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/routing"
+	"backend/internal/service/utils"
+	"context"
+	"time"
+)
+
+const (
+	// 移動時間を事前計算する座標数のデフォルト上限
+	defaultPrecomputeCoordinates = 50
+	// SLA超過率を集計する日数
+	slaStatsDays = 30
+	// よくアクセスされる画像として返すデフォルトの件数
+	defaultHotImages = 20
+	// 状態の修復で1回のトランザクションで更新する注文数
+	statusRepairBatchSize = 500
+)
+
+// 画像キャッシュに載っているかを確認する
+type ImageResidency interface {
+	Contains(path string) bool
+}
+
+type AdminService struct {
+	store          *repository.Store
+	distances      *routing.CachedDistanceProvider
+	fulfillmentSLA time.Duration
+	images         ImageResidency
+}
+
+// imagesがnilの場合、画像はキャッシュされていないものとして扱う
+func NewAdminService(store *repository.Store, distances *routing.CachedDistanceProvider, fulfillmentSLA time.Duration, images ImageResidency) *AdminService {
+	return &AdminService{store: store, distances: distances, fulfillmentSLA: fulfillmentSLA, images: images}
+}
+
+// 商品一覧キャッシュを無効にする
+// キーの指定があればそのキーだけを、なければprefix（未指定の場合は全件）で始まるキーを無効にする
+func (s *AdminService) InvalidateProductCache(req model.ProductCacheInvalidateRequest) *model.ProductCacheInvalidateResponse {
+	if len(req.Keys) > 0 {
+		return &model.ProductCacheInvalidateResponse{DeletedKeys: s.store.ProductRepo.DeleteKeys(req.Keys...)}
+	}
+	prefix := repository.ProductListKeyPrefix + req.Prefix
+	s.store.ProductRepo.InvalidatePrefix(prefix)
+	return &model.ProductCacheInvalidateResponse{Prefix: prefix}
+}
+
+// アクセス回数の多い画像と、それぞれがキャッシュに載っているかを返す
+func (s *AdminService) HotImages(limit int) *model.ImageHotReport {
+	if limit <= 0 {
+		limit = defaultHotImages
+	}
+	top, total, tracked := metrics.Access("image").Top(limit)
+	report := &model.ImageHotReport{TotalHits: total, TrackedPaths: tracked, Images: make([]model.ImageHotStat, 0, len(top))}
+	for _, c := range top {
+		stat := model.ImageHotStat{Path: c.Key, Hits: c.Hits}
+		if s.images != nil {
+			stat.Cached = s.images.Contains(c.Key)
+		}
+		report.Images = append(report.Images, stat)
+	}
+	return report
+}
+
+// 管理者向けの統計情報を取得
+func (s *AdminService) GetStats(ctx context.Context) (*model.AdminStats, error) {
+	var stats model.AdminStats
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		failures, err := s.deliveryFailureStats(ctx)
+		if err != nil {
+			return err
+		}
+		stats.DeliveryFailures = failures
+
+		revenue, err := s.store.OrderRepo.RevenueTotals(ctx)
+		if err != nil {
+			return err
+		}
+		stats.Revenue = revenue
+
+		since := time.Now().AddDate(0, 0, -slaStatsDays)
+		daily, err := s.store.EventRepo.DailySLABreaches(ctx, since)
+		if err != nil {
+			return err
+		}
+		stats.SLA = model.SLAStats{
+			SLASeconds: int(s.fulfillmentSLA / time.Second),
+			Daily:      daily,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *AdminService) deliveryFailureStats(ctx context.Context) (model.DeliveryFailureStats, error) {
+	var stats model.DeliveryFailureStats
+	var err error
+
+	if stats.TotalFailures, err = s.store.EventRepo.CountByType(ctx, repository.OrderEventDeliveryFailed); err != nil {
+		return stats, err
+	}
+	if stats.TotalRequeued, err = s.store.EventRepo.CountByType(ctx, repository.OrderEventRequeued); err != nil {
+		return stats, err
+	}
+	if stats.CurrentlyFailed, err = s.store.OrderRepo.CountByStatus(ctx, "failed"); err != nil {
+		return stats, err
+	}
+	if stats.ByReason, err = s.store.EventRepo.CountFailuresByReason(ctx); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// 不整合な状態の注文を検出して修復し、修復した注文を返す
+// - ロボットが割り当てられていない配送中の注文は配送待ちに戻す
+// - 到着時刻のない配送完了注文は到着時刻を補う
+// 長時間ロックを保持しないよう、statusRepairBatchSize件ずつ別のトランザクションで更新する
+func (s *AdminService) RepairOrderStatuses(ctx context.Context) (*model.StatusRepairReport, error) {
+	var report model.StatusRepairReport
+	err := s.repairInBatches(ctx, &report.DeliveringWithoutRobot, func(ctx context.Context, txStore *repository.Store) ([]int64, error) {
+		orderIDs, err := txStore.OrderRepo.FindDeliveringWithoutRobot(ctx, statusRepairBatchSize)
+		if err != nil || len(orderIDs) == 0 {
+			return nil, err
+		}
+		return orderIDs, txStore.OrderRepo.RollbackDelivering(ctx, orderIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.repairInBatches(ctx, &report.CompletedWithoutArrival, func(ctx context.Context, txStore *repository.Store) ([]int64, error) {
+		orderIDs, err := txStore.OrderRepo.FindCompletedWithoutArrival(ctx, statusRepairBatchSize)
+		if err != nil || len(orderIDs) == 0 {
+			return nil, err
+		}
+		return orderIDs, txStore.OrderRepo.FillMissingArrivals(ctx, orderIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// 対象がなくなるまでrepairをトランザクションごとに繰り返し、結果をresultに加える
+// repairは修復した注文IDを返す（空であれば終了）
+func (s *AdminService) repairInBatches(ctx context.Context, result *model.StatusRepairResult, repair func(ctx context.Context, txStore *repository.Store) ([]int64, error)) error {
+	result.OrderIDs = []int64{}
+	for {
+		var repaired []int64
+		err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+			return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+				orderIDs, err := repair(ctx, txStore)
+				if err != nil || len(orderIDs) == 0 {
+					return err
+				}
+				if err := txStore.EventRepo.CreateBulk(ctx, orderIDs, repository.OrderEventStatusRepaired); err != nil {
+					return err
+				}
+				repaired = orderIDs
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if len(repaired) == 0 {
+			return nil
+		}
+		result.Repaired += len(repaired)
+		result.Batches++
+		result.OrderIDs = append(result.OrderIDs, repaired...)
+	}
+}
+
+// 注文で頻出する座標の全ペアについて移動時間を事前計算する
+func (s *AdminService) PrecomputeDistances(ctx context.Context, limit int) (*model.PrecomputeDistancesResponse, error) {
+	if limit <= 0 {
+		limit = defaultPrecomputeCoordinates
+	}
+	var resp model.PrecomputeDistancesResponse
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		coords, err := s.store.DistanceRepo.FrequentCoordinates(ctx, limit)
+		if err != nil {
+			return err
+		}
+		pairs, err := s.distances.Precompute(ctx, coords)
+		if err != nil {
+			return err
+		}
+		resp = model.PrecomputeDistancesResponse{Coordinates: len(coords), Pairs: pairs}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
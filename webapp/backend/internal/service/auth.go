@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
 	"time"
 
 	"backend/internal/repository"
@@ -28,7 +28,8 @@ func NewAuthService(store *repository.Store) *AuthService {
 	return &AuthService{store: store}
 }
 
-func (s *AuthService) Login(ctx context.Context, userName, password string) (string, time.Time, error) {
+// fingerprintはセッションに記録するクライアント指紋
+func (s *AuthService) Login(ctx context.Context, userName, password, fingerprint string) (string, time.Time, error) {
 	ctx, span := otel.Tracer("service.auth").Start(ctx, "AuthService.Login")
 	defer span.End()
 
@@ -37,7 +38,7 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
 		user, err := s.store.UserRepo.FindByUserName(ctx, userName)
 		if err != nil {
-			log.Printf("[Login] ユーザー検索失敗(userName: %s): %v", userName, err)
+			slog.WarnContext(ctx, "[Login] ユーザー検索失敗", "user_name", userName, "err", err)
 			if errors.Is(err, sql.ErrNoRows) {
 				return ErrUserNotFound
 			}
@@ -46,15 +47,15 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 
 		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 		if err != nil {
-			log.Printf("[Login] パスワード検証失敗: %v", err)
+			slog.WarnContext(ctx, "[Login] パスワード検証失敗", "err", err)
 			span.RecordError(err)
 			return ErrInvalidPassword
 		}
 
 		sessionDuration := 24 * time.Hour
-		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration)
+		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration, fingerprint)
 		if err != nil {
-			log.Printf("[Login] セッション生成失敗: %v", err)
+			slog.ErrorContext(ctx, "[Login] セッション生成失敗", "err", err)
 			return ErrInternalServer
 		}
 		return nil
@@ -62,6 +63,6 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 	if err != nil {
 		return "", time.Time{}, err
 	}
-	log.Printf("Login successful for UserName '%s', session created.", userName)
+	slog.InfoContext(ctx, "Login successful, session created", "user_name", userName)
 	return sessionID, expiresAt, nil
 }
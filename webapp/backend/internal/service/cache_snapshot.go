@@ -0,0 +1,127 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// 商品一覧・注文一覧の総件数のキャッシュで、よく参照されているキーを停止時にファイルへ保存し、
+// 次の起動時にそのクエリを実行し直してキャッシュを温める（デプロイ直後のレイテンシの悪化を抑える）
+// 保存するのはキーの条件のみで、結果は起動時にDBから取得し直す
+type CacheSnapshotService struct {
+	store    *repository.Store
+	products *ProductService
+	path     string
+	// 種類ごとに保存するキーの数
+	maxKeys int
+	// これより古いスナップショットは使わない
+	maxAge time.Duration
+	// 起動時に同時に実行するクエリの数
+	concurrency int
+}
+
+func NewCacheSnapshotService(store *repository.Store, products *ProductService, path string, maxKeys int, maxAge time.Duration, concurrency int) *CacheSnapshotService {
+	return &CacheSnapshotService{
+		store:       store,
+		products:    products,
+		path:        path,
+		maxKeys:     maxKeys,
+		maxAge:      maxAge,
+		concurrency: max(concurrency, 1),
+	}
+}
+
+// 現在のキャッシュのよく参照されているキーをファイルに保存する
+// 書き込み途中で停止しても前回のファイルが壊れないよう、一時ファイルに書いてから置き換える
+func (s *CacheSnapshotService) Save(ctx context.Context) error {
+	snapshot := model.CacheSnapshot{
+		SavedAt:      time.Now(),
+		ProductLists: s.store.ProductRepo.HotListQueries(s.maxKeys),
+		OrderCounts:  s.store.OrderRepo.HotCountQueries(s.maxKeys),
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "[CacheSnapshot] キャッシュのキーを保存しました", "path", s.path,
+		"product_lists", len(snapshot.ProductLists), "order_counts", len(snapshot.OrderCounts))
+	return nil
+}
+
+// 保存したキーのクエリを実行し直してキャッシュに載せる
+// 個々のクエリの失敗は通常どおりDBから読めるため無視し、ctxが切れたら残りは実行しない
+// ファイルがない場合（初回の起動）・古い場合は何もしない
+func (s *CacheSnapshotService) Restore(ctx context.Context) error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot model.CacheSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid cache snapshot %s: %w", s.path, err)
+	}
+	if age := time.Since(snapshot.SavedAt); s.maxAge > 0 && age > s.maxAge {
+		slog.InfoContext(ctx, "[CacheSnapshot] スナップショットが古いため使いません", "path", s.path, "age", age.Round(time.Second))
+		return nil
+	}
+
+	start := time.Now()
+	var warmed, failed atomic.Int64
+	g := new(errgroup.Group)
+	g.SetLimit(s.concurrency)
+	warm := func(fn func() error) {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := fn(); err != nil {
+				failed.Add(1)
+				return nil
+			}
+			warmed.Add(1)
+			return nil
+		})
+	}
+	for _, q := range snapshot.ProductLists {
+		warm(func() error { return s.products.warmList(ctx, q) })
+	}
+	for _, q := range snapshot.OrderCounts {
+		warm(func() error { return s.store.OrderRepo.WarmOrderCount(ctx, q.UserID, q.Search, q.Type) })
+	}
+	g.Wait()
+
+	slog.InfoContext(ctx, "[CacheSnapshot] キャッシュを温めました", "path", s.path,
+		"warmed", warmed.Load(), "failed", failed.Load(),
+		"skipped", int64(len(snapshot.ProductLists)+len(snapshot.OrderCounts))-warmed.Load()-failed.Load(),
+		"elapsed", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"backend/internal/canary"
+	"backend/internal/model"
+)
+
+// 注文一覧の1ページ目を、一部のユーザーだけOFFSETによるページングの代わりにキーセットページングで取得する
+func (s *OrderService) EnableCanary(r *canary.Rollout) {
+	s.cursorRollout = r
+}
+
+// 商品検索を、一部のユーザーだけngram全文検索で、それ以外のユーザーはLIKE検索で実行する
+func (s *ProductService) EnableCanary(r *canary.Rollout) {
+	s.searchRollout = r
+}
+
+// 注文一覧を取得するユーザーのコホート（切り替えの対象外のリクエストは空文字）
+// ページ番号で指定した2ページ目以降はキーセットの位置が分からないため、1ページ目のみ振り分ける
+func (s *OrderService) cursorCohort(userID int, req model.ListRequest) string {
+	if s.cursorRollout == nil || req.Pagination == model.PaginationCursor || req.Offset != 0 {
+		return ""
+	}
+	return s.cursorRollout.Cohort(userID)
+}
+
+// 商品を検索するユーザーのコホート（切り替えの対象外のリクエストは空文字）
+func (s *ProductService) searchCohort(userID int, req model.ListRequest) string {
+	if s.searchRollout == nil || req.Search == "" {
+		return ""
+	}
+	return s.searchRollout.Cohort(userID)
+}
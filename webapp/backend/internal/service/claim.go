@@ -0,0 +1,61 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// クレームトークンが不正、または注文が現在のそのロボットの配送計画に含まれていない
+var ErrInvalidClaim = errors.New("invalid claim token")
+
+// 配送計画ごとにロボットIDとクレームIDへ署名したトークンを発行する
+// ステータス報告時にトークンを照合し、他のロボットの計画の注文を完了・失敗にさせない
+type ClaimSigner struct {
+	secret []byte
+}
+
+func NewClaimSigner(secret []byte) *ClaimSigner {
+	return &ClaimSigner{secret: secret}
+}
+
+// robotIDの新しい計画のトークンと、注文に記録するクレームIDを返す
+func (s *ClaimSigner) Issue(robotID string) (token, claimID string, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	claimID = hex.EncodeToString(b)
+	payload := robotID + "\n" + claimID
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.sign(payload), claimID, nil
+}
+
+// 署名を検証し、トークンのロボットIDとクレームIDを返す
+func (s *ClaimSigner) Parse(token string) (robotID, claimID string, err error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", ErrInvalidClaim
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", ErrInvalidClaim
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(string(payload)))) {
+		return "", "", ErrInvalidClaim
+	}
+	robotID, claimID, ok = strings.Cut(string(payload), "\n")
+	if !ok {
+		return "", "", ErrInvalidClaim
+	}
+	return robotID, claimID, nil
+}
+
+func (s *ClaimSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"backend/internal/db"
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type DashboardService struct {
+	store         *repository.Store
+	positions     *RobotPositions
+	latency       *metrics.LatencyWindow
+	latencyWindow time.Duration
+	dbStats       func() sql.DBStats
+	// 読み取り用のレプリカ（EnableReadReplicaで設定した場合のみ）
+	replica      *db.ReplicaMonitor
+	replicaStats func() sql.DBStats
+}
+
+func NewDashboardService(store *repository.Store, positions *RobotPositions, latency *metrics.LatencyWindow, latencyWindow time.Duration, dbStats func() sql.DBStats) *DashboardService {
+	return &DashboardService{store: store, positions: positions, latency: latency, latencyWindow: latencyWindow, dbStats: dbStats}
+}
+
+// ダッシュボードに読み取り用のレプリカの状態と接続プールを含める
+func (s *DashboardService) EnableReadReplica(monitor *db.ReplicaMonitor, stats func() sql.DBStats) {
+	s.replica = monitor
+	s.replicaStats = stats
+}
+
+// 各サブシステムの現在値を並行に集めて1つのレスポンスにまとめる
+func (s *DashboardService) GetDashboard(ctx context.Context) (*model.AdminDashboard, error) {
+	var dashboard model.AdminDashboard
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		g, ctx := errgroup.WithContext(ctx)
+		g.Go(func() error {
+			counts, err := s.store.OrderRepo.CountGroupedByStatus(ctx)
+			dashboard.OrdersByStatus = counts
+			return err
+		})
+		g.Go(func() error {
+			dashboard.ActiveRobots = s.positions.ActiveCount()
+			return nil
+		})
+		g.Go(func() error {
+			dashboard.Caches = metrics.CacheStats()
+			dashboard.Outbound = metrics.OutboundStats()
+			dashboard.Jobs = metrics.JobStats()
+			dashboard.EndpointShapes = metrics.ShapeStats()
+			dashboard.Canaries = metrics.CanaryStats()
+			return nil
+		})
+		g.Go(func() error {
+			dashboard.DBPool = poolStats(s.dbStats())
+			if s.replica != nil {
+				checks, failures := s.replica.Checks()
+				dashboard.ReadReplica = &model.ReadReplicaStats{
+					Available: s.replica.Available(),
+					LagMs:     s.replica.Lag().Milliseconds(),
+					Checks:    checks,
+					Failures:  failures,
+					Pool:      poolStats(s.replicaStats()),
+				}
+			}
+			return nil
+		})
+		g.Go(func() error {
+			requests, p95 := s.latency.Percentile(0.95)
+			dashboard.Latency = model.LatencyStats{
+				WindowSeconds: int(s.latencyWindow / time.Second),
+				Requests:      requests,
+				P95Ms:         p95,
+			}
+			return nil
+		})
+		return g.Wait()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dashboard, nil
+}
+
+func poolStats(stats sql.DBStats) model.DBPoolStats {
+	return model.DBPoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration.Milliseconds(),
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/sampling"
+	"backend/internal/service/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// おすすめ商品の抽出表がまだ作られていない（起動直後に集計の取得に失敗した場合など）
+var ErrFeaturedNotReady = errors.New("featured products are not ready")
+
+// おすすめ商品の抽出表（RefreshFeaturedで作り直し、作成後は変更しない）
+type featuredTable struct {
+	productIDs []int
+	alias      *sampling.Alias
+	builtAt    time.Time
+}
+
+// おすすめ商品の抽出に使う重み（注文数に加える値）を設定する
+// 0より大きくすると、注文のない商品もその割合で選ばれる
+func (s *ProductService) SetFeaturedBaseWeight(weight float64) {
+	s.featuredBaseWeight = weight
+}
+
+// 注文できる商品の注文数から、おすすめ商品の抽出表を作り直す
+// スケジューラーから定期的に呼ばれる（注文数の集計はproduct-statsの処理で更新される）
+func (s *ProductService) RefreshFeatured(ctx context.Context) error {
+	popularity, err := s.store.StatsRepo.ListPopularity(ctx)
+	if err != nil {
+		return err
+	}
+	productIDs := make([]int, len(popularity))
+	weights := make([]float64, len(popularity))
+	for i, p := range popularity {
+		productIDs[i] = p.ProductID
+		weights[i] = float64(p.OrderCount) + s.featuredBaseWeight
+	}
+	s.featured.Store(&featuredTable{productIDs: productIDs, alias: sampling.NewAlias(weights), builtAt: time.Now()})
+	slog.DebugContext(ctx, "[FeaturedProducts] 抽出表を作り直しました", "products", len(productIDs))
+	return nil
+}
+
+// 注文数で重み付けして重複なく最大n件の商品を選ぶ（よく注文される商品ほど選ばれやすい）
+// 選んだ商品のうち、抽出表の作成後に注文できなくなったものは除く
+func (s *ProductService) FeaturedProducts(ctx context.Context, n int) ([]model.Product, error) {
+	table := s.featured.Load()
+	if table == nil {
+		return nil, ErrFeaturedNotReady
+	}
+	if table.alias == nil {
+		return []model.Product{}, nil
+	}
+	picked := table.alias.SampleDistinct(n)
+	productIDs := make([]int, len(picked))
+	for i, idx := range picked {
+		productIDs[i] = table.productIDs[idx]
+	}
+
+	var products []model.Product
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		products, err = s.store.ProductRepo.FindByIDs(ctx, productIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]model.Product, len(products))
+	for _, p := range products {
+		// 在庫数は商品一覧と同じく返さない
+		p.Stock = nil
+		byID[p.ProductID] = p
+	}
+
+	// 選ばれた順に並べる
+	now := time.Now()
+	featured := make([]model.Product, 0, len(productIDs))
+	for _, id := range productIDs {
+		if p, ok := byID[id]; ok && p.AvailableAt(now) {
+			featured = append(featured, p)
+		}
+	}
+	return featured, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"backend/internal/telemetry"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ユーザー・管理者への通知を送信するためのインターフェース
+type Notifier interface {
+	NotifyUser(ctx context.Context, userID int, subject, message string) error
+	NotifyAdmins(ctx context.Context, subject, message string) error
+}
+
+// 通知内容をログに出力するだけの実装
+// 外部の通知基盤が用意されるまでのデフォルトとして使用する
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) NotifyUser(ctx context.Context, userID int, subject, message string) error {
+	slog.InfoContext(ctx, "[Notify]", "user_id", userID, "subject", subject, "message", message)
+	return nil
+}
+
+func (n *LogNotifier) NotifyAdmins(ctx context.Context, subject, message string) error {
+	slog.InfoContext(ctx, "[NotifyAdmins]", "subject", subject, "message", message)
+	return nil
+}
+
+// 管理者への通知を、nextに加えてWebhookにもPOSTする実装
+// 本文は {"subject": "...", "message": "..."} のJSONで、2xx以外の応答は失敗として返す
+// ユーザーへの通知はnextにだけ送る
+type WebhookNotifier struct {
+	next   Notifier
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(next Notifier, url string) *WebhookNotifier {
+	return &WebhookNotifier{next: next, url: url, client: telemetry.NewHTTPClient(5 * time.Second)}
+}
+
+func (n *WebhookNotifier) NotifyUser(ctx context.Context, userID int, subject, message string) error {
+	return n.next.NotifyUser(ctx, userID, subject, message)
+}
+
+func (n *WebhookNotifier) NotifyAdmins(ctx context.Context, subject, message string) error {
+	if err := n.next.NotifyAdmins(ctx, subject, message); err != nil {
+		return err
+	}
+	body, err := json.Marshal(struct {
+		Subject string `json:"subject"`
+		Message string `json:"message"`
+	}{subject, message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
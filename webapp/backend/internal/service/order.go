@@ -1,14 +1,23 @@
 package service
 
 import (
+	"backend/internal/canary"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
+	"backend/internal/shadow"
 	"context"
+	"database/sql"
+	"errors"
+	"time"
 )
 
 type OrderService struct {
 	store *repository.Store
+	// キーセットページングとの比較（未設定の場合は比較しない）
+	shadow *shadow.Runner
+	// キーセットページングへの段階的な切り替え（未設定の場合は切り替えない）
+	cursorRollout *canary.Rollout
 }
 
 func NewOrderService(store *repository.Store) *OrderService {
@@ -19,6 +28,11 @@ func NewOrderService(store *repository.Store) *OrderService {
 func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
 	var orders []model.Order
 	var total int
+	cohort := s.cursorCohort(userID, req)
+	if cohort == canary.CohortCanary {
+		req.Pagination = model.PaginationCursor
+	}
+	start := time.Now()
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
 		var fetchErr error
 		orders, total, fetchErr = s.store.OrderRepo.ListOrders(ctx, userID, req)
@@ -27,8 +41,152 @@ func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.Li
 		}
 		return nil
 	})
+	if cohort != "" {
+		s.cursorRollout.Observe(cohort, time.Since(start), err)
+	}
 	if err != nil {
 		return nil, 0, err
 	}
+	s.shadowCursorPagination(ctx, userID, req, orders, total, time.Since(start))
 	return orders, total, nil
 }
+
+// ユーザー自身の注文を1件取得
+// 他のユーザーの注文は存在しないものとして扱う
+func (s *OrderService) GetOrder(ctx context.Context, userID int, orderID int64) (*model.Order, error) {
+	var order *model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.store.OrderRepo.FindByID(ctx, orderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		if order.UserID != userID {
+			return ErrOrderNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// 注文1件の請求内容（税抜・税額・税込）を取得
+func (s *OrderService) Invoice(ctx context.Context, userID int, orderID int64) (*model.Invoice, error) {
+	order, err := s.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	preTax := order.Value + order.ShippingCost
+	return &model.Invoice{
+		OrderID:      order.OrderID,
+		ProductName:  order.ProductName,
+		Subtotal:     order.Value,
+		ShippingCost: order.ShippingCost,
+		PreTaxTotal:  preTax,
+		TaxAmount:    order.TaxAmount,
+		Total:        preTax + order.TaxAmount,
+		CreatedAt:    order.CreatedAt,
+	}, nil
+}
+
+// 配送待ちでない注文はキャンセルできない
+var ErrOrderNotCancellable = errors.New("order is not cancellable")
+
+// ユーザー自身の配送待ちの注文をキャンセルする
+// ロボットに割り当て済み（配送中）・配送済みの注文はキャンセルできない
+func (s *OrderService) CancelOrder(ctx context.Context, userID int, orderID int64) (*model.OrderCancelResponse, error) {
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			order, err := txStore.OrderRepo.FindByID(ctx, orderID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrOrderNotFound
+				}
+				return err
+			}
+			if order.UserID != userID {
+				return ErrOrderNotFound
+			}
+			// 確認後にロボットへ割り当てられた場合も更新されないため、ステータスは更新の結果で判断する
+			cancelled, err := txStore.OrderRepo.Cancel(ctx, orderID)
+			if err != nil {
+				return err
+			}
+			if !cancelled {
+				return ErrOrderNotCancellable
+			}
+			// 注文は1件ごとに数量1で作成している
+			if err := txStore.ProductRepo.RestoreStock(ctx, order.ProductID, 1); err != nil {
+				return err
+			}
+			return txStore.EventRepo.Create(ctx, orderID, repository.OrderEventCancelled, "")
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.OrderCancelResponse{OrderID: orderID, ShippedStatus: "cancelled"}, nil
+}
+
+// ユーザーの注文を商品ごと・ステータスごとに数える
+func (s *OrderService) CountByProduct(ctx context.Context, userID int) ([]model.ProductOrderCounts, error) {
+	var counts []model.ProductOrderCounts
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		counts, err = s.store.OrderRepo.CountByProduct(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ユーザーの注文件数・金額・送料・税額の集計を取得
+func (s *OrderService) Summary(ctx context.Context, userID int) (*model.OrderSummary, error) {
+	var summary *model.OrderSummary
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		summary, err = s.store.OrderRepo.SummarizeByUser(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// ユーザーが受取人のギフトを新しい順に取得
+// 金額・送料・税額・配送先は贈り主の情報のため含めない
+func (s *OrderService) ReceivedGifts(ctx context.Context, userID, page, pageSize int) (*model.ReceivedGiftList, error) {
+	var orders []model.Order
+	var total int
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		orders, total, err = s.store.OrderRepo.ListReceivedGifts(ctx, userID, pageSize, (page-1)*pageSize)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	gifts := make([]model.ReceivedGift, len(orders))
+	for i, o := range orders {
+		gifts[i] = model.ReceivedGift{
+			OrderID:       o.OrderID,
+			ProductID:     o.ProductID,
+			ProductName:   o.ProductName,
+			ShippedStatus: o.ShippedStatus,
+			CreatedAt:     o.CreatedAt,
+			TrackingToken: o.TrackingToken,
+		}
+		if o.ArrivedAt.Valid {
+			gifts[i].ArrivedAt = &o.ArrivedAt.Time
+		}
+	}
+	return &model.ReceivedGiftList{Data: gifts, Total: total}, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"backend/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 注文一覧のcursorが復元できない
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// 注文一覧のキーセットページングで並び替えられる列
+var cursorSortFields = map[string]bool{"order_id": true, "product_name": true, "created_at": true, "shipped_status": true, "arrived_at": true}
+
+// next_cursorを位置に復元する
+func DecodeOrderCursor(s string) (*model.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor model.OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !cursorSortFields[cursor.SortField] || (cursor.SortOrder != "asc" && cursor.SortOrder != "desc") {
+		return nil, ErrInvalidCursor
+	}
+	// 値がNULLになり得るのはarrived_atのみ
+	switch {
+	case cursor.SortField == "order_id":
+		cursor.Key = nil
+	case cursor.Key == nil:
+		if cursor.SortField != "arrived_at" {
+			return nil, ErrInvalidCursor
+		}
+	case cursor.SortField == "created_at" || cursor.SortField == "arrived_at":
+		if _, err := time.Parse(time.RFC3339Nano, *cursor.Key); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// ページの最後の注文から次のページのcursorを作る
+// ページが埋まらなかった場合は最後のページとみなし空文字を返す
+func NextOrderCursor(req model.ListRequest, orders []model.Order) string {
+	if len(orders) == 0 || len(orders) < req.PageSize {
+		return ""
+	}
+	last := orders[len(orders)-1]
+	cursor := model.OrderCursor{SortField: req.SortField, SortOrder: strings.ToLower(req.SortOrder), OrderID: last.OrderID}
+	if !cursorSortFields[cursor.SortField] {
+		cursor.SortField = "order_id"
+	}
+	if cursor.SortOrder != "desc" {
+		cursor.SortOrder = "asc"
+	}
+
+	var key string
+	switch cursor.SortField {
+	case "product_name":
+		key = last.ProductName
+	case "created_at":
+		key = last.CreatedAt.Format(time.RFC3339Nano)
+	case "shipped_status":
+		key = last.ShippedStatus
+	case "arrived_at":
+		if last.ArrivedAt.Valid {
+			key = last.ArrivedAt.Time.Format(time.RFC3339Nano)
+		}
+	}
+	if cursor.SortField != "order_id" && (cursor.SortField != "arrived_at" || last.ArrivedAt.Valid) {
+		cursor.Key = &key
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"backend/internal/model"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVの書き出しを始めた後に失敗した（レスポンスのステータスは送信済みのため、途中までのCSVになる）
+var ErrExportInterrupted = errors.New("order export interrupted")
+
+// 何行ごとにCSVをレスポンスに書き出すか
+const orderExportFlushRows = 500
+
+// 書き出すCSVの列
+var orderExportColumns = []string{"order_id", "product_id", "product_name", "value", "weight", "shipped_status", "created_at", "arrived_at", "shipping_cost", "tax_amount"}
+
+// ユーザーの全注文をCSVでwに書き出し、書き出した注文数を返す
+// 検索・並び替えはFetchOrdersと同じ（ページングの指定は使わない）
+// 注文は1行ずつ読み込んで書き出すため、注文数によらずメモリ使用量は一定
+// 書き出し中はDBの接続を1つ使い続けるため、utils.WithTimeoutの時間制限はかけない（ctxのキャンセルで止まる）
+func (s *OrderService) ExportOrders(ctx context.Context, userID int, req model.ListRequest, w io.Writer) (int, error) {
+	it, err := s.store.OrderRepo.IterateByUser(ctx, userID, req)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(orderExportColumns); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrExportInterrupted, err)
+	}
+	// 最初に書き出すまでに読み込みで失敗した場合は、まだレスポンスを送っていないためそのまま返す
+	flushed := false
+	readFailed := func(err error) error {
+		if flushed {
+			return fmt.Errorf("%w: %v", ErrExportInterrupted, err)
+		}
+		return err
+	}
+	record := make([]string, len(orderExportColumns))
+	rows := 0
+	for it.Next() {
+		var order model.Order
+		if err := it.Scan(&order); err != nil {
+			return rows, readFailed(err)
+		}
+		record[0] = strconv.FormatInt(order.OrderID, 10)
+		record[1] = strconv.Itoa(order.ProductID)
+		record[2] = order.ProductName
+		record[3] = strconv.Itoa(order.Value)
+		record[4] = strconv.Itoa(order.Weight)
+		record[5] = order.ShippedStatus
+		record[6] = order.CreatedAt.Format(time.RFC3339)
+		record[7] = ""
+		if order.ArrivedAt.Valid {
+			record[7] = order.ArrivedAt.Time.Format(time.RFC3339)
+		}
+		record[8] = strconv.Itoa(order.ShippingCost)
+		record[9] = strconv.Itoa(order.TaxAmount)
+		if err := writer.Write(record); err != nil {
+			return rows, fmt.Errorf("%w: %v", ErrExportInterrupted, err)
+		}
+		rows++
+		if rows%orderExportFlushRows == 0 {
+			writer.Flush()
+			flushed = true
+			if err := writer.Error(); err != nil {
+				return rows, fmt.Errorf("%w: %v", ErrExportInterrupted, err)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return rows, readFailed(err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return rows, fmt.Errorf("%w: %v", ErrExportInterrupted, err)
+	}
+	return rows, nil
+}
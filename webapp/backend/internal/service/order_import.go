@@ -0,0 +1,265 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVのヘッダー・形式が不正
+var ErrInvalidImportCSV = errors.New("invalid import csv")
+
+const (
+	// 1回のトランザクションで取り込む行数
+	orderImportBatchSize = 1000
+	// レスポンスに含める不正な行の上限
+	maxOrderImportErrors = 100
+	// 外部参照IDの最大長（orders.external_ref）
+	maxExternalRefLength = 64
+)
+
+// 取り込むCSVの列（ヘッダーで指定し、順序は問わない。arrived_atは省略可）
+var orderImportColumns = []string{"external_ref", "user_id", "product_id", "shipped_status", "created_at", "arrived_at"}
+
+// 取り込める注文のステータス（配送中はロボットの割り当てを伴うため取り込めない）
+var importableStatuses = map[string]bool{"shipping": true, "completed": true}
+
+// 日時の形式（タイムゾーンのないものはUTCとみなす）
+var orderImportTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+type importRow struct {
+	line  int
+	order model.ImportedOrder
+}
+
+// 旧システムの注文をCSVから取り込む
+// 不正な行は取り込まずに報告し、それ以外の行をorderImportBatchSize件ずつ別のトランザクションで取り込む
+// 取り込み済みの外部参照IDの行は取り込まないため、同じCSVを何度取り込んでも重複しない
+func (s *AdminService) ImportOrders(ctx context.Context, r io.Reader) (*model.OrderImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidImportCSV)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportCSV, err)
+	}
+	columns, err := importColumnIndexes(header)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.OrderImportReport{Errors: []model.OrderImportError{}}
+	seen := make(map[string]int)
+	batch := make([]importRow, 0, orderImportBatchSize)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			report.Rows++
+			addImportError(report, model.OrderImportError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		report.Rows++
+		line, _ := reader.FieldPos(0)
+
+		order, err := parseImportRecord(record, columns)
+		if err != nil {
+			addImportError(report, model.OrderImportError{Line: line, ExternalRef: order.ExternalRef, Message: err.Error()})
+			continue
+		}
+		if first, ok := seen[order.ExternalRef]; ok {
+			addImportError(report, model.OrderImportError{Line: line, ExternalRef: order.ExternalRef, Message: fmt.Sprintf("duplicate external_ref (first on line %d)", first)})
+			continue
+		}
+		seen[order.ExternalRef] = line
+
+		batch = append(batch, importRow{line: line, order: order})
+		if len(batch) == orderImportBatchSize {
+			if err := s.importBatch(ctx, batch, report); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := s.importBatch(ctx, batch, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// 取り込み済みの行を除き、ユーザー・商品が存在する行を取り込む
+func (s *AdminService) importBatch(ctx context.Context, batch []importRow, report *model.OrderImportReport) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var imported, skipped int
+		var invalid []model.OrderImportError
+		err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			refs := make([]string, len(batch))
+			userIDs := make([]int, 0, len(batch))
+			productIDs := make([]int, 0, len(batch))
+			for i, row := range batch {
+				refs[i] = row.order.ExternalRef
+				userIDs = append(userIDs, row.order.UserID)
+				productIDs = append(productIDs, row.order.ProductID)
+			}
+			existingRefs, err := txStore.OrderRepo.ExistingExternalRefs(ctx, refs)
+			if err != nil {
+				return err
+			}
+			existingUsers, err := txStore.UserRepo.ExistingIDs(ctx, userIDs)
+			if err != nil {
+				return err
+			}
+			products, err := txStore.ProductRepo.FindByIDs(ctx, productIDs)
+			if err != nil {
+				return err
+			}
+			alreadyImported := make(map[string]bool, len(existingRefs))
+			for _, ref := range existingRefs {
+				alreadyImported[ref] = true
+			}
+			userExists := make(map[int]bool, len(existingUsers))
+			for _, id := range existingUsers {
+				userExists[id] = true
+			}
+			productExists := make(map[int]bool, len(products))
+			for _, p := range products {
+				productExists[p.ProductID] = true
+			}
+
+			orders := make([]model.ImportedOrder, 0, len(batch))
+			for _, row := range batch {
+				switch {
+				case alreadyImported[row.order.ExternalRef]:
+					skipped++
+				case !userExists[row.order.UserID]:
+					invalid = append(invalid, model.OrderImportError{Line: row.line, ExternalRef: row.order.ExternalRef, Message: fmt.Sprintf("user %d not found", row.order.UserID)})
+				case !productExists[row.order.ProductID]:
+					invalid = append(invalid, model.OrderImportError{Line: row.line, ExternalRef: row.order.ExternalRef, Message: fmt.Sprintf("product %d not found", row.order.ProductID)})
+				default:
+					orders = append(orders, row.order)
+				}
+			}
+			ids, err := txStore.OrderRepo.ImportBulk(ctx, orders)
+			if err != nil {
+				return err
+			}
+			imported = len(ids)
+			// 確認後に他の取り込みで登録された行は取り込まれない
+			skipped += len(orders) - len(ids)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		report.Imported += imported
+		report.Skipped += skipped
+		for _, e := range invalid {
+			addImportError(report, e)
+		}
+		return nil
+	})
+}
+
+// ヘッダーから列の位置を求める（arrived_at以外の列は必須）
+func importColumnIndexes(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range orderImportColumns {
+		if _, ok := columns[name]; !ok && name != "arrived_at" {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImportCSV, name)
+		}
+	}
+	return columns, nil
+}
+
+// 1行を注文に変換する（エラー時も外部参照IDは返す）
+func parseImportRecord(record []string, columns map[string]int) (model.ImportedOrder, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	order := model.ImportedOrder{ExternalRef: field("external_ref")}
+	if order.ExternalRef == "" {
+		return order, errors.New("external_ref is required")
+	}
+	if len(order.ExternalRef) > maxExternalRefLength {
+		return order, fmt.Errorf("external_ref must be at most %d characters", maxExternalRefLength)
+	}
+
+	var err error
+	if order.UserID, err = strconv.Atoi(field("user_id")); err != nil || order.UserID <= 0 {
+		return order, fmt.Errorf("invalid user_id %q", field("user_id"))
+	}
+	if order.ProductID, err = strconv.Atoi(field("product_id")); err != nil || order.ProductID <= 0 {
+		return order, fmt.Errorf("invalid product_id %q", field("product_id"))
+	}
+	order.ShippedStatus = field("shipped_status")
+	if !importableStatuses[order.ShippedStatus] {
+		return order, fmt.Errorf("unsupported shipped_status %q (must be shipping or completed)", order.ShippedStatus)
+	}
+	if order.CreatedAt, err = parseImportTime(field("created_at")); err != nil {
+		return order, fmt.Errorf("invalid created_at %q", field("created_at"))
+	}
+	if arrivedAt := field("arrived_at"); arrivedAt != "" {
+		t, err := parseImportTime(arrivedAt)
+		if err != nil {
+			return order, fmt.Errorf("invalid arrived_at %q", arrivedAt)
+		}
+		if t.Before(order.CreatedAt) {
+			return order, errors.New("arrived_at is before created_at")
+		}
+		order.ArrivedAt = sql.NullTime{Time: t, Valid: true}
+	}
+	switch {
+	case order.ShippedStatus == "completed" && !order.ArrivedAt.Valid:
+		return order, errors.New("arrived_at is required for completed orders")
+	case order.ShippedStatus == "shipping" && order.ArrivedAt.Valid:
+		return order, errors.New("arrived_at must be empty for shipping orders")
+	}
+	return order, nil
+}
+
+func parseImportTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range orderImportTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func addImportError(report *model.OrderImportReport, e model.OrderImportError) {
+	report.Invalid++
+	if len(report.Errors) < maxOrderImportErrors {
+		report.Errors = append(report.Errors, e)
+	}
+}
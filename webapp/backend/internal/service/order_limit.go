@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 注文数量の上限超過を表すエラー（errors.Isで判定可能）
+var ErrOrderLimitExceeded = errors.New("order quantity limit exceeded")
+
+// 上限の種別
+const (
+	OrderLimitPerRequest  = "per_request"
+	OrderLimitPerUserHour = "per_user_hour"
+)
+
+// 注文数量の上限（0以下の場合は無制限）
+type OrderLimits struct {
+	// 1リクエストで注文できる合計数量
+	MaxQuantityPerRequest int
+	// 1ユーザーが直近1時間に注文できる合計数量
+	MaxQuantityPerUserHour int
+}
+
+// 上限超過の詳細
+type OrderLimitError struct {
+	Limit     string
+	Max       int
+	Requested int
+}
+
+func (e *OrderLimitError) Error() string {
+	return fmt.Sprintf("%s: %s max=%d requested=%d", ErrOrderLimitExceeded, e.Limit, e.Max, e.Requested)
+}
+
+func (e *OrderLimitError) Is(target error) bool {
+	return target == ErrOrderLimitExceeded
+}
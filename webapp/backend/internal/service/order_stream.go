@@ -0,0 +1,161 @@
+package service
+
+import (
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// 配送待ちのステータス変更イベントの上限（超えた分は破棄する）
+	orderStreamQueueSize = 1024
+	// 購読者ごとに溜めておく通知の上限（読み出しが遅い購読者への通知は破棄する）
+	orderStreamSubscriberBuffer = 64
+	// 注文の持ち主を調べるクエリのタイムアウト
+	orderStreamLookupTimeout = 5 * time.Second
+)
+
+// 注文のステータス変更を、その注文のユーザーの購読者（SSE接続）に配送する
+// イベントバスの購読はブロックしてはいけないため、持ち主の検索と配送はRunで行う
+type OrderStatusStream struct {
+	store *repository.Store
+	queue chan events.Event
+	// 1ユーザーがウォッチできる注文数の上限
+	watchLimit int
+
+	mutex sync.RWMutex
+	// 購読者ごとに、ウォッチ中の注文のみを送るか
+	subscribers map[int]map[chan model.OrderStatusEvent]bool
+	// ユーザーごとのウォッチ中の注文
+	watches map[int]map[int64]struct{}
+	closed  bool
+}
+
+func NewOrderStatusStream(store *repository.Store, watchLimit int) *OrderStatusStream {
+	return &OrderStatusStream{
+		store:       store,
+		queue:       make(chan events.Event, orderStreamQueueSize),
+		watchLimit:  watchLimit,
+		subscribers: make(map[int]map[chan model.OrderStatusEvent]bool),
+		watches:     make(map[int]map[int64]struct{}),
+	}
+}
+
+// ユーザーの注文のステータス変更を購読する
+// 返した関数で購読を解除すること（解除後はチャネルに送られない）
+// Closeされるとチャネルは閉じられる
+func (s *OrderStatusStream) Subscribe(userID int) (<-chan model.OrderStatusEvent, func()) {
+	return s.subscribe(userID, false)
+}
+
+// ユーザーがウォッチ中の注文のステータス変更のみを購読する（Subscribeと同じく解除すること）
+func (s *OrderStatusStream) SubscribeWatched(userID int) (<-chan model.OrderStatusEvent, func()) {
+	return s.subscribe(userID, true)
+}
+
+func (s *OrderStatusStream) subscribe(userID int, watchedOnly bool) (<-chan model.OrderStatusEvent, func()) {
+	ch := make(chan model.OrderStatusEvent, orderStreamSubscriberBuffer)
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[chan model.OrderStatusEvent]bool)
+	}
+	s.subscribers[userID][ch] = watchedOnly
+	s.mutex.Unlock()
+
+	return ch, func() {
+		s.mutex.Lock()
+		delete(s.subscribers[userID], ch)
+		if len(s.subscribers[userID]) == 0 {
+			delete(s.subscribers, userID)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// すべての購読者のチャネルを閉じ、以降の購読を受け付けない
+// 接続し続けるストリームがサーバーの停止を妨げないよう、停止の開始時に呼ぶ
+func (s *OrderStatusStream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	for _, subs := range s.subscribers {
+		for ch := range subs {
+			close(ch)
+		}
+	}
+	clear(s.subscribers)
+}
+
+// イベントバスのOrderStatusChangedの購読者（購読者・ウォッチ中の注文がなければ何もしない）
+func (s *OrderStatusStream) OnOrderStatusChanged(ev events.Event) {
+	s.mutex.RLock()
+	idle := len(s.subscribers) == 0 && len(s.watches) == 0
+	s.mutex.RUnlock()
+	if idle {
+		return
+	}
+	select {
+	case s.queue <- ev:
+	default:
+		slog.Warn("[OrderStatusStream] queue is full; dropped status changes", "dropped", len(ev.IDs))
+	}
+}
+
+// ctxがキャンセルされるまで、ステータス変更を購読者に配送する（呼び出し元をブロックする）
+func (s *OrderStatusStream) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-s.queue:
+			if err := s.dispatch(ctx, ev); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "[OrderStatusStream] failed to dispatch status changes", "err", err)
+			}
+		}
+	}
+}
+
+func (s *OrderStatusStream) dispatch(ctx context.Context, ev events.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, orderStreamLookupTimeout)
+	defer cancel()
+	owners, err := s.store.OrderRepo.FindOwners(ctx, ev.IDs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, orderID := range ev.IDs {
+		owner := owners[orderID]
+		_, watched := s.watches[owner][orderID]
+		// 配送完了・キャンセルになった注文はこの変更を最後にウォッチを解除する
+		if watched && orderWatchEnded(ev.Status) {
+			s.unwatchLocked(owner, orderID)
+		}
+		subs := s.subscribers[owner]
+		if len(subs) == 0 {
+			continue
+		}
+		change := model.OrderStatusEvent{OrderID: orderID, ShippedStatus: ev.Status, ChangedAt: now}
+		for ch, watchedOnly := range subs {
+			if watchedOnly && !watched {
+				continue
+			}
+			select {
+			case ch <- change:
+			default:
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/service/utils"
+	"context"
+	"time"
+)
+
+// 注文前チェックで返す問題の種類
+const (
+	ValidationProductNotFound  = "product_not_found"
+	ValidationUnavailable      = "product_unavailable"
+	ValidationInvalidQuantity  = "invalid_quantity"
+	ValidationPerRequestLimit  = "exceeds_" + OrderLimitPerRequest + "_limit"
+	ValidationPerUserHourLimit = "exceeds_" + OrderLimitPerUserHour + "_limit"
+)
+
+// 注文を作成せずに、商品の存在と数量の上限を確認する
+// 在庫・クーポンはこのスキーマにないため確認しない
+func (s *ProductService) ValidateOrder(ctx context.Context, userID int, items []model.RequestItem) (*model.OrderValidationResponse, error) {
+	resp := &model.OrderValidationResponse{Valid: true, Items: make([]model.OrderItemDiagnostic, len(items))}
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		productIDs := make([]int, 0, len(items))
+		for _, item := range items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		products, err := s.store.ProductRepo.FindByIDs(ctx, productIDs)
+		if err != nil {
+			return err
+		}
+		exists := make(map[int]bool, len(products))
+		available := make(map[int]bool, len(products))
+		now := time.Now()
+		for _, p := range products {
+			exists[p.ProductID] = true
+			available[p.ProductID] = p.AvailableAt(now)
+		}
+
+		totalQuantity := 0
+		for i, item := range items {
+			diag := model.OrderItemDiagnostic{ProductID: item.ProductID, Quantity: item.Quantity, Valid: true}
+			if !exists[item.ProductID] {
+				diag.Errors = append(diag.Errors, ValidationProductNotFound)
+			} else if !available[item.ProductID] {
+				diag.Errors = append(diag.Errors, ValidationUnavailable)
+			}
+			if item.Quantity < 0 {
+				diag.Errors = append(diag.Errors, ValidationInvalidQuantity)
+			} else {
+				totalQuantity += item.Quantity
+			}
+			if len(diag.Errors) > 0 {
+				diag.Valid = false
+				resp.Valid = false
+			}
+			resp.Items[i] = diag
+		}
+
+		if max := s.limits.MaxQuantityPerRequest; max > 0 && totalQuantity > max {
+			resp.Errors = append(resp.Errors, ValidationPerRequestLimit)
+		}
+		if max := s.limits.MaxQuantityPerUserHour; max > 0 {
+			recent, err := s.store.OrderRepo.CountCreatedSince(ctx, userID, time.Now().Add(-time.Hour))
+			if err != nil {
+				return err
+			}
+			if recent+totalQuantity > max {
+				resp.Errors = append(resp.Errors, ValidationPerUserHourLimit)
+			}
+		}
+		if len(resp.Errors) > 0 {
+			resp.Valid = false
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"backend/internal/model"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ウォッチ中の注文数が上限に達している
+	ErrTooManyWatches = errors.New("too many watched orders")
+	// 配送完了・キャンセル済みの注文はウォッチできない
+	ErrOrderNotWatchable = errors.New("order is no longer watchable")
+)
+
+// 以降ステータスが変わらない注文か
+func orderWatchEnded(status string) bool {
+	return status == "completed" || status == "cancelled"
+}
+
+// 注文をウォッチし、ユーザーのウォッチ中の注文数を返す（ウォッチ中の注文は何もしない）
+// ウォッチ中の注文はSubscribeWatchedの購読者に送られ、配送完了・キャンセルになると自動で解除される
+// ウォッチはこのプロセスのメモリにのみ保持する
+func (s *OrderStatusStream) Watch(userID int, order *model.Order) (int, error) {
+	if orderWatchEnded(order.ShippedStatus) {
+		return 0, ErrOrderNotWatchable
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	watches := s.watches[userID]
+	if _, ok := watches[order.OrderID]; ok {
+		return len(watches), nil
+	}
+	if len(watches) >= s.watchLimit {
+		return len(watches), fmt.Errorf("%w: at most %d orders", ErrTooManyWatches, s.watchLimit)
+	}
+	if watches == nil {
+		watches = make(map[int64]struct{})
+		s.watches[userID] = watches
+	}
+	watches[order.OrderID] = struct{}{}
+	return len(watches), nil
+}
+
+// 注文のウォッチを解除し、ユーザーのウォッチ中の注文数を返す（ウォッチしていない注文は何もしない）
+func (s *OrderStatusStream) Unwatch(userID int, orderID int64) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unwatchLocked(userID, orderID)
+	return len(s.watches[userID])
+}
+
+// 1ユーザーがウォッチできる注文数の上限
+func (s *OrderStatusStream) WatchLimit() int {
+	return s.watchLimit
+}
+
+func (s *OrderStatusStream) unwatchLocked(userID int, orderID int64) {
+	delete(s.watches[userID], orderID)
+	if len(s.watches[userID]) == 0 {
+		delete(s.watches, userID)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"backend/internal/model"
+	"sync"
+	"time"
+)
+
+// 1回の配送計画リクエストで除外を指定できる注文数の上限
+const MaxPlanExclusions = 1000
+
+// ロボットが見つけられなかった注文など、一定時間配送計画から除外する注文
+// 除外はすべてのロボットの計画に適用し、期限が切れると再び計画の候補になる
+type PlanExclusions struct {
+	cooldown time.Duration
+	until    map[int64]time.Time
+	mutex    sync.Mutex
+}
+
+// cooldownが0以下の場合は記録せず、指定したリクエストの計画からのみ除外する
+func NewPlanExclusions(cooldown time.Duration) *PlanExclusions {
+	return &PlanExclusions{cooldown: cooldown, until: make(map[int64]time.Time)}
+}
+
+// 注文をnowからcooldownの間除外する（除外中の注文は期限を延長する）
+func (e *PlanExclusions) Add(orderIDs []int64, now time.Time) {
+	if e.cooldown <= 0 || len(orderIDs) == 0 {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for id, until := range e.until {
+		if !now.Before(until) {
+			delete(e.until, id)
+		}
+	}
+	for _, id := range orderIDs {
+		e.until[id] = now.Add(e.cooldown)
+	}
+}
+
+// 除外中の注文とextraの注文を除いた注文を返す（除外する注文がなければordersをそのまま返す）
+func (e *PlanExclusions) Filter(orders []model.Order, extra []int64, now time.Time) []model.Order {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.until) == 0 && len(extra) == 0 {
+		return orders
+	}
+	skip := make(map[int64]bool, len(extra))
+	for _, id := range extra {
+		skip[id] = true
+	}
+	filtered := make([]model.Order, 0, len(orders))
+	for _, o := range orders {
+		if until, ok := e.until[o.OrderID]; skip[o.OrderID] || ok && now.Before(until) {
+			continue
+		}
+		filtered = append(filtered, o)
+	}
+	return filtered
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"backend/internal/budget"
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/service/utils"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// 待ち時間の指定がない場合・設定がない場合の上限
+	defaultPlanWaitMax = 25 * time.Second
+	// 他のインスタンスで作成された注文に気づくための確認間隔（設定がない場合）
+	defaultPlanWaitPoll = 2 * time.Second
+	// 注文の作成が続く間も、1つの待機がDBを確認する間隔はこれより短くしない
+	planWaitMinInterval = 200 * time.Millisecond
+	// リクエストのデッドラインより前に応答するための余裕
+	planWaitDeadlineMargin = 500 * time.Millisecond
+)
+
+// 配送待ちの注文が増えたことを、配送計画を待っているリクエストに知らせる
+// このプロセスで発行されたイベントのみを扱うため、他のインスタンスの注文はRobotServiceConfig.PlanWaitPollの間隔で確認する
+type PlanSignal struct {
+	mutex   sync.Mutex
+	changed chan struct{}
+}
+
+func NewPlanSignal() *PlanSignal {
+	return &PlanSignal{changed: make(chan struct{})}
+}
+
+// 次に配送待ちの注文が増えたときに閉じられるチャネル
+// 確認の前に取得しておくと、確認中に増えた場合も取りこぼさない
+func (s *PlanSignal) Changed() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.changed
+}
+
+func (s *PlanSignal) notify() {
+	s.mutex.Lock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mutex.Unlock()
+}
+
+// OrderCreatedの購読用
+func (s *PlanSignal) OnOrderCreated(events.Event) {
+	s.notify()
+}
+
+// 配送待ちに戻った注文を知らせる（OrderStatusChangedの購読用）
+func (s *PlanSignal) OnOrderStatusChanged(ev events.Event) {
+	if ev.Status == "shipping" {
+		s.notify()
+	}
+}
+
+// 積載量capacityのロボットが運ぶ配送待ち注文が十分に溜まるまで待つ（ロングポーリング）
+// 積載量に収まる注文の重量の合計が、積載量のPlanWaitMinLoadPercent%以上になればReadyとして返す
+// 重量の合計は目安のため、Readyでも計画した注文の重量がこれに届かない場合がある
+// timeoutまで（0または上限を超える場合はPlanWaitMaxまで）に溜まらなければ、Readyでない状態を返す
+func (s *RobotService) WaitForPlan(ctx context.Context, capacity int, timeout time.Duration) (*model.PlanAvailability, error) {
+	capacity, _, err := s.validateCapacity(capacity)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 || timeout > s.cfg.PlanWaitMax {
+		timeout = s.cfg.PlanWaitMax
+	}
+	// リクエストのデッドラインで打ち切られる前に、Readyでない状態を返す
+	if remaining, ok := budget.Remaining(ctx); ok {
+		timeout = min(timeout, remaining-planWaitDeadlineMargin)
+	}
+	required := max((capacity*s.cfg.PlanWaitMinLoadPercent+99)/100, 1)
+
+	start := time.Now()
+	deadline := time.NewTimer(max(timeout, 0))
+	defer deadline.Stop()
+	poll := time.NewTicker(s.cfg.PlanWaitPoll)
+	defer poll.Stop()
+	for {
+		changed := s.cfg.PlanSignal.Changed()
+		checkedAt := time.Now()
+		var load model.ShippingLoad
+		err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+			var err error
+			load, err = s.store.OrderRepo.ShippingLoad(ctx, capacity)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		availability := &model.PlanAvailability{
+			Ready:           load.Weight >= required,
+			Capacity:        capacity,
+			ShippingOrders:  load.Orders,
+			AvailableWeight: load.Weight,
+			RequiredWeight:  required,
+			WaitedMs:        time.Since(start).Milliseconds(),
+		}
+		if availability.Ready {
+			return availability, nil
+		}
+
+		select {
+		case <-changed:
+		case <-poll.C:
+		case <-deadline.C:
+			availability.WaitedMs = time.Since(start).Milliseconds()
+			return availability, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// 注文の作成が続く間に確認が重ならないよう、前回の確認から間隔を空ける
+		if wait := planWaitMinInterval - time.Since(checkedAt); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
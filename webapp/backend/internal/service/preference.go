@@ -0,0 +1,120 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+const (
+	maxPreferencePageSize = 100
+	// キャッシュするユーザー数の上限（超えたら期限切れのものを削除）
+	maxCachedPreferences = 10000
+)
+
+// 設定可能なロケール
+var supportedLocales = []string{"ja", "en"}
+
+type cachedPreferences struct {
+	prefs    model.UserPreferences
+	loadedAt time.Time
+}
+
+type PreferenceService struct {
+	store *repository.Store
+	ttl   time.Duration
+	mutex sync.RWMutex
+	cache map[int]cachedPreferences
+}
+
+func NewPreferenceService(store *repository.Store, ttl time.Duration) *PreferenceService {
+	return &PreferenceService{store: store, ttl: ttl, cache: make(map[int]cachedPreferences)}
+}
+
+// ユーザーの設定を取得（TTLの間はメモリから返す）
+func (s *PreferenceService) Get(ctx context.Context, userID int) (model.UserPreferences, error) {
+	s.mutex.RLock()
+	cached, ok := s.cache[userID]
+	s.mutex.RUnlock()
+	if ok && time.Since(cached.loadedAt) <= s.ttl {
+		return cached.prefs, nil
+	}
+
+	var prefs model.UserPreferences
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		prefs, err = s.store.PreferenceRepo.Find(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return model.UserPreferences{}, err
+	}
+	s.remember(userID, prefs)
+	return prefs, nil
+}
+
+// ユーザーの設定を検証して保存する
+func (s *PreferenceService) Update(ctx context.Context, userID int, prefs model.UserPreferences) (model.UserPreferences, error) {
+	if prefs.PageSize != nil && (*prefs.PageSize <= 0 || *prefs.PageSize > maxPreferencePageSize) {
+		return model.UserPreferences{}, ErrInvalidPreferences
+	}
+	if prefs.SortOrder != nil {
+		order := strings.ToLower(*prefs.SortOrder)
+		if order != "asc" && order != "desc" {
+			return model.UserPreferences{}, ErrInvalidPreferences
+		}
+		prefs.SortOrder = &order
+	}
+	if prefs.Locale != nil && !slices.Contains(supportedLocales, *prefs.Locale) {
+		return model.UserPreferences{}, ErrInvalidPreferences
+	}
+
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.PreferenceRepo.Upsert(ctx, userID, prefs)
+	})
+	if err != nil {
+		return model.UserPreferences{}, err
+	}
+	s.remember(userID, prefs)
+	return prefs, nil
+}
+
+// 一覧リクエストで省略された値をユーザーの設定で補う
+// 設定の取得に失敗しても一覧自体は返せるよう、エラー時は何もしない
+func (s *PreferenceService) ApplyDefaults(ctx context.Context, userID int, req *model.ListRequest) {
+	if req.PageSize > 0 && req.SortOrder != "" {
+		return
+	}
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return
+	}
+	if req.PageSize <= 0 && prefs.PageSize != nil {
+		req.PageSize = *prefs.PageSize
+	}
+	if req.SortOrder == "" && prefs.SortOrder != nil {
+		req.SortOrder = *prefs.SortOrder
+	}
+}
+
+func (s *PreferenceService) remember(userID int, prefs model.UserPreferences) {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.cache) >= maxCachedPreferences {
+		for id, c := range s.cache {
+			if now.Sub(c.loadedAt) > s.ttl {
+				delete(s.cache, id)
+			}
+		}
+	}
+	s.cache[userID] = cachedPreferences{prefs: prefs, loadedAt: now}
+}
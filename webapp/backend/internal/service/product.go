@@ -2,37 +2,168 @@ package service
 
 import (
 	"context"
-	"log"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"backend/internal/canary"
+	"backend/internal/geocode"
 	"backend/internal/model"
+	"backend/internal/planner"
 	"backend/internal/repository"
+	"backend/internal/search"
+	"backend/internal/service/utils"
+	"backend/internal/shadow"
+	"backend/internal/shipping"
+	"backend/internal/tax"
 )
 
+// 注文できる期間外の商品が含まれている
+var ErrProductUnavailable = errors.New("product is not available")
+
+type ProductUnavailableError struct {
+	ProductID int
+}
+
+func (e *ProductUnavailableError) Error() string {
+	return fmt.Sprintf("%s: product_id=%d", ErrProductUnavailable, e.ProductID)
+}
+
+func (e *ProductUnavailableError) Is(target error) bool {
+	return target == ErrProductUnavailable
+}
+
+// 在庫が注文数量に足りない商品が含まれている
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+type InsufficientStockError struct {
+	ProductID int
+	Requested int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("%s: product_id=%d requested=%d", ErrInsufficientStock, e.ProductID, e.Requested)
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
 type ProductService struct {
-	store *repository.Store
+	store    *repository.Store
+	geocoder geocode.Geocoder
+	shipping shipping.Calculator
+	tax      tax.Engine
+	synonyms *search.SynonymExpander
+	// 外部検索バックエンド（未設定の場合はnil）
+	searchBackend search.Backend
+	limits        OrderLimits
+	// 配送待ち注文の価値密度順インデックス（作成した注文を追加する）
+	density *planner.DensityIndex
+	// LIKE検索との比較（未設定の場合は比較しない）
+	shadow *shadow.Runner
+	// ngram全文検索への段階的な切り替え（未設定の場合は全員が全文検索）
+	searchRollout *canary.Rollout
+	// おすすめ商品の抽出表と、抽出の重みとして注文数に加える値
+	featured           atomic.Pointer[featuredTable]
+	featuredBaseWeight float64
+
+	// 注文できる期間の変化を前回確認した時刻
+	availabilityMutex     sync.Mutex
+	availabilityCheckedAt time.Time
+}
+
+func NewProductService(store *repository.Store, geocoder geocode.Geocoder, shippingCalc shipping.Calculator, taxEngine tax.Engine, synonyms *search.SynonymExpander, searchBackend search.Backend, limits OrderLimits, density *planner.DensityIndex) *ProductService {
+	return &ProductService{
+		store:         store,
+		geocoder:      geocoder,
+		shipping:      shippingCalc,
+		tax:           taxEngine,
+		synonyms:      synonyms,
+		searchBackend: searchBackend,
+		limits:        limits,
+		density:       density,
+
+		availabilityCheckedAt: time.Now(),
+	}
+}
+
+func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem, address string) ([]int64, error) {
+	return s.createOrders(ctx, userID, items, model.DeliveryAddress{Address: address})
 }
 
-func NewProductService(store *repository.Store) *ProductService {
-	return &ProductService{store: store}
+// 自分以外の存在するユーザーでなければならない
+var ErrInvalidGiftRecipient = errors.New("invalid gift recipient")
+
+// recipientUserIDへのギフトとして注文する
+// 注文数の上限・注文一覧・請求は贈り主（userID）のものとして扱い、受取人には配送完了時に通知する
+func (s *ProductService) CreateGiftOrders(ctx context.Context, userID, recipientUserID int, items []model.RequestItem, address string) ([]int64, error) {
+	if recipientUserID == userID {
+		return nil, ErrInvalidGiftRecipient
+	}
+	existing, err := s.store.UserRepo.ExistingIDs(ctx, []int{recipientUserID})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, ErrInvalidGiftRecipient
+	}
+	return s.createOrders(ctx, userID, items, model.DeliveryAddress{Address: address, RecipientUserID: &recipientUserID})
 }
 
-func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) ([]string, error) {
-	var insertedOrderIDs []string
+func (s *ProductService) createOrders(ctx context.Context, userID int, items []model.RequestItem, addr model.DeliveryAddress) ([]int64, error) {
+	var insertedOrderIDs []int64
+	var lines []model.OrderLine
+
+	// 数量が0より大きいアイテムのみを処理
+	var validItems []model.RequestItem
+	totalQuantity := 0
+	for _, item := range items {
+		if item.Quantity > 0 {
+			validItems = append(validItems, item)
+			totalQuantity += item.Quantity
+		}
+	}
+	if len(validItems) == 0 {
+		return nil, nil
+	}
+	// 1リクエストで大量の行が作成されないよう、DBに触れる前に弾く
+	if max := s.limits.MaxQuantityPerRequest; max > 0 && totalQuantity > max {
+		return nil, &OrderLimitError{Limit: OrderLimitPerRequest, Max: max, Requested: totalQuantity}
+	}
+
+	// 住所の座標変換はトランザクション外で行う（外部API呼び出しでロックを保持しないため）
+	// 座標が決まっている場合（再注文で元の注文の座標を使う場合）は変換しない
+	if addr.Latitude == nil || addr.Longitude == nil {
+		recipientUserID := addr.RecipientUserID
+		addr = s.resolveAddress(ctx, userID, addr.Address)
+		addr.RecipientUserID = recipientUserID
+	}
 
 	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-		// 数量が0より大きいアイテムのみを処理
-		var validItems []model.RequestItem
-		for _, item := range items {
-			if item.Quantity > 0 {
-				validItems = append(validItems, item)
-			}
+		if err := s.checkUserHourlyLimit(ctx, txStore, userID, totalQuantity); err != nil {
+			return err
 		}
-		if len(validItems) == 0 {
-			return nil
+
+		var err error
+		lines, err = s.buildOrderLines(ctx, txStore, validItems, addr)
+		if err != nil {
+			return err
+		}
+		if err := reserveStock(ctx, txStore, lines); err != nil {
+			return err
 		}
 
 		// バルクINSERTで一括作成
-		orderIDs, err := txStore.OrderRepo.CreateBulk(ctx, userID, validItems)
+		orderIDs, err := txStore.OrderRepo.CreateBulk(ctx, userID, lines, addr)
 		if err != nil {
 			return err
 		}
@@ -43,11 +174,315 @@ func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []m
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Created %d orders for user %d", len(insertedOrderIDs), userID)
+	s.indexCreatedOrders(lines, insertedOrderIDs)
+	slog.InfoContext(ctx, "Created orders", "orders", len(insertedOrderIDs), "user_id", userID)
 	return insertedOrderIDs, nil
 }
 
-func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+// 過去の注文と同じ商品・配送先（住所・座標）・ギフトの受取人で新しい注文を作成する
+// 他のユーザーの注文や、商品が削除された注文はErrOrderNotFound
+// 数量の上限は通常の注文と同じく確認する
+func (s *ProductService) Reorder(ctx context.Context, userID int, orderID int64) ([]int64, error) {
+	var orderIDs []int64
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		order, err := s.store.OrderRepo.FindByID(ctx, orderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		if order.UserID != userID {
+			return ErrOrderNotFound
+		}
+		addr := model.DeliveryAddress{
+			Latitude:        order.Latitude,
+			Longitude:       order.Longitude,
+			RecipientUserID: order.RecipientUserID,
+		}
+		if order.Address != nil {
+			addr.Address = *order.Address
+		}
+		orderIDs, err = s.createOrders(ctx, userID, []model.RequestItem{{ProductID: order.ProductID, Quantity: 1}}, addr)
+		return err
+	})
+	return orderIDs, err
+}
+
+// 作成した注文を配送計画用のインデックスに追加する
+// orderIDsは注文行の順に数量分並んでいる
+func (s *ProductService) indexCreatedOrders(lines []model.OrderLine, orderIDs []int64) {
+	orders := make([]model.Order, 0, len(orderIDs))
+	i := 0
+	for _, line := range lines {
+		for q := 0; q < line.Quantity && i < len(orderIDs); q++ {
+			orders = append(orders, model.Order{OrderID: orderIDs[i], Weight: line.Weight, Value: line.Value})
+			i++
+		}
+	}
+	s.density.Add(orders...)
+}
+
+// 直近1時間の注文数量と合わせて上限を超えないか確認する
+// 同一ユーザーの同時リクエストで上限をすり抜けないよう、ユーザー行をロックしてから数える
+func (s *ProductService) checkUserHourlyLimit(ctx context.Context, txStore *repository.Store, userID, quantity int) error {
+	max := s.limits.MaxQuantityPerUserHour
+	if max <= 0 {
+		return nil
+	}
+	if err := txStore.UserRepo.LockByID(ctx, userID); err != nil {
+		return err
+	}
+	recent, err := txStore.OrderRepo.CountCreatedSince(ctx, userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent+quantity > max {
+		return &OrderLimitError{Limit: OrderLimitPerUserHour, Max: max, Requested: recent + quantity}
+	}
+	return nil
+}
+
+// 商品ごとに送料と税額を見積もり、注文行を組み立てる
+// 存在しない商品が含まれていればErrProductNotFoundを返す
+func (s *ProductService) buildOrderLines(ctx context.Context, txStore *repository.Store, items []model.RequestItem, addr model.DeliveryAddress) ([]model.OrderLine, error) {
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	products, err := txStore.ProductRepo.FindByIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	productByID := make(map[int]model.Product, len(products))
+	for _, p := range products {
+		productByID[p.ProductID] = p
+	}
+
+	var at *model.Coordinates
+	if addr.Latitude != nil && addr.Longitude != nil {
+		at = &model.Coordinates{Latitude: *addr.Latitude, Longitude: *addr.Longitude}
+	}
+
+	now := time.Now()
+	lines := make([]model.OrderLine, len(items))
+	for i, item := range items {
+		product, ok := productByID[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product_id=%d", ErrProductNotFound, item.ProductID)
+		}
+		if !product.AvailableAt(now) {
+			return nil, &ProductUnavailableError{ProductID: item.ProductID}
+		}
+		quote, err := s.shipping.Quote(ctx, product.Weight, at)
+		if err != nil {
+			return nil, err
+		}
+		// 送料も課税対象に含める
+		taxAmount, err := s.tax.Calculate(ctx, product.Category, quote.Zone, product.Value+quote.Cost)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = model.OrderLine{
+			ProductID:    item.ProductID,
+			Quantity:     item.Quantity,
+			Weight:       product.Weight,
+			Value:        product.Value,
+			ShippingCost: quote.Cost,
+			ShippingZone: quote.Zone,
+			TaxAmount:    taxAmount,
+			Stocked:      product.Stock != nil,
+		}
+	}
+	return lines, nil
+}
+
+// 在庫を管理している商品の在庫数を注文数量分減らす
+// 1つでも足りなければInsufficientStockErrorを返し、トランザクションのロールバックで減らした分も戻す
+// 同時の注文でデッドロックしないよう、商品IDの順に行をロックする
+func reserveStock(ctx context.Context, txStore *repository.Store, lines []model.OrderLine) error {
+	quantities := make(map[int]int)
+	for _, line := range lines {
+		if line.Stocked {
+			quantities[line.ProductID] += line.Quantity
+		}
+	}
+	productIDs := make([]int, 0, len(quantities))
+	for id := range quantities {
+		productIDs = append(productIDs, id)
+	}
+	slices.Sort(productIDs)
+	for _, id := range productIDs {
+		ok, err := txStore.ProductRepo.DecrementStock(ctx, id, quantities[id])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &InsufficientStockError{ProductID: id, Requested: quantities[id]}
+		}
+	}
+	return nil
+}
+
+// 住所をジオコーディングし、注文に保存する形式にする
+// 座標が取得できなくても注文自体は受け付けるため、エラーはログ出力のみ
+// 住所は個人情報のためログには出さず、同じ住所の失敗を追えるようハッシュを出す
+func (s *ProductService) resolveAddress(ctx context.Context, userID int, address string) model.DeliveryAddress {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return model.DeliveryAddress{}
+	}
+	addr := model.DeliveryAddress{Address: address}
+	coords, err := s.geocoder.Geocode(ctx, address)
+	if err != nil {
+		slog.WarnContext(ctx, "[CreateOrders] ジオコーディング失敗", "user_id", userID, "address_hash", addressHash(address), "err", err)
+		return addr
+	}
+	addr.Latitude = &coords.Latitude
+	addr.Longitude = &coords.Longitude
+	return addr
+}
+
+// ログに出す住所のハッシュ（SHA-256の先頭8バイト）
+func addressHash(address string) string {
+	sum := sha256.Sum256([]byte(address))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) (*model.ProductList, error) {
+	// 検索語を正規化してから同義語に展開する（"ＰＣ" と "pc" を同じ検索にする）
+	req.Search = search.Normalize(req.Search)
+	if req.Search != "" {
+		terms, err := s.synonyms.Expand(ctx, req.Search)
+		if err != nil {
+			// 同義語が引けなくても通常の検索は行う
+			slog.WarnContext(ctx, "[FetchProducts] 同義語展開失敗", "search", req.Search, "err", err)
+		} else {
+			req.SearchTerms = terms
+		}
+	}
+
+	list, err := s.listProducts(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(req.Include, model.ProductIncludeStats) {
+		list.Data = s.withOrderStats(ctx, list.Data)
+	}
+	return list, nil
+}
+
+func (s *ProductService) listProducts(ctx context.Context, userID int, req model.ListRequest) (*model.ProductList, error) {
+	// あいまい検索・ファセットは外部検索バックエンドで処理し、失敗時はMySQLにフォールバックする
+	if s.searchBackend != nil && (req.Fuzzy || req.Facets) {
+		list, err := s.searchBackend.SearchProducts(ctx, req)
+		if err == nil {
+			return list, nil
+		}
+		slog.WarnContext(ctx, "[FetchProducts] 検索バックエンド失敗、MySQLにフォールバック", "err", err)
+	}
+
+	cohort := s.searchCohort(userID, req)
+	if cohort == canary.CohortControl {
+		req.SearchMethod = model.SearchMethodLike
+	}
+	start := time.Now()
 	products, total, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
-	return products, total, err
+	if cohort != "" {
+		s.searchRollout.Observe(cohort, time.Since(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := &model.ProductList{Data: products, Total: total}
+	// LIKE検索で取得した場合は比べる相手がない
+	if cohort != canary.CohortControl {
+		s.shadowLikeSearch(ctx, userID, req, list, time.Since(start))
+	}
+	return list, nil
+}
+
+// 商品に注文数・最終注文時刻を付けたコピーを返す
+// 一覧のキャッシュと共有している商品は書き換えない
+// 集計が取得できない場合は付けずに返す（一覧の取得自体は失敗させない）
+func (s *ProductService) withOrderStats(ctx context.Context, products []model.Product) []model.Product {
+	productIDs := make([]int, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ProductID
+	}
+	stats, err := s.store.StatsRepo.FindByProductIDs(ctx, productIDs)
+	if err != nil {
+		slog.WarnContext(ctx, "[FetchProducts] 注文数の集計の取得失敗", "err", err)
+		return products
+	}
+	byID := make(map[int]model.ProductOrderStats, len(stats))
+	for _, st := range stats {
+		byID[st.ProductID] = st
+	}
+
+	enriched := make([]model.Product, len(products))
+	for i, p := range products {
+		st := byID[p.ProductID]
+		count := st.OrderCount
+		p.OrderCount = &count
+		if st.LastOrderedAt.Valid {
+			lastOrderedAt := st.LastOrderedAt.Time
+			p.LastOrderedAt = &lastOrderedAt
+		}
+		enriched[i] = p
+	}
+	return enriched
+}
+
+// 商品ごとの注文数の集計を更新する
+func (s *ProductService) RefreshOrderStats(ctx context.Context) error {
+	_, err := s.store.StatsRepo.Refresh(ctx)
+	return err
+}
+
+// 前回の確認から今までに注文できる期間の始まり・終わりを迎えた商品があれば、商品一覧のキャッシュを破棄する
+// スケジューラーから定期的に呼ばれる（同時には呼ばれない）
+func (s *ProductService) CheckAvailabilityChanges(ctx context.Context) error {
+	now := time.Now()
+	s.availabilityMutex.Lock()
+	defer s.availabilityMutex.Unlock()
+	changed, err := s.store.ProductRepo.CountAvailabilityChanges(ctx, s.availabilityCheckedAt, now)
+	if err != nil {
+		return err
+	}
+	s.availabilityCheckedAt = now
+	if changed > 0 {
+		slog.InfoContext(ctx, "[ProductAvailability] 注文できる期間が変わった商品があるため商品一覧のキャッシュを破棄します", "products", changed)
+		s.store.ProductRepo.InvalidateListCache()
+	}
+	return nil
+}
+
+// 起動直後のリクエストがDBに集中しないよう、商品一覧の先頭ページをキャッシュに載せておく
+// 条件は商品一覧APIのデフォルト値に合わせる
+func (s *ProductService) WarmUp(ctx context.Context) error {
+	req := model.ListRequest{Page: 1, PageSize: 20, SortField: "product_id", SortOrder: "asc"}
+	_, _, err := s.store.ProductRepo.ListProducts(ctx, 0, req)
+	return err
+}
+
+// 保存しておいた商品一覧キャッシュのキーを、商品一覧APIと同じく同義語に展開して取得し直す
+func (s *ProductService) warmList(ctx context.Context, q model.ProductListQuery) error {
+	req := model.ListRequest{
+		Search:       q.Search,
+		SortField:    q.SortField,
+		SortOrder:    q.SortOrder,
+		PageSize:     q.PageSize,
+		Offset:       q.Offset,
+		SearchMethod: q.SearchMethod,
+	}
+	if req.Search != "" {
+		terms, err := s.synonyms.Expand(ctx, req.Search)
+		if err != nil {
+			return err
+		}
+		req.SearchTerms = terms
+	}
+	_, _, err := s.store.ProductRepo.ListProducts(ctx, 0, req)
+	return err
 }
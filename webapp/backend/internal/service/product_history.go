@@ -0,0 +1,112 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidProduct  = errors.New("invalid product")
+)
+
+const (
+	// 変更履歴として返すデフォルトの件数
+	defaultProductHistoryLimit = 100
+	// 価値・重量が不正な商品として返すデフォルトの件数
+	defaultInvalidProductLimit = 100
+)
+
+// 価値・重量はどちらも1以上でなければならない
+// 重量0の商品は配送計画の動的計画法で積載量の添字がずれ、価値0の商品は計画に載せる意味がない
+func validProductValues(value, weight int) bool {
+	return value > 0 && weight > 0
+}
+
+// 商品の価値・重量を変更し、変更があれば履歴に残す
+func (s *AdminService) UpdateProduct(ctx context.Context, productID int, req model.ProductUpdateRequest) (*model.Product, error) {
+	if (req.Value != nil && *req.Value <= 0) || (req.Weight != nil && *req.Weight <= 0) {
+		return nil, ErrInvalidProduct
+	}
+
+	var updated model.Product
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			before, err := txStore.ProductRepo.LockByID(ctx, productID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrProductNotFound
+				}
+				return err
+			}
+			updated = before
+			if req.Value != nil {
+				updated.Value = *req.Value
+			}
+			if req.Weight != nil {
+				updated.Weight = *req.Weight
+			}
+			if updated.Value == before.Value && updated.Weight == before.Weight {
+				return nil
+			}
+			// 片方だけを変更する場合も、もう片方が不正なままであれば更新しない
+			if !validProductValues(updated.Value, updated.Weight) {
+				return ErrInvalidProduct
+			}
+			if err := txStore.ProductRepo.UpdateValueWeight(ctx, productID, updated.Value, updated.Weight); err != nil {
+				return err
+			}
+			return txStore.HistoryRepo.Create(ctx, before, updated, repository.ProductChangeSourceAdminAPI)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// 商品の価値・重量の変更履歴を新しい順に取得
+func (s *AdminService) ProductHistory(ctx context.Context, productID, limit int) ([]model.ProductHistory, error) {
+	if limit <= 0 {
+		limit = defaultProductHistoryLimit
+	}
+	var history []model.ProductHistory
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		history, err = s.store.HistoryRepo.ListByProduct(ctx, productID, limit)
+		return err
+	})
+	return history, err
+}
+
+// 価値・重量が不正な商品を商品IDの昇順に取得（修復前の確認用）
+func (s *AdminService) InvalidProducts(ctx context.Context, limit int) ([]model.InvalidProduct, error) {
+	if limit <= 0 {
+		limit = defaultInvalidProductLimit
+	}
+	var products []model.Product
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		products, err = s.store.ProductRepo.ListInvalidValues(ctx, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalid := make([]model.InvalidProduct, 0, len(products))
+	for _, p := range products {
+		item := model.InvalidProduct{ProductID: p.ProductID, Name: p.Name, Value: p.Value, Weight: p.Weight}
+		if p.Value <= 0 {
+			item.Problems = append(item.Problems, "value_not_positive")
+		}
+		if p.Weight <= 0 {
+			item.Problems = append(item.Problems, "weight_not_positive")
+		}
+		invalid = append(invalid, item)
+	}
+	return invalid, nil
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CSVのヘッダー・形式が不正、または補正の指定がない
+var ErrInvalidRecalibration = errors.New("invalid recalibration")
+
+const (
+	// 1回のトランザクションで補正する商品数
+	productRecalibrationBatchSize = 500
+	// レスポンスに含める不正な行の上限
+	maxRecalibrationErrors = 100
+	// 見積もりで配送計画を計算するテーブルサイズ（注文数×積載量）の上限
+	maxRecalibrationPlanCells = 50_000_000
+)
+
+type recalibrationRow struct {
+	row        int
+	adjustment model.ProductAdjustment
+}
+
+// 商品の価値・重量をJSONの指定で一括補正する（不正な行のRowはadjustmentsの1から始まる位置）
+func (s *AdminService) RecalibrateProducts(ctx context.Context, req model.ProductRecalibrationRequest, dryRun bool, capacity int) (*model.ProductRecalibrationReport, error) {
+	rows := make([]recalibrationRow, len(req.Adjustments))
+	for i, adjustment := range req.Adjustments {
+		rows[i] = recalibrationRow{row: i + 1, adjustment: adjustment}
+	}
+	return s.recalibrate(ctx, rows, nil, dryRun, capacity)
+}
+
+// 商品の価値・重量をCSVの指定で一括補正する（不正な行のRowはCSVの行番号）
+// 列はproduct_id・value・weight（ヘッダーで指定し、順序は問わない。value・weightはどちらかがあればよく、空欄の項目は変更しない）
+func (s *AdminService) RecalibrateProductsCSV(ctx context.Context, r io.Reader, dryRun bool, capacity int) (*model.ProductRecalibrationReport, error) {
+	rows, invalid, err := parseRecalibrationCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.recalibrate(ctx, rows, invalid, dryRun, capacity)
+}
+
+func parseRecalibrationCSV(r io.Reader) ([]recalibrationRow, []model.ProductRecalibrationError, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: empty file", ErrInvalidRecalibration)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRecalibration, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["product_id"]; !ok {
+		return nil, nil, fmt.Errorf("%w: missing column %q", ErrInvalidRecalibration, "product_id")
+	}
+	_, hasValue := columns["value"]
+	_, hasWeight := columns["weight"]
+	if !hasValue && !hasWeight {
+		return nil, nil, fmt.Errorf("%w: at least one of the columns %q and %q is required", ErrInvalidRecalibration, "value", "weight")
+	}
+
+	var rows []recalibrationRow
+	var invalid []model.ProductRecalibrationError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			invalid = append(invalid, model.ProductRecalibrationError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		var adjustment model.ProductAdjustment
+		if adjustment.ProductID, err = strconv.Atoi(field("product_id")); err != nil {
+			invalid = append(invalid, model.ProductRecalibrationError{Row: line, Message: fmt.Sprintf("invalid product_id %q", field("product_id"))})
+			continue
+		}
+		if adjustment.Value, err = parseOptionalInt(field("value")); err != nil {
+			invalid = append(invalid, model.ProductRecalibrationError{Row: line, ProductID: adjustment.ProductID, Message: fmt.Sprintf("invalid value %q", field("value"))})
+			continue
+		}
+		if adjustment.Weight, err = parseOptionalInt(field("weight")); err != nil {
+			invalid = append(invalid, model.ProductRecalibrationError{Row: line, ProductID: adjustment.ProductID, Message: fmt.Sprintf("invalid weight %q", field("weight"))})
+			continue
+		}
+		rows = append(rows, recalibrationRow{row: line, adjustment: adjustment})
+	}
+	return rows, invalid, nil
+}
+
+func parseOptionalInt(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// 一括補正の本体（invalidは読み込み時に不正だった行）
+// dryRunの場合は更新せず、現在の配送待ち注文の価値・重量の変化を見積もる（capacityが1以上の場合はその積載量で計画した価値も比べる）
+// それ以外の場合はproductRecalibrationBatchSize件ずつ別のトランザクションで更新し、変更した商品を変更履歴に残す
+// 途中のトランザクションで失敗した場合、それまでのトランザクションの更新は残る（同じ指定で再実行すれば残りを補正できる）
+func (s *AdminService) recalibrate(ctx context.Context, rows []recalibrationRow, invalid []model.ProductRecalibrationError, dryRun bool, capacity int) (*model.ProductRecalibrationReport, error) {
+	if len(rows) == 0 && len(invalid) == 0 {
+		return nil, fmt.Errorf("%w: no adjustments", ErrInvalidRecalibration)
+	}
+
+	report := &model.ProductRecalibrationReport{DryRun: dryRun, Rows: len(rows) + len(invalid), Errors: []model.ProductRecalibrationError{}}
+	for _, e := range invalid {
+		addRecalibrationError(report, e)
+	}
+	seen := make(map[int]int, len(rows))
+	valid := make([]recalibrationRow, 0, len(rows))
+	for _, r := range rows {
+		row, adjustment := r.row, r.adjustment
+		if err := validateAdjustment(adjustment); err != nil {
+			addRecalibrationError(report, model.ProductRecalibrationError{Row: row, ProductID: adjustment.ProductID, Message: err.Error()})
+			continue
+		}
+		if first, ok := seen[adjustment.ProductID]; ok {
+			addRecalibrationError(report, model.ProductRecalibrationError{Row: row, ProductID: adjustment.ProductID, Message: fmt.Sprintf("duplicate product_id (first on row %d)", first)})
+			continue
+		}
+		seen[adjustment.ProductID] = row
+		valid = append(valid, r)
+	}
+	// 複数のトランザクションで同じ順に行ロックを取るよう、商品ID順に処理する
+	slices.SortFunc(valid, func(a, b recalibrationRow) int { return cmp.Compare(a.adjustment.ProductID, b.adjustment.ProductID) })
+
+	changes := make(map[int]model.Product)
+	for batch := range slices.Chunk(valid, productRecalibrationBatchSize) {
+		var err error
+		if dryRun {
+			err = s.previewRecalibrationBatch(ctx, batch, report, changes)
+		} else {
+			err = s.applyRecalibrationBatch(ctx, batch, report)
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Batches++
+	}
+
+	if dryRun {
+		projection, err := s.projectRecalibration(ctx, changes, capacity)
+		if err != nil {
+			return nil, err
+		}
+		report.Projection = projection
+	}
+	return report, nil
+}
+
+// 更新せずに、現在の値と比べて更新する商品をchangesに加える
+func (s *AdminService) previewRecalibrationBatch(ctx context.Context, batch []recalibrationRow, report *model.ProductRecalibrationReport, changes map[int]model.Product) error {
+	productIDs := make([]int, len(batch))
+	for i, row := range batch {
+		productIDs[i] = row.adjustment.ProductID
+	}
+	var products []model.Product
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		products, err = s.store.ProductRepo.FindByIDs(ctx, productIDs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]model.Product, len(products))
+	for _, p := range products {
+		byID[p.ProductID] = p
+	}
+	for _, row := range batch {
+		before, ok := byID[row.adjustment.ProductID]
+		if !ok {
+			addRecalibrationError(report, model.ProductRecalibrationError{Row: row.row, ProductID: row.adjustment.ProductID, Message: fmt.Sprintf("product %d not found", row.adjustment.ProductID)})
+			continue
+		}
+		after, changed, err := adjustProduct(before, row.adjustment)
+		switch {
+		case err != nil:
+			addRecalibrationError(report, model.ProductRecalibrationError{Row: row.row, ProductID: row.adjustment.ProductID, Message: err.Error()})
+		case !changed:
+			report.Unchanged++
+		default:
+			changes[after.ProductID] = after
+			report.Updated++
+		}
+	}
+	return nil
+}
+
+// 1つのトランザクションで商品を行ロックして更新し、変更履歴に残す
+func (s *AdminService) applyRecalibrationBatch(ctx context.Context, batch []recalibrationRow, report *model.ProductRecalibrationReport) error {
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var updated, unchanged int
+		var invalid []model.ProductRecalibrationError
+		err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			for _, row := range batch {
+				before, err := txStore.ProductRepo.LockByID(ctx, row.adjustment.ProductID)
+				if errors.Is(err, sql.ErrNoRows) {
+					invalid = append(invalid, model.ProductRecalibrationError{Row: row.row, ProductID: row.adjustment.ProductID, Message: fmt.Sprintf("product %d not found", row.adjustment.ProductID)})
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				after, changed, err := adjustProduct(before, row.adjustment)
+				if err != nil {
+					invalid = append(invalid, model.ProductRecalibrationError{Row: row.row, ProductID: row.adjustment.ProductID, Message: err.Error()})
+					continue
+				}
+				if !changed {
+					unchanged++
+					continue
+				}
+				if err := txStore.ProductRepo.UpdateValueWeight(ctx, after.ProductID, after.Value, after.Weight); err != nil {
+					return err
+				}
+				if err := txStore.HistoryRepo.Create(ctx, before, after, repository.ProductChangeSourceRecalibration); err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		report.Updated += updated
+		report.Unchanged += unchanged
+		for _, e := range invalid {
+			addRecalibrationError(report, e)
+		}
+		return nil
+	})
+}
+
+// 指定の形式を確認する（現在の値によらない確認のみ）
+func validateAdjustment(adjustment model.ProductAdjustment) error {
+	switch {
+	case adjustment.ProductID <= 0:
+		return fmt.Errorf("invalid product_id %d", adjustment.ProductID)
+	case adjustment.Value == nil && adjustment.Weight == nil:
+		return errors.New("value or weight is required")
+	case adjustment.Value != nil && *adjustment.Value <= 0:
+		return errors.New("value must be positive")
+	case adjustment.Weight != nil && *adjustment.Weight <= 0:
+		return errors.New("weight must be positive")
+	}
+	return nil
+}
+
+// 補正後の商品と、現在の値から変わるかを返す
+// 片方だけを変更する場合も、もう片方が不正なままであれば補正しない（UpdateProductと同じ）
+func adjustProduct(before model.Product, adjustment model.ProductAdjustment) (model.Product, bool, error) {
+	after := before
+	if adjustment.Value != nil {
+		after.Value = *adjustment.Value
+	}
+	if adjustment.Weight != nil {
+		after.Weight = *adjustment.Weight
+	}
+	if after.Value == before.Value && after.Weight == before.Weight {
+		return after, false, nil
+	}
+	if !validProductValues(after.Value, after.Weight) {
+		return after, false, fmt.Errorf("value %d and weight %d must both be positive", after.Value, after.Weight)
+	}
+	return after, true, nil
+}
+
+// 補正を現在の配送待ち注文に当てはめた場合の価値・重量の変化を見積もる
+func (s *AdminService) projectRecalibration(ctx context.Context, changes map[int]model.Product, capacity int) (*model.ProductRecalibrationProjection, error) {
+	var counts []model.ShippingProductCount
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		counts, err = s.store.OrderRepo.CountShippingByProduct(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	projection := &model.ProductRecalibrationProjection{}
+	for _, c := range counts {
+		value, weight := c.Value, c.Weight
+		if p, ok := changes[c.ProductID]; ok {
+			value, weight = p.Value, p.Weight
+			projection.AffectedOrders += c.Orders
+		}
+		projection.ShippingOrders += c.Orders
+		projection.TotalValueBefore += c.Orders * c.Value
+		projection.TotalWeightBefore += c.Orders * c.Weight
+		projection.TotalValueAfter += c.Orders * value
+		projection.TotalWeightAfter += c.Orders * weight
+	}
+	if capacity <= 0 {
+		return projection, nil
+	}
+
+	projection.Capacity = capacity
+	if int64(projection.ShippingOrders)*int64(capacity+1) > maxRecalibrationPlanCells {
+		projection.Warnings = append(projection.Warnings, fmt.Sprintf("plan values are omitted: %d orders x capacity %d exceeds %d cells", projection.ShippingOrders, capacity, maxRecalibrationPlanCells))
+		return projection, nil
+	}
+	before := make([]model.Order, 0, projection.ShippingOrders)
+	after := make([]model.Order, 0, projection.ShippingOrders)
+	for _, c := range counts {
+		changed, ok := changes[c.ProductID]
+		for range c.Orders {
+			// 計画の価値だけを比べるため、注文IDは連番で代用する
+			orderID := int64(len(before) + 1)
+			before = append(before, model.Order{OrderID: orderID, Weight: c.Weight, Value: c.Value})
+			if ok {
+				after = append(after, model.Order{OrderID: orderID, Weight: changed.Weight, Value: changed.Value})
+			} else {
+				after = append(after, model.Order{OrderID: orderID, Weight: c.Weight, Value: c.Value})
+			}
+		}
+	}
+	planBefore, err := selectOrdersForDelivery(ctx, before, "", capacity)
+	if err != nil {
+		return nil, err
+	}
+	planAfter, err := selectOrdersForDelivery(ctx, after, "", capacity)
+	if err != nil {
+		return nil, err
+	}
+	projection.PlanValueBefore = &planBefore.TotalValue
+	projection.PlanValueAfter = &planAfter.TotalValue
+	return projection, nil
+}
+
+func addRecalibrationError(report *model.ProductRecalibrationReport, e model.ProductRecalibrationError) {
+	report.Invalid++
+	if len(report.Errors) < maxRecalibrationErrors {
+		report.Errors = append(report.Errors, e)
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"backend/internal/db"
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// フェイルオーバー中のため書き込みを再試行キューに回した（DBが復旧し次第反映される）
+var ErrWriteDeferred = errors.New("write deferred until database recovers")
+
+// 冪等な書き込みを実行し、フェイルオーバーで失敗した場合は再試行キューに回す
+// キューに入れられなかった場合は元のエラーを返す
+func runOrDefer(ctx context.Context, retry *db.RetryQueue, name string, write func(ctx context.Context) error) error {
+	err := write(ctx)
+	if retry == nil || !db.IsFailoverError(err) {
+		return err
+	}
+	if qerr := retry.Enqueue(name, write); qerr != nil {
+		slog.ErrorContext(ctx, "["+name+"] 再試行キューに入れられませんでした", "err", qerr)
+		return err
+	}
+	return ErrWriteDeferred
+}
@@ -1,31 +1,142 @@
 package service
 
 import (
+	"backend/internal/budget"
+	"backend/internal/db"
 	"backend/internal/model"
+	"backend/internal/planner"
 	"backend/internal/repository"
+	"backend/internal/routing"
 	"backend/internal/service/utils"
+	"backend/internal/shadow"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"slices"
+	"time"
+	"unsafe"
 )
 
+var (
+	ErrInvalidFailureReason = errors.New("invalid failure reason")
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrOrderNotDelivering   = errors.New("order is not delivering")
+	ErrInvalidCapacity      = errors.New("invalid capacity")
+	ErrInvalidOrderStatus   = errors.New("invalid order status")
+	ErrOrderCancelled       = errors.New("order has been cancelled")
+)
+
+const (
+	// 配送失敗後、再キュー投入までの待ち時間（失敗回数に応じて倍増）
+	retryBaseBackoff = 30 * time.Second
+	retryMaxBackoff  = 30 * time.Minute
+	// 1回の再キュー処理・ロールバック処理で扱う最大件数
+	requeueBatchSize = 500
+)
+
+type RobotServiceConfig struct {
+	// 注文作成から配送完了までの目標時間
+	FulfillmentSLA time.Duration
+	// 配送計画の受領確認を待つ時間（0の場合はロールバックしない）
+	PlanAckTimeout time.Duration
+	// 配送中のまま完了・失敗の報告を待つ時間（0の場合は自動で戻さない）
+	// ロボットが計画を受け取った後に停止した場合に、注文を配送待ちに戻して計画し直せるようにする
+	DeliveryTimeout time.Duration
+	// 受け付ける積載量の範囲（注文の重量と同じ単位）
+	// 下限未満はエラー、上限超過は上限に丸めて計画する
+	MinCapacity int
+	MaxCapacity int
+	// 動的計画法のテーブルサイズ（注文数×積載量）の上限
+	// 超える場合は価値密度順の貪欲法で計画する
+	MaxDPCells int
+	// 配送計画のクレームトークンの署名（nilの場合は発行せず、ステータス報告時も照合しない）
+	Claims *ClaimSigner
+	// 配送計画に見込む時間（リクエストの残り時間が足りない場合は計画を始めない）
+	PlanBudget time.Duration
+	// 動的計画法を打ち切って貪欲法に切り替えるときに、リクエストのデッドラインまで残す時間
+	// 0の場合は切り替えない（デッドラインを過ぎればエラーになる）
+	// 切り替えた後の貪欲法・割り当て・応答がこの時間に収まるよう設定する
+	GreedyFallbackReserve time.Duration
+	// 動的計画法のテーブルを前回の計画から引き継ぐ（nilの場合は毎回全体を計算する）
+	// 注文数×積載量がPLANNER_WARM_START_MAX_CELLSを超える計画は、テーブル全体を確保しないよう引き継がずに計算する
+	WarmStart *planner.WarmKnapsack
+	// ロボットが除外を指定した注文を一定時間計画から除外する（nilの場合は指定したリクエストの計画からのみ除外する）
+	Exclusions *PlanExclusions
+	// 動的計画法で計画した一部のリクエストで貪欲法とも比べる（nilの場合は比べない）
+	Shadow *shadow.Runner
+	// /api/robot/plan/waitがReadyを返す配送待ち注文の重量（積載量に対する割合）
+	PlanWaitMinLoadPercent int
+	// /api/robot/plan/waitで待つ時間の上限と、DBを確認する間隔（0の場合はdefaultPlanWaitMax・defaultPlanWaitPoll）
+	PlanWaitMax  time.Duration
+	PlanWaitPoll time.Duration
+	// 配送待ちの注文が増えたことを/api/robot/plan/waitの待機に知らせる（nilの場合はPlanWaitPollの間隔でのみ確認する）
+	PlanSignal *PlanSignal
+}
+
 type RobotService struct {
-	store *repository.Store
+	store     *repository.Store
+	notifier  Notifier
+	optimizer *routing.Optimizer
+	positions *RobotPositions
+	density   *planner.DensityIndex
+	retry     *db.RetryQueue
+	cfg       RobotServiceConfig
 }
 
-func NewRobotService(store *repository.Store) *RobotService {
-	return &RobotService{store: store}
+// retryにはフェイルオーバー中に失敗した冪等な書き込みが入る（nilの場合は再試行しない）
+func NewRobotService(store *repository.Store, notifier Notifier, optimizer *routing.Optimizer, positions *RobotPositions, density *planner.DensityIndex, retry *db.RetryQueue, cfg RobotServiceConfig) *RobotService {
+	if cfg.Exclusions == nil {
+		cfg.Exclusions = NewPlanExclusions(0)
+	}
+	if cfg.PlanWaitMax <= 0 {
+		cfg.PlanWaitMax = defaultPlanWaitMax
+	}
+	if cfg.PlanWaitPoll <= 0 {
+		cfg.PlanWaitPoll = defaultPlanWaitPoll
+	}
+	if cfg.PlanSignal == nil {
+		cfg.PlanSignal = NewPlanSignal()
+	}
+	return &RobotService{store: store, notifier: notifier, optimizer: optimizer, positions: positions, density: density, retry: retry, cfg: cfg}
 }
 
-func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string, capacity int) (*model.DeliveryPlan, error) {
+// withDiagnosticsがtrueの場合、計画に使ったアルゴリズムと実行コストを含める
+// excludedの注文（前回の配送で見つけられなかった注文など）は、今回と以降一定時間の計画から除外する
+func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string, capacity int, excluded []int64, withDiagnostics bool) (*model.DeliveryPlan, error) {
+	capacity, warning, err := s.validateCapacity(capacity)
+	if err != nil {
+		return nil, err
+	}
+	if err := budget.Require(ctx, s.cfg.PlanBudget); err != nil {
+		return nil, err
+	}
+	s.cfg.Exclusions.Add(excluded, time.Now())
+
+	var claimToken, claimID string
+	if s.cfg.Claims != nil {
+		if claimToken, claimID, err = s.cfg.Claims.Issue(robotID); err != nil {
+			return nil, err
+		}
+	}
+
 	var plan model.DeliveryPlan
+	var diagnostics *model.PlanDiagnostics
 
-	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+	err = utils.WithTimeout(ctx, func(ctx context.Context) error {
 		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-			orders, err := txStore.OrderRepo.GetShippingOrders(ctx)
+			// 同時に計画している他のロボットがロックした注文は候補にしない
+			orders, err := txStore.OrderRepo.LockShippingOrders(ctx)
 			if err != nil {
 				return err
 			}
-			plan, err = selectOrdersForDelivery(ctx, orders, robotID, capacity)
+			// 配送待ち注文の取得で残り時間が減っていれば、割り当てる前にやめる
+			if err := budget.Require(ctx, s.cfg.PlanBudget); err != nil {
+				return err
+			}
+			candidates := s.cfg.Exclusions.Filter(orders, excluded, time.Now())
+			plan, diagnostics, err = s.planOrders(ctx, orders, candidates, robotID, capacity)
 			if err != nil {
 				return err
 			}
@@ -35,11 +146,11 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
 					orderIDs[i] = order.OrderID
 				}
 
-				if err := txStore.OrderRepo.UpdateStatuses(ctx, orderIDs, "delivering"); err != nil {
+				if err := txStore.OrderRepo.AssignToRobot(ctx, orderIDs, robotID, claimID); err != nil {
 					return err
 				}
 				// ログ出力を削減（パフォーマンス向上）
-				// log.Printf("Updated status to 'delivering' for %d orders", len(orderIDs))
+				// slog.InfoContext(ctx, "Updated status to 'delivering'", "orders", len(orderIDs))
 			}
 			return nil
 		})
@@ -47,13 +158,531 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
 	if err != nil {
 		return nil, err
 	}
+
+	// 訪問順の最適化はトランザクション外で行う（失敗しても計画自体は返す）
+	route, travel, err := s.optimizer.Optimize(ctx, plan.Orders)
+	if err != nil {
+		slog.WarnContext(ctx, "[GenerateDeliveryPlan] ルート最適化失敗", "err", err)
+	} else {
+		plan.Orders = route
+		plan.EstimatedTravelSeconds = int(travel / time.Second)
+	}
+	if warning != "" {
+		plan.Warnings = append(plan.Warnings, warning)
+	}
+	if withDiagnostics {
+		plan.Diagnostics = diagnostics
+	}
+	if len(plan.Orders) > 0 {
+		plan.ClaimToken = claimToken
+	}
 	return &plan, nil
 }
 
-func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
+// 配送待ち注文を価値密度インデックスに読み込んでおく
+func (s *RobotService) WarmUp(ctx context.Context) error {
+	orders, err := s.store.OrderRepo.GetShippingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	s.density.Replace(orders)
+	return nil
+}
+
+// 配送待ち注文allのうち、除外されていない候補ordersから積載量に収まる注文を選ぶ
+// 動的計画法のテーブルが大きすぎる場合は価値密度順の貪欲法で選ぶ
+// GreedyFallbackReserveを設定した場合、動的計画法がデッドラインの手前までに終わらなければ貪欲法に切り替える
+func (s *RobotService) planOrders(ctx context.Context, all, orders []model.Order, robotID string, capacity int) (model.DeliveryPlan, *model.PlanDiagnostics, error) {
+	start := time.Now()
+	diagnostics := &model.PlanDiagnostics{Candidates: len(orders), Excluded: len(all) - len(orders)}
+
+	if s.cfg.MaxDPCells <= 0 || len(orders)*(capacity+1) <= s.cfg.MaxDPCells {
+		dpCtx, cancel := s.dpContext(ctx)
+		defer cancel()
+		var plan model.DeliveryPlan
+		var err error
+		warmStart := s.cfg.WarmStart != nil && s.cfg.WarmStart.Fits(len(orders), capacity)
+		if warmStart {
+			var result planner.KnapsackResult
+			result, err = s.cfg.WarmStart.Solve(dpCtx, orders, capacity)
+			plan = model.DeliveryPlan{RobotID: robotID, TotalWeight: result.TotalWeight, TotalValue: result.TotalValue, Orders: result.Orders}
+			diagnostics.ReusedRows = result.ReusedRows
+		} else {
+			plan, err = selectOrdersForDelivery(dpCtx, orders, robotID, capacity)
+		}
+		// リクエスト自体はまだ有効で、動的計画法に割り当てた時間だけが尽きた場合は貪欲法で計画する
+		if err != nil && ctx.Err() == nil && dpCtx.Err() != nil {
+			slog.WarnContext(ctx, "[GenerateDeliveryPlan] 動的計画法が時間内に終わらないため貪欲法で計画します",
+				"orders", len(orders), "capacity", capacity, "elapsed", time.Since(start))
+			diagnostics.BudgetFallback = true
+			plan = s.greedyPlan(ctx, orders, robotID, capacity, diagnostics)
+			diagnostics.RuntimeMs = float64(time.Since(start).Microseconds()) / 1000
+			return plan, diagnostics, nil
+		}
+		diagnostics.Algorithm = "dp"
+		diagnostics.Optimal = true
+		diagnostics.MemoryEstimateBytes = dpMemoryEstimate(len(orders), capacity, warmStart)
+		diagnostics.RuntimeMs = float64(time.Since(start).Microseconds()) / 1000
+		if err == nil {
+			s.shadowGreedyPlan(ctx, plan, orders, capacity, time.Since(start))
+		}
+		return plan, diagnostics, err
+	}
+
+	plan := s.greedyPlan(ctx, orders, robotID, capacity, diagnostics)
+	diagnostics.RuntimeMs = float64(time.Since(start).Microseconds()) / 1000
+	return plan, diagnostics, nil
+}
+
+// 動的計画法に使うcontext（GreedyFallbackReserveの設定時は、デッドラインのその時間前に打ち切る）
+// 残り時間がGreedyFallbackReserveに満たない場合は、すぐに打ち切られるcontextを返す
+func (s *RobotService) dpContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if s.cfg.GreedyFallbackReserve <= 0 || !ok {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, deadline.Add(-s.cfg.GreedyFallbackReserve))
+}
+
+// 価値密度インデックスを使って貪欲法で計画し、diagnosticsに結果を記録する
+func (s *RobotService) greedyPlan(ctx context.Context, orders []model.Order, robotID string, capacity int, diagnostics *model.PlanDiagnostics) model.DeliveryPlan {
+	// インデックスが差分更新から外れていればDBの内容で作り直す
+	// インデックスは除外・ロックにかかわらずすべての配送待ち注文を保持する（プロセス内で共有する）
+	// 計画中の注文はSKIP LOCKEDでロックできたものだけのため、それでは作り直さず、ロックせずに全件を読み直す
+	if !s.density.Contains(orders) {
+		if shipping, err := s.store.OrderRepo.GetShippingOrders(ctx); err != nil {
+			slog.WarnContext(ctx, "[GenerateDeliveryPlan] 価値密度インデックスを作り直せないため候補だけを追加します", "err", err)
+			s.density.Add(orders...)
+		} else {
+			s.density.Replace(shipping)
+			// 読み直しの後に変わった候補（このトランザクションでの変更など）を反映する
+			if !s.density.Contains(orders) {
+				s.density.Add(orders...)
+			}
+		}
+	}
+	byID := make(map[int64]model.Order, len(orders))
+	for _, o := range orders {
+		byID[o.OrderID] = o
+	}
+	// 除外された注文・他の計画がロックしている注文は、重量を数える前に飛ばす
+	isCandidate := func(id int64) bool {
+		_, ok := byID[id]
+		return ok
+	}
+	plan := model.DeliveryPlan{RobotID: robotID, Orders: []model.Order{}}
+	ids, _, _ := s.density.Greedy(capacity, isCandidate)
+	for _, id := range ids {
+		// インデックスの重量は差分更新の前の値の場合があるため、候補の重量で積載量を確認し直す
+		o := byID[id]
+		if plan.TotalWeight+o.Weight > capacity {
+			continue
+		}
+		plan.Orders = append(plan.Orders, o)
+		plan.TotalWeight += o.Weight
+		plan.TotalValue += o.Value
+	}
+	bound := s.density.UpperBound(capacity, isCandidate)
+	if plan.TotalValue < bound {
+		slog.InfoContext(ctx, "[GenerateDeliveryPlan] 貪欲法で計画しました",
+			"orders", len(orders), "capacity", capacity, "value", plan.TotalValue, "upper_bound", bound)
+	}
+	diagnostics.Algorithm = "greedy"
+	diagnostics.Optimal = plan.TotalValue >= bound
+	diagnostics.UpperBound = bound
+	// 注文IDから注文を引くためのマップ（インデックス自体は常駐しているため含めない）
+	diagnostics.MemoryEstimateBytes = int64(len(orders)) * int64(unsafe.Sizeof(int64(0))+unsafe.Sizeof(model.Order{}))
+	return plan
+}
+
+// 積載量を検証し、上限を超える場合は上限に丸めて警告を返す
+func (s *RobotService) validateCapacity(capacity int) (int, string, error) {
+	minCapacity := max(s.cfg.MinCapacity, 1)
+	if capacity < minCapacity {
+		return 0, "", fmt.Errorf("%w: must be at least %d", ErrInvalidCapacity, minCapacity)
+	}
+	if s.cfg.MaxCapacity > 0 && capacity > s.cfg.MaxCapacity {
+		return s.cfg.MaxCapacity, fmt.Sprintf("capacity %d exceeds the maximum; clamped to %d", capacity, s.cfg.MaxCapacity), nil
+	}
+	return capacity, "", nil
+}
+
+// ロボットが報告できるステータス
+// キャンセルは在庫の戻しとキャンセルの記録が必要なため、利用者のキャンセル操作でのみ行う
+var robotReportableStatuses = map[string]bool{"shipping": true, "delivering": true, "completed": true}
+
+// 指定したステータスにするだけの冪等な書き込みのため、フェイルオーバー中は再試行キューに回す
+// その場合はErrWriteDeferredを返す
+// キャンセル済みの注文はErrOrderCancelled、存在しない注文はErrOrderNotFoundを返す
+func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus, claimToken string) error {
+	if !robotReportableStatuses[newStatus] {
+		return fmt.Errorf("%w: %q", ErrInvalidOrderStatus, newStatus)
+	}
+	// 再試行時も報告を受けた時刻で到着を記録する
+	reportedAt := time.Now()
 	return utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.OrderRepo.UpdateStatuses(ctx, []int64{orderID}, newStatus)
+		return runOrDefer(ctx, s.retry, "UpdateOrderStatus", func(ctx context.Context) error {
+			if err := s.verifyClaim(ctx, s.store.OrderRepo, orderID, claimToken); err != nil {
+				return err
+			}
+			if newStatus == "completed" {
+				order, err := s.completeOrder(ctx, orderID, reportedAt)
+				if err != nil {
+					return err
+				}
+				s.notifyGiftRecipient(ctx, order)
+				return nil
+			}
+			return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+				if err := checkNotCancelled(ctx, txStore.OrderRepo, orderID); err != nil {
+					return err
+				}
+				return txStore.OrderRepo.UpdateStatuses(ctx, []int64{orderID}, newStatus)
+			})
+		})
+	})
+}
+
+// 注文を配送完了にして到着時刻を記録し、SLAを超過していればイベントとして残す
+// 更新前の注文を返す
+func (s *RobotService) completeOrder(ctx context.Context, orderID int64, arrivedAt time.Time) (*model.Order, error) {
+	var order *model.Order
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := checkNotCancelled(ctx, txStore.OrderRepo, orderID); err != nil {
+			return err
+		}
+		var err error
+		order, err = txStore.OrderRepo.FindByID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if err := txStore.OrderRepo.MarkCompleted(ctx, orderID, arrivedAt); err != nil {
+			return err
+		}
+		if s.cfg.FulfillmentSLA <= 0 {
+			return nil
+		}
+		if arrivedAt.Sub(order.CreatedAt) > s.cfg.FulfillmentSLA {
+			return txStore.EventRepo.Create(ctx, orderID, repository.OrderEventSLABreached, "")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// 注文の行ロックを取り、キャンセル済みでないことを確認する
+// キャンセル済みの注文を配送中・配送完了に戻すと、戻した在庫と食い違うため
+func checkNotCancelled(ctx context.Context, orders repository.Orders, orderID int64) error {
+	status, err := orders.LockStatus(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == "cancelled" {
+		return ErrOrderCancelled
+	}
+	return nil
+}
+
+// ギフトの注文が届いたことを受取人に通知する（金額は贈り主にのみ見せるため含めない）
+// 配送完了の再送では通知しない
+func (s *RobotService) notifyGiftRecipient(ctx context.Context, order *model.Order) {
+	if order == nil || order.RecipientUserID == nil || order.ShippedStatus == "completed" {
+		return
+	}
+	message := fmt.Sprintf("ギフトが届きました(%d: %s)。", order.OrderID, order.ProductName)
+	if err := s.notifier.NotifyUser(ctx, *order.RecipientUserID, "ギフト到着のお知らせ", message); err != nil {
+		slog.ErrorContext(ctx, "[UpdateOrderStatus] ギフト通知送信失敗", "order_id", order.OrderID, "err", err)
+	}
+}
+
+// 注文が、トークンのロボットの現在の配送計画に含まれているか確認する
+// 完了後もクレームIDは残すため、同じトークンでの再送（再試行）は受け付ける
+func (s *RobotService) verifyClaim(ctx context.Context, orders repository.Orders, orderID int64, claimToken string) error {
+	if s.cfg.Claims == nil {
+		return nil
+	}
+	robotID, claimID, err := s.cfg.Claims.Parse(claimToken)
+	if err != nil {
+		return err
+	}
+	assignedRobot, assignedClaim, err := orders.FindClaim(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+	if assignedRobot != robotID || assignedClaim != claimID {
+		return ErrInvalidClaim
+	}
+	return nil
+}
+
+// ロボットが配送計画を受領したことを記録し、受領済みにした注文数を返す
+func (s *RobotService) AcknowledgePlan(ctx context.Context, robotID string) (int, error) {
+	var acknowledged int64
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		acknowledged, err = s.store.OrderRepo.AcknowledgePlan(ctx, robotID)
+		return err
 	})
+	return int(acknowledged), err
+}
+
+// ロボットの現在位置を記録する
+func (s *RobotService) ReportPosition(robotID string, at model.Coordinates) {
+	s.positions.Update(robotID, at)
+}
+
+// 配送失敗を記録し、バックオフ後に自動で再キュー投入されるようにする
+func (s *RobotService) ReportDeliveryFailure(ctx context.Context, orderID int64, reason, claimToken string) (*model.DeliveryFailedResponse, error) {
+	if !isValidFailureReason(reason) {
+		return nil, ErrInvalidFailureReason
+	}
+
+	var resp model.DeliveryFailedResponse
+	var order *model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			if err := s.verifyClaim(ctx, txStore.OrderRepo, orderID, claimToken); err != nil {
+				return err
+			}
+			var err error
+			order, err = txStore.OrderRepo.FindByID(ctx, orderID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrOrderNotFound
+				}
+				return err
+			}
+
+			failures, err := txStore.EventRepo.CountByOrder(ctx, orderID, repository.OrderEventDeliveryFailed)
+			if err != nil {
+				return err
+			}
+			retryAt := time.Now().Add(retryBackoff(failures + 1))
+
+			updated, err := txStore.OrderRepo.MarkFailed(ctx, orderID, retryAt)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return ErrOrderNotDelivering
+			}
+			if err := txStore.EventRepo.Create(ctx, orderID, repository.OrderEventDeliveryFailed, reason); err != nil {
+				return err
+			}
+
+			resp = model.DeliveryFailedResponse{
+				OrderID: orderID,
+				Status:  "failed",
+				RetryAt: retryAt,
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("ご注文(%d: %s)の配送に失敗しました。%s以降に再配送を手配します。",
+		order.OrderID, order.ProductName, resp.RetryAt.Format("2006-01-02 15:04"))
+	if err := s.notifier.NotifyUser(ctx, order.UserID, "配送失敗のお知らせ", message); err != nil {
+		slog.ErrorContext(ctx, "[ReportDeliveryFailure] 通知送信失敗", "order_id", orderID, "err", err)
+	}
+	return &resp, nil
+}
+
+// 再キュー投入時刻を過ぎた配送失敗注文をshippingに戻し、件数を返す
+func (s *RobotService) RequeueFailedOrders(ctx context.Context) (int, error) {
+	var requeued int
+	var candidates []model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			orders, err := txStore.OrderRepo.GetRequeueCandidates(ctx, time.Now(), requeueBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(orders) == 0 {
+				return nil
+			}
+			orderIDs := make([]int64, len(orders))
+			for i, order := range orders {
+				orderIDs[i] = order.OrderID
+			}
+			if err := txStore.OrderRepo.Requeue(ctx, orderIDs); err != nil {
+				return err
+			}
+			if err := txStore.EventRepo.CreateBulk(ctx, orderIDs, repository.OrderEventRequeued); err != nil {
+				return err
+			}
+			requeued = len(orderIDs)
+			candidates = orders
+			return nil
+		})
+	})
+	if err == nil {
+		s.density.Add(candidates...)
+	}
+	return requeued, err
+}
+
+// 受領確認の期限を過ぎた配送計画の注文をshippingに戻し、件数を返す
+func (s *RobotService) RollbackUnacknowledgedPlans(ctx context.Context) (int, error) {
+	if s.cfg.PlanAckTimeout <= 0 {
+		return 0, nil
+	}
+	var rolledBack int
+	var candidates []model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			orders, err := txStore.OrderRepo.GetUnacknowledgedDeliveries(ctx, time.Now().Add(-s.cfg.PlanAckTimeout), requeueBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(orders) == 0 {
+				return nil
+			}
+			orderIDs := make([]int64, len(orders))
+			for i, order := range orders {
+				orderIDs[i] = order.OrderID
+			}
+			if err := txStore.OrderRepo.RollbackDelivering(ctx, orderIDs); err != nil {
+				return err
+			}
+			if err := txStore.EventRepo.CreateBulk(ctx, orderIDs, repository.OrderEventPlanRolledBack); err != nil {
+				return err
+			}
+			rolledBack = len(orderIDs)
+			candidates = orders
+			return nil
+		})
+	})
+	if err == nil {
+		s.density.Add(candidates...)
+	}
+	return rolledBack, err
+}
+
+// 配送中になってからolderThan以上経った注文をshippingに戻し、件数を返す
+// 対象がなくなるまでトランザクションを分けて繰り返す
+func (s *RobotService) RequeueStaleDeliveries(ctx context.Context, olderThan time.Duration) (int, error) {
+	deadline := time.Now().Add(-olderThan)
+	total := 0
+	for {
+		var candidates []model.Order
+		err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+			return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+				orders, err := txStore.OrderRepo.GetStaleDeliveries(ctx, deadline, requeueBatchSize)
+				if err != nil || len(orders) == 0 {
+					return err
+				}
+				orderIDs := make([]int64, len(orders))
+				for i, order := range orders {
+					orderIDs[i] = order.OrderID
+				}
+				if err := txStore.OrderRepo.RollbackDelivering(ctx, orderIDs); err != nil {
+					return err
+				}
+				if err := txStore.EventRepo.CreateBulk(ctx, orderIDs, repository.OrderEventDeliveryTimedOut); err != nil {
+					return err
+				}
+				candidates = orders
+				return nil
+			})
+		})
+		if err != nil {
+			return total, err
+		}
+		s.density.Add(candidates...)
+		total += len(candidates)
+		if len(candidates) < requeueBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// 配送失敗注文の再キュー投入を行う（スケジューラーから定期的に呼ばれる）
+func (s *RobotService) RunRequeue(ctx context.Context) error {
+	return runAndLog(ctx, "RequeueLoop", "再キュー投入", s.RequeueFailedOrders)
+}
+
+// 受領確認されなかった配送計画のロールバックを行う（スケジューラーから定期的に呼ばれる）
+// 受領確認の期限が未設定の場合は何もしない
+func (s *RobotService) RunPlanAckRollback(ctx context.Context) error {
+	if s.cfg.PlanAckTimeout <= 0 {
+		return nil
+	}
+	return runAndLog(ctx, "PlanAckLoop", "ロールバック", s.RollbackUnacknowledgedPlans)
+}
+
+// 配送中のまま期限を過ぎた注文を配送待ちに戻す（スケジューラーから定期的に呼ばれる）
+// 期限が未設定の場合は何もしない
+func (s *RobotService) RunStaleDeliveryRequeue(ctx context.Context) error {
+	if s.cfg.DeliveryTimeout <= 0 {
+		return nil
+	}
+	return runAndLog(ctx, "StaleDeliveryLoop", "配送待ちに戻", func(ctx context.Context) (int, error) {
+		return s.RequeueStaleDeliveries(ctx, s.cfg.DeliveryTimeout)
+	})
+}
+
+// 自動で配送待ちに戻すまでの時間（0の場合は自動では戻さない）
+func (s *RobotService) DeliveryTimeout() time.Duration {
+	return s.cfg.DeliveryTimeout
+}
+
+// fnを実行し、処理件数をログに出力する
+func runAndLog(ctx context.Context, name, action string, fn func(context.Context) (int, error)) error {
+	n, err := fn(ctx)
+	if err != nil {
+		return fmt.Errorf("%s失敗: %w", action, err)
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, fmt.Sprintf("[%s] 注文を%sしました", name, action), "orders", n)
+	}
+	return nil
+}
+
+func isValidFailureReason(reason string) bool {
+	switch reason {
+	case model.FailureReasonAbsent,
+		model.FailureReasonAddressNotFound,
+		model.FailureReasonDamaged,
+		model.FailureReasonRefused,
+		model.FailureReasonOther:
+		return true
+	}
+	return false
+}
+
+// n回目の失敗に対する待ち時間（指数バックオフ、上限あり）
+func retryBackoff(n int) time.Duration {
+	backoff := retryBaseBackoff
+	for i := 1; i < n; i++ {
+		backoff *= 2
+		if backoff >= retryMaxBackoff {
+			return retryMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// 動的計画法が確保する作業領域の推定サイズ
+// 前回の計算を引き継ぐ場合はdp[n+1][capacity+1]のテーブル全体、
+// そうでなければ1次元のdp[capacity+1]と、選択を記録するn×(capacity+1)ビット
+func dpMemoryEstimate(n, capacity int, warmStart bool) int64 {
+	cells := int64(n) * int64(capacity+1)
+	if warmStart {
+		return (cells + int64(capacity+1)) * int64(unsafe.Sizeof(int(0)))
+	}
+	return int64(capacity+1)*int64(unsafe.Sizeof(int(0))) + (cells+63)/64*8
 }
 
 func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID string, robotCapacity int) (model.DeliveryPlan, error) {
@@ -67,30 +696,29 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 		}, nil
 	}
 
-	// 2次元DPテーブル: dp[i][w] = 最初のi個の注文で重さw以下の最大価値
-	dp := make([][]int, n+1)
-	for i := range dp {
-		dp[i] = make([]int, robotCapacity+1)
-	}
+	// 1次元DP: dp[w] = ここまでの注文で重さw以下の最大価値（注文ごとに上書きする）
+	// 復元用に、注文iを選んだかどうかだけを1ビットずつ記録する
+	// （選んでも選ばなくても同じ価値の場合は選んだ側を記録し、2次元テーブルで復元した場合と同じ組を返す）
+	width := robotCapacity + 1
+	dp := make([]int, width)
+	chosen := newBitset(n * width)
+	checkEvery := planner.CancelCheckRows(width)
 
 	// DPテーブルを埋める
 	for i := 1; i <= n; i++ {
 		order := orders[i-1]
-		for w := 0; w <= robotCapacity; w++ {
-			// 注文iを選ばない場合
-			dp[i][w] = dp[i-1][w]
-
-			// 注文iを選ぶ場合（重さ制限を満たす場合のみ）
-			if order.Weight <= w {
-				selectValue := dp[i-1][w-order.Weight] + order.Value
-				if selectValue > dp[i][w] {
-					dp[i][w] = selectValue
-				}
+		row := (i - 1) * width
+		// 重い側から更新し、同じ注文を2回選ばないようにする
+		for w := robotCapacity; w >= order.Weight; w-- {
+			selectValue := dp[w-order.Weight] + order.Value
+			if selectValue >= dp[w] {
+				dp[w] = selectValue
+				chosen.set(row + w)
 			}
 		}
 
 		// コンテキストキャンセルチェック
-		if i%100 == 0 {
+		if i%checkEvery == 0 {
 			select {
 			case <-ctx.Done():
 				return model.DeliveryPlan{}, ctx.Err()
@@ -99,22 +727,21 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 		}
 	}
 
-	bestValue := dp[n][robotCapacity]
+	bestValue := dp[robotCapacity]
 
 	// 最適解を復元
+	// 重さ0の注文も選ばれているため、残りの重さが0になっても先頭まで確認する
 	var selectedOrders []model.Order
 	w := robotCapacity
-	for i := n; i > 0 && w > 0; i-- {
+	for i := n; i > 0; i-- {
 		select {
 		case <-ctx.Done():
 			return model.DeliveryPlan{}, ctx.Err()
 		default:
 		}
 
-		order := orders[i-1]
-
-		// 安全な順序で条件評価
-		if w >= order.Weight && dp[i-1][w-order.Weight]+order.Value == dp[i][w] {
+		if chosen.has((i-1)*width + w) {
+			order := orders[i-1]
 			selectedOrders = append(selectedOrders, order)
 			w -= order.Weight
 		}
@@ -136,3 +763,18 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 		Orders:      selectedOrders,
 	}, nil
 }
+
+// 固定長のビット列
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"backend/internal/model"
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// すべての組み合わせを調べた最大価値（積載量に収まるもの）
+func bruteForceKnapsack(orders []model.Order, capacity int) int {
+	best := 0
+	for mask := 0; mask < 1<<len(orders); mask++ {
+		weight, value := 0, 0
+		for i, o := range orders {
+			if mask&(1<<i) != 0 {
+				weight += o.Weight
+				value += o.Value
+			}
+		}
+		if weight <= capacity && value > best {
+			best = value
+		}
+	}
+	return best
+}
+
+func testOrders(weightValues ...int) []model.Order {
+	result := make([]model.Order, 0, len(weightValues)/2)
+	for i := 0; i+1 < len(weightValues); i += 2 {
+		result = append(result, model.Order{OrderID: int64(i/2 + 1), Weight: weightValues[i], Value: weightValues[i+1]})
+	}
+	return result
+}
+
+// 選んだ注文の合計が計画の値と一致し、積載量に収まり、全探索の最大価値と一致すること
+func checkPlan(t *testing.T, input []model.Order, capacity int) {
+	t.Helper()
+	plan, err := selectOrdersForDelivery(context.Background(), input, "robot", capacity)
+	if err != nil {
+		t.Fatalf("selectOrdersForDelivery() error = %v", err)
+	}
+	weight, value := 0, 0
+	seen := make(map[int64]bool)
+	for _, o := range plan.Orders {
+		if seen[o.OrderID] {
+			t.Fatalf("order %d chosen twice", o.OrderID)
+		}
+		seen[o.OrderID] = true
+		weight += o.Weight
+		value += o.Value
+	}
+	if weight != plan.TotalWeight || value != plan.TotalValue {
+		t.Fatalf("chosen orders sum to weight %d value %d, plan reports %d/%d", weight, value, plan.TotalWeight, plan.TotalValue)
+	}
+	if weight > capacity {
+		t.Fatalf("total weight %d exceeds capacity %d", weight, capacity)
+	}
+	if want := bruteForceKnapsack(input, capacity); value != want {
+		t.Fatalf("total value = %d, want %d", value, want)
+	}
+}
+
+func TestSelectOrdersForDelivery(t *testing.T) {
+	cases := []struct {
+		name     string
+		orders   []model.Order
+		capacity int
+	}{
+		{name: "empty", orders: nil, capacity: 10},
+		{name: "simple", orders: testOrders(3, 4, 4, 5, 2, 3), capacity: 7},
+		{name: "zero_weight", orders: testOrders(0, 5, 1, 10, 0, 2, 2, 7), capacity: 1},
+		{name: "zero_weight_only", orders: testOrders(0, 3, 0, 4), capacity: 5},
+		{name: "heavier_than_capacity", orders: testOrders(11, 100, 5, 6, 20, 50), capacity: 10},
+		{name: "all_heavier_than_capacity", orders: testOrders(11, 100, 12, 6), capacity: 10},
+		{name: "equal_density", orders: testOrders(2, 4, 3, 6, 5, 10, 1, 2), capacity: 6},
+		{name: "capacity_zero", orders: testOrders(1, 5, 0, 3, 2, 8), capacity: 0},
+		{name: "exact_fit", orders: testOrders(5, 5, 5, 5, 10, 9), capacity: 10},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkPlan(t, c.orders, c.capacity)
+		})
+	}
+}
+
+func TestSelectOrdersForDeliveryRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		input := make([]model.Order, rng.Intn(12))
+		for j := range input {
+			input[j] = model.Order{OrderID: int64(j + 1), Weight: rng.Intn(8), Value: rng.Intn(20)}
+		}
+		checkPlan(t, input, rng.Intn(25))
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/planner"
+	"backend/internal/shadow"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 注文一覧の1ページ目を、OFFSETによるページングと並行してキーセットページングでも取得して比べる
+func (s *OrderService) EnableShadow(r *shadow.Runner) {
+	s.shadow = r
+}
+
+// 商品検索を、ngram全文検索と並行してLIKE検索でも実行して比べる
+func (s *ProductService) EnableShadow(r *shadow.Runner) {
+	s.shadow = r
+}
+
+type orderPage struct {
+	orders []model.Order
+	total  int
+}
+
+func (s *OrderService) shadowCursorPagination(ctx context.Context, userID int, req model.ListRequest, orders []model.Order, total int, elapsed time.Duration) {
+	// 2ページ目以降は前のページの位置が分からないため比べない
+	if req.Pagination == model.PaginationCursor || req.Offset != 0 || !s.shadow.Sample() {
+		return
+	}
+	req.Pagination = model.PaginationCursor
+	req.Cursor, req.After = "", nil
+	shadow.Run(ctx, s.shadow, orderPage{orders: orders, total: total}, elapsed,
+		func(ctx context.Context) (orderPage, error) {
+			orders, total, err := s.store.OrderRepo.ListOrders(ctx, userID, req)
+			return orderPage{orders: orders, total: total}, err
+		},
+		func(primary, cursor orderPage) string {
+			if primary.total != cursor.total {
+				return fmt.Sprintf("total offset=%d cursor=%d", primary.total, cursor.total)
+			}
+			return diffIDs(orderIDs(primary.orders), orderIDs(cursor.orders))
+		})
+}
+
+func (s *ProductService) shadowLikeSearch(ctx context.Context, userID int, req model.ListRequest, list *model.ProductList, elapsed time.Duration) {
+	if req.Search == "" || !s.shadow.Sample() {
+		return
+	}
+	req.SearchMethod = model.SearchMethodLike
+	req.Shadow = true
+	shadow.Run(ctx, s.shadow, list, elapsed,
+		func(ctx context.Context) (*model.ProductList, error) {
+			products, total, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
+			return &model.ProductList{Data: products, Total: total}, err
+		},
+		func(fulltext, like *model.ProductList) string {
+			if fulltext.Total != like.Total {
+				return fmt.Sprintf("search=%q total fulltext=%d like=%d", req.Search, fulltext.Total, like.Total)
+			}
+			if d := diffIDs(productIDs(fulltext.Data), productIDs(like.Data)); d != "" {
+				return fmt.Sprintf("search=%q %s", req.Search, d)
+			}
+			return ""
+		})
+}
+
+// 動的計画法で計画した候補から、価値密度順の貪欲法でも計画して価値・重量を比べる
+func (s *RobotService) shadowGreedyPlan(ctx context.Context, plan model.DeliveryPlan, candidates []model.Order, capacity int, elapsed time.Duration) {
+	if !s.cfg.Shadow.Sample() {
+		return
+	}
+	// 候補はこの後のリクエストの処理と共有しないようコピーする
+	candidates = slices.Clone(candidates)
+	shadow.Run(ctx, s.cfg.Shadow, plan, elapsed,
+		func(ctx context.Context) (model.DeliveryPlan, error) {
+			index := planner.NewDensityIndex()
+			index.Replace(candidates)
+			ids, weight, value := index.Greedy(capacity, nil)
+			greedy := model.DeliveryPlan{TotalWeight: weight, TotalValue: value, Orders: make([]model.Order, len(ids))}
+			for i, id := range ids {
+				greedy.Orders[i] = model.Order{OrderID: id}
+			}
+			return greedy, nil
+		},
+		func(dp, greedy model.DeliveryPlan) string {
+			if dp.TotalValue == greedy.TotalValue {
+				return ""
+			}
+			loss := 0.0
+			if dp.TotalValue > 0 {
+				loss = float64(dp.TotalValue-greedy.TotalValue) / float64(dp.TotalValue) * 100
+			}
+			return fmt.Sprintf("candidates=%d capacity=%d value dp=%d greedy=%d (%.2f%% lower) weight dp=%d greedy=%d orders dp=%d greedy=%d",
+				len(candidates), capacity, dp.TotalValue, greedy.TotalValue, loss, dp.TotalWeight, greedy.TotalWeight, len(dp.Orders), len(greedy.Orders))
+		})
+}
+
+func orderIDs(orders []model.Order) []int64 {
+	ids := make([]int64, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderID
+	}
+	return ids
+}
+
+func productIDs(products []model.Product) []int64 {
+	ids := make([]int64, len(products))
+	for i, p := range products {
+		ids[i] = int64(p.ProductID)
+	}
+	return ids
+}
+
+// 並びが異なる最初の位置（同じであれば空文字）
+func diffIDs(primary, shadow []int64) string {
+	if slices.Equal(primary, shadow) {
+		return ""
+	}
+	for i := range min(len(primary), len(shadow)) {
+		if primary[i] != shadow[i] {
+			return fmt.Sprintf("ids differ at %d: primary=%d shadow=%d", i, primary[i], shadow[i])
+		}
+	}
+	return fmt.Sprintf("ids length primary=%d shadow=%d", len(primary), len(shadow))
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// 在庫が少ない商品を確認するときに1回のクエリで取得する件数
+const lowStockScanBatch = 500
+
+// 在庫数が発注点を下回った商品を管理者に通知する
+// 同じ商品は在庫が発注点以上に戻るまで繰り返し通知しない
+// 通知済みの商品はメモリ上に記録するため、再起動後は改めて通知する
+type StockAlerter struct {
+	store    *repository.Store
+	notifier Notifier
+
+	mutex   sync.Mutex
+	alerted map[int]bool
+}
+
+func NewStockAlerter(store *repository.Store, notifier Notifier) *StockAlerter {
+	return &StockAlerter{store: store, notifier: notifier, alerted: make(map[int]bool)}
+}
+
+// 在庫が発注点を下回った商品のうち、まだ通知していないものをまとめて1件の通知で送る
+// スケジューラーから定期的に呼ばれる（同時には呼ばれない）
+// 通知に失敗した商品は次回に改めて通知する
+func (a *StockAlerter) CheckLowStock(ctx context.Context) error {
+	var low []model.LowStockProduct
+	for offset := 0; ; offset += lowStockScanBatch {
+		products, _, err := a.store.ProductRepo.ListLowStock(ctx, lowStockScanBatch, offset)
+		if err != nil {
+			return err
+		}
+		low = append(low, products...)
+		if len(products) < lowStockScanBatch {
+			break
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	current := make(map[int]bool, len(low))
+	var newly []model.LowStockProduct
+	for _, p := range low {
+		current[p.ProductID] = true
+		if !a.alerted[p.ProductID] {
+			newly = append(newly, p)
+		}
+	}
+	// 在庫が戻った商品は記録から外し、再び下回ったときに通知する
+	a.alerted = current
+	if len(newly) == 0 {
+		return nil
+	}
+
+	lines := make([]string, len(newly))
+	for i, p := range newly {
+		lines[i] = fmt.Sprintf("商品ID %d（%s）: 在庫 %d / 発注点 %d", p.ProductID, p.Name, p.Stock, p.ReorderThreshold)
+	}
+	subject := fmt.Sprintf("在庫が発注点を下回った商品が%d件あります", len(newly))
+	if err := a.notifier.NotifyAdmins(ctx, subject, strings.Join(lines, "\n")); err != nil {
+		for _, p := range newly {
+			delete(a.alerted, p.ProductID)
+		}
+		return fmt.Errorf("failed to notify low stock: %w", err)
+	}
+	slog.InfoContext(ctx, "[LowStock] 在庫が発注点を下回った商品を通知しました", "products", len(newly), "low_stock", len(low))
+	return nil
+}
+
+// 在庫数が発注点を下回った商品を在庫数の少ない順に取得
+func (s *AdminService) LowStockProducts(ctx context.Context, page, pageSize int) (*model.LowStockList, error) {
+	var products []model.LowStockProduct
+	var total int
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		products, total, err = s.store.ProductRepo.ListLowStock(ctx, pageSize, (page-1)*pageSize)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.LowStockList{Data: products, Total: total}, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// テストデータのリセットの指定が不正
+var ErrInvalidTestdataRequest = errors.New("invalid testdata reset request")
+
+const (
+	// 注文数の指定がない場合に作る注文数
+	defaultFixtureOrders = 1000
+	// 1回のリセットで作れる注文数の上限
+	MaxFixtureOrders = 100000
+	// 商品IDを読み込む単位
+	fixtureProductPageSize = 1000
+)
+
+// テストデータの注文日時の基準（実行日時によらず同じ注文を作るため固定する）
+var fixtureEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 注文・セッションを削除し、シードから決まるテストデータの注文に入れ替える
+// 負荷試験を毎回同じ状態から始めるためのもので、ユーザー・商品は変更しない
+// 注文の入れ替えは1つのトランザクションで行い、失敗した場合は元の注文が残る
+func (s *AdminService) ResetTestdata(ctx context.Context, req model.TestdataResetRequest) (*model.TestdataResetResponse, error) {
+	if req.Orders == 0 {
+		req.Orders = defaultFixtureOrders
+	}
+	if req.Orders < 0 || req.Orders > MaxFixtureOrders {
+		return nil, fmt.Errorf("%w: orders must be between 1 and %d", ErrInvalidTestdataRequest, MaxFixtureOrders)
+	}
+
+	resp := &model.TestdataResetResponse{Seed: req.Seed}
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			userIDs, err := txStore.UserRepo.ListIDs(ctx)
+			if err != nil {
+				return err
+			}
+			productIDs, err := listProductIDs(ctx, txStore.ProductRepo)
+			if err != nil {
+				return err
+			}
+			fixtures := fixtureOrders(req.Seed, req.Orders, userIDs, productIDs)
+
+			if resp.DeletedOrders, err = txStore.OrderRepo.DeleteAll(ctx); err != nil {
+				return err
+			}
+			if err := txStore.OrderRepo.InsertFixtures(ctx, fixtures); err != nil {
+				return err
+			}
+			resp.InsertedOrders = len(fixtures)
+			if resp.DeletedSessions, err = txStore.SessionRepo.DeleteAll(ctx); err != nil {
+				return err
+			}
+			_, err = txStore.StatsRepo.Rebuild(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// セッションをRedisに保存している場合や、プロセス内にキャッシュしたセッションもここで消す
+		deleted, err := s.store.SessionRepo.DeleteAll(ctx)
+		if err != nil {
+			return err
+		}
+		resp.DeletedSessions += deleted
+		// 採番のリセットは暗黙にコミットされるため、入れ替えのトランザクションの後に行う
+		if err := s.store.OrderRepo.ResetSequence(ctx); err != nil {
+			slog.ErrorContext(ctx, "[ResetTestdata] failed to reset order_id sequence", "err", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func listProductIDs(ctx context.Context, products repository.Products) ([]int, error) {
+	var ids []int
+	afterID := 0
+	for {
+		page, err := products.ListAfter(ctx, afterID, fixtureProductPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page {
+			ids = append(ids, p.ProductID)
+		}
+		if len(page) < fixtureProductPageSize {
+			return ids, nil
+		}
+		afterID = page[len(page)-1].ProductID
+	}
+}
+
+// シードから注文を作る（ユーザー・商品・注文数・シードが同じであれば同じ注文になる）
+// 注文IDは1から振り、7割を配送完了、残りを配送待ちにする
+func fixtureOrders(seed int64, n int, userIDs, productIDs []int) []model.Order {
+	if len(userIDs) == 0 || len(productIDs) == 0 {
+		return nil
+	}
+	rng := rand.New(rand.NewSource(seed))
+	orders := make([]model.Order, n)
+	for i := range orders {
+		createdAt := fixtureEpoch.Add(time.Duration(i) * time.Minute)
+		order := model.Order{
+			OrderID:       int64(i + 1),
+			UserID:        userIDs[rng.Intn(len(userIDs))],
+			ProductID:     productIDs[rng.Intn(len(productIDs))],
+			ShippedStatus: "shipping",
+			CreatedAt:     createdAt,
+		}
+		if rng.Intn(10) < 7 {
+			order.ShippedStatus = "completed"
+			order.ArrivedAt = sql.NullTime{Time: createdAt.Add(time.Duration(1+rng.Intn(48)) * time.Hour), Valid: true}
+		}
+		orders[i] = order
+	}
+	return orders
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrTrackingNotFound = errors.New("tracking token not found")
+
+const (
+	// 位置情報を有効とみなす時間（これより古い位置は返さない）
+	robotPositionTTL = 5 * time.Minute
+	// 公開する位置の精度（小数点以下2桁 = 約1km）
+	coarsePositionScale = 100
+)
+
+type robotPosition struct {
+	at        model.Coordinates
+	updatedAt time.Time
+}
+
+// ロボットごとの最新位置をメモリ上に保持する
+type RobotPositions struct {
+	positions map[string]robotPosition
+	mutex     sync.RWMutex
+}
+
+func NewRobotPositions() *RobotPositions {
+	return &RobotPositions{positions: make(map[string]robotPosition)}
+}
+
+func (p *RobotPositions) Update(robotID string, at model.Coordinates) {
+	p.mutex.Lock()
+	p.positions[robotID] = robotPosition{at: at, updatedAt: time.Now()}
+	p.mutex.Unlock()
+}
+
+// 有効期限内の位置を返す
+func (p *RobotPositions) Get(robotID string) (model.Coordinates, bool) {
+	p.mutex.RLock()
+	pos, ok := p.positions[robotID]
+	p.mutex.RUnlock()
+	if !ok || time.Since(pos.updatedAt) > robotPositionTTL {
+		return model.Coordinates{}, false
+	}
+	return pos.at, true
+}
+
+// 有効期限内に位置を報告しているロボットの数
+func (p *RobotPositions) ActiveCount() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	active := 0
+	for _, pos := range p.positions {
+		if time.Since(pos.updatedAt) <= robotPositionTTL {
+			active++
+		}
+	}
+	return active
+}
+
+type TrackingService struct {
+	store     *repository.Store
+	positions *RobotPositions
+	// 配送開始から到着までの平均所要時間（到着予定時刻の見積もりに使用）
+	avgDelivery time.Duration
+}
+
+func NewTrackingService(store *repository.Store, positions *RobotPositions, avgDelivery time.Duration) *TrackingService {
+	return &TrackingService{store: store, positions: positions, avgDelivery: avgDelivery}
+}
+
+// 追跡トークンから公開用の配送状況を組み立てる
+func (s *TrackingService) Track(ctx context.Context, token string) (*model.TrackingInfo, error) {
+	var order *model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.store.OrderRepo.FindByTrackingToken(ctx, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTrackingNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &model.TrackingInfo{Status: order.ShippedStatus}
+	switch order.ShippedStatus {
+	case "delivering":
+		if order.DeliveringAt != nil {
+			eta := order.DeliveringAt.Add(s.avgDelivery)
+			info.ETA = &eta
+		}
+		if order.RobotID != nil {
+			if at, ok := s.positions.Get(*order.RobotID); ok {
+				info.RobotPosition = &model.Coordinates{
+					Latitude:  math.Round(at.Latitude*coarsePositionScale) / coarsePositionScale,
+					Longitude: math.Round(at.Longitude*coarsePositionScale) / coarsePositionScale,
+				}
+			}
+		}
+	case "completed":
+		if order.ArrivedAt.Valid {
+			arrived := order.ArrivedAt.Time
+			info.ArrivedAt = &arrived
+		}
+	}
+	return info, nil
+}
@@ -2,7 +2,7 @@ package utils
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -27,7 +27,7 @@ func WithTimeout(parent context.Context, fn func(ctx context.Context) error) err
 	case err := <-done:
 		return err
 	case <-ctx.Done():
-		log.Printf("処理がタイムアウトしました (timeout=%s)", timeout)
+		slog.WarnContext(ctx, "処理がタイムアウトしました", "timeout", timeout)
 		return ctx.Err()
 	}
 }
@@ -0,0 +1,75 @@
+// 新しいアルゴリズムを本番のリクエストの一部で並行して実行し、結果・処理時間の差をログに出す
+// 機能フラグを切り替える前に、実際のトラフィックで結果が変わらないこと・速くなることを確かめるためのもの
+package shadow
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/task"
+)
+
+// 1つの比較（実験）
+// シャドウの実行はレスポンスを待たせず、失敗・panicも本番の処理に影響しない
+type Runner struct {
+	name    string
+	percent int
+	timeout time.Duration
+	// 同時に実行するシャドウの数の上限（超えた分は実行しない）
+	slots chan struct{}
+
+	sampled    atomic.Int64
+	mismatched atomic.Int64
+}
+
+// percent%のリクエストでシャドウを実行する（0以下の場合は実行しない）
+func New(name string, percent, maxConcurrent int, timeout time.Duration) *Runner {
+	return &Runner{
+		name:    name,
+		percent: percent,
+		timeout: timeout,
+		slots:   make(chan struct{}, max(maxConcurrent, 1)),
+	}
+}
+
+// 今回のリクエストでシャドウを実行するか（nilのRunnerは常にfalse）
+// シャドウのための準備（入力のコピーなど）はtrueの場合のみ行うこと
+func (r *Runner) Sample() bool {
+	return r != nil && r.percent > 0 && rand.IntN(100) < r.percent
+}
+
+// shadowをバックグラウンドで実行し、本番の結果primary・処理時間primaryElapsedと比べてログに出す
+// diffは差がなければ空文字を返す。Sampleがtrueを返した場合のみ呼ぶこと
+// shadowのctxはリクエストのキャンセルを引き継がない（レスポンスを返した後も実行する）
+func Run[T any](ctx context.Context, r *Runner, primary T, primaryElapsed time.Duration, shadow func(ctx context.Context) (T, error), diff func(primary, shadow T) string) {
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		return
+	}
+	r.sampled.Add(1)
+	shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+	task.Go(shadowCtx, "Shadow "+r.name, func(ctx context.Context) {
+		defer cancel()
+		defer func() { <-r.slots }()
+
+		start := time.Now()
+		result, err := shadow(ctx)
+		elapsed := time.Since(start)
+		if err != nil {
+			slog.WarnContext(ctx, "[Shadow "+r.name+"] shadow failed", "elapsed", elapsed.Round(time.Microsecond), "err", err)
+			return
+		}
+		d := diff(primary, result)
+		if d == "" {
+			slog.InfoContext(ctx, "[Shadow "+r.name+"] match", "primary", primaryElapsed.Round(time.Microsecond), "shadow", elapsed.Round(time.Microsecond))
+			return
+		}
+		r.mismatched.Add(1)
+		slog.WarnContext(ctx, "[Shadow "+r.name+"] MISMATCH", "primary", primaryElapsed.Round(time.Microsecond), "shadow", elapsed.Round(time.Microsecond),
+			"mismatched", r.mismatched.Load(), "sampled", r.sampled.Load(), "diff", d)
+	})
+}
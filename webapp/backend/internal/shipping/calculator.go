@@ -0,0 +1,101 @@
+package shipping
+
+import (
+	"backend/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// どのゾーンにも属さない、または座標が無い場合に使用するゾーン
+const DefaultZone = "default"
+
+// 注文1行あたりの送料を計算するインターフェース
+type Calculator interface {
+	Quote(ctx context.Context, weight int, at *model.Coordinates) (model.ShippingQuote, error)
+}
+
+// ゾーンと料金表の取得元
+type RateSource interface {
+	ListZones(ctx context.Context) ([]model.ShippingZone, error)
+	ListRates(ctx context.Context) ([]model.ShippingRate, error)
+}
+
+// DBで設定されたゾーン・重量別の料金表で送料を計算する実装
+// 料金表はTTLの間メモリに保持し、期限切れ後の最初の見積もりで再読み込みする
+type TieredCalculator struct {
+	source   RateSource
+	ttl      time.Duration
+	mutex    sync.RWMutex
+	zones    []model.ShippingZone
+	rates    map[string][]model.ShippingRate
+	loadedAt time.Time
+}
+
+func NewTieredCalculator(source RateSource, ttl time.Duration) *TieredCalculator {
+	return &TieredCalculator{source: source, ttl: ttl}
+}
+
+func (c *TieredCalculator) Quote(ctx context.Context, weight int, at *model.Coordinates) (model.ShippingQuote, error) {
+	zones, rates, err := c.tables(ctx)
+	if err != nil {
+		return model.ShippingQuote{}, err
+	}
+
+	zone := DefaultZone
+	if at != nil {
+		for _, z := range zones {
+			if at.Latitude >= z.MinLat && at.Latitude <= z.MaxLat &&
+				at.Longitude >= z.MinLng && at.Longitude <= z.MaxLng {
+				zone = z.ZoneCode
+				break
+			}
+		}
+	}
+
+	tiers := rates[zone]
+	if len(tiers) == 0 {
+		zone = DefaultZone
+		tiers = rates[DefaultZone]
+	}
+	if len(tiers) == 0 {
+		// 料金表が未設定の場合は送料無料
+		return model.ShippingQuote{Zone: zone, Cost: 0}, nil
+	}
+
+	// 重量が収まる最も軽い区分を適用し、どれにも収まらなければ最も重い区分を適用する
+	for _, tier := range tiers {
+		if int64(weight) <= tier.MaxWeight {
+			return model.ShippingQuote{Zone: zone, Cost: tier.Cost}, nil
+		}
+	}
+	return model.ShippingQuote{Zone: zone, Cost: tiers[len(tiers)-1].Cost}, nil
+}
+
+func (c *TieredCalculator) tables(ctx context.Context) ([]model.ShippingZone, map[string][]model.ShippingRate, error) {
+	c.mutex.RLock()
+	if !c.loadedAt.IsZero() && time.Since(c.loadedAt) <= c.ttl {
+		zones, rates := c.zones, c.rates
+		c.mutex.RUnlock()
+		return zones, rates, nil
+	}
+	c.mutex.RUnlock()
+
+	zones, err := c.source.ListZones(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := c.source.ListRates(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rates := make(map[string][]model.ShippingRate)
+	for _, rate := range list {
+		rates[rate.ZoneCode] = append(rates[rate.ZoneCode], rate)
+	}
+
+	c.mutex.Lock()
+	c.zones, c.rates, c.loadedAt = zones, rates, time.Now()
+	c.mutex.Unlock()
+	return zones, rates, nil
+}
@@ -0,0 +1,91 @@
+// HTTPの受付開始前に済ませる初期化処理（DB待ち・スキーマ確認・キャッシュ温め）を順に実行する
+package startup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 失敗時の再試行間隔（Initialから倍々にしてMaxで頭打ち）と、再試行を諦めるまでの時間
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Timeout time.Duration
+}
+
+type step struct {
+	name    string
+	backoff *Backoff
+	run     func(ctx context.Context) error
+}
+
+// 登録順にステップを実行する。1つでも失敗すればそれ以降は実行しない
+type Sequencer struct {
+	steps []step
+}
+
+func NewSequencer() *Sequencer {
+	return &Sequencer{}
+}
+
+// 失敗したら即座に起動を中止するステップを追加する
+func (s *Sequencer) Add(name string, run func(ctx context.Context) error) {
+	s.steps = append(s.steps, step{name: name, run: run})
+}
+
+// 成功するかbackoff.Timeoutに達するまで再試行するステップを追加する
+func (s *Sequencer) AddWithRetry(name string, backoff Backoff, run func(ctx context.Context) error) {
+	s.steps = append(s.steps, step{name: name, backoff: &backoff, run: run})
+}
+
+func (s *Sequencer) Run(ctx context.Context) error {
+	for _, st := range s.steps {
+		begin := time.Now()
+		var err error
+		if st.backoff != nil {
+			err = Retry(ctx, st.name, *st.backoff, st.run)
+		} else {
+			err = st.run(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("startup %s: %w", st.name, err)
+		}
+		log.Printf("[startup] %s done in %s", st.name, time.Since(begin).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// fnが成功するまで間隔を広げながら再試行する
+// Timeoutを超えた場合は最後のエラーを返す（Timeoutが0以下の場合はctxが切れるまで続ける）
+func Retry(ctx context.Context, name string, b Backoff, fn func(ctx context.Context) error) error {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	wait := b.Initial
+	if wait <= 0 {
+		wait = 100 * time.Millisecond
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Printf("[startup] %s not ready (attempt %d): %v", name, attempt, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		wait *= 2
+		if b.Max > 0 && wait > b.Max {
+			wait = b.Max
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// バックグラウンド処理をcontextのキャンセルに追従させるためのヘルパー
+package task
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// fnをgoroutineで実行する
+// fnはctxのキャンセルで速やかに終了すること。panicはログに出してプロセスを落とさない
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	go run(ctx, name, fn)
+}
+
+// 1回の実行にかけてよい時間の既定値
+// バックグラウンドの処理はリクエストのように期限を持たないため、DBの応答が止まった場合などに待ち続けないようにする
+const RunTimeout = 5 * time.Minute
+
+// ctxがキャンセルされるまで、interval毎にfnを実行する（呼び出し元をブロックする）
+// 前回の実行が終わってから次の実行までinterval待つため、処理が重なることはない
+// 各回のfnにはRunTimeoutの期限を付けたctxを渡す
+func Loop(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		runCtx, cancel := context.WithTimeout(ctx, RunTimeout)
+		err := fn(runCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[%s] %v", name, err)
+		}
+		timer.Reset(interval)
+	}
+}
+
+func run(ctx context.Context, name string, fn func(ctx context.Context)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[%s] panic: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	fn(ctx)
+}
+
+// singleflightで共有される処理を、呼び出し元ごとのctxに従って待つ
+// 共有処理自体は最初の呼び出し元のキャンセルに巻き込まれないよう、
+// キャンセルを切り離したcontextにtimeoutを付けて実行する
+func Shared(ctx context.Context, g *singleflight.Group, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ch := g.DoChan(key, func() (interface{}, error) {
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return fn(sharedCtx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,159 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// キャンセルしてから戻るまでに許容する時間
+const cancelBound = time.Second
+
+// fnがdoneを閉じるまでの時間をbound以内か確認する
+func waitDone(t *testing.T, done <-chan struct{}, bound time.Duration) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(bound):
+		t.Fatalf("did not return within %s after cancel", bound)
+	}
+}
+
+func TestGoStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	Go(ctx, "test", func(ctx context.Context) {
+		defer close(done)
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+	cancel()
+	waitDone(t, done, cancelBound)
+}
+
+func TestGoRecoversPanic(t *testing.T) {
+	done := make(chan struct{})
+	Go(context.Background(), "test", func(context.Context) {
+		defer close(done)
+		panic("boom")
+	})
+	waitDone(t, done, cancelBound)
+}
+
+func TestLoopReturnsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Loop(ctx, "test", 5*time.Millisecond, func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+	}()
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	waitDone(t, done, cancelBound)
+	if runs.Load() == 0 {
+		t.Fatal("fn was never called")
+	}
+}
+
+func TestLoopCancelsRunningFn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Loop(ctx, "test", time.Millisecond, func(ctx context.Context) error {
+			select {
+			case <-started:
+			default:
+				close(started)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-started
+	cancel()
+	waitDone(t, done, cancelBound)
+}
+
+func TestLoopWaitsIntervalAfterRun(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var finished []time.Time
+	var gaps []time.Duration
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Loop(ctx, "test", interval, func(context.Context) error {
+			if n := len(finished); n > 0 {
+				gaps = append(gaps, time.Since(finished[n-1]))
+			}
+			if len(gaps) == 3 {
+				cancel()
+				return nil
+			}
+			// intervalより長くかかる処理でも、終わってからintervalは空ける
+			time.Sleep(2 * interval)
+			finished = append(finished, time.Now())
+			return nil
+		})
+	}()
+	waitDone(t, done, 5*time.Second)
+	for _, gap := range gaps {
+		if gap < interval {
+			t.Errorf("next run started %s after the previous one finished, want at least %s", gap, interval)
+		}
+	}
+}
+
+func TestLoopSetsRunDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var deadline atomic.Bool
+	go func() {
+		defer close(done)
+		Loop(ctx, "test", time.Millisecond, func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			deadline.Store(ok)
+			cancel()
+			return nil
+		})
+	}()
+	waitDone(t, done, cancelBound)
+	if !deadline.Load() {
+		t.Fatal("fn was called without a deadline")
+	}
+}
+
+func TestSharedReturnsOnCallerCancel(t *testing.T) {
+	var g singleflight.Group
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		_, err = Shared(ctx, &g, "key", time.Minute, func(context.Context) (interface{}, error) {
+			<-release
+			return nil, nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	waitDone(t, done, cancelBound)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,84 @@
+package tax
+
+import (
+	"backend/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// すべてのカテゴリ・地域に一致するワイルドカード
+const Wildcard = "*"
+
+// 課税額を計算するインターフェース
+type Engine interface {
+	Calculate(ctx context.Context, category, region string, amount int) (int, error)
+}
+
+type RateSource interface {
+	ListTaxRates(ctx context.Context) ([]model.TaxRate, error)
+}
+
+// DBで設定されたカテゴリ・地域別税率で課税額を計算する実装
+// 税率表はTTLの間メモリに保持する
+type RateTableEngine struct {
+	source   RateSource
+	ttl      time.Duration
+	mutex    sync.RWMutex
+	rates    map[[2]string]int
+	loadedAt time.Time
+}
+
+func NewRateTableEngine(source RateSource, ttl time.Duration) *RateTableEngine {
+	return &RateTableEngine{source: source, ttl: ttl}
+}
+
+// 金額に対する税額を返す（1円未満切り捨て）
+func (e *RateTableEngine) Calculate(ctx context.Context, category, region string, amount int) (int, error) {
+	rates, err := e.table(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rateBP := lookupRate(rates, category, region)
+	return amount * rateBP / 10000, nil
+}
+
+// カテゴリ・地域 > カテゴリ > 地域 > 全体 の順に一致する税率を探す
+func lookupRate(rates map[[2]string]int, category, region string) int {
+	candidates := [][2]string{
+		{category, region},
+		{category, Wildcard},
+		{Wildcard, region},
+		{Wildcard, Wildcard},
+	}
+	for _, key := range candidates {
+		if rate, ok := rates[key]; ok {
+			return rate
+		}
+	}
+	return 0
+}
+
+func (e *RateTableEngine) table(ctx context.Context) (map[[2]string]int, error) {
+	e.mutex.RLock()
+	if !e.loadedAt.IsZero() && time.Since(e.loadedAt) <= e.ttl {
+		rates := e.rates
+		e.mutex.RUnlock()
+		return rates, nil
+	}
+	e.mutex.RUnlock()
+
+	list, err := e.source.ListTaxRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[[2]string]int, len(list))
+	for _, r := range list {
+		rates[[2]string{r.Category, r.Region}] = r.RateBP
+	}
+
+	e.mutex.Lock()
+	e.rates, e.loadedAt = rates, time.Now()
+	e.mutex.Unlock()
+	return rates, nil
+}
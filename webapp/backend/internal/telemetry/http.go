@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/internal/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const httpClientTracerName = "backend/http-client"
+
+// 外部APIを呼び出すためのhttp.Client
+// リクエストごとにスパンを作ってトレースコンテキストをヘッダーに注入し、ホストごとのレイテンシを記録する
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: NewTransport(http.DefaultTransport)}
+}
+
+// baseに計装を加えたRoundTripper（独自のTransportを使うクライアント用）
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	ctx, span := otel.Tracer(httpClientTracerName).Start(req.Context(), fmt.Sprintf("HTTP %s %s", req.Method, host),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", host),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	// RoundTripperは元のリクエストを変更してはならないため複製してからヘッダーを加える
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		metrics.Outbound(host).Observe(elapsed, true)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	failed := resp.StatusCode >= http.StatusInternalServerError
+	if failed {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	metrics.Outbound(host).Observe(elapsed, failed)
+	return resp, nil
+}
@@ -0,0 +1,4 @@
+-- 配送計画の受領確認（未確認のまま一定時間経過した計画はロールバックする）
+ALTER TABLE orders
+    ADD COLUMN acknowledged_at DATETIME NULL,
+    ADD INDEX idx_orders_status_delivering_at (shipped_status, delivering_at);
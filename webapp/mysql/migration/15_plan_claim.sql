@@ -0,0 +1,2 @@
+-- 配送計画ごとのクレームID（クレームトークンに署名して含め、ステータス報告時に照合する）
+ALTER TABLE orders ADD COLUMN claim_id CHAR(32) NULL;
@@ -0,0 +1,5 @@
+-- 旧システムから取り込んだ注文の参照ID
+-- 同じCSVを再度取り込んでも重複しないよう一意にする（通常の注文はNULL）
+ALTER TABLE orders
+    ADD COLUMN external_ref VARCHAR(64) NULL,
+    ADD UNIQUE INDEX idx_orders_external_ref (external_ref);
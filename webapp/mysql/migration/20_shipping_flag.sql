@@ -0,0 +1,6 @@
+-- 配送待ちの注文だけを引くためのフラグ（shipped_statusから自動で計算する）
+-- 配送計画は配送待ちの注文を毎回すべて読むため、文字列のステータスではなくこのフラグの索引で絞り込む
+-- 索引に注文日時・商品IDを含め、商品との結合に必要な列を索引だけで読めるようにする
+ALTER TABLE orders
+    ADD COLUMN is_shipping TINYINT(1) AS (shipped_status = 'shipping') VIRTUAL,
+    ADD INDEX idx_orders_is_shipping (is_shipping, created_at, product_id);
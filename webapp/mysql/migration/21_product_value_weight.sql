@@ -0,0 +1,14 @@
+-- 価値・重量が0の商品を1に直し、以後0を保存できないようにする
+-- 重量0の商品は配送計画の動的計画法で積載量の添字がずれ、価値0の商品は計画に載せる意味がない
+-- 修復前の対象は GET /api/admin/products/invalid で確認できる。変更は履歴に残す
+INSERT INTO product_history (product_id, old_value, new_value, old_weight, new_weight, source, changed_at)
+SELECT product_id, value, GREATEST(value, 1), weight, GREATEST(weight, 1), 'repair_migration', NOW()
+FROM products
+WHERE value = 0 OR weight = 0;
+
+UPDATE products
+SET value = GREATEST(value, 1), weight = GREATEST(weight, 1)
+WHERE value = 0 OR weight = 0;
+
+ALTER TABLE products
+    ADD CONSTRAINT chk_products_value_weight CHECK (value > 0 AND weight > 0);
@@ -0,0 +1,5 @@
+-- ギフト注文の受取人（注文したユーザーとは別のユーザーに届ける場合のみ設定し、通常の注文はNULL）
+-- 受取人が受け取ったギフトを新しい順に一覧するための索引
+ALTER TABLE orders
+    ADD COLUMN recipient_user_id INT NULL,
+    ADD INDEX idx_orders_recipient_created (recipient_user_id, created_at);
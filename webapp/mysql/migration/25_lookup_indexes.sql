@@ -0,0 +1,16 @@
+-- 0_sample.sqlにのみ記述されていた検索用のインデックス
+-- 0_sample.sqlで作成済みのDBもあるため、同名のインデックスが既にある場合は作成しない（14_composite_indexes.sqlと同じ方法で確認する）
+
+-- 商品名での検索・並び替え（商品IDまで索引で読む）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'products' AND index_name = 'idx_name_product_id'),
+    'DO 0', 'CREATE INDEX idx_name_product_id ON products(name, product_id)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- ログイン時のユーザー名での検索（WHERE user_name = ?）
+SET @ddl = IF(EXISTS(SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'users' AND index_name = 'idx_users_user_name'),
+    'DO 0', 'CREATE INDEX idx_users_user_name ON users(user_name)');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
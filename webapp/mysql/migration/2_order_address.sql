@@ -0,0 +1,5 @@
+-- 注文ごとの配送先住所と、ジオコーディング結果の座標
+ALTER TABLE orders
+    ADD COLUMN address VARCHAR(255) NULL,
+    ADD COLUMN latitude DOUBLE NULL,
+    ADD COLUMN longitude DOUBLE NULL;
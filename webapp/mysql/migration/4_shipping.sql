@@ -0,0 +1,37 @@
+-- 配送ゾーン（緯度経度の矩形で定義し、priorityの小さい順に判定）
+CREATE TABLE IF NOT EXISTS shipping_zones (
+    zone_code VARCHAR(32) NOT NULL PRIMARY KEY,
+    min_lat DOUBLE NOT NULL,
+    max_lat DOUBLE NOT NULL,
+    min_lng DOUBLE NOT NULL,
+    max_lng DOUBLE NOT NULL,
+    priority INT NOT NULL DEFAULT 0
+);
+
+-- ゾーンごとの重量別送料（max_weight以下の重量に適用）
+CREATE TABLE IF NOT EXISTS shipping_rates (
+    zone_code VARCHAR(32) NOT NULL,
+    max_weight INT UNSIGNED NOT NULL,
+    cost INT UNSIGNED NOT NULL,
+    PRIMARY KEY (zone_code, max_weight)
+);
+
+-- どのゾーンにも属さない住所、または座標が無い注文には'default'の料金表を使用する
+INSERT INTO shipping_rates (zone_code, max_weight, cost) VALUES
+    ('default', 1000, 300),
+    ('default', 5000, 600),
+    ('default', 20000, 1200),
+    ('default', 4294967295, 2000);
+
+INSERT INTO shipping_zones (zone_code, min_lat, max_lat, min_lng, max_lng, priority) VALUES
+    ('tokyo23', 35.52, 35.82, 139.56, 139.92, 0);
+
+INSERT INTO shipping_rates (zone_code, max_weight, cost) VALUES
+    ('tokyo23', 1000, 200),
+    ('tokyo23', 5000, 400),
+    ('tokyo23', 20000, 800),
+    ('tokyo23', 4294967295, 1500);
+
+ALTER TABLE orders
+    ADD COLUMN shipping_cost INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN shipping_zone VARCHAR(32) NULL;
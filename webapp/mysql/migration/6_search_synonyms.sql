@@ -0,0 +1,17 @@
+-- 商品検索の同義語（同じgroup_idの語は同じ意味として扱う）
+-- termは正規化（小文字・半角英数・カタカナ）した形で登録する
+CREATE TABLE IF NOT EXISTS search_synonyms (
+    group_id INT UNSIGNED NOT NULL,
+    term VARCHAR(255) NOT NULL,
+    PRIMARY KEY (group_id, term),
+    INDEX idx_search_synonyms_term (term)
+);
+
+INSERT INTO search_synonyms (group_id, term) VALUES
+    (1, 'pc'),
+    (1, 'パソコン'),
+    (1, 'コンピュータ'),
+    (2, 'スマホ'),
+    (2, 'スマートフォン'),
+    (3, 'tv'),
+    (3, 'テレビ');
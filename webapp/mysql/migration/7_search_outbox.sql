@@ -0,0 +1,16 @@
+-- 外部検索インデックスへの同期待ちの商品変更（アウトボックス）
+-- アプリケーション外からの変更も拾えるようトリガーで記録する
+CREATE TABLE IF NOT EXISTS search_outbox (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    product_id INT UNSIGNED NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TRIGGER trg_products_outbox_insert AFTER INSERT ON products
+FOR EACH ROW INSERT INTO search_outbox (product_id, created_at) VALUES (NEW.product_id, NOW());
+
+CREATE TRIGGER trg_products_outbox_update AFTER UPDATE ON products
+FOR EACH ROW INSERT INTO search_outbox (product_id, created_at) VALUES (NEW.product_id, NOW());
+
+CREATE TRIGGER trg_products_outbox_delete AFTER DELETE ON products
+FOR EACH ROW INSERT INTO search_outbox (product_id, created_at) VALUES (OLD.product_id, NOW());
@@ -0,0 +1,6 @@
+-- 公開追跡用トークンと配送担当ロボットの情報
+ALTER TABLE orders
+    ADD COLUMN tracking_token VARCHAR(32) NULL,
+    ADD COLUMN robot_id VARCHAR(64) NULL,
+    ADD COLUMN delivering_at DATETIME NULL,
+    ADD UNIQUE INDEX idx_orders_tracking_token (tracking_token);